@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// 导出选项
+var (
+	exportOut       string
+	exportPlaintext bool
+)
+
+// keyCmd 密钥库管理命令
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "管理加密密钥库",
+	Long:  `管理以口令加密（scrypt或Argon2id + AES-256-GCM）保存的密钥。register、rotate 等命令会按DID自动从密钥库加载密钥。`,
+}
+
+// keyImportCmd 导入密钥命令
+var keyImportCmd = &cobra.Command{
+	Use:   "import [文件]",
+	Short: "导入密钥到密钥库",
+	Long:  `导入明文密钥文件（generate 的输出）并用口令加密保存，或导入其他密钥库导出的加密密钥文件。`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openKeystore()
+		if err != nil {
+			log.Fatalf("打开密钥库失败: %v", err)
+		}
+
+		var key *crypto.EncryptedKey
+		if encrypted, err := crypto.ReadEncryptedKey(args[0]); err == nil {
+			// 加密密钥先校验口令，避免导入无法解锁的密钥
+			password, err := keystorePassword(false)
+			if err != nil {
+				log.Fatalf("读取口令失败: %v", err)
+			}
+			if _, err := crypto.DecryptKey(encrypted, password); err != nil {
+				log.Fatalf("解密密钥失败: %v", err)
+			}
+			if err := ks.Import(encrypted); err != nil {
+				log.Fatalf("导入密钥失败: %v", err)
+			}
+			key = encrypted
+		} else {
+			data, keyPair, err := readKeyFile(args[0])
+			if err != nil {
+				log.Fatalf("读取密钥文件失败: %v", err)
+			}
+			password, err := keystorePassword(true)
+			if err != nil {
+				log.Fatalf("读取口令失败: %v", err)
+			}
+			key, err = ks.Store(keyPair, data.DID, data.KeyID, password)
+			if err != nil {
+				log.Fatalf("导入密钥失败: %v", err)
+			}
+		}
+
+		fmt.Printf("密钥已导入: %s\n", ks.Dir())
+		fmt.Printf("DID: %s\n", key.DID)
+		fmt.Printf("密钥: %s\n", key.KeyID)
+		fmt.Printf("指纹: %s\n", key.Fingerprint)
+	},
+}
+
+// keyExportCmd 导出密钥命令
+var keyExportCmd = &cobra.Command{
+	Use:   "export [DID]",
+	Short: "导出DID的当前密钥",
+	Long:  `导出密钥库中DID的当前密钥。默认导出加密的密钥文件，使用 --plaintext 时解锁后导出明文密钥文件。`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openKeystore()
+		if err != nil {
+			log.Fatalf("打开密钥库失败: %v", err)
+		}
+
+		if exportPlaintext {
+			if exportOut == "" {
+				log.Fatalf("导出明文密钥需要通过 --out 指定文件")
+			}
+			password, err := keystorePassword(false)
+			if err != nil {
+				log.Fatalf("读取口令失败: %v", err)
+			}
+			keyPair, key, err := ks.Unlock(args[0], password)
+			if err != nil {
+				log.Fatalf("解锁密钥失败: %v", err)
+			}
+			if _, err := writeKeyFile(exportOut, key.DID, key.KeyID, keyPair); err != nil {
+				log.Fatalf("写入密钥文件失败: %v", err)
+			}
+			fmt.Printf("明文密钥已导出到: %s\n", exportOut)
+			return
+		}
+
+		key, err := ks.Find(args[0])
+		if err != nil {
+			log.Fatalf("查找密钥失败: %v", err)
+		}
+		data, err := json.MarshalIndent(key, "", "  ")
+		if err != nil {
+			log.Fatalf("序列化密钥失败: %v", err)
+		}
+
+		if exportOut == "" {
+			fmt.Println(string(data))
+			return
+		}
+		if err := os.WriteFile(exportOut, data, 0600); err != nil {
+			log.Fatalf("写入密钥文件失败: %v", err)
+		}
+		fmt.Printf("加密密钥已导出到: %s\n", exportOut)
+	},
+}
+
+// keyListCmd 列出密钥命令
+var keyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出密钥库中的密钥",
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openKeystore()
+		if err != nil {
+			log.Fatalf("打开密钥库失败: %v", err)
+		}
+
+		keys, err := ks.List()
+		if err != nil {
+			log.Fatalf("读取密钥库失败: %v", err)
+		}
+		if len(keys) == 0 {
+			fmt.Printf("密钥库 %s 中没有密钥\n", ks.Dir())
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "DID\t密钥\t指纹\tKDF\t创建时间")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.DID, key.KeyID, key.Fingerprint, key.Crypto.KDF, key.Created.Format(time.RFC3339))
+		}
+		w.Flush()
+	},
+}
+
+// keyUnlockCmd 解锁密钥命令
+var keyUnlockCmd = &cobra.Command{
+	Use:   "unlock [DID]",
+	Short: "校验口令并解锁DID的当前密钥",
+	Long:  `用口令解锁密钥库中DID的当前密钥并显示其信息，用于在执行签名操作前确认口令正确。`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openKeystore()
+		if err != nil {
+			log.Fatalf("打开密钥库失败: %v", err)
+		}
+
+		password, err := keystorePassword(false)
+		if err != nil {
+			log.Fatalf("读取口令失败: %v", err)
+		}
+		keyPair, key, err := ks.Unlock(args[0], password)
+		if err != nil {
+			log.Fatalf("解锁密钥失败: %v", err)
+		}
+		commitment, err := keyPair.GetNextKeyCommitment()
+		if err != nil {
+			log.Fatalf("计算密钥承诺失败: %v", err)
+		}
+
+		fmt.Printf("密钥已解锁!\n")
+		fmt.Printf("DID: %s\n", key.DID)
+		fmt.Printf("密钥: %s\n", key.KeyID)
+		fmt.Printf("指纹: %s\n", key.Fingerprint)
+		fmt.Printf("密钥承诺: %s\n", commitment)
+	},
+}
+
+// openKeystore 按命令行参数打开密钥库
+func openKeystore() (*crypto.Keystore, error) {
+	switch kdf {
+	case crypto.KDFScrypt:
+		return crypto.NewKeystore(keystoreDir, crypto.StandardKeystoreOptions), nil
+	case crypto.KDFArgon2id:
+		return crypto.NewKeystore(keystoreDir, crypto.Argon2idKeystoreOptions), nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥派生函数: %s", kdf)
+	}
+}
+
+// password 本次运行已读取的密钥库口令
+var password string
+
+// keystorePassword 返回密钥库口令：口令文件优先于环境变量 QLINK_PASSWORD，
+// 都未提供时在终端提示输入，confirm 为真时要求输入两次；同一次运行只读取一次
+func keystorePassword(confirm bool) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+
+	var value string
+	switch {
+	case passwordFile != "":
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", fmt.Errorf("读取口令文件失败: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	case os.Getenv("QLINK_PASSWORD") != "":
+		value = os.Getenv("QLINK_PASSWORD")
+	default:
+		var err error
+		if value, err = promptPassword(confirm); err != nil {
+			return "", err
+		}
+	}
+	if value == "" {
+		return "", errors.New("口令不能为空")
+	}
+	password = value
+	return password, nil
+}
+
+// promptPassword 在终端读取口令，输入不回显
+func promptPassword(confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("标准输入不是终端，请通过 --password-file 或 QLINK_PASSWORD 提供口令")
+	}
+
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		value, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("读取口令失败: %w", err)
+		}
+		return string(value), nil
+	}
+
+	value, err := read("密钥库口令: ")
+	if err != nil || !confirm {
+		return value, err
+	}
+	again, err := read("再次输入口令: ")
+	if err != nil {
+		return "", err
+	}
+	if again != value {
+		return "", errors.New("两次输入的口令不一致")
+	}
+	return value, nil
+}
+
+// encryptNewKeys 新密钥是否加密保存到密钥库：指定了 --encrypt、提供了口令，
+// 或本次运行已用口令解锁过密钥（如 rotate 密钥库中的DID）
+func encryptNewKeys() bool {
+	return encryptKey || password != "" || passwordFile != "" || os.Getenv("QLINK_PASSWORD") != ""
+}
+
+// readKeyFile 读取明文密钥文件
+func readKeyFile(path string) (*keyFileData, *crypto.HybridKeyPair, error) {
+	keyData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data keyFileData
+	if err := json.Unmarshal(keyData, &data); err != nil {
+		return nil, nil, fmt.Errorf("解析密钥文件失败: %v", err)
+	}
+
+	privateKey, err := base64.RawURLEncoding.DecodeString(data.PrivateKey)
+	if err != nil || data.PrivateKey == "" {
+		return nil, nil, fmt.Errorf("密钥文件格式无效")
+	}
+	keyPair, err := crypto.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("密钥文件格式无效: %v", err)
+	}
+	return &data, keyPair, nil
+}
+
+func init() {
+	keyExportCmd.Flags().StringVar(&exportOut, "out", "", "导出文件路径，默认输出到标准输出")
+	keyExportCmd.Flags().BoolVar(&exportPlaintext, "plaintext", false, "解锁后导出明文密钥文件")
+
+	keyCmd.AddCommand(keyImportCmd)
+	keyCmd.AddCommand(keyExportCmd)
+	keyCmd.AddCommand(keyListCmd)
+	keyCmd.AddCommand(keyUnlockCmd)
+}
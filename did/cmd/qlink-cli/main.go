@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/qujing226/QLink/did/crypto"
 	"github.com/qujing226/QLink/pkg/client"
@@ -17,6 +19,16 @@ var (
 	baseURL    string
 	keyFile    string
 	clientInst *client.Client
+
+	// 助记词选项
+	withMnemonic bool
+	passphrase   string
+
+	// 加密密钥库选项
+	keystoreDir  string
+	passwordFile string
+	encryptKey   bool
+	kdf          string
 )
 
 // rootCmd 根命令
@@ -30,48 +42,73 @@ var rootCmd = &cobra.Command{
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "生成新的密钥对",
-	Long:  `生成新的混合密钥对并保存到文件。`,
+	Long:  `生成新的混合密钥对并保存到文件。使用 --mnemonic 时同时输出24词助记词，可用 recover 命令在其他设备上恢复同一DID。`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// 生成密钥对
-		keyPair, err := crypto.GenerateHybridKeyPair()
+		var (
+			keyPair  *crypto.HybridKeyPair
+			mnemonic string
+			err      error
+		)
+		if withMnemonic {
+			keyPair, mnemonic, err = crypto.GenerateHybridKeyPairWithMnemonic(passphrase)
+		} else {
+			keyPair, err = crypto.GenerateHybridKeyPair()
+		}
 		if err != nil {
 			log.Fatalf("生成密钥对失败: %v", err)
 		}
 
-		// 获取公钥JWK
-		jwk, err := keyPair.ToJWK()
+		// 生成DID
+		did, err := crypto.GenerateDIDFromKeyPair(keyPair)
 		if err != nil {
-			log.Fatalf("获取公钥JWK失败: %v", err)
+			log.Fatalf("生成DID失败: %v", err)
 		}
 
-		// 获取指纹
-		fingerprint, err := keyPair.GetFingerprint()
+		// 保存到文件
+		fingerprint, location, err := storeKey(did, "#key-1", keyPair)
 		if err != nil {
-			log.Fatalf("获取指纹失败: %v", err)
+			log.Fatalf("保存密钥失败: %v", err)
 		}
 
-		// 生成DID
-		did, err := crypto.GenerateDIDFromKeyPair(keyPair)
+		fmt.Printf("密钥对已生成并保存到: %s\n", location)
+		fmt.Printf("DID: %s\n", did)
+		fmt.Printf("指纹: %s\n", fingerprint)
+		if mnemonic != "" {
+			fmt.Printf("助记词: %s\n", mnemonic)
+			fmt.Println("请离线妥善保管助记词，它是恢复该DID的唯一凭据")
+		}
+	},
+}
+
+// recoverCmd 从助记词恢复密钥对命令
+var recoverCmd = &cobra.Command{
+	Use:   "recover [助记词...]",
+	Short: "从助记词恢复密钥对",
+	Long:  `由BIP-39助记词和可选口令确定性地恢复混合密钥对，得到与生成时相同的DID，并保存到密钥文件。`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mnemonic := strings.Join(args, " ")
+		if err := crypto.ValidateMnemonic(mnemonic); err != nil {
+			log.Fatalf("助记词无效: %v", err)
+		}
+
+		keyPair, err := crypto.HybridKeyPairFromMnemonic(mnemonic, passphrase)
 		if err != nil {
-			log.Fatalf("生成DID失败: %v", err)
+			log.Fatalf("恢复密钥对失败: %v", err)
 		}
 
-		// 序列化密钥对
-		keyData, err := json.MarshalIndent(map[string]interface{}{
-			"public_key":  jwk,
-			"fingerprint": fingerprint,
-			"did":         did,
-		}, "", "  ")
+		did, err := crypto.GenerateDIDFromKeyPair(keyPair)
 		if err != nil {
-			log.Fatalf("序列化密钥对失败: %v", err)
+			log.Fatalf("生成DID失败: %v", err)
 		}
 
-		// 保存到文件
-		if err := os.WriteFile(keyFile, keyData, 0600); err != nil {
-			log.Fatalf("保存密钥文件失败: %v", err)
+		fingerprint, location, err := storeKey(did, "#key-1", keyPair)
+		if err != nil {
+			log.Fatalf("保存密钥失败: %v", err)
 		}
 
-		fmt.Printf("密钥对已生成并保存到: %s\n", keyFile)
+		fmt.Printf("密钥对已恢复并保存到: %s\n", location)
 		fmt.Printf("DID: %s\n", did)
 		fmt.Printf("指纹: %s\n", fingerprint)
 	},
@@ -87,7 +124,7 @@ var registerCmd = &cobra.Command{
 		did := args[0]
 
 		// 初始化客户端
-		if err := initClient(); err != nil {
+		if err := initClient(did); err != nil {
 			log.Fatalf("初始化客户端失败: %v", err)
 		}
 
@@ -128,7 +165,7 @@ var resolveCmd = &cobra.Command{
 		did := args[0]
 
 		// 初始化客户端
-		if err := initClient(); err != nil {
+		if err := initClient(""); err != nil {
 			log.Fatalf("初始化客户端失败: %v", err)
 		}
 
@@ -148,8 +185,117 @@ var resolveCmd = &cobra.Command{
 	},
 }
 
+// rotateCmd 密钥轮换命令
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [DID]",
+	Short: "轮换DID的密钥",
+	Long:  `生成新的密钥对替换当前密钥，旧密钥在DID文档中标记为退役，新密钥写回密钥文件。`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		did := args[0]
+
+		// 初始化客户端
+		if err := initClient(did); err != nil {
+			log.Fatalf("初始化客户端失败: %v", err)
+		}
+
+		newKeyPair, err := crypto.GenerateHybridKeyPair()
+		if err != nil {
+			log.Fatalf("生成密钥对失败: %v", err)
+		}
+
+		// 轮换密钥
+		resp, err := clientInst.RotateKey(did, newKeyPair)
+		if err != nil {
+			log.Fatalf("密钥轮换失败: %v", err)
+		}
+
+		fingerprint, _, err := storeKey(did, resp.KeyID, newKeyPair)
+		if err != nil {
+			log.Fatalf("保存密钥失败: %v", err)
+		}
+
+		fmt.Printf("密钥轮换成功!\n")
+		fmt.Printf("DID: %s\n", did)
+		fmt.Printf("新密钥: %s%s\n", did, resp.KeyID)
+		fmt.Printf("指纹: %s\n", fingerprint)
+	},
+}
+
+// keyFileData 密钥文件内容
+type keyFileData struct {
+	DID         string      `json:"did"`
+	KeyID       string      `json:"key_id"`
+	PublicKey   interface{} `json:"public_key"`
+	Fingerprint string      `json:"fingerprint"`
+	PrivateKey  string      `json:"private_key"`
+}
+
+// storeKey 保存密钥对：需要加密时写入密钥库，否则写入明文密钥文件
+// 返回公钥指纹和保存位置
+func storeKey(did, keyID string, keyPair *crypto.HybridKeyPair) (string, string, error) {
+	if !encryptNewKeys() {
+		fingerprint, err := saveKeyFile(did, keyID, keyPair)
+		return fingerprint, keyFile, err
+	}
+
+	password, err := keystorePassword(true)
+	if err != nil {
+		return "", "", err
+	}
+	ks, err := openKeystore()
+	if err != nil {
+		return "", "", err
+	}
+	key, err := ks.Store(keyPair, did, keyID, password)
+	if err != nil {
+		return "", "", err
+	}
+	return key.Fingerprint, ks.Dir(), nil
+}
+
+// saveKeyFile 将密钥对保存到密钥文件，返回公钥指纹
+func saveKeyFile(did, keyID string, keyPair *crypto.HybridKeyPair) (string, error) {
+	return writeKeyFile(keyFile, did, keyID, keyPair)
+}
+
+// writeKeyFile 将密钥对以明文写入指定路径
+func writeKeyFile(path, did, keyID string, keyPair *crypto.HybridKeyPair) (string, error) {
+	jwk, err := keyPair.ToJWK()
+	if err != nil {
+		return "", fmt.Errorf("获取公钥JWK失败: %w", err)
+	}
+
+	fingerprint, err := keyPair.GetFingerprint()
+	if err != nil {
+		return "", fmt.Errorf("获取指纹失败: %w", err)
+	}
+
+	privateKey, err := keyPair.MarshalPrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("序列化私钥失败: %w", err)
+	}
+
+	keyData, err := json.MarshalIndent(&keyFileData{
+		DID:         did,
+		KeyID:       keyID,
+		PublicKey:   jwk,
+		Fingerprint: fingerprint,
+		PrivateKey:  base64.RawURLEncoding.EncodeToString(privateKey),
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化密钥对失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	return fingerprint, os.WriteFile(path, keyData, 0600)
+}
+
 // initClient 初始化客户端
-func initClient() error {
+// 密钥库中有该DID的密钥时用口令解锁，否则使用明文密钥文件
+func initClient(did string) error {
 	if clientInst != nil {
 		return nil
 	}
@@ -157,6 +303,20 @@ func initClient() error {
 	// 创建客户端
 	clientInst = client.NewClient(baseURL)
 
+	if did != "" {
+		ks, err := openKeystore()
+		if err != nil {
+			return err
+		}
+		if _, err := ks.Find(did); err == nil {
+			password, err := keystorePassword(false)
+			if err != nil {
+				return fmt.Errorf("密钥库中的密钥已加密: %w", err)
+			}
+			return clientInst.LoadKey(ks, did, password)
+		}
+	}
+
 	// 如果指定了密钥文件，加载密钥对
 	if keyFile != "" {
 		if _, err := os.Stat(keyFile); err == nil {
@@ -184,23 +344,13 @@ func loadKeyPair() error {
 		return clientInst.GenerateKeyPair()
 	}
 
-	// 读取密钥文件
-	keyData, err := os.ReadFile(keyFile)
+	// 读取并验证密钥文件
+	data, keyPair, err := readKeyFile(keyFile)
 	if err != nil {
-		return fmt.Errorf("读取密钥文件失败: %v", err)
-	}
-
-	// 解析密钥数据
-	var keyPair crypto.HybridKeyPair
-	if err := json.Unmarshal(keyData, &keyPair); err != nil {
-		return fmt.Errorf("解析密钥文件失败: %v", err)
-	}
-
-	// 验证密钥对的有效性
-	if keyPair.ECDSAPrivateKey == nil || keyPair.KyberDecapsulationKey == nil {
-		return fmt.Errorf("密钥文件格式无效")
+		return err
 	}
 
+	clientInst.SetKeyPair(keyPair, data.KeyID)
 	log.Printf("成功加载密钥对从文件: %s", keyFile)
 	return nil
 }
@@ -222,11 +372,22 @@ func init() {
 	// 设置全局标志
 	rootCmd.PersistentFlags().StringVar(&baseURL, "url", "http://localhost:8080", "QLink节点的API地址")
 	rootCmd.PersistentFlags().StringVar(&keyFile, "key", filepath.Join(os.Getenv("HOME"), ".qlink", "key.json"), "密钥文件路径")
+	rootCmd.PersistentFlags().StringVar(&keystoreDir, "keystore", filepath.Join(os.Getenv("HOME"), ".qlink", "keystore"), "加密密钥库目录")
+	rootCmd.PersistentFlags().StringVar(&passwordFile, "password-file", "", "从文件读取密钥库口令，也可通过环境变量 QLINK_PASSWORD 提供，都未提供时在终端提示输入；提供口令后新密钥加密保存到密钥库")
+	rootCmd.PersistentFlags().BoolVar(&encryptKey, "encrypt", false, "新密钥加密保存到密钥库，未提供口令时在终端提示输入")
+	rootCmd.PersistentFlags().StringVar(&kdf, "kdf", crypto.KDFScrypt, "加密新密钥使用的密钥派生函数（scrypt 或 argon2id）")
+
+	generateCmd.Flags().BoolVar(&withMnemonic, "mnemonic", false, "同时生成用于恢复的助记词")
+	generateCmd.Flags().StringVar(&passphrase, "passphrase", "", "助记词口令（可选）")
+	recoverCmd.Flags().StringVar(&passphrase, "passphrase", "", "助记词口令（可选）")
 
 	// 添加子命令
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(registerCmd)
 	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(rotateCmd)
+	rootCmd.AddCommand(recoverCmd)
+	rootCmd.AddCommand(keyCmd)
 }
 
 func main() {
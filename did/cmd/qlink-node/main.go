@@ -1,6 +1,7 @@
 package main
 
 import (
+    "context"
     "flag"
     "fmt"
     "log"
@@ -13,6 +14,9 @@ import (
     "github.com/qujing226/QLink/pkg/api"
     "github.com/qujing226/QLink/pkg/blockchain"
     "github.com/qujing226/QLink/pkg/config"
+    "github.com/qujing226/QLink/pkg/consensus"
+    "github.com/qujing226/QLink/pkg/network"
+    "github.com/qujing226/QLink/pkg/storage"
 )
 
 // 去除冗余适配器，直接使用 MockBlockchain 作为 BlockchainInterface
@@ -82,9 +86,41 @@ func startNode(configPath string) {
 	}
 	defer sm.Close()
 
+	// 初始化 DID 存储，storage.type 为持久化类型时重启后可恢复已注册的 DID
+	didStorage, err := storage.NewStorageFactory().CreateDIDStorage(storage.NewFileStorageConfig(didCfg, "did"))
+	if err != nil {
+		log.Fatalf("初始化DID存储失败: %v", err)
+	}
+	defer didStorage.Close()
+
     // 初始化 DID Registry，直接使用 MockBlockchain（已实现 BlockchainInterface）
     mockBlockchain := didblockchain.NewMockBlockchain(nil)
-    registry := did.NewDIDRegistry(mockBlockchain)
+    registry := did.NewDIDRegistryWithStorage(mockBlockchain, didStorage)
+	// replicated 模式下DID操作经Raft提交，由共识集成器在每个节点上写入注册表
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var consensusManager *consensus.ConsensusManager
+	if didCfg.DID != nil {
+		commitMode, err := did.ParseCommitMode(didCfg.DID.CommitMode)
+		if err != nil {
+			log.Fatalf("初始化DID注册表失败: %v", err)
+		}
+		if commitMode == did.CommitModeReplicated {
+			p2pNetwork, manager, err := startConsensus(ctx, didCfg, registry)
+			if err != nil {
+				log.Fatalf("启动共识失败: %v", err)
+			}
+			defer p2pNetwork.Stop()
+			defer manager.Stop()
+			consensusManager = manager
+			if err := registry.SetCommitter(manager.DIDCommitter()); err != nil {
+				log.Fatalf("初始化DID注册表失败: %v", err)
+			}
+		}
+		if err := registry.SetCommitMode(commitMode); err != nil {
+			log.Fatalf("初始化DID注册表失败: %v", err)
+		}
+	}
 
 	// 初始化 DID Resolver
 	resolver := did.NewDIDResolver(didCfg, registry, sm)
@@ -105,6 +141,9 @@ func startNode(configPath string) {
 			log.Printf("API服务器创建失败")
 		} else {
 			log.Printf("API服务器创建成功，准备启动...")
+			if consensusManager != nil {
+				apiServer.SetConsensusManager(consensusManager)
+			}
 			go func() {
 				if err := apiServer.Start(); err != nil {
 					log.Printf("API 服务启动失败: %v", err)
@@ -137,6 +176,37 @@ func startNode(configPath string) {
 	fmt.Println("正在关闭节点...")
 }
 
+// startConsensus 启动P2P网络和Raft共识，共识集成器作为状态机将已提交的DID操作写入注册表
+func startConsensus(ctx context.Context, cfg *config.Config, registry *did.DIDRegistry) (*network.P2PNetwork, *consensus.ConsensusManager, error) {
+	if cfg.Network == nil || cfg.Consensus == nil {
+		return nil, nil, fmt.Errorf("replicated 写入模式需要network和consensus配置")
+	}
+
+	p2pNetwork := network.NewP2PNetwork(cfg.GetNodeID(), cfg.Network.ListenAddress, cfg.Network.ListenPort, cfg.Network)
+	if err := p2pNetwork.Start(ctx); err != nil {
+		return nil, nil, fmt.Errorf("启动P2P网络失败: %v", err)
+	}
+
+	manager := consensus.NewConsensusManager(&consensus.ManagerConfig{
+		NodeID:           cfg.GetNodeID(),
+		DefaultConsensus: consensus.ConsensusTypeRaft,
+		RaftConfig:       cfg.Consensus.Raft,
+		SnapshotEntries:  cfg.Consensus.SnapshotInterval,
+		MaxLogEntries:    cfg.Consensus.MaxLogEntries,
+		ConsensusConfig:  cfg.Consensus,
+	}, p2pNetwork)
+	manager.SetDIDRegistry(registry)
+	if err := manager.Initialize(); err != nil {
+		p2pNetwork.Stop()
+		return nil, nil, fmt.Errorf("初始化共识管理器失败: %v", err)
+	}
+	if err := manager.Start(ctx); err != nil {
+		p2pNetwork.Stop()
+		return nil, nil, fmt.Errorf("启动共识管理器失败: %v", err)
+	}
+	return p2pNetwork, manager, nil
+}
+
 func printVersion() {
 	fmt.Println("DID-QLink v1.0.0")
 	fmt.Println("基于 PoA 共识的去中心化身份区块链")
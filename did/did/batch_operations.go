@@ -7,6 +7,7 @@ import (
     "time"
 
     "github.com/qujing226/QLink/pkg/config"
+    "github.com/qujing226/QLink/pkg/interfaces"
     "github.com/qujing226/QLink/pkg/types"
     "github.com/qujing226/QLink/pkg/utils"
 )
@@ -64,10 +65,10 @@ type BatchDIDRegistry struct {
 	metrics  *Metrics
 }
 
-// NewBatchDIDRegistry 创建批量DID注册表
-func NewBatchDIDRegistry(cfg *config.Config, blockchain BlockchainInterface) *BatchDIDRegistry {
+// NewBatchDIDRegistry 创建批量DID注册表，didStorage 为空时使用内存存储
+func NewBatchDIDRegistry(cfg *config.Config, blockchain BlockchainInterface, didStorage interfaces.DIDStorage) *BatchDIDRegistry {
     return &BatchDIDRegistry{
-        registry: NewDIDRegistry(blockchain),
+        registry: NewDIDRegistryWithStorage(blockchain, didStorage),
         metrics:  NewMetrics(),
     }
 }
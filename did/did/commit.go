@@ -0,0 +1,166 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// CommitMode 注册表写入模式
+type CommitMode int
+
+const (
+	// CommitModeLocalFirst 先写入本地存储再提交到链，链上失败只记录日志（默认）
+	CommitModeLocalFirst CommitMode = iota
+	// CommitModeAtomic 先暂存操作并提交到链/共识层，确认后才写入本地存储，失败时丢弃暂存的操作
+	CommitModeAtomic
+	// CommitModeReplicated 与原子模式相同，但本地存储由共识层状态机在提交后写入（包括本节点），注册表不再重复写入
+	CommitModeReplicated
+)
+
+// String 返回写入模式的字符串表示
+func (m CommitMode) String() string {
+	switch m {
+	case CommitModeLocalFirst:
+		return "local_first"
+	case CommitModeAtomic:
+		return "atomic"
+	case CommitModeReplicated:
+		return "replicated"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCommitMode 解析配置中的写入模式，空字符串视为 local_first
+func ParseCommitMode(mode string) (CommitMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "local_first":
+		return CommitModeLocalFirst, nil
+	case "atomic":
+		return CommitModeAtomic, nil
+	case "replicated":
+		return CommitModeReplicated, nil
+	default:
+		return CommitModeLocalFirst, fmt.Errorf("不支持的写入模式: %s", mode)
+	}
+}
+
+// DID操作类型，与 types.DIDOperation.Operation 取值一致
+const (
+	OperationCreate     = "create"
+	OperationUpdate     = "update"
+	OperationDeactivate = "deactivate"
+)
+
+// CommitReceipt 链上提交回执
+type CommitReceipt struct {
+	Operation   string                  `json:"operation"`
+	DID         string                  `json:"did"`
+	TxHash      string                  `json:"txHash,omitempty"`
+	Status      types.TransactionStatus `json:"status"`
+	SubmittedAt time.Time               `json:"submittedAt"`
+	ConfirmedAt *time.Time              `json:"confirmedAt,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// OperationCommitter 将DID操作提交到链或共识层，并在确认后返回回执
+type OperationCommitter interface {
+	CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*CommitReceipt, error)
+}
+
+// ReplicatedCommitter 由共识层状态机在提交后通过 DIDRegistry.ApplyOperation 写入注册表的提交器，
+// replicated 写入模式只能使用这类提交器，否则已提交的操作不会被任何一方写入存储；
+// 其他写入模式下使用这类提交器时注册表同样按 replicated 处理，不会在本地重复写入
+type ReplicatedCommitter interface {
+	OperationCommitter
+	// AppliesCommittedOperations 标记提交器在操作提交后负责将其应用到注册表
+	AppliesCommittedOperations()
+}
+
+// CommitPendingError 提交器已接受操作（例如已追加到共识日志），但在超时内未能确认结果，操作之后仍可能生效
+// Settled 在操作最终被应用或被其他条目覆盖后关闭，在此之前注册表保持该DID的待确认状态
+type CommitPendingError struct {
+	Position string          // 操作在共识日志中的位置
+	Settled  <-chan struct{} // 结果确定后关闭
+	Err      error
+}
+
+func (e *CommitPendingError) Error() string {
+	return fmt.Sprintf("操作已进入日志 %s，等待确认超时: %v", e.Position, e.Err)
+}
+
+func (e *CommitPendingError) Unwrap() error {
+	return e.Err
+}
+
+// TransactionConfirmer 可选接口，区块链实现该接口时提交后会等待交易确认
+type TransactionConfirmer interface {
+	WaitForConfirmation(ctx context.Context, txHash string) (types.TransactionStatus, error)
+}
+
+// blockchainCommitter 基于 BlockchainInterface 的提交器
+type blockchainCommitter struct {
+	blockchain BlockchainInterface
+}
+
+// NewBlockchainCommitter 创建基于区块链接口的提交器
+func NewBlockchainCommitter(blockchain BlockchainInterface) OperationCommitter {
+	return &blockchainCommitter{blockchain: blockchain}
+}
+
+// CommitDIDOperation 提交DID操作到区块链
+func (bc *blockchainCommitter) CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*CommitReceipt, error) {
+	receipt := &CommitReceipt{
+		Operation:   op.Operation,
+		DID:         op.DID,
+		Status:      types.TransactionStatusPending,
+		SubmittedAt: time.Now(),
+	}
+
+	var tx *BlockchainTransaction
+	var err error
+	switch op.Operation {
+	case OperationCreate:
+		tx, err = bc.blockchain.RegisterDID(ctx, op.Document)
+	case OperationUpdate:
+		tx, err = bc.blockchain.UpdateDID(ctx, op.DID, op.Document, op.Proof)
+	case OperationDeactivate:
+		tx, err = bc.blockchain.RevokeDID(ctx, op.DID, op.Proof)
+	default:
+		err = fmt.Errorf("不支持的DID操作: %s", op.Operation)
+	}
+	if err != nil {
+		receipt.Status = types.TransactionStatusFailed
+		receipt.Error = err.Error()
+		return receipt, err
+	}
+	if tx != nil {
+		receipt.TxHash = tx.Hash
+	}
+
+	// 区块链支持异步确认时等待最终结果，否则以提交成功作为确认
+	status := types.TransactionStatusConfirmed
+	if confirmer, ok := bc.blockchain.(TransactionConfirmer); ok && receipt.TxHash != "" {
+		status, err = confirmer.WaitForConfirmation(ctx, receipt.TxHash)
+		if err != nil {
+			receipt.Status = types.TransactionStatusFailed
+			receipt.Error = err.Error()
+			return receipt, err
+		}
+	}
+
+	receipt.Status = status
+	if status != types.TransactionStatusConfirmed {
+		err = fmt.Errorf("交易未确认: %s, 状态: %s", receipt.TxHash, status)
+		receipt.Error = err.Error()
+		return receipt, err
+	}
+
+	now := time.Now()
+	receipt.ConfirmedAt = &now
+	return receipt, nil
+}
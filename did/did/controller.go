@@ -0,0 +1,186 @@
+package did
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// OperationSetController 变更控制者的签名操作名，链上仍以 OperationUpdate 记录
+const OperationSetController = "setController"
+
+// MaxControllerDepth 沿控制者链向上查找授权文档的最大层数
+const MaxControllerDepth = 8
+
+// permissionCheck 基于授权文档校验操作证明，对应 SignatureVerifier 的 VerifyXxxPermission
+type permissionCheck func(document *types.DIDDocument, payload interface{}, proof *types.Proof) error
+
+// ControllerSigningPayload 返回变更控制者需要签名的内容
+func ControllerSigningPayload(didStr string, controllers []string) *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation:  OperationSetController,
+		DID:        didStr,
+		Controller: controllers,
+	}
+}
+
+// SetControllers 替换DID的控制者列表，传入空列表表示只由DID主体自身控制
+// 证明需以capabilityDelegation目的对 ControllerSigningPayload 签名，签名者可以是DID主体或现有控制者
+func (r *DIDRegistry) SetControllers(ctx context.Context, didStr string, controllers []string, proof *types.Proof) (*types.DIDDocument, *CommitReceipt, error) {
+	return r.SetControllersWithNextKey(ctx, didStr, controllers, nil, proof)
+}
+
+// SetControllersWithNextKey 替换DID的控制者列表
+// 文档有预轮换承诺时，nextKey 为承诺的密钥，证明必须由它签发，当前密钥和控制者都不能变更控制者
+func (r *DIDRegistry) SetControllersWithNextKey(ctx context.Context, didStr string, controllers []string, nextKey *types.VerificationMethod, proof *types.Proof) (*types.DIDDocument, *CommitReceipt, error) {
+	op, receipt, err := r.apply(ctx, didStr, func() (*types.DIDOperation, error) {
+		doc, err := r.loadDocument(didStr)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc.Status == "revoked" {
+			return nil, &DIDError{
+				Type:    ErrorTypeValidation,
+				Code:    "DID_REVOKED",
+				Message: "DID已被撤销",
+				Details: didStr,
+			}
+		}
+
+		if err := validateControllers(controllers); err != nil {
+			return nil, err
+		}
+
+		payload := ControllerSigningPayload(didStr, controllers)
+		if doc.NextKeyCommitment != "" {
+			if err := r.authorizeNextKey(doc, nextKey, payload, proof, r.verifier.VerifyDelegationPermission); err != nil {
+				return nil, err
+			}
+		} else if err := r.authorize(doc, payload, proof, r.verifier.VerifyDelegationPermission); err != nil {
+			return nil, proofError(err)
+		}
+
+		now := time.Now()
+		doc.Controller = append(types.StringOrSet(nil), controllers...)
+		doc.Updated = &now
+		doc.Proof = proof
+
+		return &types.DIDOperation{
+			Operation: OperationUpdate,
+			DID:       didStr,
+			Document:  doc,
+			Proof:     proof,
+		}, nil
+	})
+	if err != nil {
+		return nil, receipt, err
+	}
+
+	return op.Document, receipt, nil
+}
+
+// authorize 校验变更操作的授权
+// 由DID主体自身的密钥签名时直接基于文档校验；由其他DID的密钥签名时，签名者必须能沿控制者链
+// 找到，并基于签名者自己的文档校验验证关系
+func (r *DIDRegistry) authorize(doc *types.DIDDocument, payload interface{}, proof *types.Proof, verify permissionCheck) error {
+	if proof == nil {
+		return verify(doc, payload, proof)
+	}
+
+	signer := signerDID(doc.ID, proof.VerificationMethod)
+	if signer == doc.ID {
+		return verify(doc, payload, proof)
+	}
+
+	controllerDoc, err := r.findControllerDocument(doc, signer)
+	if err != nil {
+		return err
+	}
+
+	// 证明必须晚于被控文档当前的证明，防止重放已应用过的操作
+	if previous := doc.Proof; previous != nil {
+		if !proof.Created.After(previous.Created) || (proof.Nonce != "" && proof.Nonce == previous.Nonce) {
+			return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "PROOF_REPLAYED",
+				"证明已被使用", proof.Created.Format(time.RFC3339))
+		}
+	}
+
+	return verify(controllerDoc, payload, proof)
+}
+
+// findControllerDocument 沿控制者链广度优先查找签名者的文档
+// 已撤销或不存在的控制者会中断所在的链，环路只访问一次
+func (r *DIDRegistry) findControllerDocument(doc *types.DIDDocument, signer string) (*types.DIDDocument, error) {
+	visited := map[string]bool{doc.ID: true}
+	level := []*types.DIDDocument{doc}
+
+	for depth := 0; depth < MaxControllerDepth && len(level) > 0; depth++ {
+		var next []*types.DIDDocument
+		for _, current := range level {
+			for _, controller := range current.Controller {
+				if visited[controller] {
+					continue
+				}
+				visited[controller] = true
+
+				controllerDoc, err := r.loadDocument(controller)
+				if err != nil || controllerDoc.Status == "revoked" {
+					continue
+				}
+				if controller == signer {
+					return controllerDoc, nil
+				}
+				next = append(next, controllerDoc)
+			}
+		}
+		level = next
+	}
+
+	return nil, &DIDError{
+		Type:    ErrorTypeUnauthorized,
+		Code:    "CONTROLLER_NOT_AUTHORIZED",
+		Message: "签名者不是该DID的控制者",
+		Details: signer,
+	}
+}
+
+// signerDID 返回验证方法ID所属的DID，相对ID属于当前文档
+func signerDID(didStr, verificationMethodID string) string {
+	if strings.HasPrefix(verificationMethodID, "#") {
+		return didStr
+	}
+	if i := strings.IndexByte(verificationMethodID, '#'); i >= 0 {
+		return verificationMethodID[:i]
+	}
+	return verificationMethodID
+}
+
+// validateControllers 校验控制者列表：必须是不带路径、查询和片段的DID，且不能重复
+func validateControllers(controllers []string) error {
+	seen := make(map[string]bool, len(controllers))
+	for _, controller := range controllers {
+		parsed, err := ParseDIDURL(controller)
+		if err != nil || parsed.DID != controller {
+			return controllerError("控制者必须是DID", controller)
+		}
+		if seen[controller] {
+			return controllerError("控制者重复", controller)
+		}
+		seen[controller] = true
+	}
+	return nil
+}
+
+// controllerError 创建控制者校验错误
+func controllerError(message, details string) error {
+	return &DIDError{
+		Type:    ErrorTypeValidation,
+		Code:    "INVALID_CONTROLLER",
+		Message: message,
+		Details: details,
+	}
+}
@@ -0,0 +1,141 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// registerBuilder 用构建器注册DID
+func registerBuilder(t *testing.T, registry *DIDRegistry, builder *DIDDocumentBuilder) *types.DIDDocument {
+	t.Helper()
+	req, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	doc, err := registry.Register(req)
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	return doc
+}
+
+func TestControllerDelegation(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+
+	org, _ := NewDIDDocumentBuilder()
+	team, _ := NewDIDDocumentBuilder()
+	employee, _ := NewDIDDocumentBuilder()
+	stranger, _ := NewDIDDocumentBuilder()
+
+	registerBuilder(t, registry, org)
+	registerBuilder(t, registry, team.SetController(org.GetDID()))
+	employeeDoc := registerBuilder(t, registry, employee.SetController(team.GetDID()))
+	registerBuilder(t, registry, stranger)
+
+	if len(employeeDoc.CapabilityInvocation) != 1 || len(employeeDoc.CapabilityDelegation) != 1 {
+		t.Errorf("应填充capabilityInvocation和capabilityDelegation: %+v", employeeDoc)
+	}
+	data, _ := json.Marshal(employeeDoc)
+	var raw map[string]interface{}
+	_ = json.Unmarshal(data, &raw)
+	if raw["controller"] != team.GetDID() {
+		t.Errorf("单个控制者应序列化为字符串: %v", raw["controller"])
+	}
+
+	managed, err := registry.ListByController(team.GetDID())
+	if err != nil || len(managed) != 1 || managed[0].ID != employee.GetDID() {
+		t.Fatalf("控制者索引不正确: %v, %v", managed, err)
+	}
+
+	// 控制者的控制者可以沿链更新员工DID
+	service := types.Service{ID: employee.GetDID() + "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com"}
+	req := &UpdateRequest{DID: employee.GetDID(), Service: []types.Service{service}}
+	req.Proof, _ = org.SignPayload(req.SigningPayload(), "capabilityInvocation")
+	if _, err := registry.Update(req); err != nil {
+		t.Fatalf("控制者链上的DID更新失败: %v", err)
+	}
+
+	// 同一证明不能重放
+	if _, err := registry.Update(req); err == nil {
+		t.Error("重放的证明不应通过验证")
+	}
+
+	req.Proof, _ = stranger.SignPayload(req.SigningPayload(), "capabilityInvocation")
+	if _, err := registry.Update(req); err == nil {
+		t.Error("非控制者不应更新DID")
+	}
+
+	// 撤销需要authentication或capabilityInvocation
+	proof, _ := org.SignPayload(RevokeSigningPayload(employee.GetDID()), "assertionMethod")
+	if err := registry.Revoke(employee.GetDID(), proof); err == nil {
+		t.Error("assertionMethod证明不应撤销DID")
+	}
+
+	// 变更控制者需要capabilityDelegation
+	controllers := []string{org.GetDID()}
+	proof, _ = employee.SignPayload(ControllerSigningPayload(employee.GetDID(), controllers), "authentication")
+	if _, _, err := registry.SetControllers(context.Background(), employee.GetDID(), controllers, proof); err == nil {
+		t.Error("authentication证明不应变更控制者")
+	}
+	proof, _ = team.SignPayload(ControllerSigningPayload(employee.GetDID(), controllers), "capabilityDelegation")
+	doc, _, err := registry.SetControllers(context.Background(), employee.GetDID(), controllers, proof)
+	if err != nil {
+		t.Fatalf("控制者变更失败: %v", err)
+	}
+	if len(doc.Controller) != 1 || doc.Controller[0] != org.GetDID() {
+		t.Errorf("控制者未更新: %v", doc.Controller)
+	}
+	if managed, _ := registry.ListByController(team.GetDID()); len(managed) != 0 {
+		t.Errorf("旧控制者的索引应被清理: %v", managed)
+	}
+
+	// 团队不再是控制者
+	req = &UpdateRequest{DID: employee.GetDID()}
+	req.Proof, _ = team.SignPayload(req.SigningPayload(), "capabilityInvocation")
+	if _, err := registry.Update(req); err == nil {
+		t.Error("被移除的控制者不应再更新DID")
+	}
+
+	// 已撤销的控制者失去控制权
+	proof, _ = org.SignPayload(RevokeSigningPayload(org.GetDID()), "authentication")
+	if err := registry.Revoke(org.GetDID(), proof); err != nil {
+		t.Fatalf("撤销控制者失败: %v", err)
+	}
+	proof, _ = org.SignPayload(RevokeSigningPayload(employee.GetDID()), "capabilityInvocation")
+	if err := registry.Revoke(employee.GetDID(), proof); err == nil {
+		t.Error("已撤销的控制者不应撤销受控DID")
+	}
+
+	// 无效的控制者
+	proof, _ = employee.SignSetControllers([]string{"not-a-did"})
+	if _, _, err := registry.SetControllers(context.Background(), employee.GetDID(), []string{"not-a-did"}, proof); err == nil {
+		t.Error("无效的控制者应被拒绝")
+	}
+}
+
+func TestControllerCycle(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+
+	a, _ := NewDIDDocumentBuilder()
+	b, _ := NewDIDDocumentBuilder()
+	stranger, _ := NewDIDDocumentBuilder()
+	registerBuilder(t, registry, a.SetController(b.GetDID()))
+	registerBuilder(t, registry, b.SetController(a.GetDID()))
+	registerBuilder(t, registry, stranger)
+
+	// 互为控制者时双方都可以更新对方，且查找不会陷入循环
+	req := &UpdateRequest{DID: a.GetDID()}
+	req.Proof, _ = b.SignPayload(req.SigningPayload(), "capabilityInvocation")
+	if _, err := registry.Update(req); err != nil {
+		t.Errorf("控制者更新失败: %v", err)
+	}
+
+	req = &UpdateRequest{DID: b.GetDID()}
+	req.Proof, _ = stranger.SignPayload(req.SigningPayload(), "capabilityInvocation")
+	if _, err := registry.Update(req); err == nil {
+		t.Error("非控制者不应更新DID")
+	}
+}
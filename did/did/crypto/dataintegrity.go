@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"strings"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// W3C Data Integrity 证明
+const (
+	// DataIntegrityProofType W3C Data Integrity 证明类型，算法由 cryptosuite 指定
+	DataIntegrityProofType = "DataIntegrityProof"
+	// CryptosuiteECDSAJCS2019 P-256 ECDSA + JCS规范化（W3C ecdsa-jcs-2019）
+	CryptosuiteECDSAJCS2019 = "ecdsa-jcs-2019"
+	// CryptosuiteCompositeJCS2025 ES256 + ML-DSA-65组合签名 + JCS规范化
+	CryptosuiteCompositeJCS2025 = "mldsa65-es256-jcs-2025"
+)
+
+// dataIntegrityAlgorithms cryptosuite 对应的签名算法
+var dataIntegrityAlgorithms = map[string]string{
+	CryptosuiteECDSAJCS2019:     "ES256",
+	CryptosuiteCompositeJCS2025: CompositeAlgorithm,
+}
+
+// SignDataIntegrityProof 为文档生成 DataIntegrityProof
+// 签名输入为 SHA-256(JCS(去掉proofValue的证明)) || SHA-256(JCS(去掉proof的文档))，
+// proofValue 为签名的 multibase（base58btc）编码；组合密钥使用 CryptosuiteCompositeJCS2025
+func (hkp *HybridKeyPair) SignDataIntegrityProof(document interface{}, proof *types.Proof) error {
+	proof.Type = DataIntegrityProofType
+	proof.Cryptosuite = CryptosuiteECDSAJCS2019
+	if hkp.IsComposite() {
+		proof.Cryptosuite = CryptosuiteCompositeJCS2025
+	}
+	proof.ProofValue = ""
+
+	signingInput, err := ProofSigningInput(document, proof)
+	if err != nil {
+		return err
+	}
+
+	signature, err := hkp.signInput(signingInput)
+	if err != nil {
+		return err
+	}
+
+	proof.ProofValue = "z" + EncodeBase58(signature)
+	return nil
+}
+
+// verifyDataIntegrityProof 验证 DataIntegrityProof
+func (sv *SignatureVerifier) verifyDataIntegrityProof(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
+	alg, ok := dataIntegrityAlgorithms[proof.Cryptosuite]
+	if !ok {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_CRYPTOSUITE",
+			"不支持的密码套件", proof.Cryptosuite)
+	}
+
+	if err := sv.checkSuite(proof.Type, proof.Cryptosuite); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(proof.ProofValue, "z") {
+		return utils.NewError(utils.ErrorTypeValidation, "INVALID_SIGNATURE_FORMAT", "proofValue必须是base58btc编码的multibase")
+	}
+	signature, err := DecodeBase58(proof.ProofValue[1:])
+	if err != nil {
+		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_SIGNATURE_FORMAT", "签名格式无效", err)
+	}
+
+	signingInput, err := sv.createSignatureData(document, proof)
+	if err != nil {
+		return err
+	}
+
+	return sv.verifyAlgorithmSignature(alg, signingInput, signature, verificationMethod)
+}
@@ -0,0 +1,308 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 加密信封格式
+//
+// 信封是带有发送方签名的JWE（RFC 7516），DID节点、spec客户端（spec/pkg/secure/envelope.go）和IM服务按此格式互通，
+// 三处实现都须能解密仓库根目录 testdata/envelope-v1.json 中的共享测试向量：
+//   - 受保护头部: {"alg":"ML-KEM-768+A256KW","enc":"A256GCM","typ":"qlink-envelope+jwe","ver":1,"ek":BASE64URL(ML-KEM-768密文),"skid":发送方DID,"kid":接收方DID}
+//   - KEK = HKDF-SHA256(ikm=ML-KEM共享密钥, salt=ML-KEM密文, info="ML-KEM-768+A256KW" || 0x00 || 发送方DID || 0x00 || 接收方DID)，32字节
+//   - encrypted_key = AES-256-KW(KEK, CEK)（RFC 3394），CEK为随机的32字节内容加密密钥
+//   - ciphertext/tag = AES-256-GCM(CEK, iv, 明文, AAD=ASCII(BASE64URL(受保护头部)))
+//   - signature = 发送方对JWE紧凑序列化（头部.encrypted_key.iv.ciphertext.tag）的混合签名
+const (
+	EnvelopeVersion    = 1
+	EnvelopeAlgorithm  = "ML-KEM-768+A256KW"
+	EnvelopeEncryption = "A256GCM"
+	EnvelopeType       = "qlink-envelope+jwe"
+)
+
+const (
+	envelopeKeySize   = 32
+	envelopeNonceSize = 12
+	envelopeTagSize   = 16
+)
+
+// ErrEnvelopeDecrypt 信封无法解密（密文、头部或密钥不匹配）
+var ErrEnvelopeDecrypt = errors.New("信封解密失败")
+
+// EnvelopeHeader JWE受保护头部
+type EnvelopeHeader struct {
+	Algorithm       string `json:"alg"`
+	Encryption      string `json:"enc"`
+	Type            string `json:"typ"`
+	Version         int    `json:"ver"`
+	EncapsulatedKey string `json:"ek"`
+	Sender          string `json:"skid"`
+	Recipient       string `json:"kid"`
+}
+
+// HybridEnvelope 混合加密信封，即JWE扁平JSON序列化加上发送方签名
+type HybridEnvelope struct {
+	Protected    string           `json:"protected"`
+	EncryptedKey string           `json:"encrypted_key"`
+	IV           string           `json:"iv"`
+	Ciphertext   string           `json:"ciphertext"`
+	Tag          string           `json:"tag"`
+	Signature    *HybridSignature `json:"signature"`
+}
+
+// Header 解析受保护头部
+func (env *HybridEnvelope) Header() (*EnvelopeHeader, error) {
+	data, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return nil, fmt.Errorf("信封头部编码无效: %w", err)
+	}
+	var header EnvelopeHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("信封头部格式无效: %w", err)
+	}
+	return &header, nil
+}
+
+// Compact 返回JWE紧凑序列化，也是发送方签名的内容
+func (env *HybridEnvelope) Compact() string {
+	return strings.Join([]string{env.Protected, env.EncryptedKey, env.IV, env.Ciphertext, env.Tag}, ".")
+}
+
+// HybridEncrypt 将数据加密给接收方的Kyber768公钥，并用自己的私钥签名整个信封
+func (hkp *HybridKeyPair) HybridEncrypt(data []byte, senderDID, recipientDID string, recipientPublicKey *HybridKeyPair) (*HybridEnvelope, error) {
+	if senderDID == "" || recipientDID == "" {
+		return nil, fmt.Errorf("发送方和接收方DID不能为空")
+	}
+
+	kemCiphertext, sharedKey, err := recipientPublicKey.EncapsulateSharedKey()
+	if err != nil {
+		return nil, fmt.Errorf("密钥封装失败: %w", err)
+	}
+	kek, err := deriveEnvelopeKEK(sharedKey, kemCiphertext, senderDID, recipientDID)
+	if err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, envelopeKeySize)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, fmt.Errorf("生成内容加密密钥失败: %w", err)
+	}
+	wrappedKey, err := aesKeyWrap(kek, cek)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(&EnvelopeHeader{
+		Algorithm:       EnvelopeAlgorithm,
+		Encryption:      EnvelopeEncryption,
+		Type:            EnvelopeType,
+		Version:         EnvelopeVersion,
+		EncapsulatedKey: base64.RawURLEncoding.EncodeToString(kemCiphertext),
+		Sender:          senderDID,
+		Recipient:       recipientDID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化信封头部失败: %w", err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	gcm, err := newEnvelopeGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, envelopeNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("生成IV失败: %w", err)
+	}
+	sealed := gcm.Seal(nil, iv, data, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-envelopeTagSize], sealed[len(sealed)-envelopeTagSize:]
+
+	env := &HybridEnvelope{
+		Protected:    protected,
+		EncryptedKey: base64.RawURLEncoding.EncodeToString(wrappedKey),
+		IV:           base64.RawURLEncoding.EncodeToString(iv),
+		Ciphertext:   base64.RawURLEncoding.EncodeToString(ciphertext),
+		Tag:          base64.RawURLEncoding.EncodeToString(tag),
+	}
+
+	env.Signature, err = hkp.Sign([]byte(env.Compact()))
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %w", err)
+	}
+	return env, nil
+}
+
+// HybridDecrypt 验证发送方签名后解密senderDID发给recipientDID的信封
+// senderPublicKey 须是调用方从 senderDID 的DID文档中解析出的密钥，头部声明的发送方必须与之一致
+func (hkp *HybridKeyPair) HybridDecrypt(env *HybridEnvelope, senderDID, recipientDID string, senderPublicKey *HybridKeyPair) ([]byte, error) {
+	// 先验证签名，未经发送方签名的信封不做解封装
+	if env.Signature == nil || !senderPublicKey.Verify([]byte(env.Compact()), env.Signature) {
+		return nil, fmt.Errorf("签名验证失败")
+	}
+
+	header, err := env.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("不支持的信封版本: %d", header.Version)
+	}
+	if header.Algorithm != EnvelopeAlgorithm || header.Encryption != EnvelopeEncryption {
+		return nil, fmt.Errorf("不支持的信封算法: %s/%s", header.Algorithm, header.Encryption)
+	}
+	if header.Sender != senderDID {
+		return nil, fmt.Errorf("信封发送方不匹配: %s", header.Sender)
+	}
+	if header.Recipient != recipientDID {
+		return nil, fmt.Errorf("信封接收方不匹配: %s", header.Recipient)
+	}
+
+	kemCiphertext, err := decodeEnvelopeField(header.EncapsulatedKey, mlkem.CiphertextSize768)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := decodeEnvelopeField(env.EncryptedKey, envelopeKeySize+8)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := decodeEnvelopeField(env.IV, envelopeNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := decodeEnvelopeField(env.Tag, envelopeTagSize)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("信封字段编码无效: %w", err)
+	}
+
+	sharedKey, err := hkp.DecapsulateSharedKey(kemCiphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密钥解封装失败: %w", err)
+	}
+	kek, err := deriveEnvelopeKEK(sharedKey, kemCiphertext, header.Sender, header.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := aesKeyUnwrap(kek, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newEnvelopeGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(env.Protected))
+	if err != nil {
+		return nil, ErrEnvelopeDecrypt
+	}
+	return data, nil
+}
+
+// deriveEnvelopeKEK 从ML-KEM共享密钥派生密钥加密密钥，并绑定发送方和接收方DID
+func deriveEnvelopeKEK(sharedKey, kemCiphertext []byte, senderDID, recipientDID string) ([]byte, error) {
+	info := EnvelopeAlgorithm + "\x00" + senderDID + "\x00" + recipientDID
+	kek, err := hkdf.Key(sha256.New, sharedKey, kemCiphertext, info, envelopeKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("派生密钥加密密钥失败: %w", err)
+	}
+	return kek, nil
+}
+
+func newEnvelopeGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeEnvelopeField(value string, size int) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("信封字段编码无效: %w", err)
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("信封字段长度无效: %d", len(data))
+	}
+	return data, nil
+}
+
+// aesKeyWrapIV RFC 3394 默认初始值
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap RFC 3394 AES密钥包装
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, fmt.Errorf("待包装密钥长度无效: %d", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, aesKeyWrapIV)
+	copy(out[8:], key)
+
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, out[:8])
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[i*8:], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap RFC 3394 AES密钥解包，完整性校验失败时返回 ErrEnvelopeDecrypt
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("包装密钥长度无效: %d", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Decrypt(buf, buf)
+			copy(out[:8], buf[:8])
+			copy(out[i*8:], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(out[:8], aesKeyWrapIV) != 1 {
+		return nil, ErrEnvelopeDecrypt
+	}
+	return out[8:], nil
+}
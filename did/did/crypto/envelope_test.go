@@ -0,0 +1,160 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAESKeyWrapVector(t *testing.T) {
+	// RFC 3394 4.6: 256位KEK包装256位密钥
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	want := "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21"
+
+	wrapped, err := aesKeyWrap(kek, key)
+	if err != nil || hex.EncodeToString(wrapped) != want {
+		t.Fatalf("密钥包装结果不正确: %x, %v", wrapped, err)
+	}
+
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	if err != nil || hex.EncodeToString(unwrapped) != hex.EncodeToString(key) {
+		t.Fatalf("密钥解包结果不正确: %x, %v", unwrapped, err)
+	}
+
+	wrapped[0] ^= 1
+	if _, err := aesKeyUnwrap(kek, wrapped); !errors.Is(err, ErrEnvelopeDecrypt) {
+		t.Errorf("篡改的包装密钥应被拒绝: %v", err)
+	}
+}
+
+func TestEnvelopeIsSignedJWE(t *testing.T) {
+	sender, _ := GenerateHybridKeyPair()
+	recipient, _ := GenerateHybridKeyPair()
+	senderDID, _ := GenerateDIDFromKeyPair(sender)
+	recipientDID, _ := GenerateDIDFromKeyPair(recipient)
+
+	envelope, err := sender.HybridEncrypt([]byte("hello"), senderDID, recipientDID, recipient)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	header, err := envelope.Header()
+	if err != nil {
+		t.Fatalf("解析头部失败: %v", err)
+	}
+	if header.Algorithm != EnvelopeAlgorithm || header.Encryption != EnvelopeEncryption || header.Version != EnvelopeVersion {
+		t.Errorf("头部算法不正确: %+v", header)
+	}
+	if header.Sender != senderDID || header.Recipient != recipientDID {
+		t.Errorf("头部应绑定双方DID: %+v", header)
+	}
+	if parts := strings.Split(envelope.Compact(), "."); len(parts) != 5 {
+		t.Errorf("紧凑序列化应有5段: %d", len(parts))
+	}
+
+	// JSON序列化后可在另一端解密
+	data, _ := json.Marshal(envelope)
+	var received HybridEnvelope
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("解析信封失败: %v", err)
+	}
+	plaintext, err := recipient.HybridDecrypt(&received, senderDID, recipientDID, sender)
+	if err != nil || string(plaintext) != "hello" {
+		t.Fatalf("解密失败: %q, %v", plaintext, err)
+	}
+
+	// 发给其他DID的信封不能被接收
+	if _, err := recipient.HybridDecrypt(envelope, senderDID, "did:qlink:other", sender); err == nil {
+		t.Error("接收方DID不匹配时应拒绝")
+	}
+
+	// 非发送方签名的信封被拒绝
+	if _, err := recipient.HybridDecrypt(envelope, senderDID, recipientDID, recipient); err == nil {
+		t.Error("签名者不匹配时应拒绝")
+	}
+
+	// 攻击者用自己的密钥签发声称来自其他DID的信封：签名有效，但头部发送方与公钥所属的DID不一致
+	attacker, _ := GenerateHybridKeyPair()
+	attackerDID, _ := GenerateDIDFromKeyPair(attacker)
+	spoofed, err := attacker.HybridEncrypt([]byte("hello"), senderDID, recipientDID, recipient)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if _, err := recipient.HybridDecrypt(spoofed, attackerDID, recipientDID, attacker); err == nil {
+		t.Error("头部发送方与签名密钥所属DID不一致时应拒绝")
+	}
+
+	// 替换头部中的发送方DID会使签名失效
+	forgedHeader := *header
+	forgedHeader.Sender = "did:qlink:attacker"
+	headerJSON, _ := json.Marshal(&forgedHeader)
+	forged := *envelope
+	forged.Protected = base64.RawURLEncoding.EncodeToString(headerJSON)
+	if _, err := recipient.HybridDecrypt(&forged, senderDID, recipientDID, sender); err == nil {
+		t.Error("篡改头部后应拒绝")
+	}
+
+	// 攻击者重新签名篡改后的信封，AAD和KEK绑定使解密失败
+	forged.Signature, _ = attacker.Sign([]byte(forged.Compact()))
+	if _, err := recipient.HybridDecrypt(&forged, "did:qlink:attacker", recipientDID, attacker); !errors.Is(err, ErrEnvelopeDecrypt) {
+		t.Errorf("篡改头部后应解密失败: %v", err)
+	}
+}
+
+// envelopeVector 仓库根目录 testdata/envelope-v1.json，DID节点、spec客户端和IM服务共用
+type envelopeVector struct {
+	Plaintext string                     `json:"plaintext"`
+	Sender    envelopeVectorParty        `json:"sender"`
+	Recipient envelopeVectorParty        `json:"recipient"`
+	Envelopes map[string]*HybridEnvelope `json:"envelopes"`
+}
+
+type envelopeVectorParty struct {
+	DID          string        `json:"did"`
+	PrivateKey   string        `json:"privateKey"`
+	PublicKeyJWK *PublicKeyJWK `json:"publicKeyJwk"`
+}
+
+// TestEnvelopeSharedVector 能解密其他实现生成的信封
+func TestEnvelopeSharedVector(t *testing.T) {
+	data, err := os.ReadFile("../../../testdata/envelope-v1.json")
+	if err != nil {
+		t.Fatalf("读取共享测试向量失败: %v", err)
+	}
+	var vector envelopeVector
+	if err := json.Unmarshal(data, &vector); err != nil {
+		t.Fatalf("解析共享测试向量失败: %v", err)
+	}
+
+	recipientKey, _ := hex.DecodeString(vector.Recipient.PrivateKey)
+	recipient, err := ParsePrivateKey(recipientKey)
+	if err != nil {
+		t.Fatalf("解析接收方私钥失败: %v", err)
+	}
+	sender, err := FromJWK(vector.Sender.PublicKeyJWK)
+	if err != nil {
+		t.Fatalf("解析发送方公钥失败: %v", err)
+	}
+	if jwk, _ := recipient.ToJWK(); *jwk != *vector.Recipient.PublicKeyJWK {
+		t.Errorf("接收方私钥与公钥不对应: %+v", jwk)
+	}
+
+	for _, producer := range []string{"did", "spec"} {
+		env := vector.Envelopes[producer]
+		if env == nil {
+			t.Fatalf("缺少 %s 生成的信封", producer)
+		}
+		plaintext, err := recipient.HybridDecrypt(env, vector.Sender.DID, vector.Recipient.DID, sender)
+		if err != nil || string(plaintext) != vector.Plaintext {
+			t.Errorf("解密 %s 生成的信封失败: %q, %v", producer, plaintext, err)
+		}
+		if _, err := recipient.HybridDecrypt(env, vector.Recipient.DID, vector.Recipient.DID, sender); err == nil {
+			t.Errorf("%s: 发送方DID不匹配时应拒绝", producer)
+		}
+	}
+}
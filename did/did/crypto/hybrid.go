@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/mldsa"
 	"crypto/mlkem"
 	"crypto/rand"
 	"crypto/sha256"
@@ -13,31 +14,39 @@ import (
 	"math/big"
 )
 
-// HybridKeyPair 混合密钥对（ECDSA + Kyber768）
+// CompositeAlgorithm ML-DSA-65与ES256组合签名的算法名，两者都验证通过签名才有效
+const CompositeAlgorithm = "ML-DSA-65-ES256"
+
+// HybridKeyPair 混合密钥对（ECDSA + Kyber768 + ML-DSA-65）
+// 早期生成的密钥对没有ML-DSA密钥，此时只使用ECDSA签名
 type HybridKeyPair struct {
 	ECDSAPrivateKey *ecdsa.PrivateKey `json:"-"` // 不序列化私钥
 	ECDSAPublicKey  *ecdsa.PublicKey  `json:"ecdsa_public_key"`
 	// Kyber768密钥对
 	KyberDecapsulationKey *mlkem.DecapsulationKey768 `json:"-"` // 不序列化私钥
 	KyberEncapsulationKey *mlkem.EncapsulationKey768 `json:"kyber_public_key"`
+	// ML-DSA-65密钥对
+	MLDSAPrivateKey *mldsa.PrivateKey `json:"-"` // 不序列化私钥
+	MLDSAPublicKey  *mldsa.PublicKey  `json:"mldsa_public_key,omitempty"`
 }
 
 // PublicKeyJWK JWK格式的公钥
 type PublicKeyJWK struct {
-	Kty   string `json:"kty"`   // 密钥类型
-	Alg   string `json:"alg"`   // 算法
-	Use   string `json:"use"`   // 用途
-	Crv   string `json:"crv"`   // 曲线（ECDSA）
-	X     string `json:"x"`     // X坐标（ECDSA）
-	Y     string `json:"y"`     // Y坐标（ECDSA）
-	Kyber string `json:"kyber"` // Kyber768公钥
+	Kty   string `json:"kty"`             // 密钥类型
+	Alg   string `json:"alg"`             // 算法
+	Use   string `json:"use"`             // 用途
+	Crv   string `json:"crv"`             // 曲线（ECDSA）
+	X     string `json:"x"`               // X坐标（ECDSA）
+	Y     string `json:"y"`               // Y坐标（ECDSA）
+	Kyber string `json:"kyber"`           // Kyber768公钥
+	MLDSA string `json:"mldsa,omitempty"` // ML-DSA-65公钥
 }
 
 // HybridSignature 混合签名
+// 密钥对包含ML-DSA-65密钥时同时给出两种签名，验证方要求两者都有效
 type HybridSignature struct {
 	ECDSASignature []byte `json:"ecdsa_signature"`
-	// Kyber768不用于签名，而是用于密钥封装，这里保留字段用于未来扩展
-	KyberProof []byte `json:"kyber_proof,omitempty"`
+	MLDSASignature []byte `json:"mldsa_signature,omitempty"`
 }
 
 // GenerateHybridKeyPair 生成混合密钥对
@@ -56,14 +65,27 @@ func GenerateHybridKeyPair() (*HybridKeyPair, error) {
 
 	kyberEncapsKey := kyberDecapsKey.EncapsulationKey()
 
+	// 生成ML-DSA-65密钥对
+	mldsaPrivKey, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if err != nil {
+		return nil, fmt.Errorf("生成ML-DSA-65密钥失败: %w", err)
+	}
+
 	return &HybridKeyPair{
 		ECDSAPrivateKey:       ecdsaPrivKey,
 		ECDSAPublicKey:        &ecdsaPrivKey.PublicKey,
 		KyberDecapsulationKey: kyberDecapsKey,
 		KyberEncapsulationKey: kyberEncapsKey,
+		MLDSAPrivateKey:       mldsaPrivKey,
+		MLDSAPublicKey:        mldsaPrivKey.PublicKey(),
 	}, nil
 }
 
+// IsComposite 密钥对是否包含ML-DSA-65签名密钥
+func (hkp *HybridKeyPair) IsComposite() bool {
+	return hkp.MLDSAPublicKey != nil
+}
+
 // Sign 使用混合密钥对数据进行签名
 func (hkp *HybridKeyPair) Sign(data []byte) (*HybridSignature, error) {
 	if hkp.ECDSAPrivateKey == nil {
@@ -79,9 +101,22 @@ func (hkp *HybridKeyPair) Sign(data []byte) (*HybridSignature, error) {
 		return nil, fmt.Errorf("ECDSA签名失败: %w", err)
 	}
 
-	return &HybridSignature{
+	sig := &HybridSignature{
 		ECDSASignature: ecdsaSig,
-	}, nil
+	}
+
+	// ML-DSA-65签名
+	if hkp.IsComposite() {
+		if hkp.MLDSAPrivateKey == nil {
+			return nil, fmt.Errorf("ML-DSA-65私钥为空")
+		}
+		sig.MLDSASignature, err = hkp.MLDSAPrivateKey.Sign(nil, data, &mldsa.Options{Context: CompositeAlgorithm})
+		if err != nil {
+			return nil, fmt.Errorf("ML-DSA-65签名失败: %w", err)
+		}
+	}
+
+	return sig, nil
 }
 
 // Verify 验证混合签名
@@ -94,7 +129,15 @@ func (hkp *HybridKeyPair) Verify(data []byte, sig *HybridSignature) bool {
 	hash := sha256.Sum256(data)
 
 	// 验证ECDSA签名
-	return ecdsa.VerifyASN1(hkp.ECDSAPublicKey, hash[:], sig.ECDSASignature)
+	if !ecdsa.VerifyASN1(hkp.ECDSAPublicKey, hash[:], sig.ECDSASignature) {
+		return false
+	}
+
+	// 公钥包含ML-DSA-65时必须同时提供有效的ML-DSA签名，防止降级为单一ECDSA签名
+	if hkp.IsComposite() {
+		return mldsa.Verify(hkp.MLDSAPublicKey, data, sig.MLDSASignature, &mldsa.Options{Context: CompositeAlgorithm}) == nil
+	}
+	return true
 }
 
 // ToJWK 将公钥转换为JWK格式
@@ -126,7 +169,7 @@ func (hkp *HybridKeyPair) ToJWK() (*PublicKeyJWK, error) {
 	// 获取Kyber768公钥字节
 	kyberBytes := hkp.KyberEncapsulationKey.Bytes()
 
-	return &PublicKeyJWK{
+	jwk := &PublicKeyJWK{
 		Kty:   "EC",
 		Alg:   "ES256",
 		Use:   "sig",
@@ -134,7 +177,15 @@ func (hkp *HybridKeyPair) ToJWK() (*PublicKeyJWK, error) {
 		X:     base64.RawURLEncoding.EncodeToString(x),
 		Y:     base64.RawURLEncoding.EncodeToString(y),
 		Kyber: base64.RawURLEncoding.EncodeToString(kyberBytes),
-	}, nil
+	}
+
+	// 组合密钥声明组合签名算法，验证方据此拒绝只有ES256的签名
+	if hkp.IsComposite() {
+		jwk.Alg = CompositeAlgorithm
+		jwk.MLDSA = base64.RawURLEncoding.EncodeToString(hkp.MLDSAPublicKey.Bytes())
+	}
+
+	return jwk, nil
 }
 
 // FromJWK 从JWK格式创建公钥
@@ -176,9 +227,26 @@ func FromJWK(jwk *PublicKeyJWK) (*HybridKeyPair, error) {
 		}
 	}
 
+	// 解码ML-DSA-65公钥
+	var mldsaPubKey *mldsa.PublicKey
+	if jwk.MLDSA != "" {
+		mldsaBytes, err := base64.RawURLEncoding.DecodeString(jwk.MLDSA)
+		if err != nil {
+			return nil, fmt.Errorf("解码ML-DSA-65公钥失败: %w", err)
+		}
+
+		mldsaPubKey, err = mldsa.NewPublicKey(mldsa.MLDSA65(), mldsaBytes)
+		if err != nil {
+			return nil, fmt.Errorf("创建ML-DSA-65公钥失败: %w", err)
+		}
+	} else if jwk.Alg == CompositeAlgorithm {
+		return nil, fmt.Errorf("组合密钥缺少ML-DSA-65公钥")
+	}
+
 	return &HybridKeyPair{
 		ECDSAPublicKey:        pubKey,
 		KyberEncapsulationKey: kyberEncapsKey,
+		MLDSAPublicKey:        mldsaPubKey,
 	}, nil
 }
 
@@ -200,6 +268,69 @@ func DeserializePublicKey(data []byte) (*HybridKeyPair, error) {
 	return FromJWK(&jwk)
 }
 
+// 私钥序列化长度：ECDSA私钥标量(32) + ML-KEM-768种子(64) + ML-DSA-65种子(32)
+// 没有ML-DSA密钥的早期密钥对不含最后32字节
+const (
+	legacyPrivateKeySize = 32 + mlkem.SeedSize
+	privateKeySize       = legacyPrivateKeySize + mldsa.PrivateKeySize
+)
+
+// MarshalPrivateKey 序列化私钥（ECDSA私钥标量 || ML-KEM-768种子 || ML-DSA-65种子）
+// 结果是明文私钥，调用方负责加密保存
+func (hkp *HybridKeyPair) MarshalPrivateKey() ([]byte, error) {
+	if hkp.ECDSAPrivateKey == nil || hkp.KyberDecapsulationKey == nil {
+		return nil, fmt.Errorf("私钥为空")
+	}
+	if hkp.IsComposite() && hkp.MLDSAPrivateKey == nil {
+		return nil, fmt.Errorf("ML-DSA-65私钥为空")
+	}
+
+	data := make([]byte, 0, privateKeySize)
+	data = append(data, hkp.ECDSAPrivateKey.D.FillBytes(make([]byte, 32))...)
+	data = append(data, hkp.KyberDecapsulationKey.Bytes()...)
+	if hkp.MLDSAPrivateKey != nil {
+		data = append(data, hkp.MLDSAPrivateKey.Bytes()...)
+	}
+	return data, nil
+}
+
+// ParsePrivateKey 从 MarshalPrivateKey 的结果恢复密钥对
+func ParsePrivateKey(data []byte) (*HybridKeyPair, error) {
+	if len(data) != privateKeySize && len(data) != legacyPrivateKeySize {
+		return nil, fmt.Errorf("私钥长度无效: 期望 %d, 实际 %d", privateKeySize, len(data))
+	}
+
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(data[:32])
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("无效的ECDSA私钥")
+	}
+	ecdsaPrivKey := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	ecdsaPrivKey.PublicKey.X, ecdsaPrivKey.PublicKey.Y = curve.ScalarBaseMult(data[:32])
+
+	kyberDecapsKey, err := mlkem.NewDecapsulationKey768(data[32:legacyPrivateKeySize])
+	if err != nil {
+		return nil, fmt.Errorf("无效的Kyber768私钥: %w", err)
+	}
+
+	keyPair := &HybridKeyPair{
+		ECDSAPrivateKey:       ecdsaPrivKey,
+		ECDSAPublicKey:        &ecdsaPrivKey.PublicKey,
+		KyberDecapsulationKey: kyberDecapsKey,
+		KyberEncapsulationKey: kyberDecapsKey.EncapsulationKey(),
+	}
+
+	if len(data) == privateKeySize {
+		keyPair.MLDSAPrivateKey, err = mldsa.NewPrivateKey(mldsa.MLDSA65(), data[legacyPrivateKeySize:])
+		if err != nil {
+			return nil, fmt.Errorf("无效的ML-DSA-65私钥: %w", err)
+		}
+		keyPair.MLDSAPublicKey = keyPair.MLDSAPrivateKey.PublicKey()
+	}
+
+	return keyPair, nil
+}
+
 // GetFingerprint 获取密钥指纹
 func (hkp *HybridKeyPair) GetFingerprint() (string, error) {
 	pubKeyData, err := hkp.SerializePublicKey()
@@ -211,6 +342,27 @@ func (hkp *HybridKeyPair) GetFingerprint() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(hash[:16]), nil // 使用前16字节作为指纹
 }
 
+// GetNextKeyCommitment 计算预轮换承诺
+// 与 GetFingerprint 对相同的公钥序列化结果求SHA-256，但保留完整摘要作为承诺
+func (hkp *HybridKeyPair) GetNextKeyCommitment() (string, error) {
+	pubKeyData, err := hkp.SerializePublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(pubKeyData)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// ValidateNextKeyCommitment 校验预轮换承诺格式（base64url编码的SHA-256摘要）
+func ValidateNextKeyCommitment(commitment string) error {
+	digest, err := base64.RawURLEncoding.DecodeString(commitment)
+	if err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("无效的预轮换承诺: %s", commitment)
+	}
+	return nil
+}
+
 // GenerateDIDFromKeyPair 从密钥对生成DID
 func GenerateDIDFromKeyPair(keyPair *HybridKeyPair) (string, error) {
 	fingerprint, err := keyPair.GetFingerprint()
@@ -239,75 +391,8 @@ func (hkp *HybridKeyPair) DecapsulateSharedKey(ciphertext []byte) (sharedKey []b
 	return hkp.KyberDecapsulationKey.Decapsulate(ciphertext)
 }
 
-// HybridEncrypt 使用混合加密（ECDSA签名 + Kyber768密钥封装）
-func (hkp *HybridKeyPair) HybridEncrypt(data []byte, recipientPublicKey *HybridKeyPair) (encryptedData []byte, signature *HybridSignature, err error) {
-	// 1. 使用接收方的Kyber768公钥封装共享密钥
-	ciphertext, sharedKey, err := recipientPublicKey.EncapsulateSharedKey()
-	if err != nil {
-		return nil, nil, fmt.Errorf("密钥封装失败: %w", err)
-	}
-
-	// 2. 使用共享密钥加密数据（这里简化处理，实际应该使用AES等对称加密）
-	// 为了演示，这里只是简单的XOR操作
-	encryptedData = make([]byte, len(data)+len(ciphertext))
-	copy(encryptedData[:len(ciphertext)], ciphertext)
-
-	// 使用共享密钥的前几个字节作为XOR密钥
-	keyBytes := sharedKey[:min(len(sharedKey), len(data))]
-	for i := 0; i < len(data); i++ {
-		encryptedData[len(ciphertext)+i] = data[i] ^ keyBytes[i%len(keyBytes)]
-	}
-
-	// 3. 使用发送方的ECDSA私钥对原始数据进行签名
-	signature, err = hkp.Sign(data)
-	if err != nil {
-		return nil, nil, fmt.Errorf("签名失败: %w", err)
-	}
-
-	return encryptedData, signature, nil
-}
-
-// HybridDecrypt 使用混合解密（ECDSA验证 + Kyber768密钥解封装）
-func (hkp *HybridKeyPair) HybridDecrypt(encryptedData []byte, signature *HybridSignature, senderPublicKey *HybridKeyPair) (data []byte, err error) {
-	// 1. 提取密文和加密的数据
-	if len(encryptedData) < mlkem.CiphertextSize768 {
-		return nil, fmt.Errorf("加密数据长度不足")
-	}
-
-	ciphertext := encryptedData[:mlkem.CiphertextSize768]
-	encryptedPayload := encryptedData[mlkem.CiphertextSize768:]
-
-	// 2. 使用自己的Kyber768私钥解封装共享密钥
-	sharedKey, err := hkp.DecapsulateSharedKey(ciphertext)
-	if err != nil {
-		return nil, fmt.Errorf("密钥解封装失败: %w", err)
-	}
-
-	// 3. 使用共享密钥解密数据
-	data = make([]byte, len(encryptedPayload))
-	keyBytes := sharedKey[:min(len(sharedKey), len(encryptedPayload))]
-	for i := 0; i < len(encryptedPayload); i++ {
-		data[i] = encryptedPayload[i] ^ keyBytes[i%len(keyBytes)]
-	}
-
-	// 4. 使用发送方的ECDSA公钥验证签名
-	if !senderPublicKey.Verify(data, signature) {
-		return nil, fmt.Errorf("签名验证失败")
-	}
-
-	return data, nil
-}
-
-// min 辅助函数
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // FromPrivateKeyString 从私钥字符串创建HybridKeyPair
-// 支持多种格式：hex编码、base64编码等
+// 支持多种格式：hex编码、base64编码等；同一字符串总是得到相同的密钥对
 func FromPrivateKeyString(privateKeyStr string) (*HybridKeyPair, error) {
 	// 尝试不同的解码方式
 	var privateKeyBytes []byte
@@ -339,61 +424,17 @@ func FromPrivateKeyString(privateKeyStr string) (*HybridKeyPair, error) {
 }
 
 // createKeyPairFromBytes 从字节数组创建密钥对
+// MarshalPrivateKey 格式的私钥直接解析，其余字节作为种子确定性派生
 func createKeyPairFromBytes(seed []byte) (*HybridKeyPair, error) {
-	// 确保种子长度至少32字节
-	if len(seed) < 32 {
-		// 如果不够32字节，用SHA256扩展
-		hash := sha256.Sum256(seed)
-		seed = hash[:]
+	if len(seed) == privateKeySize || len(seed) == legacyPrivateKeySize {
+		return ParsePrivateKey(seed)
 	}
 
-	// 使用种子创建ECDSA私钥
-	curve := elliptic.P256()
-	// 取前32字节作为私钥
-	privateKeyInt := new(big.Int).SetBytes(seed[:32])
-	
-	// 确保私钥在有效范围内
-	n := curve.Params().N
-	privateKeyInt.Mod(privateKeyInt, n)
-	if privateKeyInt.Sign() == 0 {
-		privateKeyInt.SetInt64(1) // 避免零私钥
-	}
-
-	ecdsaPrivKey := &ecdsa.PrivateKey{
-		PublicKey: ecdsa.PublicKey{
-			Curve: curve,
-		},
-		D: privateKeyInt,
-	}
-
-	// 计算公钥
-	ecdsaPrivKey.PublicKey.X, ecdsaPrivKey.PublicKey.Y = curve.ScalarBaseMult(privateKeyInt.Bytes())
-
-	// 为Kyber768生成密钥对（使用种子的另一部分）
-	// 注意：这是简化实现，实际应用中可能需要更复杂的种子处理
-	kyberSeed := make([]byte, 64)
-	copy(kyberSeed[:32], seed[:32])
-	if len(seed) >= 64 {
-		copy(kyberSeed[32:], seed[32:64])
-	} else {
-		// 如果种子不够长，使用哈希扩展
-		hash := sha256.Sum256(append(seed, []byte("kyber")...))
-		copy(kyberSeed[32:], hash[:])
-	}
-
-	// 使用确定性方式生成Kyber密钥（这里简化处理）
-	// 实际应用中应该使用更安全的方法
-	kyberDecapsKey, err := mlkem.GenerateKey768()
-	if err != nil {
-		return nil, fmt.Errorf("生成Kyber768密钥失败: %w", err)
+	// 种子不足32字节时用SHA256扩展
+	if len(seed) < MinSeedSize {
+		hash := sha256.Sum256(seed)
+		seed = hash[:]
 	}
 
-	kyberEncapsKey := kyberDecapsKey.EncapsulationKey()
-
-	return &HybridKeyPair{
-		ECDSAPrivateKey:       ecdsaPrivKey,
-		ECDSAPublicKey:        &ecdsaPrivKey.PublicKey,
-		KyberDecapsulationKey: kyberDecapsKey,
-		KyberEncapsulationKey: kyberEncapsKey,
-	}, nil
+	return DeriveHybridKeyPair(seed)
 }
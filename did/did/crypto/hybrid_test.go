@@ -83,20 +83,20 @@ func TestHybridEncryptDecrypt(t *testing.T) {
 	originalData := []byte("这是一个测试消息，用于验证Kyber768混合加密功能")
 
 	// 加密
-	encryptedData, signature, err := senderKeyPair.HybridEncrypt(originalData, recipientKeyPair)
+	envelope, err := senderKeyPair.HybridEncrypt(originalData, "did:qlink:sender", "did:qlink:recipient", recipientKeyPair)
 	if err != nil {
 		t.Fatalf("混合加密失败: %v", err)
 	}
 
-	if len(encryptedData) == 0 {
+	if envelope.Ciphertext == "" {
 		t.Error("加密数据为空")
 	}
-	if signature == nil {
+	if envelope.Signature == nil {
 		t.Error("签名为空")
 	}
 
 	// 解密
-	decryptedData, err := recipientKeyPair.HybridDecrypt(encryptedData, signature, senderKeyPair)
+	decryptedData, err := recipientKeyPair.HybridDecrypt(envelope, "did:qlink:sender", "did:qlink:recipient", senderKeyPair)
 	if err != nil {
 		t.Fatalf("混合解密失败: %v", err)
 	}
@@ -178,3 +178,39 @@ func TestDIDGenerationWithKyber768(t *testing.T) {
 
 	t.Logf("生成的DID: %s", did)
 }
+
+func TestMarshalPrivateKeyRoundTrip(t *testing.T) {
+	keyPair, err := GenerateHybridKeyPair()
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+
+	data, err := keyPair.MarshalPrivateKey()
+	if err != nil {
+		t.Fatalf("序列化私钥失败: %v", err)
+	}
+	restored, err := ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("解析私钥失败: %v", err)
+	}
+
+	want, _ := keyPair.GetFingerprint()
+	got, _ := restored.GetFingerprint()
+	if want != got {
+		t.Errorf("恢复的公钥指纹不一致: 期望 %s, 实际 %s", want, got)
+	}
+
+	// 恢复的密钥对可以解封装原密钥对封装的共享密钥
+	ciphertext, sharedKey, err := keyPair.EncapsulateSharedKey()
+	if err != nil {
+		t.Fatalf("封装失败: %v", err)
+	}
+	decapsulated, err := restored.DecapsulateSharedKey(ciphertext)
+	if err != nil || string(decapsulated) != string(sharedKey) {
+		t.Error("恢复的密钥对无法解封装共享密钥")
+	}
+
+	if _, err := ParsePrivateKey(data[:40]); err == nil {
+		t.Error("长度无效的私钥应被拒绝")
+	}
+}
@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// JWSHeader 紧凑JWS的头部
+type JWSHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// SignJWS 生成载荷内嵌的紧凑JWS（header.payload.signature）
+// alg 由密钥对决定：组合密钥为 CompositeAlgorithm，否则为ES256
+func (hkp *HybridKeyPair) SignJWS(kid, typ string, payload []byte) (string, error) {
+	header, err := json.Marshal(&JWSHeader{Alg: hkp.jwsAlgorithm(), Typ: typ, Kid: kid})
+	if err != nil {
+		return "", fmt.Errorf("序列化JWS头部失败: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := hkp.signInput([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ParseJWS 解析紧凑JWS的头部和载荷，不验证签名
+func ParseJWS(compact string) (*JWSHeader, []byte, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 3 {
+		return nil, nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWS_FORMAT", "无效的JWS格式，应为header.payload.signature")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER", "无效的JWS头部", err)
+	}
+	var header JWSHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER_JSON", "JWS头部JSON解析失败", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_PAYLOAD", "无效的JWS载荷", err)
+	}
+	return &header, payload, nil
+}
+
+// VerifyJWS 用验证方法验证紧凑JWS并返回载荷
+// 算法必须属于已启用的套件，包含ML-DSA公钥的验证方法只接受组合签名
+func (sv *SignatureVerifier) VerifyJWS(compact string, verificationMethod *types.VerificationMethod) ([]byte, error) {
+	if verificationMethod == nil {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "VERIFICATION_METHOD_REQUIRED", "验证方法不能为空")
+	}
+
+	header, payload, err := ParseJWS(compact)
+	if err != nil {
+		return nil, err
+	}
+
+	proofType := "JsonWebSignature2020"
+	if header.Alg == CompositeAlgorithm {
+		proofType = CompositeProofType
+	}
+	if err := sv.checkSuite(proofType, header.Alg); err != nil {
+		return nil, err
+	}
+
+	i := strings.LastIndex(compact, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(compact[i+1:])
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_SIGNATURE", "无效的JWS签名", err)
+	}
+
+	if err := sv.verifyAlgorithmSignature(header.Alg, []byte(compact[:i]), signature, verificationMethod); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
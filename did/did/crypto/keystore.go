@@ -0,0 +1,423 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeystoreVersion 密钥库文件格式版本
+const KeystoreVersion = 1
+
+// 密钥派生函数
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+// keystoreCipher 私钥加密算法，DID、密钥ID和指纹作为附加数据参与认证
+const keystoreCipher = "aes-256-gcm"
+
+// keystoreKeySize AES-256密钥长度
+const keystoreKeySize = 32
+
+// ErrKeystoreDecrypt 口令错误或密钥文件被篡改
+var ErrKeystoreDecrypt = errors.New("无法解密密钥：口令错误或密钥文件已损坏")
+
+// ErrKeyNotFound 密钥库中没有对应DID的密钥
+var ErrKeyNotFound = errors.New("密钥库中未找到密钥")
+
+// KeystoreOptions 密钥派生参数
+type KeystoreOptions struct {
+	KDF string
+
+	// scrypt参数
+	ScryptN int
+	ScryptR int
+	ScryptP int
+
+	// Argon2id参数，Memory单位为KiB
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+var (
+	// StandardKeystoreOptions 默认参数，与以太坊keystore v3的标准scrypt参数一致
+	StandardKeystoreOptions = KeystoreOptions{KDF: KDFScrypt, ScryptN: 1 << 18, ScryptR: 8, ScryptP: 1}
+
+	// LightKeystoreOptions 低内存设备和测试使用的scrypt参数
+	LightKeystoreOptions = KeystoreOptions{KDF: KDFScrypt, ScryptN: 1 << 12, ScryptR: 8, ScryptP: 6}
+
+	// Argon2idKeystoreOptions RFC 9106推荐的Argon2id参数（64 MiB内存）
+	Argon2idKeystoreOptions = KeystoreOptions{KDF: KDFArgon2id, Argon2Time: 3, Argon2Memory: 64 * 1024, Argon2Threads: 4}
+)
+
+// EncryptedKey 加密存储的混合私钥
+type EncryptedKey struct {
+	Version     int            `json:"version"`
+	ID          string         `json:"id"`
+	DID         string         `json:"did"`
+	KeyID       string         `json:"keyId"`
+	Fingerprint string         `json:"fingerprint"`
+	Created     time.Time      `json:"created"`
+	Crypto      KeystoreCrypto `json:"crypto"`
+}
+
+// KeystoreCrypto 加密参数和密文
+type KeystoreCrypto struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams CipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    KDFParams    `json:"kdfparams"`
+}
+
+// CipherParams AES-GCM参数
+type CipherParams struct {
+	Nonce string `json:"nonce"`
+}
+
+// KDFParams 密钥派生参数，按KDF只填写对应字段
+type KDFParams struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// EncryptKey 用口令加密混合私钥（ECDSA私钥和ML-KEM-768种子），返回可直接序列化保存的密钥
+func EncryptKey(keyPair *HybridKeyPair, did, keyID, passphrase string, opts KeystoreOptions) (*EncryptedKey, error) {
+	privateKey, err := keyPair.MarshalPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := keyPair.GetFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成盐失败: %w", err)
+	}
+
+	params := KDFParams{DKLen: keystoreKeySize, Salt: hex.EncodeToString(salt)}
+	switch opts.KDF {
+	case KDFScrypt:
+		params.N, params.R, params.P = opts.ScryptN, opts.ScryptR, opts.ScryptP
+	case KDFArgon2id:
+		params.Time, params.Memory, params.Threads = opts.Argon2Time, opts.Argon2Memory, opts.Argon2Threads
+	default:
+		return nil, fmt.Errorf("不支持的密钥派生函数: %s", opts.KDF)
+	}
+
+	derivedKey, err := deriveKeystoreKey(opts.KDF, params, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("生成密钥标识失败: %w", err)
+	}
+
+	key := &EncryptedKey{
+		Version:     KeystoreVersion,
+		ID:          hex.EncodeToString(id),
+		DID:         did,
+		KeyID:       keyID,
+		Fingerprint: fingerprint,
+		Created:     time.Now().UTC(),
+		Crypto: KeystoreCrypto{
+			Cipher:    keystoreCipher,
+			KDF:       opts.KDF,
+			KDFParams: params,
+		},
+	}
+
+	gcm, err := newKeystoreGCM(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+
+	key.Crypto.CipherParams.Nonce = hex.EncodeToString(nonce)
+	key.Crypto.CipherText = hex.EncodeToString(gcm.Seal(nil, nonce, privateKey, key.additionalData()))
+	return key, nil
+}
+
+// DecryptKey 用口令解密混合私钥，并校验解出的密钥与记录的指纹一致
+func DecryptKey(key *EncryptedKey, passphrase string) (*HybridKeyPair, error) {
+	if key.Version != KeystoreVersion {
+		return nil, fmt.Errorf("不支持的密钥库版本: %d", key.Version)
+	}
+	if key.Crypto.Cipher != keystoreCipher {
+		return nil, fmt.Errorf("不支持的加密算法: %s", key.Crypto.Cipher)
+	}
+
+	nonce, err := hex.DecodeString(key.Crypto.CipherParams.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("无效的随机数: %w", err)
+	}
+	cipherText, err := hex.DecodeString(key.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("无效的密文: %w", err)
+	}
+
+	derivedKey, err := deriveKeystoreKey(key.Crypto.KDF, key.Crypto.KDFParams, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newKeystoreGCM(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("随机数长度无效: %d", len(nonce))
+	}
+
+	privateKey, err := gcm.Open(nil, nonce, cipherText, key.additionalData())
+	if err != nil {
+		return nil, ErrKeystoreDecrypt
+	}
+
+	keyPair, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := keyPair.GetFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(key.Fingerprint)) != 1 {
+		return nil, ErrKeystoreDecrypt
+	}
+	return keyPair, nil
+}
+
+// additionalData 将元数据绑定到密文，修改DID、密钥ID或指纹都会导致解密失败
+func (k *EncryptedKey) additionalData() []byte {
+	return []byte(strings.Join([]string{"qlink-keystore", fmt.Sprint(k.Version), k.DID, k.KeyID, k.Fingerprint}, "\x00"))
+}
+
+// deriveKeystoreKey 由口令派生AES密钥
+func deriveKeystoreKey(kdf string, params KDFParams, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("无效的盐")
+	}
+	if params.DKLen != keystoreKeySize {
+		return nil, fmt.Errorf("派生密钥长度无效: %d", params.DKLen)
+	}
+
+	switch kdf {
+	case KDFScrypt:
+		key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("scrypt派生失败: %w", err)
+		}
+		return key, nil
+	case KDFArgon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, fmt.Errorf("无效的Argon2id参数")
+		}
+		return argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, uint32(params.DKLen)), nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥派生函数: %s", kdf)
+	}
+}
+
+// newKeystoreGCM 创建AES-256-GCM
+func newKeystoreGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keystore 基于目录的加密密钥库，每个密钥保存为一个JSON文件
+type Keystore struct {
+	dir     string
+	options KeystoreOptions
+}
+
+// NewKeystore 创建密钥库，目录在首次写入时创建
+func NewKeystore(dir string, options KeystoreOptions) *Keystore {
+	return &Keystore{dir: dir, options: options}
+}
+
+// Dir 返回密钥库目录
+func (ks *Keystore) Dir() string {
+	return ks.dir
+}
+
+// Store 用口令加密密钥对并写入密钥库，同一DID和密钥ID的旧文件会被覆盖
+func (ks *Keystore) Store(keyPair *HybridKeyPair, did, keyID, passphrase string) (*EncryptedKey, error) {
+	key, err := EncryptKey(keyPair, did, keyID, passphrase, ks.options)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Import(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Import 写入已加密的密钥
+func (ks *Keystore) Import(key *EncryptedKey) error {
+	data, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化密钥失败: %w", err)
+	}
+
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return fmt.Errorf("创建密钥库目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中途失败留下损坏的密钥文件
+	path := filepath.Join(ks.dir, keystoreFileName(key.DID, key.KeyID))
+	tmp, err := os.CreateTemp(ks.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("写入密钥失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入密钥失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入密钥失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List 列出密钥库中的全部密钥，按创建时间排序；无法解析的文件会被跳过
+func (ks *Keystore) List() ([]*EncryptedKey, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥库失败: %w", err)
+	}
+
+	var keys []*EncryptedKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		key, err := ReadEncryptedKey(filepath.Join(ks.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.Before(keys[j].Created)
+	})
+	return keys, nil
+}
+
+// Find 返回DID最近保存的密钥，密钥轮换后即为当前密钥
+func (ks *Keystore) Find(did string) (*EncryptedKey, error) {
+	keys, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].DID == did {
+			return keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, did)
+}
+
+// Unlock 用口令解密DID的当前密钥
+func (ks *Keystore) Unlock(did, passphrase string) (*HybridKeyPair, *EncryptedKey, error) {
+	key, err := ks.Find(did)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPair, err := DecryptKey(key, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPair, key, nil
+}
+
+// Delete 删除DID的全部密钥
+func (ks *Keystore) Delete(did string) error {
+	keys, err := ks.List()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, key := range keys {
+		if key.DID != did {
+			continue
+		}
+		found = true
+		if err := os.Remove(filepath.Join(ks.dir, keystoreFileName(key.DID, key.KeyID))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除密钥失败: %w", err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, did)
+	}
+	return nil
+}
+
+// ReadEncryptedKey 读取加密密钥文件
+func ReadEncryptedKey(path string) (*EncryptedKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key EncryptedKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	if key.Version != KeystoreVersion || key.DID == "" {
+		return nil, fmt.Errorf("不是有效的密钥库文件: %s", path)
+	}
+	return &key, nil
+}
+
+// keystoreFileName 由DID和密钥ID生成文件名，替换文件系统不允许的字符
+func keystoreFileName(did, keyID string) string {
+	name := did
+	if keyID != "" {
+		name += "#" + strings.TrimPrefix(strings.TrimPrefix(keyID, did), "#")
+	}
+	return strings.NewReplacer(":", "_", "#", "--", "/", "_", "\\", "_").Replace(name) + ".json"
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptKeyRoundTrip(t *testing.T) {
+	keyPair, _ := GenerateHybridKeyPair()
+	did, _ := GenerateDIDFromKeyPair(keyPair)
+
+	options := []KeystoreOptions{
+		LightKeystoreOptions,
+		{KDF: KDFArgon2id, Argon2Time: 1, Argon2Memory: 8 * 1024, Argon2Threads: 1},
+	}
+	for _, opts := range options {
+		key, err := EncryptKey(keyPair, did, "#key-1", "correct horse", opts)
+		if err != nil {
+			t.Fatalf("%s 加密失败: %v", opts.KDF, err)
+		}
+
+		decrypted, err := DecryptKey(key, "correct horse")
+		if err != nil {
+			t.Fatalf("%s 解密失败: %v", opts.KDF, err)
+		}
+		original, _ := keyPair.MarshalPrivateKey()
+		restored, _ := decrypted.MarshalPrivateKey()
+		if string(original) != string(restored) {
+			t.Errorf("%s 解密的私钥不一致", opts.KDF)
+		}
+
+		if _, err := DecryptKey(key, "wrong"); !errors.Is(err, ErrKeystoreDecrypt) {
+			t.Errorf("%s 错误口令应解密失败: %v", opts.KDF, err)
+		}
+
+		// 元数据与密文绑定
+		tampered := *key
+		tampered.DID = "did:qlink:attacker"
+		if _, err := DecryptKey(&tampered, "correct horse"); !errors.Is(err, ErrKeystoreDecrypt) {
+			t.Errorf("%s 篡改DID后应解密失败: %v", opts.KDF, err)
+		}
+	}
+}
+
+func TestKeystoreFindsCurrentKey(t *testing.T) {
+	ks := NewKeystore(t.TempDir(), LightKeystoreOptions)
+
+	first, _ := GenerateHybridKeyPair()
+	did, _ := GenerateDIDFromKeyPair(first)
+	if _, err := ks.Store(first, did, "#key-1", "secret"); err != nil {
+		t.Fatalf("保存密钥失败: %v", err)
+	}
+
+	// 轮换后按DID加载最近保存的密钥
+	rotated, _ := GenerateHybridKeyPair()
+	if _, err := ks.Store(rotated, did, "#key-2", "secret"); err != nil {
+		t.Fatalf("保存密钥失败: %v", err)
+	}
+
+	keys, err := ks.List()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("应列出两把密钥: %v, %v", keys, err)
+	}
+
+	keyPair, key, err := ks.Unlock(did, "secret")
+	if err != nil {
+		t.Fatalf("解锁失败: %v", err)
+	}
+	want, _ := rotated.GetFingerprint()
+	got, _ := keyPair.GetFingerprint()
+	if key.KeyID != "#key-2" || got != want {
+		t.Errorf("应解锁轮换后的密钥: %s %s", key.KeyID, got)
+	}
+
+	if err := ks.Delete(did); err != nil {
+		t.Fatalf("删除密钥失败: %v", err)
+	}
+	if _, _, err := ks.Unlock(did, "secret"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("删除后不应找到密钥: %v", err)
+	}
+}
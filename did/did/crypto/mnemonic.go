@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/mldsa"
+	"crypto/mlkem"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// wordlistEnglish BIP-39 英文词表（2048个词，SHA-256: 2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda）
+//
+//go:embed wordlist_english.txt
+var wordlistEnglish string
+
+var (
+	mnemonicWords = strings.Fields(wordlistEnglish)
+	mnemonicIndex = func() map[string]int {
+		index := make(map[string]int, len(mnemonicWords))
+		for i, word := range mnemonicWords {
+			index[word] = i
+		}
+		return index
+	}()
+)
+
+// 确定性派生参数，修改会改变由同一种子恢复出的身份
+const (
+	hybridKeySalt      = "qlink-hybrid-key-v1"
+	hybridKeyInfoECDSA = "ecdsa-p256"
+	hybridKeyInfoKyber = "ml-kem-768"
+	hybridKeyInfoMLDSA = "ml-dsa-65"
+
+	// MinSeedSize 确定性派生所需的最小种子长度
+	MinSeedSize = 32
+)
+
+// DeriveHybridKeyPair 从种子确定性派生混合密钥对
+// 种子经HKDF-SHA256按用途分离：ECDSA私钥取40字节后约减到 [1, n-1]，ML-KEM-768使用64字节种子，
+// ML-DSA-65使用32字节种子，同一种子总是得到相同的密钥对和DID
+func DeriveHybridKeyPair(seed []byte) (*HybridKeyPair, error) {
+	if len(seed) < MinSeedSize {
+		return nil, fmt.Errorf("种子长度不足: 至少 %d 字节, 实际 %d", MinSeedSize, len(seed))
+	}
+
+	ecdsaSeed, err := hkdf.Key(sha256.New, seed, []byte(hybridKeySalt), hybridKeyInfoECDSA, 40)
+	if err != nil {
+		return nil, fmt.Errorf("派生ECDSA种子失败: %w", err)
+	}
+	kyberSeed, err := hkdf.Key(sha256.New, seed, []byte(hybridKeySalt), hybridKeyInfoKyber, mlkem.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("派生Kyber768种子失败: %w", err)
+	}
+	mldsaSeed, err := hkdf.Key(sha256.New, seed, []byte(hybridKeySalt), hybridKeyInfoMLDSA, mldsa.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("派生ML-DSA-65种子失败: %w", err)
+	}
+
+	// d = seed mod (n-1) + 1，多取的64位使结果的偏差可以忽略
+	curve := elliptic.P256()
+	nMinusOne := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(ecdsaSeed)
+	d.Mod(d, nMinusOne)
+	d.Add(d, big.NewInt(1))
+
+	ecdsaPrivKey := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	ecdsaPrivKey.PublicKey.X, ecdsaPrivKey.PublicKey.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+
+	kyberDecapsKey, err := mlkem.NewDecapsulationKey768(kyberSeed)
+	if err != nil {
+		return nil, fmt.Errorf("创建Kyber768密钥失败: %w", err)
+	}
+
+	mldsaPrivKey, err := mldsa.NewPrivateKey(mldsa.MLDSA65(), mldsaSeed)
+	if err != nil {
+		return nil, fmt.Errorf("创建ML-DSA-65密钥失败: %w", err)
+	}
+
+	return &HybridKeyPair{
+		ECDSAPrivateKey:       ecdsaPrivKey,
+		ECDSAPublicKey:        &ecdsaPrivKey.PublicKey,
+		KyberDecapsulationKey: kyberDecapsKey,
+		KyberEncapsulationKey: kyberDecapsKey.EncapsulationKey(),
+		MLDSAPrivateKey:       mldsaPrivKey,
+		MLDSAPublicKey:        mldsaPrivKey.PublicKey(),
+	}, nil
+}
+
+// NewMnemonic 生成随机的BIP-39助记词
+// entropyBits 为128到256之间32的倍数，对应12到24个词
+func NewMnemonic(entropyBits int) (string, error) {
+	if entropyBits < 128 || entropyBits > 256 || entropyBits%32 != 0 {
+		return "", fmt.Errorf("无效的熵长度: %d", entropyBits)
+	}
+
+	entropy := make([]byte, entropyBits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", fmt.Errorf("生成熵失败: %w", err)
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic 将熵编码为BIP-39助记词：熵后附SHA-256校验位，每11位对应一个词
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("无效的熵长度: %d", bits)
+	}
+
+	checksumBits := bits / 32
+	hash := sha256.Sum256(entropy)
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, uint(checksumBits))
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (bits + checksumBits) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy 解码BIP-39助记词并校验校验位
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("助记词词数无效: %d", len(words))
+	}
+
+	value := new(big.Int)
+	for _, word := range words {
+		index, ok := mnemonicIndex[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("助记词包含未知的词: %s", word)
+		}
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * 11 / 33
+	checksum := new(big.Int).And(value, big.NewInt(int64(1<<checksumBits-1))).Int64()
+	value.Rsh(value, uint(checksumBits))
+	entropy := value.FillBytes(make([]byte, checksumBits*4))
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, fmt.Errorf("助记词校验失败")
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic 校验助记词的词表和校验位
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed 按BIP-39由助记词和可选口令计算64字节种子
+// PBKDF2-HMAC-SHA512，2048次迭代，盐为 "mnemonic"+口令，两者均先做NFKD规范化
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(norm.NFKD.String(mnemonic))), " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key(sha512.New, normalized, []byte(salt), 2048, 64)
+}
+
+// HybridKeyPairFromMnemonic 由助记词和可选口令恢复混合密钥对
+func HybridKeyPairFromMnemonic(mnemonic, passphrase string) (*HybridKeyPair, error) {
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return DeriveHybridKeyPair(seed)
+}
+
+// GenerateHybridKeyPairWithMnemonic 生成24词助记词及其对应的混合密钥对
+// 助记词是恢复身份的唯一凭据，只在生成时返回一次
+func GenerateHybridKeyPairWithMnemonic(passphrase string) (*HybridKeyPair, string, error) {
+	mnemonic, err := NewMnemonic(256)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyPair, err := HybridKeyPairFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return keyPair, mnemonic, nil
+}
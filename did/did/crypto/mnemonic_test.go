@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMnemonicVectors(t *testing.T) {
+	// BIP-39 官方测试向量（口令 TREZOR）
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+	}
+
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		if err != nil || mnemonic != v.mnemonic {
+			t.Errorf("助记词编码不正确: %s, %v", mnemonic, err)
+		}
+
+		decoded, err := MnemonicToEntropy(v.mnemonic)
+		if err != nil || !bytes.Equal(decoded, entropy) {
+			t.Errorf("助记词解码不正确: %x, %v", decoded, err)
+		}
+
+		seed, err := MnemonicToSeed(v.mnemonic, "TREZOR")
+		if err != nil || hex.EncodeToString(seed) != v.seed {
+			t.Errorf("种子不正确: %x, %v", seed, err)
+		}
+	}
+
+	invalid := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon qlink",
+		"abandon abandon about",
+	}
+	for _, mnemonic := range invalid {
+		if err := ValidateMnemonic(mnemonic); err == nil {
+			t.Errorf("无效的助记词应被拒绝: %s", mnemonic)
+		}
+	}
+}
+
+func TestHybridKeyPairFromMnemonicIsDeterministic(t *testing.T) {
+	keyPair, mnemonic, err := GenerateHybridKeyPairWithMnemonic("")
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+	if len(strings.Fields(mnemonic)) != 24 {
+		t.Fatalf("应生成24个词: %s", mnemonic)
+	}
+
+	// 在新设备上用同一助记词恢复出相同的DID
+	restored, err := HybridKeyPairFromMnemonic(mnemonic, "")
+	if err != nil {
+		t.Fatalf("恢复密钥对失败: %v", err)
+	}
+	want, _ := GenerateDIDFromKeyPair(keyPair)
+	got, _ := GenerateDIDFromKeyPair(restored)
+	if want != got {
+		t.Errorf("恢复的DID不一致: 期望 %s, 实际 %s", want, got)
+	}
+
+	// 恢复的密钥对可以解封装原密钥对封装的共享密钥
+	ciphertext, sharedKey, _ := keyPair.EncapsulateSharedKey()
+	if decapsulated, err := restored.DecapsulateSharedKey(ciphertext); err != nil || !bytes.Equal(decapsulated, sharedKey) {
+		t.Error("恢复的Kyber768私钥不一致")
+	}
+
+	// 口令不同得到不同的身份
+	other, _ := HybridKeyPairFromMnemonic(mnemonic, "passphrase")
+	if did, _ := GenerateDIDFromKeyPair(other); did == want {
+		t.Error("不同口令应派生不同的密钥对")
+	}
+}
+
+func TestFromPrivateKeyStringIsDeterministic(t *testing.T) {
+	const privateKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+	first, err := FromPrivateKeyString(privateKey)
+	if err != nil {
+		t.Fatalf("解析私钥失败: %v", err)
+	}
+	second, _ := FromPrivateKeyString(privateKey)
+
+	a, _ := GenerateDIDFromKeyPair(first)
+	b, _ := GenerateDIDFromKeyPair(second)
+	if a != b {
+		t.Errorf("同一私钥字符串应得到相同的DID: %s, %s", a, b)
+	}
+
+	// MarshalPrivateKey 的导出结果可以直接导入
+	exported, _ := first.MarshalPrivateKey()
+	imported, err := FromPrivateKeyString(hex.EncodeToString(exported))
+	if err != nil {
+		t.Fatalf("导入私钥失败: %v", err)
+	}
+	if did, _ := GenerateDIDFromKeyPair(imported); did != a {
+		t.Errorf("导入的私钥DID不一致: %s", did)
+	}
+
+	if _, err := DeriveHybridKeyPair(make([]byte, 16)); err == nil {
+		t.Error("过短的种子应被拒绝")
+	}
+}
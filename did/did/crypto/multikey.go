@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"crypto/mldsa"
+	"crypto/mlkem"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Multicodec 公钥类型编码（https://github.com/multiformats/multicodec）
+const (
+	MulticodecEd25519Pub  uint64 = 0xed
+	MulticodecX25519Pub   uint64 = 0xec
+	MulticodecP256Pub     uint64 = 0x1200
+	MulticodecMLKEM768Pub uint64 = 0x120c
+	MulticodecMLDSA65Pub  uint64 = 0x1211
+)
+
+// multicodecKeySizes 各类公钥的字节长度（P-256为压缩格式）
+var multicodecKeySizes = map[uint64]int{
+	MulticodecEd25519Pub:  32,
+	MulticodecX25519Pub:   32,
+	MulticodecP256Pub:     33,
+	MulticodecMLKEM768Pub: mlkem.EncapsulationKeySize768,
+	MulticodecMLDSA65Pub:  mldsa.MLDSA65PublicKeySize,
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// EncodeMultikey 将公钥编码为Multikey格式：'z' + base58btc(varint(codec) || key)
+func EncodeMultikey(codec uint64, key []byte) string {
+	prefix := binary.AppendUvarint(nil, codec)
+	return "z" + EncodeBase58(append(prefix, key...))
+}
+
+// DecodeMultikey 解码Multikey格式的公钥，返回multicodec类型和原始公钥
+func DecodeMultikey(value string) (uint64, []byte, error) {
+	if !strings.HasPrefix(value, "z") {
+		return 0, nil, fmt.Errorf("只支持base58btc编码的multibase: %s", value)
+	}
+
+	data, err := DecodeBase58(value[1:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	codec, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("无效的multicodec前缀")
+	}
+	key := data[n:]
+
+	size, ok := multicodecKeySizes[codec]
+	if !ok {
+		return 0, nil, fmt.Errorf("不支持的multicodec类型: 0x%x", codec)
+	}
+	if len(key) != size {
+		return 0, nil, fmt.Errorf("公钥长度无效: 期望 %d, 实际 %d", size, len(key))
+	}
+
+	return codec, key, nil
+}
+
+// EncodeBase58 base58btc编码
+func EncodeBase58(data []byte) string {
+	// 前导零字节编码为'1'
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	num := new(big.Int).SetBytes(data)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for num.Sign() > 0 {
+		num.DivMod(num, base, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeros; i++ {
+		encoded = append(encoded, '1')
+	}
+
+	// 反转
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// DecodeBase58 base58btc解码
+func DecodeBase58(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("base58字符串为空")
+	}
+
+	num := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range s {
+		index := strings.IndexRune(base58Alphabet, c)
+		if index < 0 {
+			return nil, fmt.Errorf("无效的base58字符: %q", c)
+		}
+		num.Mul(num, base)
+		num.Add(num, big.NewInt(int64(index)))
+	}
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), num.Bytes()...), nil
+}
@@ -4,24 +4,48 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/mldsa"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/qujing226/QLink/pkg/types"
 	"github.com/qujing226/QLink/pkg/utils"
 )
 
+const (
+	// ProofValidityPeriod 证明的有效期
+	ProofValidityPeriod = 24 * time.Hour
+	// ProofClockSkew 允许的证明时间偏差
+	ProofClockSkew = 5 * time.Minute
+	// CompositeProofType 组合签名证明类型，proofValue为 CompositeAlgorithm 的分离式JWS
+	CompositeProofType = "CompositeSignature2025"
+)
+
 // SignatureVerifier 签名验证器
-type SignatureVerifier struct{}
+type SignatureVerifier struct {
+	mu         sync.Mutex
+	usedNonces map[string]time.Time // 已使用的nonce -> 失效时间，用于防重放
+	suites     *SuiteRegistry       // 只接受已启用套件的证明
+}
 
-// NewSignatureVerifier 创建签名验证器实例
+// NewSignatureVerifier 创建使用默认算法套件的签名验证器实例
 func NewSignatureVerifier() *SignatureVerifier {
-	return &SignatureVerifier{}
+	return NewSignatureVerifierWithSuites(DefaultSuites)
+}
+
+// NewSignatureVerifierWithSuites 创建只接受指定注册表中已启用套件的签名验证器
+func NewSignatureVerifierWithSuites(suites *SuiteRegistry) *SignatureVerifier {
+	return &SignatureVerifier{
+		usedNonces: make(map[string]time.Time),
+		suites:     suites,
+	}
 }
 
 // VerifyProof 验证DID文档的证明
@@ -35,7 +59,8 @@ func (sv *SignatureVerifier) VerifyProof(document interface{}, proof *types.Proo
 	}
 
 	// 检查证明类型
-	if proof.Type != "Ed25519Signature2020" && proof.Type != "JsonWebSignature2020" {
+	if proof.Type != "Ed25519Signature2020" && proof.Type != "JsonWebSignature2020" && proof.Type != CompositeProofType &&
+		proof.Type != DataIntegrityProofType {
 		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_PROOF_TYPE",
 			"不支持的证明类型", proof.Type)
 	}
@@ -45,18 +70,60 @@ func (sv *SignatureVerifier) VerifyProof(document interface{}, proof *types.Proo
 		return err
 	}
 
+	// 退役的密钥不能再签发新的证明
+	if verificationMethod.Revoked != nil {
+		return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "KEY_REVOKED",
+			"验证方法已退役", verificationMethod.ID)
+	}
+
 	// 验证签名
+	if err := sv.VerifyProofSignature(document, proof, verificationMethod); err != nil {
+		return err
+	}
+
+	// 签名有效后才记录nonce，避免伪造的证明占用合法nonce
+	return sv.consumeNonce(proof)
+}
+
+// VerifyProofSignature 只验证证明的签名，不检查时间和nonce
+// 用于验证长期有效的文档证明（如注册时提交的自签名文档）
+func (sv *SignatureVerifier) VerifyProofSignature(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
+	if proof == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "证明不能为空")
+	}
+
+	if verificationMethod == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "VERIFICATION_METHOD_REQUIRED", "验证方法不能为空")
+	}
+
 	switch proof.Type {
 	case "Ed25519Signature2020":
 		return sv.verifyEd25519Signature(document, proof, verificationMethod)
 	case "JsonWebSignature2020":
 		return sv.verifyJWSSignature(document, proof, verificationMethod)
+	case CompositeProofType:
+		return sv.verifyCompositeSignature(document, proof, verificationMethod)
+	case DataIntegrityProofType:
+		return sv.verifyDataIntegrityProof(document, proof, verificationMethod)
 	default:
 		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_PROOF_TYPE",
 			"不支持的证明类型", proof.Type)
 	}
 }
 
+// VerifyHistoricalProof 验证历史证明，允许使用已退役的验证方法
+// 证明必须创建于验证方法退役之前，不检查有效期和nonce
+func (sv *SignatureVerifier) VerifyHistoricalProof(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
+	if proof != nil && verificationMethod != nil && verificationMethod.Revoked != nil &&
+		!proof.Created.Before(*verificationMethod.Revoked) {
+		return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "KEY_REVOKED",
+			"证明创建于验证方法退役之后", fmt.Sprintf("%s 退役于 %s", verificationMethod.ID,
+				verificationMethod.Revoked.Format(time.RFC3339)))
+	}
+
+	return sv.VerifyProofSignature(document, proof, verificationMethod)
+}
+
 // VerifyController 验证控制者权限
 func (sv *SignatureVerifier) VerifyController(didStr string, verificationMethod *types.VerificationMethod) error {
 	if verificationMethod == nil {
@@ -73,73 +140,149 @@ func (sv *SignatureVerifier) VerifyController(didStr string, verificationMethod
 }
 
 // VerifyUpdatePermission 验证更新权限
-func (sv *SignatureVerifier) VerifyUpdatePermission(didStr string, proof *types.Proof, verificationMethods []types.VerificationMethod) error {
+// 证明必须由当前文档中的验证方法签发，且该方法需在证明目的对应的验证关系中
+func (sv *SignatureVerifier) VerifyUpdatePermission(document *types.DIDDocument, payload interface{}, proof *types.Proof) error {
 	if proof == nil {
 		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "更新操作需要提供证明")
 	}
 
-	// 查找对应的验证方法
-	var verificationMethod *types.VerificationMethod
-	for _, vm := range verificationMethods {
-		if vm.ID == proof.VerificationMethod {
-			verificationMethod = &vm
-			break
-		}
+	// 验证证明目的
+	if proof.ProofPurpose != "assertionMethod" && proof.ProofPurpose != "authentication" &&
+		proof.ProofPurpose != "capabilityInvocation" {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
+			"无效的证明目的", proof.ProofPurpose)
 	}
 
-	if verificationMethod == nil {
-		return utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND",
-			"验证方法不存在", proof.VerificationMethod)
+	return sv.verifyOperationProof(document, payload, proof)
+}
+
+// VerifyRevokePermission 验证撤销权限
+func (sv *SignatureVerifier) VerifyRevokePermission(document *types.DIDDocument, payload interface{}, proof *types.Proof) error {
+	if proof == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "撤销操作需要提供证明")
 	}
 
-	// 验证控制者权限
-	if err := sv.VerifyController(didStr, verificationMethod); err != nil {
-		return err
+	// 验证证明目的（撤销需要更高权限）
+	if proof.ProofPurpose != "authentication" && proof.ProofPurpose != "capabilityInvocation" {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
+			"撤销操作需要authentication或capabilityInvocation权限", proof.ProofPurpose)
 	}
 
-	// 验证证明目的
-	if proof.ProofPurpose != "assertionMethod" && proof.ProofPurpose != "authentication" {
+	return sv.verifyOperationProof(document, payload, proof)
+}
+
+// VerifyRotatePermission 验证密钥轮换权限，轮换必须由当前的authentication或capabilityInvocation密钥签发
+func (sv *SignatureVerifier) VerifyRotatePermission(document *types.DIDDocument, payload interface{}, proof *types.Proof) error {
+	if proof == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "密钥轮换需要提供证明")
+	}
+
+	if proof.ProofPurpose != "authentication" && proof.ProofPurpose != "capabilityInvocation" {
 		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
-			"无效的证明目的", proof.ProofPurpose)
+			"密钥轮换需要authentication或capabilityInvocation权限", proof.ProofPurpose)
 	}
 
-	return nil
+	return sv.verifyOperationProof(document, payload, proof)
 }
 
-// VerifyRevokePermission 验证撤销权限
-func (sv *SignatureVerifier) VerifyRevokePermission(didStr string, proof *types.Proof, verificationMethods []types.VerificationMethod) error {
+// VerifyDelegationPermission 验证委托权限，变更控制者必须由capabilityDelegation密钥签发
+func (sv *SignatureVerifier) VerifyDelegationPermission(document *types.DIDDocument, payload interface{}, proof *types.Proof) error {
 	if proof == nil {
-		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "撤销操作需要提供证明")
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "变更控制者需要提供证明")
 	}
 
-	// 查找对应的验证方法
-	var verificationMethod *types.VerificationMethod
-	for _, vm := range verificationMethods {
-		if vm.ID == proof.VerificationMethod {
-			verificationMethod = &vm
-			break
-		}
+	if proof.ProofPurpose != "capabilityDelegation" {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
+			"变更控制者需要capabilityDelegation权限", proof.ProofPurpose)
 	}
 
+	return sv.verifyOperationProof(document, payload, proof)
+}
+
+// verifyOperationProof 基于当前文档验证变更操作的证明
+func (sv *SignatureVerifier) verifyOperationProof(document *types.DIDDocument, payload interface{}, proof *types.Proof) error {
+	if document == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "DOCUMENT_REQUIRED", "文档不能为空")
+	}
+
+	// 查找对应的验证方法
+	verificationMethod := FindVerificationMethod(document, proof.VerificationMethod)
 	if verificationMethod == nil {
 		return utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND",
 			"验证方法不存在", proof.VerificationMethod)
 	}
 
 	// 验证控制者权限
-	if err := sv.VerifyController(didStr, verificationMethod); err != nil {
+	if err := sv.VerifyDocumentController(document, verificationMethod); err != nil {
 		return err
 	}
 
-	// 验证证明目的（撤销需要更高权限）
-	if proof.ProofPurpose != "authentication" {
-		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
-			"撤销操作需要authentication权限", proof.ProofPurpose)
+	// 验证方法必须被授权用于该证明目的
+	if !HasVerificationRelationship(document, proof.ProofPurpose, verificationMethod.ID) {
+		return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "VERIFICATION_RELATIONSHIP_MISMATCH",
+			"验证方法未被授权用于该证明目的", fmt.Sprintf("%s: %s", proof.ProofPurpose, verificationMethod.ID))
 	}
 
+	// 证明必须晚于文档当前的证明，防止重放已应用过的操作（重启后依然有效）
+	if previous := document.Proof; previous != nil {
+		if !proof.Created.After(previous.Created) || (proof.Nonce != "" && proof.Nonce == previous.Nonce) {
+			return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "PROOF_REPLAYED",
+				"证明已被使用", fmt.Sprintf("创建时间: %s", proof.Created.Format(time.RFC3339)))
+		}
+	}
+
+	return sv.VerifyProof(payload, proof, verificationMethod)
+}
+
+// VerifyDocumentController 验证验证方法的控制者是文档的DID主体或文档声明的控制者之一
+func (sv *SignatureVerifier) VerifyDocumentController(document *types.DIDDocument, verificationMethod *types.VerificationMethod) error {
+	if verificationMethod == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "VERIFICATION_METHOD_REQUIRED", "验证方法不能为空")
+	}
+
+	for _, controller := range document.Controller {
+		if verificationMethod.Controller == controller {
+			return nil
+		}
+	}
+	return sv.VerifyController(document.ID, verificationMethod)
+}
+
+// FindVerificationMethod 在文档中查找验证方法，支持相对ID（#key-1）
+func FindVerificationMethod(document *types.DIDDocument, id string) *types.VerificationMethod {
+	for i := range document.VerificationMethod {
+		vm := &document.VerificationMethod[i]
+		if vm.ID == id || document.ID+vm.ID == id {
+			return vm
+		}
+	}
 	return nil
 }
 
+// HasVerificationRelationship 检查验证方法是否在指定的验证关系中
+func HasVerificationRelationship(document *types.DIDDocument, purpose, id string) bool {
+	var refs []string
+	switch purpose {
+	case "authentication":
+		refs = document.Authentication
+	case "assertionMethod":
+		refs = document.AssertionMethod
+	case "keyAgreement":
+		refs = document.KeyAgreement
+	case "capabilityInvocation":
+		refs = document.CapabilityInvocation
+	case "capabilityDelegation":
+		refs = document.CapabilityDelegation
+	}
+
+	for _, ref := range refs {
+		if ref == id || document.ID+ref == id {
+			return true
+		}
+	}
+	return false
+}
+
 // 私有方法
 
 // validateProofTime 验证证明时间
@@ -147,22 +290,69 @@ func (sv *SignatureVerifier) validateProofTime(proof *types.Proof) error {
 	now := time.Now()
 
 	// 检查证明是否过期（24小时内有效）
-	if now.Sub(proof.Created) > 24*time.Hour {
+	if now.Sub(proof.Created) > ProofValidityPeriod {
 		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "PROOF_EXPIRED",
 			"证明已过期", fmt.Sprintf("创建时间: %s", proof.Created.Format(time.RFC3339)))
 	}
 
 	// 检查证明是否来自未来
-	if proof.Created.After(now.Add(5 * time.Minute)) {
+	if proof.Created.After(now.Add(ProofClockSkew)) {
 		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "PROOF_FROM_FUTURE",
 			"证明时间不能来自未来", fmt.Sprintf("创建时间: %s", proof.Created.Format(time.RFC3339)))
 	}
 
+	// 有效期内的nonce只能使用一次
+	if proof.Nonce == "" {
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_NONCE_REQUIRED", "证明缺少nonce")
+	}
+
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.pruneNonces(now)
+	if _, used := sv.usedNonces[nonceKey(proof)]; used {
+		return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "PROOF_REPLAYED",
+			"证明已被使用", proof.Nonce)
+	}
+
 	return nil
 }
 
+// consumeNonce 记录已使用的nonce，并发提交同一证明时只有一个能成功
+func (sv *SignatureVerifier) consumeNonce(proof *types.Proof) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	key := nonceKey(proof)
+	if _, used := sv.usedNonces[key]; used {
+		return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "PROOF_REPLAYED",
+			"证明已被使用", proof.Nonce)
+	}
+
+	// nonce只需保留到证明本身失效为止
+	sv.usedNonces[key] = proof.Created.Add(ProofValidityPeriod + ProofClockSkew)
+	return nil
+}
+
+// pruneNonces 清理已失效的nonce（调用方需持有锁）
+func (sv *SignatureVerifier) pruneNonces(now time.Time) {
+	for key, expiry := range sv.usedNonces {
+		if now.After(expiry) {
+			delete(sv.usedNonces, key)
+		}
+	}
+}
+
+// nonceKey nonce按验证方法隔离
+func nonceKey(proof *types.Proof) string {
+	return proof.VerificationMethod + "|" + proof.Nonce
+}
+
 // verifyEd25519Signature 验证Ed25519签名
 func (sv *SignatureVerifier) verifyEd25519Signature(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
+	if err := sv.checkSuite(proof.Type, ""); err != nil {
+		return err
+	}
+
 	// 获取公钥
 	publicKey, err := sv.extractEd25519PublicKey(verificationMethod)
 	if err != nil {
@@ -191,70 +381,143 @@ func (sv *SignatureVerifier) verifyEd25519Signature(document interface{}, proof
 }
 
 // verifyJWSSignature 验证JWS签名
+// JsonWebSignature2020 使用分离式、未编码载荷的JWS（RFC 7797）：header..signature，
+// 载荷为 ProofSigningInput 计算出的摘要
 func (sv *SignatureVerifier) verifyJWSSignature(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
-	// 解析JWS格式的签名
+	header, signingInput, signatureBytes, err := sv.parseDetachedJWS(document, proof)
+	if err != nil {
+		return err
+	}
+
+	// 验证算法类型
+	if header.Alg != "ES256" && header.Alg != "EdDSA" {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_JWS_ALGORITHM",
+			"不支持的JWS算法", header.Alg)
+	}
+
+	if err := sv.checkSuite(proof.Type, header.Alg); err != nil {
+		return err
+	}
+
+	return sv.verifyAlgorithmSignature(header.Alg, signingInput, signatureBytes, verificationMethod)
+}
+
+// verifyCompositeSignature 验证组合签名证明
+func (sv *SignatureVerifier) verifyCompositeSignature(document interface{}, proof *types.Proof, verificationMethod *types.VerificationMethod) error {
+	header, signingInput, signatureBytes, err := sv.parseDetachedJWS(document, proof)
+	if err != nil {
+		return err
+	}
+
+	if header.Alg != CompositeAlgorithm {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_JWS_ALGORITHM",
+			"组合签名证明需要"+CompositeAlgorithm+"算法", header.Alg)
+	}
+
+	if err := sv.checkSuite(proof.Type, header.Alg); err != nil {
+		return err
+	}
+
+	return sv.verifyAlgorithmSignature(header.Alg, signingInput, signatureBytes, verificationMethod)
+}
+
+// verifyAlgorithmSignature 按JWS算法验证签名
+// 组合签名为 ES256签名(R || S，64字节) || ML-DSA-65签名，两者对同一签名输入签名，必须都验证通过
+func (sv *SignatureVerifier) verifyAlgorithmSignature(alg string, signingInput, signatureBytes []byte, verificationMethod *types.VerificationMethod) error {
+	switch alg {
+	case "ES256":
+		// 组合密钥只接受组合签名，否则能伪造ECDSA签名的攻击者即可绕过ML-DSA
+		if jwkHasMember(verificationMethod, "mldsa") {
+			return utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "COMPOSITE_SIGNATURE_REQUIRED",
+				"验证方法包含ML-DSA-65公钥，需要组合签名", verificationMethod.ID)
+		}
+		return sv.verifyES256Signature(signingInput, signatureBytes, verificationMethod)
+	case "EdDSA":
+		return sv.verifyEdDSASignature(signingInput, signatureBytes, verificationMethod)
+	case CompositeAlgorithm:
+	default:
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_JWS_ALGORITHM",
+			"不支持的JWS算法", alg)
+	}
+
+	if len(signatureBytes) != 64+mldsa.MLDSA65SignatureSize {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_COMPOSITE_SIGNATURE",
+			"组合签名长度无效", fmt.Sprintf("期望: %d, 实际: %d", 64+mldsa.MLDSA65SignatureSize, len(signatureBytes)))
+	}
+
+	publicKey, err := sv.extractMLDSAPublicKey(verificationMethod)
+	if err != nil {
+		return err
+	}
+
+	if err := sv.verifyES256Signature(signingInput, signatureBytes[:64], verificationMethod); err != nil {
+		return err
+	}
+
+	if err := mldsa.Verify(publicKey, signingInput, signatureBytes[64:], &mldsa.Options{Context: CompositeAlgorithm}); err != nil {
+		return utils.NewError(utils.ErrorTypeValidation, "INVALID_MLDSA_SIGNATURE", "ML-DSA-65签名验证失败")
+	}
+
+	return nil
+}
+
+// checkSuite 检查证明格式属于已启用的算法套件
+func (sv *SignatureVerifier) checkSuite(proofType, algorithm string) error {
+	if _, err := sv.suites.ForProof(proofType, algorithm); err != nil {
+		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "UNSUPPORTED_ALGORITHM_SUITE",
+			"证明使用的算法套件未启用", err)
+	}
+	return nil
+}
+
+// parseDetachedJWS 解析证明中的分离式JWS（header..signature），返回头部、签名输入和签名
+func (sv *SignatureVerifier) parseDetachedJWS(document interface{}, proof *types.Proof) (*jwsHeader, []byte, []byte, error) {
 	jwsSignature := proof.ProofValue
 	if jwsSignature == "" {
-		return utils.NewError(utils.ErrorTypeValidation, "EMPTY_JWS_SIGNATURE", "JWS签名不能为空")
+		return nil, nil, nil, utils.NewError(utils.ErrorTypeValidation, "EMPTY_JWS_SIGNATURE", "JWS签名不能为空")
 	}
 
-	// JWS格式: header.payload.signature
+	// 分离式JWS格式: header..signature
 	parts := strings.Split(jwsSignature, ".")
-	if len(parts) != 3 {
-		return utils.NewError(utils.ErrorTypeValidation, "INVALID_JWS_FORMAT", "无效的JWS格式，应为header.payload.signature")
+	if len(parts) != 3 || parts[1] != "" {
+		return nil, nil, nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWS_FORMAT", "无效的JWS格式，应为分离式的header..signature")
 	}
 
-	headerB64, payloadB64, signatureB64 := parts[0], parts[1], parts[2]
+	headerB64, signatureB64 := parts[0], parts[2]
 
 	// 解码header
 	headerBytes, err := base64.RawURLEncoding.DecodeString(headerB64)
 	if err != nil {
-		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER", "无效的JWS头部", err)
+		return nil, nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER", "无效的JWS头部", err)
 	}
 
-	var header struct {
-		Alg string `json:"alg"`
-		Typ string `json:"typ"`
-		Kid string `json:"kid,omitempty"`
-	}
+	var header jwsHeader
 	if err := json.Unmarshal(headerBytes, &header); err != nil {
-		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER_JSON", "JWS头部JSON解析失败", err)
-	}
-
-	// 验证算法类型
-	if header.Alg != "ES256" && header.Alg != "EdDSA" {
-		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_JWS_ALGORITHM",
-			"不支持的JWS算法", header.Alg)
+		return nil, nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_HEADER_JSON", "JWS头部JSON解析失败", err)
 	}
 
-	// 解码payload（验证payload格式但不需要使用内容）
-	_, err = base64.RawURLEncoding.DecodeString(payloadB64)
-	if err != nil {
-		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_PAYLOAD", "无效的JWS载荷", err)
+	// 载荷不做base64url编码，头部必须声明 b64=false 并将其列为关键参数
+	if header.B64 == nil || *header.B64 || !containsString(header.Crit, "b64") {
+		return nil, nil, nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWS_HEADER", "JWS头部必须包含b64=false且crit包含b64")
 	}
 
 	// 解码签名
 	signatureBytes, err := base64.RawURLEncoding.DecodeString(signatureB64)
 	if err != nil {
-		return utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_SIGNATURE", "无效的JWS签名", err)
+		return nil, nil, nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWS_SIGNATURE", "无效的JWS签名", err)
 	}
 
-	// 创建签名数据 (header.payload)
-	signingInput := headerB64 + "." + payloadB64
-
-	// 根据算法验证签名
-	switch header.Alg {
-	case "ES256":
-		return sv.verifyES256Signature([]byte(signingInput), signatureBytes, verificationMethod)
-	case "EdDSA":
-		return sv.verifyEdDSASignature([]byte(signingInput), signatureBytes, verificationMethod)
-	default:
-		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_JWS_ALGORITHM",
-			"不支持的JWS算法", header.Alg)
+	// 签名输入: ASCII(BASE64URL(header)) || '.' || payload
+	payload, err := sv.createSignatureData(document, proof)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+
+	return &header, jwsSigningInput(headerB64, payload), signatureBytes, nil
 }
 
 // verifyES256Signature 验证ES256签名（ECDSA P-256 + SHA256）
+// 按RFC 7518，签名为定长的 R || S（各32字节）
 func (sv *SignatureVerifier) verifyES256Signature(signingInput, signature []byte, verificationMethod *types.VerificationMethod) error {
 	// 从验证方法中提取ECDSA公钥
 	publicKey, err := sv.extractECDSAPublicKey(verificationMethod)
@@ -262,11 +525,18 @@ func (sv *SignatureVerifier) verifyES256Signature(signingInput, signature []byte
 		return err
 	}
 
+	if len(signature) != 64 {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_ES256_SIGNATURE",
+			"ES256签名长度无效", fmt.Sprintf("期望: 64, 实际: %d", len(signature)))
+	}
+
 	// 计算签名输入的SHA256哈希
 	hash := sha256.Sum256(signingInput)
 
 	// 验证ECDSA签名
-	if !ecdsa.VerifyASN1(publicKey, hash[:], signature) {
+	r := new(big.Int).SetBytes(signature[:32])
+	sig := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(publicKey, hash[:], r, sig) {
 		return utils.NewError(utils.ErrorTypeValidation, "INVALID_ES256_SIGNATURE", "ES256签名验证失败")
 	}
 
@@ -296,10 +566,9 @@ func (sv *SignatureVerifier) extractECDSAPublicKey(verificationMethod *types.Ver
 			"ES256签名需要JsonWebKey2020类型的验证方法", verificationMethod.Type)
 	}
 
-	// 解析JWK格式的公钥
-	jwkData, ok := verificationMethod.PublicKeyJwk.(map[string]interface{})
-	if !ok {
-		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWK_FORMAT", "无效的JWK格式")
+	jwkData, err := jwkMembers(verificationMethod)
+	if err != nil {
+		return nil, err
 	}
 
 	// 检查密钥类型
@@ -356,9 +625,63 @@ func (sv *SignatureVerifier) extractECDSAPublicKey(verificationMethod *types.Ver
 	return publicKey, nil
 }
 
+// extractMLDSAPublicKey 从JsonWebKey2020验证方法的 mldsa 字段提取ML-DSA-65公钥
+func (sv *SignatureVerifier) extractMLDSAPublicKey(verificationMethod *types.VerificationMethod) (*mldsa.PublicKey, error) {
+	if verificationMethod.Type != "JsonWebKey2020" {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_KEY_TYPE",
+			"组合签名需要JsonWebKey2020类型的验证方法", verificationMethod.Type)
+	}
+
+	jwkData, err := jwkMembers(verificationMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := jwkData["mldsa"].(string)
+	if !ok || encoded == "" {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "MISSING_MLDSA_PUBLIC_KEY", "验证方法缺少ML-DSA-65公钥")
+	}
+
+	keyBytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_MLDSA_PUBLIC_KEY", "无效的ML-DSA-65公钥", err)
+	}
+
+	publicKey, err := mldsa.NewPublicKey(mldsa.MLDSA65(), keyBytes)
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_MLDSA_PUBLIC_KEY", "无效的ML-DSA-65公钥", err)
+	}
+	return publicKey, nil
+}
+
+// jwkMembers 以map形式返回验证方法的JWK（未经JSON往返的文档中可能是结构体）
+func jwkMembers(verificationMethod *types.VerificationMethod) (map[string]interface{}, error) {
+	jwkData, ok := verificationMethod.PublicKeyJwk.(map[string]interface{})
+	if !ok {
+		raw, err := json.Marshal(verificationMethod.PublicKeyJwk)
+		if err != nil || json.Unmarshal(raw, &jwkData) != nil || jwkData == nil {
+			return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWK_FORMAT", "无效的JWK格式")
+		}
+	}
+	return jwkData, nil
+}
+
+// jwkHasMember 检查验证方法的JWK是否包含非空的指定字段
+func jwkHasMember(verificationMethod *types.VerificationMethod, name string) bool {
+	if verificationMethod.PublicKeyJwk == nil {
+		return false
+	}
+	jwkData, err := jwkMembers(verificationMethod)
+	if err != nil {
+		return false
+	}
+	value, ok := jwkData[name].(string)
+	return ok && value != ""
+}
+
 // extractEd25519PublicKey 提取Ed25519公钥
 func (sv *SignatureVerifier) extractEd25519PublicKey(verificationMethod *types.VerificationMethod) (ed25519.PublicKey, error) {
-	if verificationMethod.Type != "Ed25519VerificationKey2020" {
+	if verificationMethod.Type != "Ed25519VerificationKey2020" && verificationMethod.Type != "Multikey" {
 		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_KEY_TYPE",
 			"不支持的密钥类型", verificationMethod.Type)
 	}
@@ -367,13 +690,20 @@ func (sv *SignatureVerifier) extractEd25519PublicKey(verificationMethod *types.V
 		return nil, utils.NewError(utils.ErrorTypeValidation, "PUBLIC_KEY_REQUIRED", "公钥不能为空")
 	}
 
-	// 解码multibase格式的公钥
-	// 假设使用base58编码，前缀为'z'
 	if !strings.HasPrefix(verificationMethod.PublicKeyMultibase, "z") {
 		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_MULTIBASE_FORMAT", "无效的multibase格式")
 	}
 
-	// 简化处理：直接使用base64解码（实际应该使用multibase库）
+	// 标准格式：'z' + base58btc(0xed01 || 公钥)
+	if codec, key, err := DecodeMultikey(verificationMethod.PublicKeyMultibase); err == nil {
+		if codec != MulticodecEd25519Pub {
+			return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_KEY_TYPE",
+				"公钥不是Ed25519类型", fmt.Sprintf("0x%x", codec))
+		}
+		return ed25519.PublicKey(key), nil
+	}
+
+	// 兼容旧数据：'z' + base64(公钥)
 	keyData := strings.TrimPrefix(verificationMethod.PublicKeyMultibase, "z")
 	publicKey, err := base64.StdEncoding.DecodeString(keyData)
 	if err != nil {
@@ -391,26 +721,132 @@ func (sv *SignatureVerifier) extractEd25519PublicKey(verificationMethod *types.V
 
 // createSignatureData 创建签名数据
 func (sv *SignatureVerifier) createSignatureData(document interface{}, proof *types.Proof) ([]byte, error) {
-	// 创建规范化的文档副本（移除proof字段）
-	docBytes, err := json.Marshal(document)
+	return ProofSigningInput(document, proof)
+}
+
+// ProofSigningInput 计算证明的签名载荷：SHA-256(规范化证明选项) || SHA-256(规范化文档)
+// 证明选项为去掉proofValue的证明，文档会去掉已附加的proof，两者均使用JCS规范化
+func ProofSigningInput(document interface{}, proof *types.Proof) ([]byte, error) {
+	docBytes, err := canonicalizeWithout(document, "proof")
 	if err != nil {
 		return nil, utils.NewErrorWithCause(utils.ErrorTypeInternal, "DOCUMENT_SERIALIZATION_FAILED",
 			"文档序列化失败", err)
 	}
 
-	// 创建证明副本（移除proofValue字段）
-	proofCopy := *proof
-	proofCopy.ProofValue = ""
-
-	proofBytes, err := json.Marshal(proofCopy)
+	proofBytes, err := canonicalizeWithout(proof, "proofValue")
 	if err != nil {
 		return nil, utils.NewErrorWithCause(utils.ErrorTypeInternal, "PROOF_SERIALIZATION_FAILED",
 			"证明序列化失败", err)
 	}
 
-	// 组合数据并计算哈希
-	combinedData := append(docBytes, proofBytes...)
-	hash := sha256.Sum256(combinedData)
+	proofHash := sha256.Sum256(proofBytes)
+	docHash := sha256.Sum256(docBytes)
+
+	return append(proofHash[:], docHash[:]...), nil
+}
+
+// jwsHeader JWS受保护头部
+type jwsHeader struct {
+	Alg  string   `json:"alg"`
+	B64  *bool    `json:"b64,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+	Kid  string   `json:"kid,omitempty"`
+}
+
+// jwsSigningInput 构造未编码载荷的JWS签名输入
+func jwsSigningInput(headerB64 string, payload []byte) []byte {
+	input := make([]byte, 0, len(headerB64)+1+len(payload))
+	input = append(input, headerB64...)
+	input = append(input, '.')
+	return append(input, payload...)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SignProof 为文档生成证明，结果写入proof.ProofValue
+// 包含ML-DSA-65密钥的密钥对生成 CompositeProofType 组合签名证明，否则生成JsonWebSignature2020证明（ES256分离式JWS）
+// 调用方应先填好证明的其他字段，它们同样受签名保护
+func (hkp *HybridKeyPair) SignProof(document interface{}, proof *types.Proof) error {
+	if hkp.ECDSAPrivateKey == nil {
+		return fmt.Errorf("ECDSA私钥为空")
+	}
+	if hkp.IsComposite() && hkp.MLDSAPrivateKey == nil {
+		return fmt.Errorf("ML-DSA-65私钥为空")
+	}
+
+	alg := "ES256"
+	proof.Type = "JsonWebSignature2020"
+	if hkp.IsComposite() {
+		alg = CompositeAlgorithm
+		proof.Type = CompositeProofType
+	}
+
+	payload, err := ProofSigningInput(document, proof)
+	if err != nil {
+		return err
+	}
+
+	b64 := false
+	header, err := json.Marshal(jwsHeader{
+		Alg:  alg,
+		B64:  &b64,
+		Crit: []string{"b64"},
+	})
+	if err != nil {
+		return fmt.Errorf("序列化JWS头部失败: %w", err)
+	}
+	headerB64 := base64.RawURLEncoding.EncodeToString(header)
+	signingInput := jwsSigningInput(headerB64, payload)
+
+	signature, err := hkp.signInput(signingInput)
+	if err != nil {
+		return err
+	}
 
-	return hash[:], nil
+	proof.ProofValue = headerB64 + ".." + base64.RawURLEncoding.EncodeToString(signature)
+	return nil
+}
+
+// jwsAlgorithm 密钥对签名使用的JWS算法
+func (hkp *HybridKeyPair) jwsAlgorithm() string {
+	if hkp.IsComposite() {
+		return CompositeAlgorithm
+	}
+	return "ES256"
+}
+
+// signInput 对签名输入签名，返回 R || S（各32字节），组合密钥再附加ML-DSA-65签名
+func (hkp *HybridKeyPair) signInput(signingInput []byte) ([]byte, error) {
+	if hkp.ECDSAPrivateKey == nil {
+		return nil, fmt.Errorf("ECDSA私钥为空")
+	}
+	if hkp.IsComposite() && hkp.MLDSAPrivateKey == nil {
+		return nil, fmt.Errorf("ML-DSA-65私钥为空")
+	}
+
+	hash := sha256.Sum256(signingInput)
+	r, sig, err := ecdsa.Sign(rand.Reader, hkp.ECDSAPrivateKey, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("ECDSA签名失败: %w", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	if hkp.IsComposite() {
+		mldsaSig, err := hkp.MLDSAPrivateKey.Sign(nil, signingInput, &mldsa.Options{Context: CompositeAlgorithm})
+		if err != nil {
+			return nil, fmt.Errorf("ML-DSA-65签名失败: %w", err)
+		}
+		signature = append(signature, mldsaSig...)
+	}
+	return signature, nil
 }
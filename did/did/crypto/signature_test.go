@@ -0,0 +1,231 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"成员排序", `{"b":1,"a":{"d":true,"c":null}}`, `{"a":{"c":null,"d":true},"b":1}`},
+		{"UTF-16排序", `{"\u20ac":1,"\ud83d\ude00":2,"\r":3,"1":4}`, "{\"\\r\":3,\"1\":4,\"\u20ac\":1,\"\U0001F600\":2}"},
+		{"数字格式", `[1.0,-0,1e21,1e-7,0.000001,123456789012345680000,4.5e-10]`,
+			`[1,0,1e+21,1e-7,0.000001,123456789012345680000,4.5e-10]`},
+		{"字符串转义", `"<\u0001\"\/>"`, "\"<\\u0001\\\"/>\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			decoder := json.NewDecoder(strings.NewReader(tt.input))
+			decoder.UseNumber()
+			if err := decoder.Decode(&v); err != nil {
+				t.Fatalf("解析输入失败: %v", err)
+			}
+
+			got, err := Canonicalize(v)
+			if err != nil {
+				t.Fatalf("规范化失败: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("规范化结果不正确:\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignProofVerifiesAcrossRepresentations(t *testing.T) {
+	keyPair, err := GenerateHybridKeyPair()
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+	jwk, err := keyPair.ToJWK()
+	if err != nil {
+		t.Fatalf("转换JWK失败: %v", err)
+	}
+
+	vm := &types.VerificationMethod{
+		ID:           "did:qlink:abc#key-1",
+		Type:         "JsonWebKey2020",
+		Controller:   "did:qlink:abc",
+		PublicKeyJwk: jwk,
+	}
+	doc := &types.DIDDocument{
+		Context:            []string{"https://www.w3.org/ns/did/v1"},
+		ID:                 "did:qlink:abc",
+		VerificationMethod: []types.VerificationMethod{*vm},
+		Authentication:     []string{vm.ID},
+	}
+
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: vm.ID,
+		ProofPurpose:       "assertionMethod",
+	}
+	if err := keyPair.SignProof(doc, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if parts := strings.Split(proof.ProofValue, "."); len(parts) != 3 || parts[1] != "" {
+		t.Fatalf("应生成分离式JWS: %s", proof.ProofValue)
+	}
+
+	// 以map形式（成员顺序不同、附带proof）传输后依然可以验证
+	raw, _ := json.Marshal(doc)
+	var received map[string]interface{}
+	json.Unmarshal(raw, &received)
+	received["proof"] = proof
+
+	verifier := NewSignatureVerifier()
+	if err := verifier.VerifyProofSignature(received, proof, vm); err != nil {
+		t.Fatalf("验证签名失败: %v", err)
+	}
+
+	// 篡改文档或证明选项都会导致验证失败
+	received["authentication"] = []string{"did:qlink:abc#key-2"}
+	if err := verifier.VerifyProofSignature(received, proof, vm); err == nil {
+		t.Error("篡改文档后验证应失败")
+	}
+
+	tampered := *proof
+	tampered.ProofPurpose = "authentication"
+	if err := verifier.VerifyProofSignature(doc, &tampered, vm); err == nil {
+		t.Error("篡改证明选项后验证应失败")
+	}
+}
+
+func TestVerifyProofRejectsReplayedNonce(t *testing.T) {
+	keyPair, _ := GenerateHybridKeyPair()
+	jwk, _ := keyPair.ToJWK()
+	vm := &types.VerificationMethod{
+		ID:           "did:qlink:abc#key-1",
+		Type:         "JsonWebKey2020",
+		Controller:   "did:qlink:abc",
+		PublicKeyJwk: jwk,
+	}
+
+	payload := map[string]string{"operation": "update", "did": "did:qlink:abc"}
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: vm.ID,
+		ProofPurpose:       "authentication",
+		Nonce:              "n-1",
+	}
+	if err := keyPair.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	verifier := NewSignatureVerifier()
+	if err := verifier.VerifyProof(payload, proof, vm); err != nil {
+		t.Fatalf("首次验证应成功: %v", err)
+	}
+	if err := verifier.VerifyProof(payload, proof, vm); err == nil {
+		t.Error("重复使用nonce应被拒绝")
+	}
+}
+
+func TestCompositeProofRequiresBothSignatures(t *testing.T) {
+	keyPair, _ := GenerateHybridKeyPair()
+	jwk, _ := keyPair.ToJWK()
+	if jwk.Alg != CompositeAlgorithm || jwk.MLDSA == "" {
+		t.Fatalf("组合密钥的JWK应包含ML-DSA-65公钥: %+v", jwk)
+	}
+	vm := &types.VerificationMethod{
+		ID:           "did:qlink:abc#key-1",
+		Type:         "JsonWebKey2020",
+		Controller:   "did:qlink:abc",
+		PublicKeyJwk: jwk,
+	}
+
+	payload := map[string]string{"operation": "update", "did": "did:qlink:abc"}
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: vm.ID,
+		ProofPurpose:       "authentication",
+	}
+	if err := keyPair.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if proof.Type != CompositeProofType {
+		t.Fatalf("组合密钥应生成组合签名证明: %s", proof.Type)
+	}
+
+	verifier := NewSignatureVerifier()
+	if err := verifier.VerifyProofSignature(payload, proof, vm); err != nil {
+		t.Fatalf("验证组合签名失败: %v", err)
+	}
+
+	// 篡改ML-DSA签名部分时，即使ES256签名有效也验证失败
+	parts := strings.Split(proof.ProofValue, "..")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	signature[len(signature)-1] ^= 0xff
+	tampered := *proof
+	tampered.ProofValue = parts[0] + ".." + base64.RawURLEncoding.EncodeToString(signature)
+	if err := verifier.VerifyProofSignature(payload, &tampered, vm); err == nil {
+		t.Error("ML-DSA签名无效时验证应失败")
+	}
+
+	// 只持有ECDSA私钥的攻击者不能降级为ES256签名
+	downgraded := &HybridKeyPair{ECDSAPrivateKey: keyPair.ECDSAPrivateKey, ECDSAPublicKey: keyPair.ECDSAPublicKey}
+	esProof := &types.Proof{Created: time.Now(), VerificationMethod: vm.ID, ProofPurpose: "authentication"}
+	if err := downgraded.SignProof(payload, esProof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if err := verifier.VerifyProofSignature(payload, esProof, vm); err == nil {
+		t.Error("组合密钥不应接受ES256签名")
+	}
+
+	sig, _ := keyPair.Sign([]byte("challenge"))
+	if !keyPair.Verify([]byte("challenge"), sig) {
+		t.Error("混合签名验证失败")
+	}
+	sig.MLDSASignature = nil
+	if keyPair.Verify([]byte("challenge"), sig) {
+		t.Error("缺少ML-DSA签名时验证应失败")
+	}
+}
+
+func TestLegacyKeyKeepsES256Proofs(t *testing.T) {
+	keyPair, _ := GenerateHybridKeyPair()
+	data, _ := keyPair.MarshalPrivateKey()
+
+	// 不含ML-DSA种子的旧私钥仍可解析，并继续使用JsonWebSignature2020
+	legacy, err := ParsePrivateKey(data[:legacyPrivateKeySize])
+	if err != nil {
+		t.Fatalf("解析旧格式私钥失败: %v", err)
+	}
+	if legacy.IsComposite() {
+		t.Fatal("旧格式私钥不应包含ML-DSA密钥")
+	}
+
+	jwk, _ := legacy.ToJWK()
+	if jwk.Alg != "ES256" || jwk.MLDSA != "" {
+		t.Errorf("旧密钥的JWK应保持不变: %+v", jwk)
+	}
+	vm := &types.VerificationMethod{
+		ID:           "did:qlink:abc#key-1",
+		Type:         "JsonWebKey2020",
+		Controller:   "did:qlink:abc",
+		PublicKeyJwk: jwk,
+	}
+
+	payload := map[string]string{"operation": "update"}
+	proof := &types.Proof{Created: time.Now(), VerificationMethod: vm.ID, ProofPurpose: "authentication"}
+	if err := legacy.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if proof.Type != "JsonWebSignature2020" {
+		t.Errorf("旧密钥应生成JsonWebSignature2020证明: %s", proof.Type)
+	}
+	if err := NewSignatureVerifier().VerifyProofSignature(payload, proof, vm); err != nil {
+		t.Errorf("验证ES256签名失败: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 内置算法套件标识，格式为 QLINK-<经典签名>-<密钥封装>[-<后量子签名>]
+const (
+	// SuiteP256MLKEM768MLDSA65 ES256 + ML-KEM-768 + ML-DSA-65组合签名，新密钥的默认套件
+	SuiteP256MLKEM768MLDSA65 = "QLINK-P256-MLKEM768-MLDSA65"
+	// SuiteP256MLKEM768 ES256 + ML-KEM-768，没有ML-DSA密钥的早期混合密钥
+	SuiteP256MLKEM768 = "QLINK-P256-MLKEM768"
+	// SuiteEd25519 Ed25519，只用于验证外部签发的证明（如did:key）
+	SuiteEd25519 = "QLINK-ED25519"
+)
+
+// ErrSuiteUnsupported 算法套件未注册或已禁用
+var ErrSuiteUnsupported = errors.New("不支持的算法套件")
+
+// SuiteProof 套件可接受的证明格式
+type SuiteProof struct {
+	Type      string // 证明类型，如 JsonWebSignature2020
+	Algorithm string // JWS alg 或 DataIntegrityProof 的 cryptosuite，其他证明为空
+}
+
+// AlgorithmSuite 算法套件，描述一组一起使用的签名和密钥封装算法
+type AlgorithmSuite struct {
+	ID          string
+	Signature   string       // 经典签名算法
+	KEM         string       // 密钥封装算法，为空表示不支持加密
+	PQSignature string       // 后量子签名算法，为空表示只有经典签名
+	Proofs      []SuiteProof // 该套件签发的证明格式
+
+	// Generate 生成该套件的混合密钥对，为空表示只用于验证
+	Generate func() (*HybridKeyPair, error)
+}
+
+// AcceptsProof 套件是否接受指定类型和算法的证明
+func (s *AlgorithmSuite) AcceptsProof(proofType, algorithm string) bool {
+	for _, proof := range s.Proofs {
+		if proof.Type == proofType && proof.Algorithm == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// SuiteRegistry 算法套件注册表
+// 套件按注册顺序排列偏好，协商时选择本地最偏好且对方支持的套件
+type SuiteRegistry struct {
+	mu       sync.RWMutex
+	suites   []*AlgorithmSuite
+	disabled map[string]bool
+}
+
+// DefaultSuites 默认算法套件注册表，DIDDocumentBuilder 和 SignatureVerifier 默认使用
+var DefaultSuites = NewDefaultSuiteRegistry()
+
+// NewSuiteRegistry 创建空的算法套件注册表
+func NewSuiteRegistry() *SuiteRegistry {
+	return &SuiteRegistry{
+		disabled: make(map[string]bool),
+	}
+}
+
+// NewDefaultSuiteRegistry 创建包含内置套件的注册表
+func NewDefaultSuiteRegistry() *SuiteRegistry {
+	r := NewSuiteRegistry()
+	r.Register(&AlgorithmSuite{
+		ID:          SuiteP256MLKEM768MLDSA65,
+		Signature:   "ES256",
+		KEM:         "ML-KEM-768",
+		PQSignature: "ML-DSA-65",
+		Proofs: []SuiteProof{
+			{Type: CompositeProofType, Algorithm: CompositeAlgorithm},
+			{Type: DataIntegrityProofType, Algorithm: CryptosuiteCompositeJCS2025},
+		},
+		Generate: GenerateHybridKeyPair,
+	})
+	r.Register(&AlgorithmSuite{
+		ID:        SuiteP256MLKEM768,
+		Signature: "ES256",
+		KEM:       "ML-KEM-768",
+		Proofs: []SuiteProof{
+			{Type: "JsonWebSignature2020", Algorithm: "ES256"},
+			{Type: DataIntegrityProofType, Algorithm: CryptosuiteECDSAJCS2019},
+		},
+		Generate: generateClassicHybridKeyPair,
+	})
+	r.Register(&AlgorithmSuite{
+		ID:        SuiteEd25519,
+		Signature: "EdDSA",
+		Proofs: []SuiteProof{
+			{Type: "Ed25519Signature2020"},
+			{Type: "JsonWebSignature2020", Algorithm: "EdDSA"},
+		},
+	})
+	return r
+}
+
+// Register 注册算法套件，新套件的偏好排在已注册套件之后
+func (r *SuiteRegistry) Register(suite *AlgorithmSuite) error {
+	if suite == nil || suite.ID == "" {
+		return fmt.Errorf("算法套件标识不能为空")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.suites {
+		if existing.ID == suite.ID {
+			return fmt.Errorf("算法套件已注册: %s", suite.ID)
+		}
+	}
+	r.suites = append(r.suites, suite)
+	return nil
+}
+
+// Disable 禁用算法套件，之后不再用它生成密钥、协商或验证证明
+func (r *SuiteRegistry) Disable(id string) error {
+	return r.setDisabled(id, true)
+}
+
+// Enable 重新启用被禁用的算法套件
+func (r *SuiteRegistry) Enable(id string) error {
+	return r.setDisabled(id, false)
+}
+
+func (r *SuiteRegistry) setDisabled(id string, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, suite := range r.suites {
+		if suite.ID == id {
+			r.disabled[id] = disabled
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrSuiteUnsupported, id)
+}
+
+// Lookup 查找已启用的算法套件
+func (r *SuiteRegistry) Lookup(id string) (*AlgorithmSuite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, suite := range r.suites {
+		if suite.ID == id && !r.disabled[id] {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSuiteUnsupported, id)
+}
+
+// Supported 按偏好顺序返回已启用的套件标识
+func (r *SuiteRegistry) Supported() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.suites))
+	for _, suite := range r.suites {
+		if !r.disabled[suite.ID] {
+			ids = append(ids, suite.ID)
+		}
+	}
+	return ids
+}
+
+// Preferred 返回最偏好的可生成密钥的套件
+func (r *SuiteRegistry) Preferred() (*AlgorithmSuite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, suite := range r.suites {
+		if suite.Generate != nil && !r.disabled[suite.ID] {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 没有可生成密钥的套件", ErrSuiteUnsupported)
+}
+
+// Negotiate 从对方提供的套件中选择本地最偏好的套件
+func (r *SuiteRegistry) Negotiate(offered []string) (*AlgorithmSuite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, suite := range r.suites {
+		if !r.disabled[suite.ID] && containsString(offered, suite.ID) {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 没有共同支持的套件 %v", ErrSuiteUnsupported, offered)
+}
+
+// ForProof 查找接受指定证明格式的已启用套件
+func (r *SuiteRegistry) ForProof(proofType, algorithm string) (*AlgorithmSuite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, suite := range r.suites {
+		if !r.disabled[suite.ID] && suite.AcceptsProof(proofType, algorithm) {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s/%s", ErrSuiteUnsupported, proofType, algorithm)
+}
+
+// SuiteID 返回密钥对所属的算法套件
+func (hkp *HybridKeyPair) SuiteID() string {
+	if hkp.IsComposite() {
+		return SuiteP256MLKEM768MLDSA65
+	}
+	return SuiteP256MLKEM768
+}
+
+// GenerateKeyPairForSuite 使用默认注册表中的套件生成混合密钥对
+func GenerateKeyPairForSuite(id string) (*HybridKeyPair, error) {
+	suite, err := DefaultSuites.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if suite.Generate == nil {
+		return nil, fmt.Errorf("%w: %s 不能生成密钥", ErrSuiteUnsupported, id)
+	}
+	return suite.Generate()
+}
+
+// generateClassicHybridKeyPair 生成不含ML-DSA密钥的混合密钥对
+func generateClassicHybridKeyPair() (*HybridKeyPair, error) {
+	keyPair, err := GenerateHybridKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	keyPair.MLDSAPrivateKey = nil
+	keyPair.MLDSAPublicKey = nil
+	return keyPair, nil
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestSuiteNegotiation(t *testing.T) {
+	r := NewDefaultSuiteRegistry()
+
+	suite, err := r.Negotiate([]string{SuiteP256MLKEM768, SuiteP256MLKEM768MLDSA65})
+	if err != nil || suite.ID != SuiteP256MLKEM768MLDSA65 {
+		t.Fatalf("应选择本地最偏好的共同套件: %v, %v", suite, err)
+	}
+
+	if _, err := r.Negotiate([]string{"QLINK-ED25519-MLKEM1024-MLDSA65"}); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("没有共同套件时应协商失败: %v", err)
+	}
+
+	// 新注册的套件可以参与协商
+	if err := r.Register(&AlgorithmSuite{ID: "QLINK-P256-MLKEM1024", Signature: "ES256", KEM: "ML-KEM-1024"}); err != nil {
+		t.Fatalf("注册套件失败: %v", err)
+	}
+	if err := r.Register(&AlgorithmSuite{ID: "QLINK-P256-MLKEM1024"}); err == nil {
+		t.Error("重复注册应失败")
+	}
+	if suite, err := r.Negotiate([]string{"QLINK-P256-MLKEM1024"}); err != nil || suite.KEM != "ML-KEM-1024" {
+		t.Errorf("应协商到新注册的套件: %v, %v", suite, err)
+	}
+
+	// 禁用后不再参与协商
+	r.Disable(SuiteP256MLKEM768MLDSA65)
+	suite, err = r.Negotiate([]string{SuiteP256MLKEM768, SuiteP256MLKEM768MLDSA65})
+	if err != nil || suite.ID != SuiteP256MLKEM768 {
+		t.Errorf("禁用的套件不应被选择: %v, %v", suite, err)
+	}
+	if preferred, _ := r.Preferred(); preferred.ID != SuiteP256MLKEM768 {
+		t.Errorf("禁用后应使用下一个可生成密钥的套件: %s", preferred.ID)
+	}
+}
+
+func TestVerifierRejectsDisabledSuite(t *testing.T) {
+	keyPair, err := GenerateKeyPairForSuite(SuiteP256MLKEM768)
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+	if keyPair.IsComposite() || keyPair.SuiteID() != SuiteP256MLKEM768 {
+		t.Fatal("经典套件的密钥对不应包含ML-DSA密钥")
+	}
+
+	jwk, _ := keyPair.ToJWK()
+	vm := &types.VerificationMethod{
+		ID:           "did:qlink:abc#key-1",
+		Type:         "JsonWebKey2020",
+		Controller:   "did:qlink:abc",
+		PublicKeyJwk: jwk,
+	}
+	payload := map[string]string{"operation": "update"}
+	proof := &types.Proof{Created: time.Now(), VerificationMethod: vm.ID, ProofPurpose: "authentication"}
+	if err := keyPair.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	r := NewDefaultSuiteRegistry()
+	if err := NewSignatureVerifierWithSuites(r).VerifyProofSignature(payload, proof, vm); err != nil {
+		t.Fatalf("启用的套件应验证通过: %v", err)
+	}
+
+	// 淘汰经典套件后，只有ES256的证明被拒绝
+	r.Disable(SuiteP256MLKEM768)
+	err = NewSignatureVerifierWithSuites(r).VerifyProofSignature(payload, proof, vm)
+	if !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("禁用的套件应被拒绝: %v", err)
+	}
+}
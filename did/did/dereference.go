@@ -0,0 +1,214 @@
+package did
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// 解引用错误（W3C DID Resolution dereferencingMetadata.error）
+const (
+	DereferenceErrorInvalidDIDURL           = "invalidDidUrl"
+	DereferenceErrorNotFound                = "notFound"
+	DereferenceErrorContentTypeNotSupported = "contentTypeNotSupported"
+)
+
+// 解引用结果的内容类型
+const (
+	ContentTypeDIDLDJSON = "application/did+ld+json"
+	ContentTypeDIDJSON   = "application/did+json"
+	ContentTypeURIList   = "text/uri-list"
+)
+
+// DereferenceOptions 解引用选项
+type DereferenceOptions struct {
+	Accept string // 期望的内容类型，为空时使用 application/did+ld+json
+}
+
+// DereferencingResult DID URL解引用结果
+// ContentStream 为 *types.DIDDocument、*types.VerificationMethod、*types.Service 或服务端点URL
+type DereferencingResult struct {
+	DereferencingMetadata *DereferencingMetadata `json:"dereferencingMetadata"`
+	ContentStream         interface{}            `json:"contentStream,omitempty"`
+	ContentMetadata       *DocumentMetadata      `json:"contentMetadata,omitempty"`
+}
+
+// DereferencingMetadata 解引用元数据
+type DereferencingMetadata struct {
+	ContentType string `json:"contentType,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Dereference 解引用DID URL
+func (r *DIDResolver) Dereference(didURL string) (*DereferencingResult, error) {
+	return r.DereferenceWithOptions(didURL, DereferenceOptions{})
+}
+
+// DereferenceWithOptions 按选项解引用DID URL
+// 支持：整个文档、#fragment 指向的验证方法或服务、?service=xxx[&relativeRef=yyy] 选择服务端点
+func (r *DIDResolver) DereferenceWithOptions(didURL string, opts DereferenceOptions) (*DereferencingResult, error) {
+	contentType := opts.Accept
+	switch contentType {
+	case "", "*/*":
+		contentType = ContentTypeDIDLDJSON
+	case ContentTypeDIDLDJSON, ContentTypeDIDJSON:
+	default:
+		return dereferenceError(DereferenceErrorContentTypeNotSupported), nil
+	}
+
+	parsed, err := ParseDIDURL(didURL)
+	if err != nil {
+		return dereferenceError(DereferenceErrorInvalidDIDURL), nil
+	}
+
+	// 目前没有通过路径寻址的资源
+	if parsed.Path != "" {
+		return dereferenceError(DereferenceErrorNotFound), nil
+	}
+
+	// 解析DID，版本参数交给解析器处理
+	resolution, err := r.Resolve(parsed.DID + versionParams(parsed.Query))
+	if err != nil {
+		return nil, err
+	}
+	if resolution.DIDResolutionMetadata.Error != "" {
+		errorCode := resolution.DIDResolutionMetadata.Error
+		if errorCode == "invalidDid" {
+			errorCode = DereferenceErrorInvalidDIDURL
+		}
+		return dereferenceError(errorCode), nil
+	}
+	doc := resolution.DIDDocument
+
+	// 服务选择：返回服务端点URL
+	if serviceName := parsed.Query.Get("service"); serviceName != "" {
+		service := findService(doc, serviceName)
+		if service == nil {
+			return dereferenceError(DereferenceErrorNotFound), nil
+		}
+
+		endpoint, ok := serviceEndpointURI(service.ServiceEndpoint)
+		if !ok {
+			return dereferenceError(DereferenceErrorNotFound), nil
+		}
+
+		output, err := url.Parse(endpoint)
+		if err != nil {
+			return dereferenceError(DereferenceErrorInvalidDIDURL), nil
+		}
+		if relativeRef := parsed.Query.Get("relativeRef"); relativeRef != "" {
+			ref, err := url.Parse(relativeRef)
+			if err != nil {
+				return dereferenceError(DereferenceErrorInvalidDIDURL), nil
+			}
+			output = output.ResolveReference(ref)
+		}
+		// 片段作用于最终的服务URL
+		if parsed.Fragment != "" {
+			output.Fragment = parsed.Fragment
+		}
+
+		return &DereferencingResult{
+			DereferencingMetadata: &DereferencingMetadata{ContentType: ContentTypeURIList},
+			ContentStream:         output.String(),
+			ContentMetadata:       resolution.DIDDocumentMetadata,
+		}, nil
+	}
+
+	// 没有片段时返回整个文档
+	if parsed.Fragment == "" {
+		return &DereferencingResult{
+			DereferencingMetadata: &DereferencingMetadata{ContentType: contentType},
+			ContentStream:         doc,
+			ContentMetadata:       resolution.DIDDocumentMetadata,
+		}, nil
+	}
+
+	// 片段：文档内的验证方法或服务
+	var resource interface{}
+	if vm := findVerificationMethodByFragment(doc, parsed.Fragment); vm != nil {
+		resource = vm
+	} else if service := findService(doc, parsed.Fragment); service != nil {
+		resource = service
+	} else {
+		return dereferenceError(DereferenceErrorNotFound), nil
+	}
+
+	return &DereferencingResult{
+		DereferencingMetadata: &DereferencingMetadata{ContentType: contentType},
+		ContentStream:         resource,
+		ContentMetadata:       resolution.DIDDocumentMetadata,
+	}, nil
+}
+
+// dereferenceError 创建带错误的解引用结果
+func dereferenceError(code string) *DereferencingResult {
+	return &DereferencingResult{
+		DereferencingMetadata: &DereferencingMetadata{Error: code},
+	}
+}
+
+// versionParams 只保留需要传给解析器的版本参数
+func versionParams(query url.Values) string {
+	params := url.Values{}
+	for _, key := range []string{"versionId", "versionTime"} {
+		if value := query.Get(key); value != "" {
+			params.Set(key, value)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+// matchesFragment 判断资源ID是否指向给定片段，兼容绝对ID和相对ID（#key-1）
+func matchesFragment(doc *types.DIDDocument, id, fragment string) bool {
+	return id == doc.ID+"#"+fragment || id == "#"+fragment
+}
+
+// findVerificationMethodByFragment 按片段查找验证方法
+func findVerificationMethodByFragment(doc *types.DIDDocument, fragment string) *types.VerificationMethod {
+	for i := range doc.VerificationMethod {
+		if matchesFragment(doc, doc.VerificationMethod[i].ID, fragment) {
+			vm := doc.VerificationMethod[i]
+			return &vm
+		}
+	}
+	return nil
+}
+
+// findService 按片段查找服务
+func findService(doc *types.DIDDocument, fragment string) *types.Service {
+	for i := range doc.Service {
+		if matchesFragment(doc, doc.Service[i].ID, fragment) {
+			service := doc.Service[i]
+			return &service
+		}
+	}
+	return nil
+}
+
+// serviceEndpointURI 提取服务端点URI
+// serviceEndpoint 可以是字符串、带uri字段的对象（如DIDCommMessaging）或它们组成的数组
+func serviceEndpointURI(endpoint interface{}) (string, bool) {
+	switch v := endpoint.(type) {
+	case string:
+		return v, strings.TrimSpace(v) != ""
+	case map[string]interface{}:
+		uri, ok := v["uri"].(string)
+		return uri, ok && uri != ""
+	case []interface{}:
+		for _, item := range v {
+			if uri, ok := serviceEndpointURI(item); ok {
+				return uri, true
+			}
+		}
+	case []string:
+		if len(v) > 0 {
+			return v[0], v[0] != ""
+		}
+	}
+	return "", false
+}
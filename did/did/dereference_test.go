@@ -0,0 +1,111 @@
+package did
+
+import (
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestParseDIDURL(t *testing.T) {
+	u, err := ParseDIDURL("did:qlink:abc:1/path/to?service=messaging&relativeRef=%2Finbox#key-1")
+	if err != nil {
+		t.Fatalf("解析DID URL失败: %v", err)
+	}
+	if u.DID != "did:qlink:abc:1" || u.Method != "qlink" || u.ID != "abc:1" {
+		t.Errorf("DID部分不正确: %+v", u)
+	}
+	if u.Path != "/path/to" || u.Fragment != "key-1" {
+		t.Errorf("路径或片段不正确: %q, %q", u.Path, u.Fragment)
+	}
+	if u.Query.Get("service") != "messaging" || u.Query.Get("relativeRef") != "/inbox" {
+		t.Errorf("查询参数不正确: %v", u.Query)
+	}
+	if u.String() != "did:qlink:abc:1/path/to?service=messaging&relativeRef=%2Finbox#key-1" {
+		t.Errorf("字符串形式不正确: %s", u.String())
+	}
+
+	invalid := []string{
+		"qlink:abc",
+		"did:QLINK:abc",
+		"did:qlink:",
+		"did:qlink:abc:",
+		"did:qlink:a b",
+		"did:qlink:abc#key 1",
+		"did:qlink:abc%zz",
+	}
+	for _, raw := range invalid {
+		if _, err := ParseDIDURL(raw); err == nil {
+			t.Errorf("应当拒绝无效的DID URL: %s", raw)
+		}
+	}
+}
+
+func TestDereference(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	jack := newTestIdentity(t, "did:qlink:jack")
+	req := jack.registerRequest(t)
+	req.Service = []types.Service{
+		{ID: jack.did + "#messaging", Type: "DIDCommMessaging", ServiceEndpoint: map[string]interface{}{"uri": "https://example.com/didcomm/"}},
+		{ID: "#web", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+	}
+	if _, err := registry.Register(req); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	resolver := NewDIDResolver(config.DefaultConfig(), registry, nil)
+
+	tests := []struct {
+		name      string
+		url       string
+		accept    string
+		wantError string
+		check     func(t *testing.T, result *DereferencingResult)
+	}{
+		{name: "整个文档", url: jack.did, check: func(t *testing.T, result *DereferencingResult) {
+			if doc, ok := result.ContentStream.(*types.DIDDocument); !ok || doc.ID != jack.did {
+				t.Errorf("应返回DID文档: %#v", result.ContentStream)
+			}
+		}},
+		{name: "验证方法", url: jack.did + "#key-1", check: func(t *testing.T, result *DereferencingResult) {
+			if vm, ok := result.ContentStream.(*types.VerificationMethod); !ok || vm.ID != jack.did+"#key-1" {
+				t.Errorf("应返回验证方法: %#v", result.ContentStream)
+			}
+		}},
+		{name: "相对ID的服务", url: jack.did + "#web", check: func(t *testing.T, result *DereferencingResult) {
+			if service, ok := result.ContentStream.(*types.Service); !ok || service.Type != "LinkedDomains" {
+				t.Errorf("应返回服务: %#v", result.ContentStream)
+			}
+		}},
+		{name: "服务端点", url: jack.did + "?service=messaging&relativeRef=%2Finbox", check: func(t *testing.T, result *DereferencingResult) {
+			if result.ContentStream != "https://example.com/inbox" || result.DereferencingMetadata.ContentType != ContentTypeURIList {
+				t.Errorf("服务端点URL不正确: %v, %s", result.ContentStream, result.DereferencingMetadata.ContentType)
+			}
+		}},
+		{name: "无效URL", url: "did:qlink:jack#a b", wantError: DereferenceErrorInvalidDIDURL},
+		{name: "不存在的片段", url: jack.did + "#key-9", wantError: DereferenceErrorNotFound},
+		{name: "不存在的服务", url: jack.did + "?service=missing", wantError: DereferenceErrorNotFound},
+		{name: "不存在的DID", url: "did:qlink:nobody", wantError: DereferenceErrorNotFound},
+		{name: "不支持的内容类型", url: jack.did, accept: "application/did+cbor", wantError: DereferenceErrorContentTypeNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.DereferenceWithOptions(tt.url, DereferenceOptions{Accept: tt.accept})
+			if err != nil {
+				t.Fatalf("解引用失败: %v", err)
+			}
+			if result.DereferencingMetadata.Error != tt.wantError {
+				t.Fatalf("错误码不正确: 期望 %q, 实际 %q", tt.wantError, result.DereferencingMetadata.Error)
+			}
+			if tt.check != nil {
+				tt.check(t, result)
+			}
+		})
+	}
+
+	vm, err := resolver.ResolveVerificationMethod(jack.did + "#key-1")
+	if err != nil || vm.Controller != jack.did {
+		t.Errorf("解析验证方法失败: %v, %v", vm, err)
+	}
+}
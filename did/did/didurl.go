@@ -0,0 +1,153 @@
+package did
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DIDURL 解析后的DID URL（W3C DID Core 3.2）
+// did-url = did path-abempty [ "?" query ] [ "#" fragment ]
+type DIDURL struct {
+	DID      string     // 不含路径、查询和片段的DID
+	Method   string     // DID方法名
+	ID       string     // 方法特定标识符
+	Path     string     // 以"/"开头的路径，可为空
+	Query    url.Values // 查询参数
+	RawQuery string     // 原始查询字符串
+	Fragment string     // 片段（不含"#"）
+}
+
+// ParseDIDURL 解析DID URL
+func ParseDIDURL(raw string) (*DIDURL, error) {
+	if !strings.HasPrefix(raw, "did:") {
+		return nil, fmt.Errorf("DID URL必须以'did:'开头: %s", raw)
+	}
+
+	u := &DIDURL{Query: url.Values{}}
+
+	rest, fragment, hasFragment := strings.Cut(raw, "#")
+	if hasFragment {
+		if err := validateURLPart(fragment, "/?"); err != nil {
+			return nil, fmt.Errorf("无效的片段: %w", err)
+		}
+		u.Fragment = fragment
+	}
+
+	rest, rawQuery, hasQuery := strings.Cut(rest, "?")
+	if hasQuery {
+		if err := validateURLPart(rawQuery, "/?"); err != nil {
+			return nil, fmt.Errorf("无效的查询: %w", err)
+		}
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("无效的查询: %w", err)
+		}
+		u.RawQuery = rawQuery
+		u.Query = query
+	}
+
+	didPart, path, hasPath := strings.Cut(rest, "/")
+	if hasPath {
+		path = "/" + path
+		if err := validateURLPart(path, "/"); err != nil {
+			return nil, fmt.Errorf("无效的路径: %w", err)
+		}
+		u.Path = path
+	}
+
+	// did = "did:" method-name ":" method-specific-id
+	parts := strings.SplitN(didPart, ":", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("DID格式无效，至少需要3个部分: %s", didPart)
+	}
+	if !isValidMethodName(parts[1]) {
+		return nil, fmt.Errorf("无效的DID方法名: %s", parts[1])
+	}
+	if !isValidMethodSpecificID(parts[2]) {
+		return nil, fmt.Errorf("无效的方法特定标识符: %s", parts[2])
+	}
+
+	u.DID = didPart
+	u.Method = parts[1]
+	u.ID = parts[2]
+	return u, nil
+}
+
+// String 返回DID URL的字符串形式
+func (u *DIDURL) String() string {
+	var b strings.Builder
+	b.WriteString(u.DID)
+	b.WriteString(u.Path)
+	if u.RawQuery != "" {
+		b.WriteString("?")
+		b.WriteString(u.RawQuery)
+	}
+	if u.Fragment != "" {
+		b.WriteString("#")
+		b.WriteString(u.Fragment)
+	}
+	return b.String()
+}
+
+// isValidMethodName method-name = 1*method-char，method-char = %x61-7A / DIGIT
+func isValidMethodName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidMethodSpecificID method-specific-id = *( *idchar ":" ) 1*idchar
+func isValidMethodSpecificID(id string) bool {
+	if id == "" || strings.HasSuffix(id, ":") {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case isAlphaNum(c), c == '.', c == '-', c == '_', c == ':':
+		case c == '%':
+			if i+2 >= len(id) || !isHex(id[i+1]) || !isHex(id[i+2]) {
+				return false
+			}
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validateURLPart 按RFC 3986校验路径、查询或片段中的字符
+// pchar = unreserved / pct-encoded / sub-delims / ":" / "@"，extra 为额外允许的字符
+func validateURLPart(s, extra string) error {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isAlphaNum(c), strings.IndexByte("-._~!$&'()*+,;=:@", c) >= 0, strings.IndexByte(extra, c) >= 0:
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return fmt.Errorf("无效的百分号编码: %s", s)
+			}
+			i += 2
+		default:
+			return fmt.Errorf("非法字符 %q", c)
+		}
+	}
+	return nil
+}
+
+func isAlphaNum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
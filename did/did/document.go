@@ -1,6 +1,8 @@
 package did
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -10,32 +12,44 @@ import (
 
 // DIDDocumentBuilder DID文档构建器
 type DIDDocumentBuilder struct {
-	keyPair *crypto.HybridKeyPair
-	did     string
+	keyPair     *crypto.HybridKeyPair
+	suite       *crypto.AlgorithmSuite // 密钥对所属的算法套件
+	did         string
+	keyID       string                // 当前签名密钥的验证方法ID，轮换后指向新密钥
+	next        *crypto.HybridKeyPair // 预轮换密钥，文档中只公开其承诺
+	services    []types.Service
+	controllers []string // 控制者DID
+	err         error    // AddService 等链式调用中的第一个错误，由 BuildDocument 返回
 }
 
-// NewDIDDocumentBuilder 创建DID文档构建器
+// NewDIDDocumentBuilder 使用默认注册表中最偏好的算法套件创建DID文档构建器
 func NewDIDDocumentBuilder() (*DIDDocumentBuilder, error) {
-	// 生成混合密钥对
-	keyPair, err := crypto.GenerateHybridKeyPair()
+	suite, err := crypto.DefaultSuites.Preferred()
 	if err != nil {
-		return nil, fmt.Errorf("生成密钥对失败: %w", err)
+		return nil, err
 	}
+	return NewDIDDocumentBuilderForSuite(suite.ID)
+}
 
-	// 从密钥对生成DID
-	did, err := crypto.GenerateDIDFromKeyPair(keyPair)
+// NewDIDDocumentBuilderForSuite 使用指定算法套件生成密钥对并创建DID文档构建器
+func NewDIDDocumentBuilderForSuite(suiteID string) (*DIDDocumentBuilder, error) {
+	// 生成混合密钥对
+	keyPair, err := crypto.GenerateKeyPairForSuite(suiteID)
 	if err != nil {
-		return nil, fmt.Errorf("生成DID失败: %w", err)
+		return nil, fmt.Errorf("生成密钥对失败: %w", err)
 	}
 
-	return &DIDDocumentBuilder{
-		keyPair: keyPair,
-		did:     did,
-	}, nil
+	return NewDIDDocumentBuilderFromKeyPair(keyPair)
 }
 
 // NewDIDDocumentBuilderFromKeyPair 从现有密钥对创建DID文档构建器
+// 密钥对所属的算法套件必须在默认注册表中启用
 func NewDIDDocumentBuilderFromKeyPair(keyPair *crypto.HybridKeyPair) (*DIDDocumentBuilder, error) {
+	suite, err := crypto.DefaultSuites.Lookup(keyPair.SuiteID())
+	if err != nil {
+		return nil, err
+	}
+
 	// 从密钥对生成DID
 	did, err := crypto.GenerateDIDFromKeyPair(keyPair)
 	if err != nil {
@@ -44,22 +58,26 @@ func NewDIDDocumentBuilderFromKeyPair(keyPair *crypto.HybridKeyPair) (*DIDDocume
 
 	return &DIDDocumentBuilder{
 		keyPair: keyPair,
+		suite:   suite,
 		did:     did,
+		keyID:   did + "#key-1",
 	}, nil
 }
 
 // BuildDocument 构建DID文档
 func (builder *DIDDocumentBuilder) BuildDocument() (*types.DIDDocument, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+
 	// 将公钥转换为JWK格式
 	jwk, err := builder.keyPair.ToJWK()
 	if err != nil {
 		return nil, fmt.Errorf("转换公钥为JWK失败: %w", err)
 	}
 
-	// 创建验证方法ID
-	verificationMethodID := fmt.Sprintf("%s#key-1", builder.did)
-
 	// 创建验证方法
+	verificationMethodID := builder.keyID
 	verificationMethod := types.VerificationMethod{
 		ID:           verificationMethodID,
 		Type:         "JsonWebKey2020",
@@ -74,56 +92,152 @@ func (builder *DIDDocumentBuilder) BuildDocument() (*types.DIDDocument, error) {
 			"https://www.w3.org/ns/did/v1",
 			"https://w3id.org/security/suites/jws-2020/v1",
 		},
-		ID:                 builder.did,
-		VerificationMethod: []types.VerificationMethod{verificationMethod},
-		Authentication:     []string{verificationMethodID},
-		AssertionMethod:    []string{verificationMethodID},
-		KeyAgreement:       []string{verificationMethodID},
-		Created:            &now,
-		Updated:            &now,
-		Status:             "active",
+		ID:                   builder.did,
+		Controller:           append(types.StringOrSet(nil), builder.controllers...),
+		VerificationMethod:   []types.VerificationMethod{verificationMethod},
+		Authentication:       []string{verificationMethodID},
+		AssertionMethod:      []string{verificationMethodID},
+		KeyAgreement:         []string{verificationMethodID},
+		CapabilityInvocation: []string{verificationMethodID},
+		CapabilityDelegation: []string{verificationMethodID},
+		Service:              append([]types.Service(nil), builder.services...),
+		AlgorithmSuites:      []string{builder.suite.ID},
+		Created:              &now,
+		Updated:              &now,
+		Status:               "active",
+	}
+
+	if builder.next != nil {
+		commitment, err := builder.next.GetNextKeyCommitment()
+		if err != nil {
+			return nil, fmt.Errorf("计算预轮换承诺失败: %w", err)
+		}
+		doc.NextKeyCommitment = commitment
 	}
 
 	return doc, nil
 }
 
 // AddService 添加服务端点
+// DIDCommMessaging 的端点会包装为 {"uri": endpoint, "accept": ["didcomm/v2"]}；
+// 服务ID按类型自动生成（#didcomm、#linked-domain、#service，重复时追加序号）
 func (builder *DIDDocumentBuilder) AddService(serviceType, endpoint string) *DIDDocumentBuilder {
-	// 这里可以扩展服务添加逻辑
+	var serviceEndpoint interface{} = endpoint
+	if serviceType == ServiceTypeDIDCommMessaging {
+		serviceEndpoint = map[string]interface{}{
+			"uri":    endpoint,
+			"accept": []string{"didcomm/v2"},
+		}
+	}
+
+	return builder.AddServiceEntry(types.Service{
+		ID:              builder.nextServiceID(serviceType),
+		Type:            serviceType,
+		ServiceEndpoint: serviceEndpoint,
+	})
+}
+
+// AddDIDCommService 添加带中继路由密钥的DIDComm消息端点
+func (builder *DIDDocumentBuilder) AddDIDCommService(uri string, routingKeys []string) *DIDDocumentBuilder {
+	endpoint := map[string]interface{}{
+		"uri":    uri,
+		"accept": []string{"didcomm/v2"},
+	}
+	if len(routingKeys) > 0 {
+		endpoint["routingKeys"] = routingKeys
+	}
+
+	return builder.AddServiceEntry(types.Service{
+		ID:              builder.nextServiceID(ServiceTypeDIDCommMessaging),
+		Type:            ServiceTypeDIDCommMessaging,
+		ServiceEndpoint: endpoint,
+	})
+}
+
+// AddServiceEntry 添加完整的服务条目，校验失败时记录错误
+func (builder *DIDDocumentBuilder) AddServiceEntry(service types.Service) *DIDDocumentBuilder {
+	if builder.err != nil {
+		return builder
+	}
+
+	services := append(append([]types.Service(nil), builder.services...), service)
+	if err := validateServices(builder.did, services); err != nil {
+		builder.err = err
+		return builder
+	}
+
+	builder.services = services
 	return builder
 }
 
+// nextServiceID 按服务类型生成未被占用的服务ID
+func (builder *DIDDocumentBuilder) nextServiceID(serviceType string) string {
+	base := "service"
+	switch serviceType {
+	case ServiceTypeDIDCommMessaging:
+		base = "didcomm"
+	case ServiceTypeLinkedDomains:
+		base = "linked-domain"
+	}
+
+	taken := make(map[string]bool, len(builder.services))
+	for _, service := range builder.services {
+		taken[serviceFragment(builder.did, service.ID)] = true
+	}
+
+	fragment := base
+	for i := 1; taken[fragment]; i++ {
+		fragment = fmt.Sprintf("%s-%d", base, i)
+	}
+	return builder.did + "#" + fragment
+}
+
 // GetDID 获取DID
 func (builder *DIDDocumentBuilder) GetDID() string {
 	return builder.did
 }
 
+// GetKeyID 获取当前签名密钥的验证方法ID
+func (builder *DIDDocumentBuilder) GetKeyID() string {
+	return builder.keyID
+}
+
+// SetController 设置控制者DID，控制者的密钥可以代为更新和撤销该DID
+func (builder *DIDDocumentBuilder) SetController(controllers ...string) *DIDDocumentBuilder {
+	builder.controllers = controllers
+	return builder
+}
+
+// SetNextKeyPair 设置预轮换密钥，注册时文档会携带它的承诺
+func (builder *DIDDocumentBuilder) SetNextKeyPair(next *crypto.HybridKeyPair) *DIDDocumentBuilder {
+	builder.next = next
+	return builder
+}
+
+// GetNextKeyPair 获取预轮换密钥
+func (builder *DIDDocumentBuilder) GetNextKeyPair() *crypto.HybridKeyPair {
+	return builder.next
+}
+
 // GetKeyPair 获取密钥对
 func (builder *DIDDocumentBuilder) GetKeyPair() *crypto.HybridKeyPair {
 	return builder.keyPair
 }
 
 // SignDocument 对DID文档进行签名
+// 生成分离式JWS证明（组合密钥为 CompositeSignature2025，否则为JsonWebSignature2020），签名覆盖规范化后的文档（不含proof）和证明选项
 func (builder *DIDDocumentBuilder) SignDocument(doc *types.DIDDocument) error {
-	// 序列化文档用于签名
-	docData, err := doc.ToJSON()
-	if err != nil {
-		return fmt.Errorf("序列化文档失败: %w", err)
-	}
-
-	// 使用混合密钥对文档进行签名
-	signature, err := builder.keyPair.Sign(docData)
-	if err != nil {
-		return fmt.Errorf("签名失败: %w", err)
-	}
-
-	// 创建证明
 	proof := &types.Proof{
 		Type:               "JsonWebSignature2020",
 		Created:            time.Now(),
-		VerificationMethod: fmt.Sprintf("%s#key-1", builder.did),
+		VerificationMethod: builder.keyID,
 		ProofPurpose:       "assertionMethod",
-		ProofValue:         fmt.Sprintf("%x", signature.ECDSASignature),
+	}
+
+	// 签名时忽略文档上已有的证明
+	doc.Proof = nil
+	if err := builder.keyPair.SignProof(doc, proof); err != nil {
+		return fmt.Errorf("签名失败: %w", err)
 	}
 
 	doc.Proof = proof
@@ -136,32 +250,141 @@ func VerifyDocument(doc *types.DIDDocument, keyPair *crypto.HybridKeyPair) error
 		return fmt.Errorf("文档没有证明")
 	}
 
-	// 临时移除证明进行验证
-	originalProof := doc.Proof
-	doc.Proof = nil
+	jwk, err := keyPair.ToJWK()
+	if err != nil {
+		return fmt.Errorf("转换公钥为JWK失败: %w", err)
+	}
 
-	// 序列化文档
-	docData, err := doc.ToJSON()
+	verificationMethod := &types.VerificationMethod{
+		ID:           doc.Proof.VerificationMethod,
+		Type:         "JsonWebKey2020",
+		Controller:   doc.ID,
+		PublicKeyJwk: jwk,
+	}
+
+	// 规范化时会去掉文档上的proof，无需临时修改文档
+	if err := crypto.NewSignatureVerifier().VerifyProofSignature(doc, doc.Proof, verificationMethod); err != nil {
+		return fmt.Errorf("签名验证失败: %w", err)
+	}
+
+	return nil
+}
+
+// signOperation 为变更操作生成带nonce的证明
+func (builder *DIDDocumentBuilder) signOperation(payload interface{}, purpose string) (*types.Proof, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %w", err)
+	}
+
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: builder.keyID,
+		ProofPurpose:       purpose,
+		Nonce:              hex.EncodeToString(nonce),
+	}
+	if err := builder.keyPair.SignProof(payload, proof); err != nil {
+		return nil, fmt.Errorf("签名失败: %w", err)
+	}
+
+	return proof, nil
+}
+
+// SignPayload 用当前密钥为任意DID的操作内容生成证明
+// 作为控制者代管其他DID时，对被控DID的 SigningPayload 签名
+func (builder *DIDDocumentBuilder) SignPayload(payload interface{}, purpose string) (*types.Proof, error) {
+	return builder.signOperation(payload, purpose)
+}
+
+// SignSetControllers 为变更控制者生成证明
+func (builder *DIDDocumentBuilder) SignSetControllers(controllers []string) (*types.Proof, error) {
+	return builder.signOperation(ControllerSigningPayload(builder.did, controllers), "capabilityDelegation")
+}
+
+// SignAddService 为添加服务生成证明
+func (builder *DIDDocumentBuilder) SignAddService(service types.Service) (*types.Proof, error) {
+	return builder.signOperation(ServiceSigningPayload(builder.did, service), "authentication")
+}
+
+// SignSetService 为添加或替换服务生成证明
+func (builder *DIDDocumentBuilder) SignSetService(service types.Service) (*types.Proof, error) {
+	return builder.signOperation(SetServiceSigningPayload(builder.did, service), "authentication")
+}
+
+// SignRemoveService 为移除服务生成证明
+func (builder *DIDDocumentBuilder) SignRemoveService(serviceID string) (*types.Proof, error) {
+	return builder.signOperation(RemoveServiceSigningPayload(builder.did, serviceID), "authentication")
+}
+
+// CreateRotationRequest 创建密钥轮换请求
+// 请求由当前密钥签名，新密钥接替当前密钥的全部验证关系；之后构建器改用新密钥签名
+func (builder *DIDDocumentBuilder) CreateRotationRequest(current *types.DIDDocument, newKeyPair *crypto.HybridKeyPair) (*RotateKeyRequest, error) {
+	suite, err := crypto.DefaultSuites.Lookup(newKeyPair.SuiteID())
 	if err != nil {
-		doc.Proof = originalProof
-		return fmt.Errorf("序列化文档失败: %w", err)
+		return nil, err
 	}
 
-	// 恢复证明
-	doc.Proof = originalProof
+	newVM, err := NewKeyVerificationMethod(builder.did, NextKeyID(current), newKeyPair)
+	if err != nil {
+		return nil, err
+	}
 
-	// 解析签名
-	signatureBytes := []byte(originalProof.ProofValue) // 使用ProofValue字段而不是Jws
-	signature := &crypto.HybridSignature{
-		ECDSASignature: signatureBytes,
+	req := &RotateKeyRequest{
+		DID:                   builder.did,
+		NewVerificationMethod: *newVM,
+		RetireKeyID:           builder.keyID,
 	}
+	proof, err := builder.signOperation(req.SigningPayload(), "authentication")
+	if err != nil {
+		return nil, err
+	}
+	req.Proof = proof
 
-	// 验证签名
-	if !keyPair.Verify(docData, signature) {
-		return fmt.Errorf("签名验证失败")
+	builder.keyPair = newKeyPair
+	builder.suite = suite
+	builder.keyID = newVM.ID
+	return req, nil
+}
+
+// CreatePreRotationRequest 为有预轮换承诺的文档创建轮换请求
+// 公开预轮换密钥并由它签名，following 成为新的预轮换密钥；之后构建器使用公开的密钥签名
+func (builder *DIDDocumentBuilder) CreatePreRotationRequest(current *types.DIDDocument, following *crypto.HybridKeyPair) (*RotateKeyRequest, error) {
+	if builder.next == nil {
+		return nil, fmt.Errorf("未设置预轮换密钥")
+	}
+	suite, err := crypto.DefaultSuites.Lookup(builder.next.SuiteID())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	newVM, err := NewKeyVerificationMethod(builder.did, NextKeyID(current), builder.next)
+	if err != nil {
+		return nil, err
+	}
+	commitment, err := following.GetNextKeyCommitment()
+	if err != nil {
+		return nil, fmt.Errorf("计算预轮换承诺失败: %w", err)
+	}
+
+	req := &RotateKeyRequest{
+		DID:                   builder.did,
+		NewVerificationMethod: *newVM,
+		RetireKeyID:           builder.keyID,
+		NextKeyCommitment:     commitment,
+	}
+
+	previousKeyPair, previousKeyID := builder.keyPair, builder.keyID
+	builder.keyPair, builder.keyID = builder.next, newVM.ID
+	proof, err := builder.signOperation(req.SigningPayload(), "authentication")
+	if err != nil {
+		builder.keyPair, builder.keyID = previousKeyPair, previousKeyID
+		return nil, err
+	}
+	req.Proof = proof
+
+	builder.suite = suite
+	builder.next = following
+	return req, nil
 }
 
 // CreateRegistrationRequest 创建DID注册请求
@@ -179,9 +402,16 @@ func (builder *DIDDocumentBuilder) CreateRegistrationRequest() (*RegisterRequest
 
 	// 创建注册请求
 	req := &RegisterRequest{
-		DID:                builder.did,
-		VerificationMethod: doc.VerificationMethod,
-		Service:            doc.Service,
+		DID:                  builder.did,
+		VerificationMethod:   doc.VerificationMethod,
+		Service:              doc.Service,
+		Authentication:       doc.Authentication,
+		AssertionMethod:      doc.AssertionMethod,
+		KeyAgreement:         doc.KeyAgreement,
+		CapabilityInvocation: doc.CapabilityInvocation,
+		CapabilityDelegation: doc.CapabilityDelegation,
+		Controller:           doc.Controller,
+		NextKeyCommitment:    doc.NextKeyCommitment,
 	}
 
 	return req, nil
@@ -198,19 +428,20 @@ func (builder *DIDDocumentBuilder) CreateUpdateRequest(newServices []types.Servi
 	// 添加新服务
 	doc.Service = newServices
 
-	// 对文档进行签名
-	if err := builder.SignDocument(doc); err != nil {
-		return nil, err
-	}
-
 	// 创建更新请求
 	req := &UpdateRequest{
 		DID:                builder.did,
 		VerificationMethod: doc.VerificationMethod,
 		Service:            doc.Service,
-		Proof:              doc.Proof,
 	}
 
+	// 对更新内容进行签名
+	proof, err := builder.signOperation(req.SigningPayload(), "authentication")
+	if err != nil {
+		return nil, err
+	}
+	req.Proof = proof
+
 	return req, nil
 }
 
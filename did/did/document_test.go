@@ -0,0 +1,54 @@
+package did
+
+import (
+	"testing"
+)
+
+func TestSignAndVerifyDocument(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+
+	doc, err := builder.BuildDocument()
+	if err != nil {
+		t.Fatalf("构建文档失败: %v", err)
+	}
+	if err := builder.SignDocument(doc); err != nil {
+		t.Fatalf("签名文档失败: %v", err)
+	}
+
+	if err := VerifyDocument(doc, builder.GetKeyPair()); err != nil {
+		t.Fatalf("验证文档失败: %v", err)
+	}
+
+	doc.Status = "revoked"
+	if err := VerifyDocument(doc, builder.GetKeyPair()); err == nil {
+		t.Error("篡改后的文档不应通过验证")
+	}
+}
+
+func TestCreateUpdateRequestIsAccepted(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+
+	registry := NewDIDRegistry(nil)
+	if _, err := registry.Register(regReq); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	updateReq, err := builder.CreateUpdateRequest(nil)
+	if err != nil {
+		t.Fatalf("创建更新请求失败: %v", err)
+	}
+	if _, err := registry.Update(updateReq); err != nil {
+		t.Fatalf("构建器生成的更新请求应通过验证: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package did
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MethodDriver DID方法驱动，每个DID方法注册一个驱动
+type MethodDriver interface {
+	// Method 返回驱动处理的DID方法名（如 "qlink"、"key"）
+	Method() string
+	// Resolve 解析DID；DID不存在等标准错误通过 DIDResolutionMetadata.Error 返回，
+	// 只有驱动内部故障才返回error
+	Resolve(ctx context.Context, did string, opts ResolutionOptions) (*ResolutionResult, error)
+}
+
+// ResolutionOptions 解析选项
+type ResolutionOptions struct {
+	VersionID   string
+	VersionTime *time.Time
+}
+
+// RegisterDriver 注册DID方法驱动，同名方法的驱动会被替换
+func (r *DIDResolver) RegisterDriver(driver MethodDriver) {
+	r.driversMu.Lock()
+	defer r.driversMu.Unlock()
+
+	r.drivers[driver.Method()] = driver
+}
+
+// UnregisterDriver 移除DID方法驱动
+func (r *DIDResolver) UnregisterDriver(method string) {
+	r.driversMu.Lock()
+	defer r.driversMu.Unlock()
+
+	delete(r.drivers, method)
+}
+
+// getDriver 获取DID方法驱动
+func (r *DIDResolver) getDriver(method string) (MethodDriver, bool) {
+	r.driversMu.RLock()
+	defer r.driversMu.RUnlock()
+
+	driver, ok := r.drivers[method]
+	return driver, ok
+}
+
+// GetSupportedMethods 获取支持的DID方法
+func (r *DIDResolver) GetSupportedMethods() []string {
+	r.driversMu.RLock()
+	defer r.driversMu.RUnlock()
+
+	methods := make([]string, 0, len(r.drivers))
+	for method := range r.drivers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// IsSupported 检查是否支持指定的DID方法
+func (r *DIDResolver) IsSupported(method string) bool {
+	_, ok := r.getDriver(method)
+	return ok
+}
+
+// qlinkDriver did:qlink 驱动，基于注册表及链下存储解析
+type qlinkDriver struct {
+	resolver *DIDResolver
+}
+
+func (d *qlinkDriver) Method() string {
+	return "qlink"
+}
+
+func (d *qlinkDriver) Resolve(ctx context.Context, didStr string, opts ResolutionOptions) (*ResolutionResult, error) {
+	if opts.VersionID != "" || opts.VersionTime != nil {
+		return d.resolver.resolveQlinkVersion(didStr, &versionQuery{
+			versionID:   opts.VersionID,
+			versionTime: opts.VersionTime,
+		})
+	}
+	return d.resolver.resolveQlinkDID(didStr)
+}
+
+// resolutionError 创建带错误的解析结果
+func resolutionError(code string) *ResolutionResult {
+	return &ResolutionResult{
+		DIDResolutionMetadata: &ResolutionMetadata{
+			ContentType: ContentTypeDIDLDJSON,
+			Error:       code,
+		},
+		DIDDocumentMetadata: &DocumentMetadata{},
+	}
+}
@@ -0,0 +1,119 @@
+package did
+
+import (
+	"context"
+	"crypto/elliptic"
+	"crypto/mldsa"
+	"crypto/mlkem"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// keyDriver did:key 驱动，文档完全由公钥派生，无需任何存储
+// 支持 Ed25519、P-256、ML-KEM-768 和 ML-DSA-65 公钥
+type keyDriver struct{}
+
+// NewKeyDriver 创建did:key驱动
+func NewKeyDriver() MethodDriver {
+	return &keyDriver{}
+}
+
+func (d *keyDriver) Method() string {
+	return "key"
+}
+
+// FormatDIDKey 由multicodec类型和原始公钥生成did:key
+func FormatDIDKey(codec uint64, publicKey []byte) string {
+	return "did:key:" + crypto.EncodeMultikey(codec, publicKey)
+}
+
+func (d *keyDriver) Resolve(ctx context.Context, didStr string, opts ResolutionOptions) (*ResolutionResult, error) {
+	// did:key 不可变，只有一个版本
+	if opts.VersionID != "" || opts.VersionTime != nil {
+		return resolutionError(ResolutionErrorNotFound), nil
+	}
+
+	multibase := strings.TrimPrefix(didStr, "did:key:")
+	codec, publicKey, err := crypto.DecodeMultikey(multibase)
+	if err != nil {
+		return resolutionError(ResolutionErrorInvalidDID), nil
+	}
+
+	vm, err := keyVerificationMethod(didStr, multibase, codec, publicKey)
+	if err != nil {
+		return resolutionError(ResolutionErrorInvalidDID), nil
+	}
+
+	doc := &types.DIDDocument{
+		Context:            []string{"https://www.w3.org/ns/did/v1"},
+		ID:                 didStr,
+		VerificationMethod: []types.VerificationMethod{*vm},
+	}
+	switch codec {
+	case crypto.MulticodecMLKEM768Pub, crypto.MulticodecX25519Pub:
+		// KEM公钥只能用于密钥协商
+		doc.KeyAgreement = []string{vm.ID}
+	default:
+		doc.Authentication = []string{vm.ID}
+		doc.AssertionMethod = []string{vm.ID}
+		doc.CapabilityInvocation = []string{vm.ID}
+		doc.CapabilityDelegation = []string{vm.ID}
+	}
+
+	return &ResolutionResult{
+		DIDDocument: doc,
+		DIDResolutionMetadata: &ResolutionMetadata{
+			ContentType: ContentTypeDIDLDJSON,
+		},
+		DIDDocumentMetadata: &DocumentMetadata{},
+	}, nil
+}
+
+// keyVerificationMethod 根据公钥类型生成验证方法
+func keyVerificationMethod(didStr, multibase string, codec uint64, publicKey []byte) (*types.VerificationMethod, error) {
+	vm := &types.VerificationMethod{
+		ID:         didStr + "#" + multibase,
+		Controller: didStr,
+	}
+
+	switch codec {
+	case crypto.MulticodecEd25519Pub:
+		vm.Type = "Ed25519VerificationKey2020"
+		vm.PublicKeyMultibase = multibase
+	case crypto.MulticodecP256Pub:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKey)
+		if x == nil {
+			return nil, fmt.Errorf("无效的P-256公钥")
+		}
+		vm.Type = "JsonWebKey2020"
+		vm.PublicKeyJwk = map[string]interface{}{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(x.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(y.FillBytes(make([]byte, 32))),
+		}
+	case crypto.MulticodecMLKEM768Pub:
+		if _, err := mlkem.NewEncapsulationKey768(publicKey); err != nil {
+			return nil, err
+		}
+		vm.Type = "Multikey"
+		vm.PublicKeyMultibase = multibase
+	case crypto.MulticodecX25519Pub:
+		vm.Type = "Multikey"
+		vm.PublicKeyMultibase = multibase
+	case crypto.MulticodecMLDSA65Pub:
+		if _, err := mldsa.NewPublicKey(mldsa.MLDSA65(), publicKey); err != nil {
+			return nil, err
+		}
+		vm.Type = "Multikey"
+		vm.PublicKeyMultibase = multibase
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型: 0x%x", codec)
+	}
+
+	return vm, nil
+}
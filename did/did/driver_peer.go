@@ -0,0 +1,242 @@
+package did
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// did:peer:2 中各元素的用途前缀
+const (
+	PeerPurposeAssertion            = 'A'
+	PeerPurposeEncryption           = 'E'
+	PeerPurposeVerification         = 'V'
+	PeerPurposeCapabilityInvocation = 'I'
+	PeerPurposeCapabilityDelegation = 'D'
+	PeerPurposeService              = 'S'
+)
+
+// peerServiceAbbreviations did:peer:2 服务编码中的缩写
+var peerServiceAbbreviations = map[string]string{
+	"t":  "type",
+	"s":  "serviceEndpoint",
+	"r":  "routingKeys",
+	"a":  "accept",
+	"dm": "DIDCommMessaging",
+}
+
+// PeerKey did:peer:2 中的一个公钥
+type PeerKey struct {
+	Purpose   byte   // 用途前缀，如 PeerPurposeVerification
+	Multibase string // Multikey格式的公钥
+}
+
+// peerDriver did:peer 驱动，目前只支持 numalgo 2（内联密钥和服务）
+type peerDriver struct{}
+
+// NewPeerDriver 创建did:peer驱动
+func NewPeerDriver() MethodDriver {
+	return &peerDriver{}
+}
+
+func (d *peerDriver) Method() string {
+	return "peer"
+}
+
+// FormatPeerDID 生成did:peer:2
+func FormatPeerDID(keys []PeerKey, services []types.Service) (string, error) {
+	var b strings.Builder
+	b.WriteString("did:peer:2")
+
+	for _, key := range keys {
+		if !strings.ContainsRune("AEVID", rune(key.Purpose)) {
+			return "", fmt.Errorf("无效的密钥用途: %c", key.Purpose)
+		}
+		if _, _, err := crypto.DecodeMultikey(key.Multibase); err != nil {
+			return "", err
+		}
+		b.WriteByte('.')
+		b.WriteByte(key.Purpose)
+		b.WriteString(key.Multibase)
+	}
+
+	for _, service := range services {
+		encoded := map[string]interface{}{
+			"t": service.Type,
+			"s": service.ServiceEndpoint,
+		}
+		if service.Type == "DIDCommMessaging" {
+			encoded["t"] = "dm"
+		}
+		if service.ID != "" {
+			encoded["id"] = service.ID
+		}
+		data, err := json.Marshal(encoded)
+		if err != nil {
+			return "", fmt.Errorf("序列化服务失败: %w", err)
+		}
+		b.WriteString(".S")
+		b.WriteString(base64.RawURLEncoding.EncodeToString(data))
+	}
+
+	return b.String(), nil
+}
+
+func (d *peerDriver) Resolve(ctx context.Context, didStr string, opts ResolutionOptions) (*ResolutionResult, error) {
+	// did:peer 不可变，只有一个版本
+	if opts.VersionID != "" || opts.VersionTime != nil {
+		return resolutionError(ResolutionErrorNotFound), nil
+	}
+
+	doc, err := parsePeerDID2(didStr)
+	if err != nil {
+		return resolutionError(ResolutionErrorInvalidDID), nil
+	}
+
+	return &ResolutionResult{
+		DIDDocument: doc,
+		DIDResolutionMetadata: &ResolutionMetadata{
+			ContentType: ContentTypeDIDLDJSON,
+		},
+		DIDDocumentMetadata: &DocumentMetadata{},
+	}, nil
+}
+
+// parsePeerDID2 从did:peer:2生成DID文档
+// 密钥依次编号为 #key-1、#key-2…，服务依次编号为 #service、#service-1…
+func parsePeerDID2(didStr string) (*types.DIDDocument, error) {
+	body, ok := strings.CutPrefix(didStr, "did:peer:2")
+	if !ok {
+		return nil, fmt.Errorf("只支持did:peer numalgo 2: %s", didStr)
+	}
+	if !strings.HasPrefix(body, ".") {
+		return nil, fmt.Errorf("did:peer:2 至少需要一个元素")
+	}
+
+	doc := &types.DIDDocument{
+		Context: []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/multikey/v1"},
+		ID:      didStr,
+	}
+
+	keyIndex := 0
+	for _, element := range strings.Split(body[1:], ".") {
+		if len(element) < 2 {
+			return nil, fmt.Errorf("无效的did:peer:2元素: %q", element)
+		}
+		purpose, value := element[0], element[1:]
+
+		if purpose == PeerPurposeService {
+			service, err := decodePeerService(value, len(doc.Service))
+			if err != nil {
+				return nil, err
+			}
+			doc.Service = append(doc.Service, *service)
+			continue
+		}
+
+		if _, _, err := crypto.DecodeMultikey(value); err != nil {
+			return nil, err
+		}
+		keyIndex++
+		vmID := fmt.Sprintf("#key-%d", keyIndex)
+		doc.VerificationMethod = append(doc.VerificationMethod, types.VerificationMethod{
+			ID:                 vmID,
+			Type:               "Multikey",
+			Controller:         didStr,
+			PublicKeyMultibase: value,
+		})
+
+		switch purpose {
+		case PeerPurposeAssertion:
+			doc.AssertionMethod = append(doc.AssertionMethod, vmID)
+		case PeerPurposeEncryption:
+			doc.KeyAgreement = append(doc.KeyAgreement, vmID)
+		case PeerPurposeVerification:
+			doc.Authentication = append(doc.Authentication, vmID)
+		case PeerPurposeCapabilityInvocation:
+			doc.CapabilityInvocation = append(doc.CapabilityInvocation, vmID)
+		case PeerPurposeCapabilityDelegation:
+			doc.CapabilityDelegation = append(doc.CapabilityDelegation, vmID)
+		default:
+			return nil, fmt.Errorf("无效的密钥用途: %c", purpose)
+		}
+	}
+
+	return doc, nil
+}
+
+// decodePeerService 解码did:peer:2中的服务并展开缩写
+func decodePeerService(value string, index int) (*types.Service, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, fmt.Errorf("无效的服务编码: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("无效的服务JSON: %w", err)
+	}
+	expanded, _ := expandPeerAbbreviations(raw).(map[string]interface{})
+
+	serviceType, _ := expanded["type"].(string)
+	if serviceType == "" || expanded["serviceEndpoint"] == nil {
+		return nil, fmt.Errorf("服务缺少type或serviceEndpoint")
+	}
+
+	service := &types.Service{
+		Type:            serviceType,
+		ServiceEndpoint: expanded["serviceEndpoint"],
+	}
+	if id, ok := expanded["id"].(string); ok && id != "" {
+		service.ID = id
+	} else if index == 0 {
+		service.ID = "#service"
+	} else {
+		service.ID = fmt.Sprintf("#service-%d", index)
+	}
+
+	// 旧格式中routingKeys和accept位于服务顶层，合并到端点对象中
+	if uri, ok := service.ServiceEndpoint.(string); ok && (expanded["routingKeys"] != nil || expanded["accept"] != nil) {
+		endpoint := map[string]interface{}{"uri": uri}
+		for _, key := range []string{"routingKeys", "accept"} {
+			if v, ok := expanded[key]; ok {
+				endpoint[key] = v
+			}
+		}
+		service.ServiceEndpoint = endpoint
+	}
+
+	return service, nil
+}
+
+// expandPeerAbbreviations 递归展开键名和type取值中的缩写
+func expandPeerAbbreviations(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if full, ok := peerServiceAbbreviations[key]; ok && len(key) == 1 {
+				key = full
+			}
+			if s, ok := item.(string); ok && key == "type" {
+				if full, ok := peerServiceAbbreviations[s]; ok {
+					item = full
+				}
+			}
+			expanded[key] = expandPeerAbbreviations(item)
+		}
+		return expanded
+	case []interface{}:
+		for i := range v {
+			v[i] = expandPeerAbbreviations(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
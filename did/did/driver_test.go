@@ -0,0 +1,294 @@
+package did
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/mlkem"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestSupportedMethods(t *testing.T) {
+	resolver := NewDIDResolver(config.DefaultConfig(), NewDIDRegistry(nil), nil)
+
+	want := []string{"key", "peer", "qlink"}
+	if got := resolver.GetSupportedMethods(); !reflect.DeepEqual(got, want) {
+		t.Errorf("支持的方法不正确: %v", got)
+	}
+
+	// did:web 须在配置中显式开启
+	cfg := config.DefaultConfig()
+	cfg.DID.Resolver.EnableWeb = true
+	want = []string{"key", "peer", "qlink", "web"}
+	if got := NewDIDResolver(cfg, NewDIDRegistry(nil), nil).GetSupportedMethods(); !reflect.DeepEqual(got, want) {
+		t.Errorf("开启did:web后支持的方法不正确: %v", got)
+	}
+
+	result, err := resolver.Resolve("did:example:123")
+	if err != nil || result.DIDResolutionMetadata.Error != "methodNotSupported" {
+		t.Errorf("未注册的方法应返回methodNotSupported: %+v, %v", result.DIDResolutionMetadata, err)
+	}
+}
+
+func TestResolveDIDKey(t *testing.T) {
+	resolver := NewDIDResolver(config.DefaultConfig(), NewDIDRegistry(nil), nil)
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kemKey, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Ed25519", func(t *testing.T) {
+		didKey := FormatDIDKey(crypto.MulticodecEd25519Pub, edPub)
+		if !strings.HasPrefix(didKey, "did:key:z6Mk") {
+			t.Errorf("Ed25519 did:key前缀不正确: %s", didKey)
+		}
+
+		doc := resolveDocument(t, resolver, didKey)
+		vm := doc.VerificationMethod[0]
+		if vm.Type != "Ed25519VerificationKey2020" || len(doc.Authentication) != 1 || doc.Authentication[0] != vm.ID {
+			t.Fatalf("验证方法不正确: %+v", doc)
+		}
+
+		// 解析出的验证方法可以直接验证签名
+		signingInput := []byte("qlink")
+		signature := ed25519.Sign(edPriv, signingInput)
+		codec, key, err := crypto.DecodeMultikey(vm.PublicKeyMultibase)
+		if err != nil || codec != crypto.MulticodecEd25519Pub || !ed25519.Verify(key, signingInput, signature) {
+			t.Errorf("无法用解析出的公钥验证签名: %v", err)
+		}
+	})
+
+	t.Run("P-256", func(t *testing.T) {
+		didKey := FormatDIDKey(crypto.MulticodecP256Pub, elliptic.MarshalCompressed(elliptic.P256(), ecKey.X, ecKey.Y))
+		if !strings.HasPrefix(didKey, "did:key:zDn") {
+			t.Errorf("P-256 did:key前缀不正确: %s", didKey)
+		}
+
+		doc := resolveDocument(t, resolver, didKey)
+		jwk, ok := doc.VerificationMethod[0].PublicKeyJwk.(map[string]interface{})
+		if !ok || jwk["x"] != base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))) {
+			t.Errorf("JWK不正确: %v", doc.VerificationMethod[0].PublicKeyJwk)
+		}
+	})
+
+	t.Run("ML-KEM-768", func(t *testing.T) {
+		didKey := FormatDIDKey(crypto.MulticodecMLKEM768Pub, kemKey.EncapsulationKey().Bytes())
+
+		doc := resolveDocument(t, resolver, didKey)
+		if len(doc.KeyAgreement) != 1 || len(doc.Authentication) != 0 {
+			t.Errorf("KEM公钥只能用于密钥协商: %+v", doc)
+		}
+		if doc.VerificationMethod[0].Type != "Multikey" {
+			t.Errorf("验证方法类型不正确: %s", doc.VerificationMethod[0].Type)
+		}
+	})
+
+	for _, invalid := range []string{"did:key:abc", "did:key:z6Mk", "did:key:" + crypto.EncodeMultikey(0x99, edPub)} {
+		result, err := resolver.Resolve(invalid)
+		if err != nil || result.DIDResolutionMetadata.Error != "invalidDid" {
+			t.Errorf("应拒绝无效的did:key %s: %+v, %v", invalid, result.DIDResolutionMetadata, err)
+		}
+	}
+}
+
+func TestResolveDIDPeer(t *testing.T) {
+	resolver := NewDIDResolver(config.DefaultConfig(), NewDIDRegistry(nil), nil)
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kemKey, err := mlkem.GenerateKey768()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerDID, err := FormatPeerDID([]PeerKey{
+		{Purpose: PeerPurposeVerification, Multibase: crypto.EncodeMultikey(crypto.MulticodecEd25519Pub, edPub)},
+		{Purpose: PeerPurposeEncryption, Multibase: crypto.EncodeMultikey(crypto.MulticodecMLKEM768Pub, kemKey.EncapsulationKey().Bytes())},
+	}, []types.Service{
+		{Type: "DIDCommMessaging", ServiceEndpoint: map[string]interface{}{"uri": "https://example.com/didcomm", "accept": []string{"didcomm/v2"}}},
+		{Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("生成did:peer失败: %v", err)
+	}
+	if !strings.HasPrefix(peerDID, "did:peer:2.Vz6Mk") {
+		t.Errorf("did:peer格式不正确: %s", peerDID)
+	}
+
+	doc := resolveDocument(t, resolver, peerDID)
+	if len(doc.VerificationMethod) != 2 || doc.Authentication[0] != "#key-1" || doc.KeyAgreement[0] != "#key-2" {
+		t.Fatalf("验证方法不正确: %+v", doc)
+	}
+	if len(doc.Service) != 2 || doc.Service[0].ID != "#service" || doc.Service[1].ID != "#service-1" {
+		t.Fatalf("服务不正确: %+v", doc.Service)
+	}
+	if doc.Service[0].Type != "DIDCommMessaging" {
+		t.Errorf("服务类型缩写未展开: %s", doc.Service[0].Type)
+	}
+
+	// 相对ID的验证方法可以通过DID URL解引用
+	vm, err := resolver.ResolveVerificationMethod(peerDID + "#key-1")
+	if err != nil || vm.PublicKeyMultibase != crypto.EncodeMultikey(crypto.MulticodecEd25519Pub, edPub) {
+		t.Errorf("解引用验证方法失败: %v, %v", vm, err)
+	}
+
+	// 旧格式：缩写键名、routingKeys位于顶层
+	legacy := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"dm","s":"https://example.com/endpoint","r":["did:example:somemediator#somekey"],"a":["didcomm/v2"]}`))
+	doc = resolveDocument(t, resolver, "did:peer:2.Vz6MkqRYqQiSgvZQdnBytw86Qbs2ZWUkGv22od935YF4s8M7V.S"+legacy)
+	endpoint, ok := doc.Service[0].ServiceEndpoint.(map[string]interface{})
+	if !ok || endpoint["uri"] != "https://example.com/endpoint" || endpoint["routingKeys"] == nil {
+		t.Errorf("旧格式服务端点不正确: %#v", doc.Service[0].ServiceEndpoint)
+	}
+
+	for _, invalid := range []string{"did:peer:0z6Mk", "did:peer:2", "did:peer:2.Xz6Mk", "did:peer:2.Sabc"} {
+		result, err := resolver.Resolve(invalid)
+		if err != nil || result.DIDResolutionMetadata.Error != "invalidDid" {
+			t.Errorf("应拒绝无效的did:peer %s: %+v, %v", invalid, result.DIDResolutionMetadata, err)
+		}
+	}
+}
+
+func TestResolveDIDWeb(t *testing.T) {
+	var host string
+	mux := http.NewServeMux()
+	serveDoc := func(id string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(types.DIDDocument{
+				Context: []string{"https://www.w3.org/ns/did/v1"},
+				ID:      id,
+			})
+		}
+	}
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+	host = strings.ReplaceAll(strings.TrimPrefix(srv.URL, "https://"), ":", "%3A")
+
+	rootDID := "did:web:" + host
+	aliceDID := rootDID + ":user:alice"
+	mux.HandleFunc("/.well-known/did.json", serveDoc(rootDID))
+	mux.HandleFunc("/user/alice/did.json", serveDoc(aliceDID))
+	mux.HandleFunc("/user/mallory/did.json", serveDoc(aliceDID))
+
+	resolver := NewDIDResolver(config.DefaultConfig(), NewDIDRegistry(nil), nil)
+	resolver.RegisterDriver(NewWebDriver(NewHTTPFetcher(srv.Client())))
+
+	if doc := resolveDocument(t, resolver, rootDID); doc.ID != rootDID {
+		t.Errorf("根路径文档ID不正确: %s", doc.ID)
+	}
+	if doc := resolveDocument(t, resolver, aliceDID); doc.ID != aliceDID {
+		t.Errorf("子路径文档ID不正确: %s", doc.ID)
+	}
+
+	tests := map[string]string{
+		rootDID + ":user:bob":     "notFound",
+		rootDID + ":user:mallory": "invalidDidDocument",
+	}
+	for didStr, wantError := range tests {
+		result, err := resolver.Resolve(didStr)
+		if err != nil || result.DIDResolutionMetadata.Error != wantError {
+			t.Errorf("%s: 期望 %s, 实际 %+v, %v", didStr, wantError, result.DIDResolutionMetadata, err)
+		}
+	}
+}
+
+func TestWebDIDToURL(t *testing.T) {
+	tests := map[string]string{
+		"did:web:w3c-ccg.github.io":                   "https://w3c-ccg.github.io/.well-known/did.json",
+		"did:web:w3c-ccg.github.io:user:alice":        "https://w3c-ccg.github.io/user/alice/did.json",
+		"did:web:example.com%3A3000:user:alice":       "https://example.com:3000/user/alice/did.json",
+		"did:web:example.com:path%20with%20space:doc": "https://example.com/path%20with%20space/doc/did.json",
+	}
+	for didStr, want := range tests {
+		got, err := WebDIDToURL(didStr)
+		if err != nil || got != want {
+			t.Errorf("%s: 期望 %s, 实际 %s, %v", didStr, want, got, err)
+		}
+	}
+
+	for _, invalid := range []string{"did:web:", "did:web:example.com:..", "did:web:example.com%2Fevil"} {
+		if _, err := WebDIDToURL(invalid); err == nil {
+			t.Errorf("应拒绝无效的did:web: %s", invalid)
+		}
+	}
+}
+
+// resolveDocument 解析DID并要求成功
+func resolveDocument(t *testing.T, resolver *DIDResolver, didStr string) *types.DIDDocument {
+	t.Helper()
+
+	result, err := resolver.Resolve(didStr)
+	if err != nil {
+		t.Fatalf("解析 %s 失败: %v", didStr, err)
+	}
+	if result.DIDResolutionMetadata.Error != "" {
+		t.Fatalf("解析 %s 返回错误: %s", didStr, result.DIDResolutionMetadata.Error)
+	}
+	return result.DIDDocument
+}
+
+// TestWebFetcherRefusesInternalHosts 默认客户端不能被DID引向节点所在的内部网络
+func TestWebFetcherRefusesInternalHosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("不应访问内部地址: %s", r.URL)
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTPFetcher(nil).Fetch(context.Background(), srv.URL+"/did.json"); err == nil {
+		t.Error("应拒绝连接回环地址")
+	}
+
+	for _, address := range []string{"127.0.0.1:443", "[::1]:443", "10.0.0.1:443", "192.168.1.1:443",
+		"169.254.169.254:80", "[fe80::1]:443", "[::ffff:127.0.0.1]:443", "0.0.0.0:443"} {
+		if err := checkWebDestination("tcp", address, nil); err == nil {
+			t.Errorf("应拒绝内部地址 %s", address)
+		}
+	}
+	if err := checkWebDestination("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("公网地址应允许访问: %v", err)
+	}
+}
+
+func TestWebRedirectPolicy(t *testing.T) {
+	origin, _ := http.NewRequest(http.MethodGet, "https://example.com/.well-known/did.json", nil)
+	tests := map[string]bool{
+		"https://example.com/user/did.json":         true,
+		"https://EXAMPLE.com/user/did.json":         true,
+		"http://example.com/.well-known/did.json":   false,
+		"https://example.com:8443/did.json":         false,
+		"https://169.254.169.254/latest/meta-data/": false,
+	}
+	for target, allowed := range tests {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if err := checkWebRedirect(req, []*http.Request{origin}); (err == nil) != allowed {
+			t.Errorf("%s: 期望允许=%v, 实际 %v", target, allowed, err)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/next", nil)
+	if err := checkWebRedirect(req, []*http.Request{origin, origin, origin}); err == nil {
+		t.Error("应限制重定向次数")
+	}
+}
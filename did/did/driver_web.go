@@ -0,0 +1,203 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// maxWebDocumentSize did:web 文档的最大字节数
+const maxWebDocumentSize = 1 << 20
+
+// maxWebRedirects did:web 请求最多跟随的重定向次数
+const maxWebRedirects = 3
+
+// ErrDocumentNotFound 远端不存在DID文档
+var ErrDocumentNotFound = errors.New("DID文档不存在")
+
+// HTTPFetcher 获取远端资源，便于测试时替换
+type HTTPFetcher interface {
+	// Fetch 获取URL的内容；资源不存在时返回 ErrDocumentNotFound
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// httpFetcher 基于 http.Client 的默认实现
+type httpFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPFetcher 创建HTTP获取器，client为nil时使用 newWebClient 创建的默认客户端
+func NewHTTPFetcher(client *http.Client) HTTPFetcher {
+	if client == nil {
+		client = newWebClient()
+	}
+	return &httpFetcher{client: client}
+}
+
+// newWebClient 创建访问did:web主机的默认客户端
+// 域名来自任意用户提交的DID：不经过代理，拒绝连接内部地址，重定向不能改变协议和主机
+func newWebClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkWebDestination}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:       10 * time.Second,
+		Transport:     transport,
+		CheckRedirect: checkWebRedirect,
+	}
+}
+
+// checkWebDestination 在连接前检查域名解析后的地址，拒绝回环、私有和链路本地等内部地址
+// 每次拨号都会检查，DNS重绑定无法绕过
+func checkWebDestination(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("无效的目标地址 %s: %w", address, err)
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("拒绝访问内部地址: %s", addr)
+	}
+	return nil
+}
+
+// checkWebRedirect 只跟随同协议、同主机的重定向
+func checkWebRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxWebRedirects {
+		return fmt.Errorf("重定向次数过多: %s", req.URL.Redacted())
+	}
+	origin := via[0].URL
+	if req.URL.Scheme != origin.Scheme || !strings.EqualFold(req.URL.Host, origin.Host) {
+		return fmt.Errorf("拒绝重定向到其他主机: %s", req.URL.Redacted())
+	}
+	return nil
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, ErrDocumentNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("获取 %s 失败: HTTP %d", rawURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWebDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWebDocumentSize {
+		return nil, fmt.Errorf("DID文档过大: %s", rawURL)
+	}
+	return data, nil
+}
+
+// webDriver did:web 驱动，通过HTTPS获取 did.json
+type webDriver struct {
+	fetcher HTTPFetcher
+}
+
+// NewWebDriver 创建did:web驱动，fetcher为nil时使用默认HTTP获取器
+// NewDIDResolver 只在配置 did.resolver.enable_web 时注册该驱动
+func NewWebDriver(fetcher HTTPFetcher) MethodDriver {
+	if fetcher == nil {
+		fetcher = NewHTTPFetcher(nil)
+	}
+	return &webDriver{fetcher: fetcher}
+}
+
+func (d *webDriver) Method() string {
+	return "web"
+}
+
+func (d *webDriver) Resolve(ctx context.Context, didStr string, opts ResolutionOptions) (*ResolutionResult, error) {
+	// did:web 没有版本历史
+	if opts.VersionID != "" || opts.VersionTime != nil {
+		return resolutionError(ResolutionErrorNotFound), nil
+	}
+
+	docURL, err := WebDIDToURL(didStr)
+	if err != nil {
+		return resolutionError(ResolutionErrorInvalidDID), nil
+	}
+
+	data, err := d.fetcher.Fetch(ctx, docURL)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return resolutionError(ResolutionErrorNotFound), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取did:web文档失败: %w", err)
+	}
+
+	var doc types.DIDDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return resolutionError(ResolutionErrorInvalidDIDDocument), nil
+	}
+	// 文档ID必须与请求的DID一致，防止域名返回他人的文档
+	if doc.ID != didStr {
+		return resolutionError(ResolutionErrorInvalidDIDDocument), nil
+	}
+
+	return &ResolutionResult{
+		DIDDocument: &doc,
+		DIDResolutionMetadata: &ResolutionMetadata{
+			ContentType: ContentTypeDIDLDJSON,
+		},
+		DIDDocumentMetadata: newDocumentMetadata(&doc),
+	}, nil
+}
+
+// WebDIDToURL 将did:web转换为文档URL
+// did:web:example.com → https://example.com/.well-known/did.json
+// did:web:example.com%3A8443:user:alice → https://example.com:8443/user/alice/did.json
+func WebDIDToURL(didStr string) (string, error) {
+	id, ok := strings.CutPrefix(didStr, "did:web:")
+	if !ok || id == "" {
+		return "", fmt.Errorf("不是did:web: %s", didStr)
+	}
+
+	segments := strings.Split(id, ":")
+	host, err := url.PathUnescape(segments[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("无效的did:web域名: %s", segments[0])
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		path = ""
+		for _, segment := range segments[1:] {
+			decoded, err := url.PathUnescape(segment)
+			if err != nil || decoded == "" || decoded == "." || decoded == ".." || strings.Contains(decoded, "/") {
+				return "", fmt.Errorf("无效的did:web路径: %s", segment)
+			}
+			path += "/" + decoded
+		}
+	}
+
+	u := url.URL{Scheme: "https", Host: host, Path: path + "/did.json"}
+	return u.String(), nil
+}
@@ -0,0 +1,208 @@
+package did
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// VersionEntry DID文档的一个历史版本
+// 每个版本记录上一版本的哈希，形成可校验的哈希链
+type VersionEntry struct {
+	VersionID    string             `json:"versionId"` // 从1开始递增
+	Operation    string             `json:"operation"`
+	VersionTime  time.Time          `json:"versionTime"`
+	Document     *types.DIDDocument `json:"document"`
+	TxHash       string             `json:"txHash,omitempty"`
+	PreviousHash string             `json:"previousHash,omitempty"`
+	Hash         string             `json:"hash,omitempty"`
+}
+
+// computeHash 计算版本哈希：SHA-256(JCS(去掉hash字段的版本记录))
+func (e *VersionEntry) computeHash() (string, error) {
+	entry := *e
+	entry.Hash = ""
+
+	data, err := crypto.Canonicalize(&entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// History 获取DID的全部历史版本，按版本号升序
+func (r *DIDRegistry) History(didStr string) ([]*VersionEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.loadHistory(didStr)
+}
+
+// VerifyHistory 校验DID历史版本的哈希链是否完整
+func (r *DIDRegistry) VerifyHistory(didStr string) error {
+	history, err := r.History(didStr)
+	if err != nil {
+		return err
+	}
+
+	previous := ""
+	for i, entry := range history {
+		if entry.VersionID != strconv.Itoa(i+1) {
+			return historyError("HISTORY_BROKEN", fmt.Sprintf("版本号不连续: %s", entry.VersionID))
+		}
+		if entry.PreviousHash != previous {
+			return historyError("HISTORY_BROKEN", fmt.Sprintf("版本 %s 的前序哈希不匹配", entry.VersionID))
+		}
+
+		hash, err := entry.computeHash()
+		if err != nil {
+			return storageError(err)
+		}
+		if hash != entry.Hash {
+			return historyError("HISTORY_BROKEN", fmt.Sprintf("版本 %s 的哈希不匹配", entry.VersionID))
+		}
+		previous = entry.Hash
+	}
+
+	return nil
+}
+
+// ResolveVersion 解析DID的历史版本
+// versionID 与 versionTime 至多指定一个；都为空时返回最新版本。
+// 同时返回下一个版本（当前版本为最新时为nil），用于填充 nextUpdate/nextVersionId
+func (r *DIDRegistry) ResolveVersion(didStr, versionID string, versionTime *time.Time) (*VersionEntry, *VersionEntry, error) {
+	if versionID != "" && versionTime != nil {
+		return nil, nil, &DIDError{
+			Type:    ErrorTypeValidation,
+			Code:    "INVALID_VERSION_QUERY",
+			Message: "versionId和versionTime不能同时指定",
+			Details: didStr,
+		}
+	}
+
+	history, err := r.History(didStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := -1
+	switch {
+	case versionID != "":
+		for i, entry := range history {
+			if entry.VersionID == versionID {
+				index = i
+				break
+			}
+		}
+	case versionTime != nil:
+		// 取在指定时间点生效的版本，即最后一个不晚于该时间的版本
+		for i, entry := range history {
+			if entry.VersionTime.After(*versionTime) {
+				break
+			}
+			index = i
+		}
+	default:
+		index = len(history) - 1
+	}
+
+	if index < 0 {
+		return nil, nil, &DIDError{
+			Type:    ErrorTypeNotFound,
+			Code:    "VERSION_NOT_FOUND",
+			Message: "DID版本不存在",
+			Details: didStr,
+		}
+	}
+
+	var next *VersionEntry
+	if index+1 < len(history) {
+		next = history[index+1]
+	}
+
+	return history[index], next, nil
+}
+
+// recordVersion 为已写入存储的操作追加一个历史版本（调用方需持有写锁）
+func (r *DIDRegistry) recordVersion(op *types.DIDOperation, txHash string) error {
+	history, err := r.loadHistory(op.DID)
+	if err != nil {
+		return err
+	}
+
+	entry := &VersionEntry{
+		VersionID:   strconv.Itoa(len(history) + 1),
+		Operation:   op.Operation,
+		VersionTime: time.Now().UTC(),
+		Document:    op.Document,
+		TxHash:      txHash,
+	}
+	if op.Document != nil && op.Document.Updated != nil {
+		entry.VersionTime = op.Document.Updated.UTC()
+	}
+	if len(history) > 0 {
+		entry.PreviousHash = history[len(history)-1].Hash
+	}
+
+	entry.Hash, err = entry.computeHash()
+	if err != nil {
+		return storageError(err)
+	}
+
+	if err := r.storage.PutDIDHistory(op.DID, entry); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+// headHash 返回DID最新历史版本的哈希，没有历史时为空（调用方需持有锁）
+func (r *DIDRegistry) headHash(didStr string) (string, error) {
+	history, err := r.loadHistory(didStr)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "", nil
+	}
+	return history[len(history)-1].Hash, nil
+}
+
+// loadHistory 从存储读取并解码历史版本
+func (r *DIDRegistry) loadHistory(didStr string) ([]*VersionEntry, error) {
+	values, err := r.storage.GetDIDHistory(didStr)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	// 通过JSON往返解码，同时保证返回的是副本
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	var history []*VersionEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, storageError(err)
+	}
+
+	return history, nil
+}
+
+// historyError 创建历史记录相关错误
+func historyError(code, details string) *DIDError {
+	return &DIDError{
+		Type:    ErrorTypeStorage,
+		Code:    code,
+		Message: "DID历史记录校验失败",
+		Details: details,
+	}
+}
@@ -0,0 +1,101 @@
+package did
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestRegistryRecordsHashLinkedHistory(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	henry := newTestIdentity(t, "did:qlink:henry")
+
+	if _, err := registry.Register(henry.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	beforeUpdate := time.Now()
+
+	req := &UpdateRequest{
+		DID:     henry.did,
+		Service: []types.Service{{ID: henry.did + "#hub", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}
+	req.Proof = henry.proof(t, req.SigningPayload(), "authentication")
+	if _, err := registry.Update(req); err != nil {
+		t.Fatalf("更新DID失败: %v", err)
+	}
+	if err := registry.Revoke(henry.did, henry.revokeProof(t)); err != nil {
+		t.Fatalf("撤销DID失败: %v", err)
+	}
+
+	history, err := registry.History(henry.did)
+	if err != nil {
+		t.Fatalf("获取历史失败: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("历史版本数量不正确: %d", len(history))
+	}
+	if history[0].Operation != OperationCreate || history[2].Operation != OperationDeactivate {
+		t.Errorf("历史操作不正确: %s, %s", history[0].Operation, history[2].Operation)
+	}
+	if history[1].PreviousHash != history[0].Hash || history[0].PreviousHash != "" {
+		t.Error("版本未通过哈希链接")
+	}
+	if err := registry.VerifyHistory(henry.did); err != nil {
+		t.Fatalf("哈希链校验失败: %v", err)
+	}
+
+	// 按版本号解析
+	entry, next, err := registry.ResolveVersion(henry.did, "1", nil)
+	if err != nil || len(entry.Document.Service) != 0 || next == nil || next.VersionID != "2" {
+		t.Fatalf("按版本号解析结果不正确: %+v, %+v, %v", entry, next, err)
+	}
+
+	// 按时间解析：更新之前生效的是第一个版本
+	entry, _, err = registry.ResolveVersion(henry.did, "", &beforeUpdate)
+	if err != nil || entry.VersionID != "1" {
+		t.Fatalf("按时间解析结果不正确: %+v, %v", entry, err)
+	}
+
+	if _, _, err := registry.ResolveVersion(henry.did, "9", nil); err == nil {
+		t.Error("不存在的版本应返回错误")
+	}
+}
+
+func TestResolverVersionQuery(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	iris := newTestIdentity(t, "did:qlink:iris")
+	if _, err := registry.Register(iris.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	if err := registry.Revoke(iris.did, iris.revokeProof(t)); err != nil {
+		t.Fatalf("撤销DID失败: %v", err)
+	}
+
+	resolver := NewDIDResolver(config.DefaultConfig(), registry, nil)
+
+	result, err := resolver.Resolve(iris.did + "?versionId=1")
+	if err != nil || result.DIDResolutionMetadata.Error != "" {
+		t.Fatalf("按版本解析失败: %v, %+v", err, result.DIDResolutionMetadata)
+	}
+	metadata := result.DIDDocumentMetadata
+	if metadata.VersionID != "1" || metadata.NextVersionID != "2" || metadata.NextUpdate == "" || metadata.Deactivated {
+		t.Errorf("版本元数据不正确: %+v", metadata)
+	}
+
+	result, _ = resolver.Resolve(iris.did)
+	if result.DIDDocumentMetadata.VersionID != "2" || result.DIDDocumentMetadata.NextVersionID != "" {
+		t.Errorf("最新版本元数据不正确: %+v", result.DIDDocumentMetadata)
+	}
+
+	result, _ = resolver.Resolve(iris.did + "?versionTime=2000-01-01T00:00:00Z")
+	if result.DIDResolutionMetadata.Error != "notFound" {
+		t.Errorf("早于创建时间的版本应返回notFound: %+v", result.DIDResolutionMetadata)
+	}
+
+	result, _ = resolver.Resolve(iris.did + "?versionId=1&versionTime=2026-01-01T00:00:00Z")
+	if result.DIDResolutionMetadata.Error != "invalidDidUrl" {
+		t.Errorf("同时指定版本号和时间应返回invalidDidUrl: %+v", result.DIDResolutionMetadata)
+	}
+}
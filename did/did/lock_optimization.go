@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/interfaces"
 	"github.com/qujing226/QLink/pkg/types"
 	"github.com/qujing226/QLink/pkg/utils"
 )
@@ -255,10 +256,10 @@ type OptimizedDIDRegistry struct {
 	metrics     *Metrics
 }
 
-// NewOptimizedDIDRegistry 创建优化的DID注册表
-func NewOptimizedDIDRegistry(lockTimeout time.Duration, cfg *config.Config, blockchain BlockchainInterface) *OptimizedDIDRegistry {
+// NewOptimizedDIDRegistry 创建优化的DID注册表，didStorage 为空时使用内存存储
+func NewOptimizedDIDRegistry(lockTimeout time.Duration, cfg *config.Config, blockchain BlockchainInterface, didStorage interfaces.DIDStorage) *OptimizedDIDRegistry {
     return &OptimizedDIDRegistry{
-        DIDRegistry: NewDIDRegistry(blockchain),
+        DIDRegistry: NewDIDRegistryWithStorage(blockchain, didStorage),
         lockManager: NewLockManager(lockTimeout),
         metrics:     NewMetrics(),
     }
@@ -0,0 +1,146 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// 预轮换（KERI风格）：
+// 文档在注册时携带下一把轮换密钥的公钥摘要 NextKeyCommitment。此后任何引入新密钥的
+// 变更都必须由与承诺匹配的密钥签发，并同时提交再下一把密钥的承诺；当前密钥只能做
+// 不涉及新密钥的变更，也不能修改承诺；撤销和变更控制者同样只能由承诺的密钥签发。
+// 即使当前密钥泄露，攻击者也无法撤销或接管文档，持有预轮换密钥的一方可以通过一次轮换恢复控制。
+
+// verificationMethodCommitment 计算验证方法公钥对应的预轮换承诺，只支持混合密钥的JWK
+func verificationMethodCommitment(vm *types.VerificationMethod) (string, error) {
+	if vm.PublicKeyJwk == nil {
+		return "", fmt.Errorf("验证方法 %s 没有JWK公钥", vm.ID)
+	}
+
+	var jwk crypto.PublicKeyJWK
+	if err := remarshal(vm.PublicKeyJwk, &jwk); err != nil {
+		return "", fmt.Errorf("解析JWK失败: %w", err)
+	}
+	keyPair, err := crypto.FromJWK(&jwk)
+	if err != nil {
+		return "", err
+	}
+	return keyPair.GetNextKeyCommitment()
+}
+
+// introducedKey 返回vms中当前文档没有的第一个验证方法（ID和公钥都相同才视为已有），没有则返回nil
+func introducedKey(doc *types.DIDDocument, vms []types.VerificationMethod) *types.VerificationMethod {
+	for i := range vms {
+		index := indexOfVerificationMethod(doc, vms[i].ID)
+		if index < 0 || !samePublicKey(&doc.VerificationMethod[index], &vms[i]) {
+			return &vms[i]
+		}
+	}
+	return nil
+}
+
+// samePublicKey 判断两个验证方法的类型和公钥是否相同
+// 公钥先转为通用JSON结构再序列化，结构体和map表示的JWK比较结果一致
+func samePublicKey(a, b *types.VerificationMethod) bool {
+	keyOf := func(vm *types.VerificationMethod) string {
+		var key interface{}
+		if err := remarshal([]interface{}{vm.Type, vm.PublicKeyJwk, vm.PublicKeyMultibase, vm.PublicKeyLattice}, &key); err != nil {
+			return ""
+		}
+		data, _ := json.Marshal(key)
+		return string(data)
+	}
+	ka, kb := keyOf(a), keyOf(b)
+	return ka != "" && ka == kb
+}
+
+// verifyPreRotation 验证预轮换操作
+// doc 是应用变更后的文档，签名密钥必须在其中拥有authentication关系且与原承诺匹配
+func (r *DIDRegistry) verifyPreRotation(doc *types.DIDDocument, commitment string, payload interface{}, proof *types.Proof, nextCommitment string) error {
+	if nextCommitment == "" {
+		return preRotationError("NEXT_KEY_COMMITMENT_REQUIRED", "预轮换必须提交下一把密钥的承诺", doc.ID)
+	}
+	if err := crypto.ValidateNextKeyCommitment(nextCommitment); err != nil {
+		return preRotationError("INVALID_NEXT_KEY_COMMITMENT", err.Error(), nextCommitment)
+	}
+	if proof == nil {
+		return proofError(utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "预轮换需要提供证明"))
+	}
+
+	index := indexOfVerificationMethod(doc, proof.VerificationMethod)
+	if index < 0 {
+		return preRotationError("NEXT_KEY_MISMATCH", "签名密钥不是预轮换承诺的密钥", proof.VerificationMethod)
+	}
+	revealed, err := verificationMethodCommitment(&doc.VerificationMethod[index])
+	if err != nil || revealed != commitment {
+		return preRotationError("NEXT_KEY_MISMATCH", "签名密钥不是预轮换承诺的密钥", proof.VerificationMethod)
+	}
+
+	if err := r.verifier.VerifyRotatePermission(doc, payload, proof); err != nil {
+		return proofError(err)
+	}
+	return nil
+}
+
+// authorizeNextKey 验证由预轮换承诺的密钥签发的撤销或变更控制者操作
+// 承诺的密钥尚未加入文档，请求需要公开该密钥；公开后文档和承诺都不变，之后的同类操作仍由同一把密钥签发
+func (r *DIDRegistry) authorizeNextKey(doc *types.DIDDocument, nextKey *types.VerificationMethod, payload interface{}, proof *types.Proof, verify permissionCheck) error {
+	if nextKey == nil {
+		return preRotationError("NEXT_KEY_REQUIRED", "文档设置了预轮换承诺，撤销和变更控制者必须由承诺的密钥签发", doc.ID)
+	}
+	if proof == nil {
+		return proofError(utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "该操作需要提供证明"))
+	}
+	if !sameReference(doc, proof.VerificationMethod, nextKey.ID) || indexOfVerificationMethod(doc, nextKey.ID) >= 0 {
+		return preRotationError("NEXT_KEY_MISMATCH", "签名密钥不是预轮换承诺的密钥", proof.VerificationMethod)
+	}
+	revealed, err := verificationMethodCommitment(nextKey)
+	if err != nil || revealed != doc.NextKeyCommitment {
+		return preRotationError("NEXT_KEY_MISMATCH", "签名密钥不是预轮换承诺的密钥", proof.VerificationMethod)
+	}
+
+	// 在文档副本中临时加入公开的密钥，按操作要求的验证关系校验证明
+	revealedDoc := *doc
+	revealedDoc.VerificationMethod = append(append([]types.VerificationMethod(nil), doc.VerificationMethod...), *nextKey)
+	revealedDoc.Authentication = append(append([]string(nil), doc.Authentication...), nextKey.ID)
+	revealedDoc.CapabilityInvocation = append(append([]string(nil), doc.CapabilityInvocation...), nextKey.ID)
+	revealedDoc.CapabilityDelegation = append(append([]string(nil), doc.CapabilityDelegation...), nextKey.ID)
+	if err := verify(&revealedDoc, payload, proof); err != nil {
+		return proofError(err)
+	}
+	return nil
+}
+
+// setNextKeyCommitment 由当前密钥设置预轮换承诺，只能在文档尚无承诺时设置
+func setNextKeyCommitment(doc *types.DIDDocument, commitment string) error {
+	if commitment == "" || commitment == doc.NextKeyCommitment {
+		return nil
+	}
+	if doc.NextKeyCommitment != "" {
+		return preRotationError("NEXT_KEY_COMMITMENT_LOCKED", "预轮换承诺只能由承诺的密钥在轮换时更新", doc.ID)
+	}
+	if err := crypto.ValidateNextKeyCommitment(commitment); err != nil {
+		return preRotationError("INVALID_NEXT_KEY_COMMITMENT", err.Error(), commitment)
+	}
+
+	doc.NextKeyCommitment = commitment
+	return nil
+}
+
+// preRotationError 创建预轮换校验错误
+func preRotationError(code, message, details string) error {
+	errType := ErrorTypeValidation
+	if code == "NEXT_KEY_MISMATCH" || code == "NEXT_KEY_COMMITMENT_LOCKED" || code == "NEXT_KEY_REQUIRED" {
+		errType = ErrorTypeUnauthorized
+	}
+	return &DIDError{
+		Type:    errType,
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+}
@@ -0,0 +1,260 @@
+package did
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// signWith 用指定密钥为操作内容生成authentication证明
+func signWith(t *testing.T, keyPair *crypto.HybridKeyPair, vmID string, payload interface{}) *types.Proof {
+	t.Helper()
+	return signWithPurpose(t, keyPair, vmID, payload, "authentication")
+}
+
+// signWithPurpose 用指定密钥为操作内容生成指定目的的证明
+func signWithPurpose(t *testing.T, keyPair *crypto.HybridKeyPair, vmID string, payload interface{}, purpose string) *types.Proof {
+	t.Helper()
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: vmID,
+		ProofPurpose:       purpose,
+		Nonce:              vmID + time.Now().String(),
+	}
+	if err := keyPair.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	return proof
+}
+
+func didErrorCode(err error) string {
+	if didErr, ok := err.(*DIDError); ok {
+		return didErr.Code
+	}
+	return ""
+}
+
+func TestPreRotationRecoversFromKeyCompromise(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	next, _ := crypto.GenerateHybridKeyPair()
+	builder.SetNextKeyPair(next)
+
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	registry := NewDIDRegistry(nil)
+	doc, err := registry.Register(regReq)
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	commitment, _ := next.GetNextKeyCommitment()
+	if doc.NextKeyCommitment != commitment {
+		t.Fatalf("注册的文档应携带预轮换承诺: %s", doc.NextKeyCommitment)
+	}
+
+	didStr := builder.GetDID()
+	stolen := builder.GetKeyPair()
+	stolenKeyID := builder.GetKeyID()
+
+	// 当前密钥仍可做不涉及新密钥的更新
+	updateReq, err := builder.CreateUpdateRequest([]types.Service{
+		{ID: didStr + "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("创建更新请求失败: %v", err)
+	}
+	if _, err := registry.Update(updateReq); err != nil {
+		t.Fatalf("当前密钥更新服务失败: %v", err)
+	}
+
+	// 持有当前密钥的攻击者无法换入自己的密钥
+	attacker, _ := crypto.GenerateHybridKeyPair()
+	attackerVM, _ := NewKeyVerificationMethod(didStr, didStr+"#key-2", attacker)
+	attackerCommitment, _ := attacker.GetNextKeyCommitment()
+
+	rotateReq := &RotateKeyRequest{
+		DID:                   didStr,
+		NewVerificationMethod: *attackerVM,
+		RetireKeyID:           stolenKeyID,
+		NextKeyCommitment:     attackerCommitment,
+	}
+	rotateReq.Proof = signWith(t, stolen, stolenKeyID, rotateReq.SigningPayload())
+	if _, _, err := registry.RotateKey(context.Background(), rotateReq); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("当前密钥签发的轮换应被拒绝: %v", err)
+	}
+
+	rotateReq.Proof = signWith(t, attacker, attackerVM.ID, rotateReq.SigningPayload())
+	if _, _, err := registry.RotateKey(context.Background(), rotateReq); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("未承诺的新密钥签发的轮换应被拒绝: %v", err)
+	}
+
+	current, _ := registry.Resolve(didStr)
+	updateReq = &UpdateRequest{
+		DID:                didStr,
+		VerificationMethod: append(current.VerificationMethod, *attackerVM),
+		NextKeyCommitment:  attackerCommitment,
+	}
+	updateReq.Proof = signWith(t, stolen, stolenKeyID, updateReq.SigningPayload())
+	if _, err := registry.Update(updateReq); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("当前密钥添加新密钥应被拒绝: %v", err)
+	}
+
+	updateReq = &UpdateRequest{DID: didStr, NextKeyCommitment: attackerCommitment}
+	updateReq.Proof = signWith(t, stolen, stolenKeyID, updateReq.SigningPayload())
+	if _, err := registry.Update(updateReq); didErrorCode(err) != "NEXT_KEY_COMMITMENT_LOCKED" {
+		t.Errorf("当前密钥不能替换预轮换承诺: %v", err)
+	}
+
+	// 持有预轮换密钥的一方轮换并退役泄露的密钥
+	following, _ := crypto.GenerateHybridKeyPair()
+	rotateReq, err = builder.CreatePreRotationRequest(current, following)
+	if err != nil {
+		t.Fatalf("创建预轮换请求失败: %v", err)
+	}
+	withoutCommitment := *rotateReq
+	withoutCommitment.NextKeyCommitment = ""
+	if _, _, err := registry.RotateKey(context.Background(), &withoutCommitment); didErrorCode(err) != "NEXT_KEY_COMMITMENT_REQUIRED" {
+		t.Errorf("预轮换必须提交新的承诺: %v", err)
+	}
+
+	doc, _, err = registry.RotateKey(context.Background(), rotateReq)
+	if err != nil {
+		t.Fatalf("预轮换失败: %v", err)
+	}
+	followingCommitment, _ := following.GetNextKeyCommitment()
+	if doc.NextKeyCommitment != followingCommitment {
+		t.Errorf("承诺应更新为下一把密钥: %s", doc.NextKeyCommitment)
+	}
+	if doc.VerificationMethod[0].Revoked == nil || builder.GetKeyID() != didStr+"#key-2" {
+		t.Errorf("泄露的密钥应被退役: %+v", doc.VerificationMethod)
+	}
+
+	// 泄露的密钥失效，新密钥可以继续更新
+	updateReq = &UpdateRequest{DID: didStr}
+	updateReq.Proof = signWith(t, stolen, stolenKeyID, updateReq.SigningPayload())
+	if _, err := registry.Update(updateReq); err == nil {
+		t.Error("退役的密钥不应通过验证")
+	}
+	updateReq.Proof = signWith(t, next, builder.GetKeyID(), updateReq.SigningPayload())
+	if _, err := registry.Update(updateReq); err != nil {
+		t.Errorf("新密钥更新失败: %v", err)
+	}
+}
+
+func TestPreRotationThroughUpdate(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	next, _ := crypto.GenerateHybridKeyPair()
+	regReq, _ := builder.SetNextKeyPair(next).CreateRegistrationRequest()
+	registry := NewDIDRegistry(nil)
+	current, err := registry.Register(regReq)
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	// 更新请求公开预轮换密钥，由它签名并提交下一个承诺
+	didStr := builder.GetDID()
+	revealed, _ := NewKeyVerificationMethod(didStr, NextKeyID(current), next)
+	following, _ := crypto.GenerateHybridKeyPair()
+	followingCommitment, _ := following.GetNextKeyCommitment()
+	req := &UpdateRequest{
+		DID:                didStr,
+		VerificationMethod: []types.VerificationMethod{*revealed},
+		NextKeyCommitment:  followingCommitment,
+	}
+	req.Proof = signWith(t, next, revealed.ID, req.SigningPayload())
+
+	doc, err := registry.Update(req)
+	if err != nil {
+		t.Fatalf("预轮换更新失败: %v", err)
+	}
+	if len(doc.VerificationMethod) != 1 || doc.VerificationMethod[0].ID != revealed.ID || doc.NextKeyCommitment != followingCommitment {
+		t.Errorf("预轮换更新结果不正确: %+v", doc)
+	}
+
+	// 注册请求中的承诺格式无效时被拒绝
+	other, _ := NewDIDDocumentBuilder()
+	regReq, _ = other.CreateRegistrationRequest()
+	regReq.NextKeyCommitment = "not-a-digest"
+	if _, err := registry.Register(regReq); didErrorCode(err) != "INVALID_NEXT_KEY_COMMITMENT" {
+		t.Errorf("无效的预轮换承诺应被拒绝: %v", err)
+	}
+}
+
+func TestPreRotationGuardsRevokeAndControllers(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	next, _ := crypto.GenerateHybridKeyPair()
+	builder.SetNextKeyPair(next)
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	registry := NewDIDRegistry(nil)
+	if _, err := registry.Register(regReq); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	didStr := builder.GetDID()
+	stolen, stolenKeyID := builder.GetKeyPair(), builder.GetKeyID()
+	nextVM, _ := NewKeyVerificationMethod(didStr, didStr+"#key-2", next)
+	attacker, _ := crypto.GenerateHybridKeyPair()
+	attackerVM, _ := NewKeyVerificationMethod(didStr, didStr+"#key-2", attacker)
+
+	// 持有当前密钥的攻击者不能把控制权交给自己的DID
+	controllers := []string{"did:qlink:attacker"}
+	controllerPayload := ControllerSigningPayload(didStr, controllers)
+	proof, _ := builder.SignSetControllers(controllers)
+	if _, _, err := registry.SetControllers(context.Background(), didStr, controllers, proof); didErrorCode(err) != "NEXT_KEY_REQUIRED" {
+		t.Errorf("当前密钥变更控制者应被拒绝: %v", err)
+	}
+	if _, _, err := registry.SetControllersWithNextKey(context.Background(), didStr, controllers, nextVM, proof); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("公开承诺的密钥但由当前密钥签名应被拒绝: %v", err)
+	}
+	proof = signWithPurpose(t, attacker, attackerVM.ID, controllerPayload, "capabilityDelegation")
+	if _, _, err := registry.SetControllersWithNextKey(context.Background(), didStr, controllers, attackerVM, proof); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("未承诺的密钥变更控制者应被拒绝: %v", err)
+	}
+
+	// 也不能撤销DID
+	if err := registry.Revoke(didStr, signWith(t, stolen, stolenKeyID, RevokeSigningPayload(didStr))); didErrorCode(err) != "NEXT_KEY_REQUIRED" {
+		t.Errorf("当前密钥撤销应被拒绝: %v", err)
+	}
+	proof = signWith(t, attacker, attackerVM.ID, RevokeSigningPayload(didStr))
+	if _, err := registry.RevokeWithNextKey(context.Background(), didStr, attackerVM, proof); didErrorCode(err) != "NEXT_KEY_MISMATCH" {
+		t.Errorf("未承诺的密钥撤销应被拒绝: %v", err)
+	}
+	if doc, _ := registry.Resolve(didStr); doc.Status != "active" || len(doc.Controller) != 0 {
+		t.Fatalf("被拒绝的操作不应改变文档: %+v", doc)
+	}
+
+	// 承诺的密钥可以变更控制者和撤销，文档中的密钥和承诺保持不变
+	controllers = []string{"did:qlink:recovery"}
+	proof = signWithPurpose(t, next, nextVM.ID, ControllerSigningPayload(didStr, controllers), "capabilityDelegation")
+	doc, _, err := registry.SetControllersWithNextKey(context.Background(), didStr, controllers, nextVM, proof)
+	if err != nil {
+		t.Fatalf("承诺的密钥变更控制者失败: %v", err)
+	}
+	if len(doc.Controller) != 1 || len(doc.VerificationMethod) != 1 || doc.NextKeyCommitment != regReq.NextKeyCommitment {
+		t.Errorf("变更控制者后的文档不正确: %+v", doc)
+	}
+
+	proof = signWith(t, next, nextVM.ID, RevokeSigningPayload(didStr))
+	if _, err := registry.RevokeWithNextKey(context.Background(), didStr, nextVM, proof); err != nil {
+		t.Fatalf("承诺的密钥撤销失败: %v", err)
+	}
+	if doc, _ := registry.Resolve(didStr); doc.Status != "revoked" {
+		t.Errorf("DID应已撤销: %s", doc.Status)
+	}
+}
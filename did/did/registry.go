@@ -3,19 +3,32 @@ package did
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/interfaces"
+	"github.com/qujing226/QLink/pkg/storage"
 	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
 )
 
 // DIDRegistry DID注册表
 type DIDRegistry struct {
-	blockchain BlockchainInterface           // 区块链接口
-	storage    map[string]*types.DIDDocument // 内存存储，实际应该用数据库
+	blockchain BlockchainInterface   // 区块链接口
+	storage    interfaces.DIDStorage // DID文档存储
+	verifier   *crypto.SignatureVerifier
 	mu         sync.RWMutex
+
+	// 链上提交
+	committer      OperationCommitter
+	commitMode     CommitMode
+	confirmTimeout time.Duration
+	pending        map[string]bool // 等待链上确认的DID
 }
 
 // RegisterRequest DID注册请求
@@ -23,6 +36,19 @@ type RegisterRequest struct {
 	DID                string                     `json:"did"`
 	VerificationMethod []types.VerificationMethod `json:"verificationMethod"`
 	Service            []types.Service            `json:"service,omitempty"`
+
+	// 验证关系，全部为空时所有验证方法都用于authentication、assertionMethod、capabilityInvocation和capabilityDelegation
+	Authentication       []string `json:"authentication,omitempty"`
+	AssertionMethod      []string `json:"assertionMethod,omitempty"`
+	KeyAgreement         []string `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []string `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []string `json:"capabilityDelegation,omitempty"`
+
+	// Controller 控制者DID，控制者的密钥可以沿控制者链更新和撤销该DID
+	Controller []string `json:"controller,omitempty"`
+
+	// NextKeyCommitment 预轮换承诺，设置后引入新密钥的变更必须由承诺的密钥签发
+	NextKeyCommitment string `json:"nextKeyCommitment,omitempty"`
 }
 
 // UpdateRequest DID更新请求
@@ -30,75 +56,156 @@ type UpdateRequest struct {
 	DID                string                     `json:"did"`
 	VerificationMethod []types.VerificationMethod `json:"verificationMethod,omitempty"`
 	Service            []types.Service            `json:"service,omitempty"`
+	NextKeyCommitment  string                     `json:"nextKeyCommitment,omitempty"`
 	Proof              *types.Proof               `json:"proof"`
 }
 
-// NewDIDRegistry 创建DID注册表实例
+// SigningPayload 返回更新请求需要签名的内容
+func (req *UpdateRequest) SigningPayload() *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation:          OperationUpdate,
+		DID:                req.DID,
+		VerificationMethod: req.VerificationMethod,
+		Service:            req.Service,
+		NextKeyCommitment:  req.NextKeyCommitment,
+	}
+}
+
+// RevokeSigningPayload 返回撤销操作需要签名的内容
+func RevokeSigningPayload(didStr string) *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation: OperationDeactivate,
+		DID:       didStr,
+	}
+}
+
+// NewDIDRegistry 创建DID注册表实例（使用内存存储）
 func NewDIDRegistry(blockchain BlockchainInterface) *DIDRegistry {
-	return &DIDRegistry{
-		blockchain: blockchain,
-		storage:    make(map[string]*types.DIDDocument),
+	return NewDIDRegistryWithStorage(blockchain, nil)
+}
+
+// NewDIDRegistryWithStorage 使用指定的DID存储创建DID注册表实例
+// didStorage 为空时使用内存存储
+func NewDIDRegistryWithStorage(blockchain BlockchainInterface, didStorage interfaces.DIDStorage) *DIDRegistry {
+	if didStorage == nil {
+		didStorage = storage.NewDIDStorage(storage.NewMemoryStorage())
 	}
+
+	registry := &DIDRegistry{
+		blockchain:     blockchain,
+		storage:        didStorage,
+		verifier:       crypto.NewSignatureVerifier(),
+		commitMode:     CommitModeLocalFirst,
+		confirmTimeout: 30 * time.Second,
+		pending:        make(map[string]bool),
+	}
+	if blockchain != nil {
+		registry.committer = NewBlockchainCommitter(blockchain)
+	}
+
+	return registry
+}
+
+// Storage 获取注册表使用的DID存储
+func (r *DIDRegistry) Storage() interfaces.DIDStorage {
+	return r.storage
 }
 
 // Register 注册DID
 func (r *DIDRegistry) Register(req *RegisterRequest) (*types.DIDDocument, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	doc, _, err := r.RegisterWithReceipt(context.Background(), req)
+	return doc, err
+}
 
-	// 验证DID格式
-	if err := r.validateDID(req.DID); err != nil {
-		return nil, err
-	}
+// RegisterWithReceipt 注册DID并返回链上提交回执
+func (r *DIDRegistry) RegisterWithReceipt(ctx context.Context, req *RegisterRequest) (*types.DIDDocument, *CommitReceipt, error) {
+	op, receipt, err := r.apply(ctx, req.DID, func() (*types.DIDOperation, error) {
+		// 验证DID格式
+		if err := r.validateDID(req.DID); err != nil {
+			return nil, err
+		}
 
-	// 检查DID是否已存在
-	if _, exists := r.storage[req.DID]; exists {
-		return nil, &DIDError{
-			Type:    ErrorTypeConflict,
-			Code:    "DID_EXISTS",
-			Message: "DID已存在",
-			Details: req.DID,
+		if err := validateServices(req.DID, req.Service); err != nil {
+			return nil, err
 		}
-	}
 
-	// 创建DID文档
-	now := time.Now()
-	doc := &types.DIDDocument{
-		Context: []string{
-			"https://www.w3.org/ns/did/v1",
-			"https://w3id.org/security/suites/jws-2020/v1",
-		},
-		ID:                 req.DID,
-		VerificationMethod: req.VerificationMethod,
-		Service:            req.Service,
-		Created:            &now,
-		Updated:            &now,
-		Status:             "active",
-	}
+		if err := validateControllers(req.Controller); err != nil {
+			return nil, err
+		}
 
-	// 设置认证和断言方法
-	for _, vm := range req.VerificationMethod {
-		doc.Authentication = append(doc.Authentication, vm.ID)
-		doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
-	}
+		if req.NextKeyCommitment != "" {
+			if err := crypto.ValidateNextKeyCommitment(req.NextKeyCommitment); err != nil {
+				return nil, preRotationError("INVALID_NEXT_KEY_COMMITMENT", err.Error(), req.NextKeyCommitment)
+			}
+		}
 
-	// 存储DID文档
-	r.storage[req.DID] = doc
+		// 检查DID是否已存在
+		if r.exists(req.DID) {
+			return nil, &DIDError{
+				Type:    ErrorTypeConflict,
+				Code:    "DID_EXISTS",
+				Message: "DID已存在",
+				Details: req.DID,
+			}
+		}
 
-	// 提交DID注册交易到区块链
-	if r.blockchain != nil {
-		tx, err := r.blockchain.RegisterDID(context.Background(), doc)
-		if err != nil {
-			// 区块链注册失败时，记录错误但不阻止DID注册
-			log.Printf("区块链注册失败，但DID已在内存中注册: %s, 错误: %v", doc.ID, err)
+		// 创建DID文档
+		now := time.Now()
+		doc := &types.DIDDocument{
+			Context: []string{
+				"https://www.w3.org/ns/did/v1",
+				"https://w3id.org/security/suites/jws-2020/v1",
+			},
+			ID:                 req.DID,
+			Controller:         req.Controller,
+			VerificationMethod: req.VerificationMethod,
+			Service:            req.Service,
+			Created:            &now,
+			Updated:            &now,
+			Status:             "active",
+			NextKeyCommitment:  req.NextKeyCommitment,
+		}
+
+		// 设置验证关系
+		if len(req.Authentication) == 0 && len(req.AssertionMethod) == 0 && len(req.KeyAgreement) == 0 &&
+			len(req.CapabilityInvocation) == 0 && len(req.CapabilityDelegation) == 0 {
+			for _, vm := range req.VerificationMethod {
+				doc.Authentication = append(doc.Authentication, vm.ID)
+				doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
+				doc.CapabilityInvocation = append(doc.CapabilityInvocation, vm.ID)
+				doc.CapabilityDelegation = append(doc.CapabilityDelegation, vm.ID)
+			}
 		} else {
-			log.Printf("DID注册交易已提交到区块链: %s, 交易哈希: %s", doc.ID, tx.Hash)
+			doc.Authentication = req.Authentication
+			doc.AssertionMethod = req.AssertionMethod
+			doc.KeyAgreement = req.KeyAgreement
+			doc.CapabilityInvocation = req.CapabilityInvocation
+			doc.CapabilityDelegation = req.CapabilityDelegation
+			for _, name := range rotatableRelationships {
+				for _, ref := range *relationshipRefs(doc, name) {
+					if indexOfVerificationMethod(doc, ref) < 0 {
+						return nil, &DIDError{
+							Type:    ErrorTypeValidation,
+							Code:    "INVALID_RELATIONSHIP",
+							Message: "验证关系引用了不存在的验证方法",
+							Details: ref,
+						}
+					}
+				}
+			}
 		}
-	} else {
-		log.Printf("注册DID (仅内存存储): %s", req.DID)
+
+		return &types.DIDOperation{
+			Operation: OperationCreate,
+			DID:       req.DID,
+			Document:  doc,
+		}, nil
+	})
+	if err != nil {
+		return nil, receipt, err
 	}
 
-	return doc, nil
+	return op.Document, receipt, nil
 }
 
 // Resolve 解析DID
@@ -106,86 +213,393 @@ func (r *DIDRegistry) Resolve(didStr string) (*types.DIDDocument, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	doc, exists := r.storage[didStr]
-	if !exists {
-		return nil, &DIDError{
-			Type:    ErrorTypeNotFound,
-			Code:    "DID_NOT_FOUND",
-			Message: "DID不存在",
-			Details: didStr,
+	return r.loadDocument(didStr)
+}
+
+// Update 更新DID
+func (r *DIDRegistry) Update(req *UpdateRequest) (*types.DIDDocument, error) {
+	doc, _, err := r.UpdateWithReceipt(context.Background(), req)
+	return doc, err
+}
+
+// UpdateWithReceipt 更新DID并返回链上提交回执
+func (r *DIDRegistry) UpdateWithReceipt(ctx context.Context, req *UpdateRequest) (*types.DIDDocument, *CommitReceipt, error) {
+	op, receipt, err := r.apply(ctx, req.DID, func() (*types.DIDOperation, error) {
+		doc, err := r.loadDocument(req.DID)
+		if err != nil {
+			return nil, err
 		}
+
+		if doc.Status == "revoked" {
+			return nil, &DIDError{
+				Type:    ErrorTypeValidation,
+				Code:    "DID_REVOKED",
+				Message: "DID已被撤销",
+				Details: req.DID,
+			}
+		}
+
+		// 文档有预轮换承诺时，引入新密钥必须由承诺的密钥签发；否则由当前文档或其控制者授权的验证方法签发
+		commitment := doc.NextKeyCommitment
+		preRotation := commitment != "" && introducedKey(doc, req.VerificationMethod) != nil
+		if !preRotation {
+			if err := r.authorize(doc, req.SigningPayload(), req.Proof, r.verifier.VerifyUpdatePermission); err != nil {
+				return nil, proofError(err)
+			}
+			if err := setNextKeyCommitment(doc, req.NextKeyCommitment); err != nil {
+				return nil, err
+			}
+		}
+
+		// 更新文档
+		if len(req.VerificationMethod) > 0 {
+			replaceVerificationMethods(doc, req.VerificationMethod)
+		}
+
+		if len(req.Service) > 0 {
+			if err := validateServices(req.DID, req.Service); err != nil {
+				return nil, err
+			}
+			doc.Service = req.Service
+		}
+
+		if preRotation {
+			if err := r.verifyPreRotation(doc, commitment, req.SigningPayload(), req.Proof, req.NextKeyCommitment); err != nil {
+				return nil, err
+			}
+			doc.NextKeyCommitment = req.NextKeyCommitment
+		}
+
+		now := time.Now()
+		doc.Updated = &now
+		doc.Proof = req.Proof
+
+		return &types.DIDOperation{
+			Operation: OperationUpdate,
+			DID:       req.DID,
+			Document:  doc,
+			Proof:     req.Proof,
+		}, nil
+	})
+	if err != nil {
+		return nil, receipt, err
 	}
 
-	return doc, nil
+	return op.Document, receipt, nil
 }
 
-// Update 更新DID
-func (r *DIDRegistry) Update(req *UpdateRequest) (*types.DIDDocument, error) {
+// Revoke 撤销DID
+func (r *DIDRegistry) Revoke(didStr string, proof *types.Proof) error {
+	_, err := r.RevokeWithReceipt(context.Background(), didStr, proof)
+	return err
+}
+
+// RevokeWithReceipt 撤销DID并返回链上提交回执
+func (r *DIDRegistry) RevokeWithReceipt(ctx context.Context, didStr string, proof *types.Proof) (*CommitReceipt, error) {
+	return r.RevokeWithNextKey(ctx, didStr, nil, proof)
+}
+
+// RevokeWithNextKey 撤销DID并返回链上提交回执
+// 文档有预轮换承诺时，nextKey 为承诺的密钥，证明必须由它签发；否则 nextKey 为空
+func (r *DIDRegistry) RevokeWithNextKey(ctx context.Context, didStr string, nextKey *types.VerificationMethod, proof *types.Proof) (*CommitReceipt, error) {
+	_, receipt, err := r.apply(ctx, didStr, func() (*types.DIDOperation, error) {
+		doc, err := r.loadDocument(didStr)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc.Status == "revoked" {
+			return nil, &DIDError{
+				Type:    ErrorTypeValidation,
+				Code:    "DID_ALREADY_REVOKED",
+				Message: "DID已被撤销",
+				Details: didStr,
+			}
+		}
+
+		if doc.NextKeyCommitment != "" {
+			if err := r.authorizeNextKey(doc, nextKey, RevokeSigningPayload(didStr), proof, r.verifier.VerifyRevokePermission); err != nil {
+				return nil, err
+			}
+		} else if err := r.authorize(doc, RevokeSigningPayload(didStr), proof, r.verifier.VerifyRevokePermission); err != nil {
+			return nil, proofError(err)
+		}
+
+		// 更新状态
+		doc.Status = "revoked"
+		now := time.Now()
+		doc.Updated = &now
+		doc.Proof = proof
+
+		return &types.DIDOperation{
+			Operation: OperationDeactivate,
+			DID:       didStr,
+			Document:  doc,
+			Proof:     proof,
+		}, nil
+	})
+
+	return receipt, err
+}
+
+// SetCommitMode 设置写入模式
+// replicated 模式要求已通过 SetCommitter 设置 ReplicatedCommitter
+func (r *DIDRegistry) SetCommitMode(mode CommitMode) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	doc, exists := r.storage[req.DID]
-	if !exists {
-		return nil, &DIDError{
-			Type:    ErrorTypeNotFound,
-			Code:    "DID_NOT_FOUND",
-			Message: "DID不存在",
-			Details: req.DID,
-		}
+	if err := checkCommitter(mode, r.committer); err != nil {
+		return err
 	}
+	r.commitMode = mode
+	return nil
+}
 
-	if doc.Status == "revoked" {
-		return nil, &DIDError{
+// GetCommitMode 获取写入模式
+func (r *DIDRegistry) GetCommitMode() CommitMode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.commitMode
+}
+
+// SetCommitter 设置链/共识层提交器，为空时操作只写入本地存储
+// 处于 replicated 模式时只能替换为另一个 ReplicatedCommitter
+func (r *DIDRegistry) SetCommitter(committer OperationCommitter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := checkCommitter(r.commitMode, committer); err != nil {
+		return err
+	}
+	r.committer = committer
+	return nil
+}
+
+// checkCommitter 检查写入模式与提交器是否匹配
+func checkCommitter(mode CommitMode, committer OperationCommitter) error {
+	if mode != CommitModeReplicated {
+		return nil
+	}
+	if _, ok := committer.(ReplicatedCommitter); !ok {
+		return &DIDError{
 			Type:    ErrorTypeValidation,
-			Code:    "DID_REVOKED",
-			Message: "DID已被撤销",
-			Details: req.DID,
+			Code:    "COMMITTER_NOT_REPLICATED",
+			Message: "replicated 写入模式需要由共识层状态机应用操作的提交器",
+			Details: mode.String(),
 		}
 	}
+	return nil
+}
 
-	// 更新文档
-	if len(req.VerificationMethod) > 0 {
-		doc.VerificationMethod = req.VerificationMethod
-		// 重新设置认证方法
-		doc.Authentication = []string{}
-		doc.AssertionMethod = []string{}
-		for _, vm := range req.VerificationMethod {
-			doc.Authentication = append(doc.Authentication, vm.ID)
-			doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
+// SetConfirmTimeout 设置原子模式下等待链上确认的超时时间
+func (r *DIDRegistry) SetConfirmTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.confirmTimeout = timeout
+}
+
+// apply 准备并提交一个DID操作
+// prepare 在持有写锁时执行，负责校验并构造操作；之后根据写入模式写入存储并提交到链
+func (r *DIDRegistry) apply(ctx context.Context, didStr string, prepare func() (*types.DIDOperation, error)) (*types.DIDOperation, *CommitReceipt, error) {
+	r.mu.Lock()
+
+	// 同一DID已有操作等待链上确认时拒绝新的操作，避免基于过期状态修改
+	if r.pending[didStr] {
+		r.mu.Unlock()
+		return nil, nil, &DIDError{
+			Type:    ErrorTypeConflict,
+			Code:    "DID_OPERATION_PENDING",
+			Message: "DID有尚未确认的操作",
+			Details: didStr,
 		}
 	}
 
-	if len(req.Service) > 0 {
-		doc.Service = req.Service
+	op, err := prepare()
+	if err == nil {
+		// 记录操作基于的版本，共识层应用时据此拒绝基于过期状态授权的操作
+		op.PreviousHash, err = r.headHash(op.DID)
+	}
+	if err != nil {
+		r.mu.Unlock()
+		return nil, nil, err
+	}
+
+	committer := r.committer
+	// ReplicatedCommitter 提交后由共识层状态机写入存储（包括本节点），无论写入模式如何注册表都不能再写入一次
+	_, replicated := committer.(ReplicatedCommitter)
+	if committer == nil {
+		defer r.mu.Unlock()
+		if err := r.applyLocal(op, ""); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("DID操作仅写入本地存储: %s %s", op.Operation, op.DID)
+		return op, nil, nil
 	}
 
-	now := time.Now()
-	doc.Updated = &now
-	doc.Proof = req.Proof
+	if r.commitMode == CommitModeLocalFirst && !replicated {
+		err := r.applyLocal(op, "")
+		timeout := r.confirmTimeout
+		r.mu.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
 
-	// 提交DID更新交易到区块链
-	if r.blockchain != nil {
-		tx, err := r.blockchain.UpdateDID(context.Background(), req.DID, doc, req.Proof)
+		// 本地写入已完成，释放锁后再提交，避免链客户端阻塞时拖住整个注册表
+		commitCtx, cancel := context.WithTimeout(ctx, timeout)
+		receipt, err := committer.CommitDIDOperation(commitCtx, op)
+		cancel()
 		if err != nil {
-			log.Printf("区块链更新失败，但继续内存更新: %v", err)
+			// 兼容模式下链上失败不影响本地写入
+			log.Printf("区块链提交失败，但DID已写入本地存储: %s %s, 错误: %v", op.Operation, op.DID, err)
 		} else {
-			log.Printf("DID更新交易已提交到区块链: %s, 交易哈希: %s", req.DID, tx.Hash)
+			log.Printf("DID操作已提交到区块链: %s %s, 交易哈希: %s", op.Operation, op.DID, receipt.TxHash)
 		}
-	} else {
-		log.Printf("仅内存更新DID: %s", req.DID)
+		return op, receipt, nil
 	}
 
-	return doc, nil
+	// 原子模式和复制模式（以及使用 ReplicatedCommitter 的任何模式）：暂存操作，释放锁后提交并等待确认
+	r.pending[didStr] = true
+	timeout := r.confirmTimeout
+	r.mu.Unlock()
+
+	commitCtx, cancel := context.WithTimeout(ctx, timeout)
+	receipt, err := committer.CommitDIDOperation(commitCtx, op)
+	cancel()
+
+	var pendingErr *CommitPendingError
+	if errors.As(err, &pendingErr) {
+		// 操作已进入共识日志，之后仍可能在各节点应用：保持待确认状态直到结果确定，避免基于过期状态重试
+		log.Printf("DID操作等待确认超时，结果未知: %s %s, 日志位置: %s", op.Operation, op.DID, pendingErr.Position)
+		go r.settlePending(didStr, pendingErr.Settled)
+		return nil, receipt, &DIDError{
+			Type:    ErrorTypePending,
+			Code:    "COMMIT_PENDING",
+			Message: "DID操作已提交但尚未确认，请稍后查询结果",
+			Details: pendingErr.Position,
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, didStr)
+
+	if err != nil {
+		// 回滚：丢弃暂存的操作，本地存储保持不变
+		log.Printf("DID操作未获链上确认，已回滚: %s %s, 错误: %v", op.Operation, op.DID, err)
+		return nil, receipt, &DIDError{
+			Type:    ErrorTypeBlockchain,
+			Code:    "CHAIN_COMMIT_FAILED",
+			Message: "DID操作未能在链上确认",
+			Details: err.Error(),
+		}
+	}
+
+	if replicated {
+		log.Printf("DID操作已由共识层提交并应用: %s %s, 日志位置: %s", op.Operation, op.DID, receipt.TxHash)
+		return op, receipt, nil
+	}
+
+	if err := r.applyLocal(op, receipt.TxHash); err != nil {
+		log.Printf("DID操作已在链上确认，但写入本地存储失败: %s %s, 交易哈希: %s, 错误: %v",
+			op.Operation, op.DID, receipt.TxHash, err)
+		return nil, receipt, err
+	}
+
+	log.Printf("DID操作已确认并应用: %s %s, 交易哈希: %s", op.Operation, op.DID, receipt.TxHash)
+	return op, receipt, nil
 }
 
-// Revoke 撤销DID
-func (r *DIDRegistry) Revoke(didStr string, proof *types.Proof) error {
+// settlePending 在结果未知的操作被应用或覆盖后解除DID的待确认状态
+func (r *DIDRegistry) settlePending(didStr string, settled <-chan struct{}) {
+	<-settled
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	delete(r.pending, didStr)
+}
 
-	doc, exists := r.storage[didStr]
-	if !exists {
-		return &DIDError{
+// applyLocal 将操作写入本地存储并记录历史版本（调用方需持有写锁）
+func (r *DIDRegistry) applyLocal(op *types.DIDOperation, txHash string) error {
+	if err := r.saveDocument(op.Document); err != nil {
+		return err
+	}
+
+	return r.recordVersion(op, txHash)
+}
+
+// List 列出所有DID
+func (r *DIDRegistry) List() ([]*types.DIDDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dids, err := r.storage.QueryDIDs(map[string]interface{}{})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return r.loadDocuments(dids)
+}
+
+// ListByController 列出由指定控制器管理的DID
+func (r *DIDRegistry) ListByController(controller string) ([]*types.DIDDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dids, err := r.storage.GetDIDsByController(controller)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return r.loadDocuments(dids)
+}
+
+// ListByStatus 列出指定状态（active、revoked）的DID
+func (r *DIDRegistry) ListByStatus(status string) ([]*types.DIDDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dids, err := r.storage.GetDIDsByStatus(status)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return r.loadDocuments(dids)
+}
+
+// Search 按关键字搜索DID（匹配DID标识符及文档内容）
+func (r *DIDRegistry) Search(keyword string) ([]*types.DIDDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dids, err := r.storage.SearchDIDs(keyword)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return r.loadDocuments(dids)
+}
+
+// Count 获取已注册的DID数量
+func (r *DIDRegistry) Count() (int64, error) {
+	count, err := r.storage.GetDIDCount()
+	if err != nil {
+		return 0, storageError(err)
+	}
+	return count, nil
+}
+
+// exists 检查DID是否已存在
+func (r *DIDRegistry) exists(didStr string) bool {
+	_, err := r.storage.GetDIDDocument(didStr)
+	return err == nil
+}
+
+// loadDocument 从存储中读取DID文档
+// 每次读取都返回新的副本，修改后需要通过 saveDocument 写回
+func (r *DIDRegistry) loadDocument(didStr string) (*types.DIDDocument, error) {
+	value, err := r.storage.GetDIDDocument(didStr)
+	if err != nil {
+		return nil, &DIDError{
 			Type:    ErrorTypeNotFound,
 			Code:    "DID_NOT_FOUND",
 			Message: "DID不存在",
@@ -193,47 +607,85 @@ func (r *DIDRegistry) Revoke(didStr string, proof *types.Proof) error {
 		}
 	}
 
-	if doc.Status == "revoked" {
-		return &DIDError{
-			Type:    ErrorTypeValidation,
-			Code:    "DID_ALREADY_REVOKED",
-			Message: "DID已被撤销",
-			Details: didStr,
-		}
+	doc, err := decodeStoredDocument(value)
+	if err != nil {
+		return nil, storageError(err)
 	}
 
-	// 更新状态
-	doc.Status = "revoked"
-	now := time.Now()
-	doc.Updated = &now
-	doc.Proof = proof
+	return doc, nil
+}
+
+// loadDocuments 批量读取DID文档，结果按DID排序
+func (r *DIDRegistry) loadDocuments(dids []string) ([]*types.DIDDocument, error) {
+	sorted := append([]string(nil), dids...)
+	sort.Strings(sorted)
 
-	// 提交DID撤销交易到区块链
-	if r.blockchain != nil {
-		tx, err := r.blockchain.RevokeDID(context.Background(), didStr, proof)
+	docs := make([]*types.DIDDocument, 0, len(sorted))
+	for _, didStr := range sorted {
+		doc, err := r.loadDocument(didStr)
 		if err != nil {
-			log.Printf("区块链撤销失败，但继续内存撤销: %v", err)
-		} else {
-			log.Printf("DID撤销交易已提交到区块链: %s, 交易哈希: %s", didStr, tx.Hash)
+			// 索引与文档不一致时跳过
+			continue
 		}
-	} else {
-		log.Printf("仅内存撤销DID: %s", didStr)
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// saveDocument 将DID文档写入存储
+// 文档以JSON对象形式保存，以便存储层建立控制器、状态等索引
+func (r *DIDRegistry) saveDocument(doc *types.DIDDocument) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return storageError(err)
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return storageError(err)
+	}
+
+	if err := r.storage.PutDIDDocument(doc.ID, value); err != nil {
+		return storageError(err)
 	}
 
 	return nil
 }
 
-// List 列出所有DID
-func (r *DIDRegistry) List() ([]*types.DIDDocument, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// decodeStoredDocument 将存储中的值转换为DID文档
+func decodeStoredDocument(value interface{}) (*types.DIDDocument, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
 
-	var docs []*types.DIDDocument
-	for _, doc := range r.storage {
-		docs = append(docs, doc)
+	return FromJSON(data)
+}
+
+// storageError 包装存储层错误
+func storageError(err error) *DIDError {
+	return &DIDError{
+		Type:    ErrorTypeStorage,
+		Code:    "STORAGE_ERROR",
+		Message: "DID存储操作失败",
+		Details: err.Error(),
 	}
+}
 
-	return docs, nil
+// proofError 将证明验证失败转换为DIDError，缺少证明与证明无效使用不同的错误码
+func proofError(err error) *DIDError {
+	code := "INVALID_PROOF"
+	if appErr, ok := err.(*utils.AppError); ok && appErr.Code == "PROOF_REQUIRED" {
+		code = "PROOF_REQUIRED"
+	}
+
+	return &DIDError{
+		Type:    ErrorTypeUnauthorized,
+		Code:    code,
+		Message: "DID操作证明验证失败",
+		Details: err.Error(),
+	}
 }
 
 // validateDID 验证DID格式
@@ -289,8 +741,11 @@ func (e *DIDError) Error() string {
 
 // 错误类型常量
 const (
-	ErrorTypeValidation = "validation"
-	ErrorTypeNotFound   = "not_found"
-	ErrorTypeConflict   = "conflict"
-	ErrorTypeBlockchain = "blockchain"
+	ErrorTypeValidation   = "validation"
+	ErrorTypeNotFound     = "not_found"
+	ErrorTypeConflict     = "conflict"
+	ErrorTypeBlockchain   = "blockchain"
+	ErrorTypeStorage      = "storage"
+	ErrorTypeUnauthorized = "unauthorized"
+	ErrorTypePending      = "pending" // 操作已被接受但结果尚未确认
 )
@@ -0,0 +1,403 @@
+package did
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/storage"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// testIdentity 测试用DID及其密钥
+type testIdentity struct {
+	did     string
+	keyPair *crypto.HybridKeyPair
+}
+
+func newTestIdentity(t *testing.T, didStr string) *testIdentity {
+	t.Helper()
+
+	keyPair, err := crypto.GenerateHybridKeyPair()
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+	return &testIdentity{did: didStr, keyPair: keyPair}
+}
+
+func (id *testIdentity) registerRequest(t *testing.T) *RegisterRequest {
+	t.Helper()
+
+	jwk, err := id.keyPair.ToJWK()
+	if err != nil {
+		t.Fatalf("转换JWK失败: %v", err)
+	}
+	return &RegisterRequest{
+		DID: id.did,
+		VerificationMethod: []types.VerificationMethod{
+			{
+				ID:           id.did + "#key-1",
+				Type:         "JsonWebKey2020",
+				Controller:   id.did,
+				PublicKeyJwk: jwk,
+			},
+		},
+	}
+}
+
+// proof 使用该身份的密钥对操作内容签名
+func (id *testIdentity) proof(t *testing.T, payload interface{}, purpose string) *types.Proof {
+	t.Helper()
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: id.did + "#key-1",
+		ProofPurpose:       purpose,
+		Nonce:              hex.EncodeToString(nonce),
+	}
+	if err := id.keyPair.SignProof(payload, proof); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	return proof
+}
+
+func (id *testIdentity) revokeProof(t *testing.T) *types.Proof {
+	return id.proof(t, RevokeSigningPayload(id.did), "authentication")
+}
+
+func TestRegistryPersistsThroughDIDStorage(t *testing.T) {
+	base := storage.NewBoltStorage(filepath.Join(t.TempDir(), "did.db"))
+	if err := base.Open(); err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+
+	alice := newTestIdentity(t, "did:qlink:alice")
+	bob := newTestIdentity(t, "did:qlink:bob")
+
+	registry := NewDIDRegistryWithStorage(nil, storage.NewDIDStorage(base))
+	if _, err := registry.Register(alice.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	if _, err := registry.Register(bob.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	if err := registry.Revoke(bob.did, bob.revokeProof(t)); err != nil {
+		t.Fatalf("撤销DID失败: %v", err)
+	}
+	base.Close()
+
+	// 重新打开存储，模拟节点重启
+	reopened := storage.NewBoltStorage(base.Path())
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer reopened.Close()
+
+	didStorage := storage.NewDIDStorage(reopened)
+	if err := didStorage.LoadFromStorage(); err != nil {
+		t.Fatalf("加载DID存储失败: %v", err)
+	}
+	registry = NewDIDRegistryWithStorage(nil, didStorage)
+
+	doc, err := registry.Resolve("did:qlink:alice")
+	if err != nil {
+		t.Fatalf("重启后解析DID失败: %v", err)
+	}
+	if len(doc.Authentication) != 1 || doc.Authentication[0] != "did:qlink:alice#key-1" {
+		t.Errorf("认证方法未正确恢复: %v", doc.Authentication)
+	}
+
+	if err := registry.VerifyHistory(bob.did); err != nil {
+		t.Errorf("重启后历史记录校验失败: %v", err)
+	}
+
+	revoked, err := registry.ListByStatus("revoked")
+	if err != nil || len(revoked) != 1 || revoked[0].ID != "did:qlink:bob" {
+		t.Errorf("按状态查询结果不正确: %v, %v", revoked, err)
+	}
+
+	all, err := registry.List()
+	if err != nil || len(all) != 2 {
+		t.Errorf("列出DID结果不正确: %d, %v", len(all), err)
+	}
+}
+
+func TestRegistryResolveReturnsCopy(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	if _, err := registry.Register(newTestIdentity(t, "did:qlink:carol").registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	doc, _ := registry.Resolve("did:qlink:carol")
+	doc.Status = "revoked"
+
+	stored, _ := registry.Resolve("did:qlink:carol")
+	if stored.Status != "active" {
+		t.Error("修改解析结果不应影响存储中的文档")
+	}
+}
+
+// fakeCommitter 测试用提交器，按配置返回成功或失败
+type fakeCommitter struct {
+	err error
+	ops []*types.DIDOperation
+}
+
+func (f *fakeCommitter) CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*CommitReceipt, error) {
+	f.ops = append(f.ops, op)
+
+	receipt := &CommitReceipt{
+		Operation:   op.Operation,
+		DID:         op.DID,
+		TxHash:      "0xabc",
+		SubmittedAt: time.Now(),
+	}
+	if f.err != nil {
+		receipt.Status = types.TransactionStatusFailed
+		receipt.Error = f.err.Error()
+		return receipt, f.err
+	}
+
+	receipt.Status = types.TransactionStatusConfirmed
+	return receipt, nil
+}
+
+func TestRegistryAtomicCommitFailureDiscardsOperation(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	registry.SetCommitMode(CommitModeAtomic)
+	registry.SetCommitter(&fakeCommitter{err: errors.New("共识超时")})
+
+	_, receipt, err := registry.RegisterWithReceipt(context.Background(), newTestIdentity(t, "did:qlink:dave").registerRequest(t))
+	var didErr *DIDError
+	if !errors.As(err, &didErr) || didErr.Code != "CHAIN_COMMIT_FAILED" {
+		t.Fatalf("链上提交失败时应返回CHAIN_COMMIT_FAILED: %v", err)
+	}
+	if receipt == nil || receipt.Status != types.TransactionStatusFailed {
+		t.Errorf("回执状态不正确: %+v", receipt)
+	}
+	if _, err := registry.Resolve("did:qlink:dave"); err == nil {
+		t.Error("未确认的注册不应写入存储")
+	}
+}
+
+func TestRegistryAtomicCommitReturnsReceipt(t *testing.T) {
+	committer := &fakeCommitter{}
+	registry := NewDIDRegistry(nil)
+	registry.SetCommitMode(CommitModeAtomic)
+	registry.SetCommitter(committer)
+
+	erin := newTestIdentity(t, "did:qlink:erin")
+	doc, receipt, err := registry.RegisterWithReceipt(context.Background(), erin.registerRequest(t))
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	if receipt.TxHash != "0xabc" || receipt.Status != types.TransactionStatusConfirmed {
+		t.Errorf("回执不正确: %+v", receipt)
+	}
+	if doc.ID != "did:qlink:erin" {
+		t.Errorf("返回的文档不正确: %s", doc.ID)
+	}
+
+	// 撤销失败时本地状态保持不变
+	committer.err = errors.New("交易被拒绝")
+	if _, err := registry.RevokeWithReceipt(context.Background(), erin.did, erin.revokeProof(t)); err == nil {
+		t.Fatal("链上提交失败时撤销应失败")
+	}
+	stored, err := registry.Resolve("did:qlink:erin")
+	if err != nil || stored.Status != "active" {
+		t.Errorf("撤销失败后DID状态不应改变: %v, %v", stored, err)
+	}
+
+	if len(committer.ops) != 2 || committer.ops[1].Operation != OperationDeactivate {
+		t.Errorf("提交的操作不正确: %d", len(committer.ops))
+	}
+}
+
+func TestRegistryLocalFirstKeepsWriteOnCommitFailure(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	registry.SetCommitter(&fakeCommitter{err: errors.New("节点不可用")})
+
+	_, receipt, err := registry.RegisterWithReceipt(context.Background(), newTestIdentity(t, "did:qlink:frank").registerRequest(t))
+	if err != nil {
+		t.Fatalf("兼容模式下链上失败不应返回错误: %v", err)
+	}
+	if receipt == nil || receipt.Status != types.TransactionStatusFailed {
+		t.Errorf("回执应标记为失败: %+v", receipt)
+	}
+	if _, err := registry.Resolve("did:qlink:frank"); err != nil {
+		t.Errorf("兼容模式下DID应写入本地存储: %v", err)
+	}
+}
+
+// replicatingCommitter 测试用提交器，声明由共识层应用已提交的操作
+type replicatingCommitter struct {
+	fakeCommitter
+}
+
+func (c *replicatingCommitter) AppliesCommittedOperations() {}
+
+func TestRegistryReplicatedModeRequiresReplicatedCommitter(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	if err := registry.SetCommitMode(CommitModeReplicated); didErrorCode(err) != "COMMITTER_NOT_REPLICATED" {
+		t.Fatalf("没有提交器时不应使用replicated模式: %v", err)
+	}
+	registry.SetCommitter(&fakeCommitter{})
+	if err := registry.SetCommitMode(CommitModeReplicated); didErrorCode(err) != "COMMITTER_NOT_REPLICATED" {
+		t.Fatalf("区块链提交器不应用于replicated模式: %v", err)
+	}
+	if registry.GetCommitMode() != CommitModeLocalFirst {
+		t.Errorf("设置失败时写入模式不应改变: %s", registry.GetCommitMode())
+	}
+
+	if err := registry.SetCommitter(&replicatingCommitter{}); err != nil {
+		t.Fatalf("设置提交器失败: %v", err)
+	}
+	if err := registry.SetCommitMode(CommitModeReplicated); err != nil {
+		t.Fatalf("共识提交器应可用于replicated模式: %v", err)
+	}
+	if err := registry.SetCommitter(&fakeCommitter{}); didErrorCode(err) != "COMMITTER_NOT_REPLICATED" {
+		t.Errorf("replicated模式下不应替换为其他提交器: %v", err)
+	}
+	if err := registry.SetCommitter(nil); didErrorCode(err) != "COMMITTER_NOT_REPLICATED" {
+		t.Errorf("replicated模式下不应移除提交器: %v", err)
+	}
+}
+
+// applyingCommitter 测试用提交器，像共识层状态机一样在提交时把操作应用到注册表
+type applyingCommitter struct {
+	registry *DIDRegistry
+	count    int
+}
+
+func (a *applyingCommitter) CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*CommitReceipt, error) {
+	a.count++
+	txHash := fmt.Sprintf("raft-1-%d", a.count)
+	if err := a.registry.ApplyOperation(op, txHash); err != nil {
+		return nil, err
+	}
+	return &CommitReceipt{Operation: op.Operation, DID: op.DID, TxHash: txHash, Status: types.TransactionStatusConfirmed}, nil
+}
+
+func (a *applyingCommitter) AppliesCommittedOperations() {}
+
+// TestRegistryReplicatedCommitterAppliesOnce 使用ReplicatedCommitter时，任何写入模式下操作都只由状态机写入一次
+func TestRegistryReplicatedCommitterAppliesOnce(t *testing.T) {
+	for _, mode := range []CommitMode{CommitModeLocalFirst, CommitModeAtomic, CommitModeReplicated} {
+		registry := NewDIDRegistry(nil)
+		if err := registry.SetCommitter(&applyingCommitter{registry: registry}); err != nil {
+			t.Fatalf("设置提交器失败: %v", err)
+		}
+		if err := registry.SetCommitMode(mode); err != nil {
+			t.Fatalf("设置写入模式失败: %v", err)
+		}
+
+		jack := newTestIdentity(t, "did:qlink:jack")
+		if _, err := registry.Register(jack.registerRequest(t)); err != nil {
+			t.Fatalf("%s 模式下注册DID失败: %v", mode, err)
+		}
+		history, err := registry.History(jack.did)
+		if err != nil || len(history) != 1 || history[0].TxHash != "raft-1-1" {
+			t.Errorf("%s 模式下应只记录一个由状态机写入的版本: %+v, %v", mode, history, err)
+		}
+	}
+}
+
+// blockingCommitter 测试用提交器，阻塞直到上下文结束
+type blockingCommitter struct {
+	started chan struct{}
+}
+
+func (b *blockingCommitter) CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*CommitReceipt, error) {
+	close(b.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRegistryLocalFirstCommitDoesNotHoldLock(t *testing.T) {
+	committer := &blockingCommitter{started: make(chan struct{})}
+	registry := NewDIDRegistry(nil)
+	registry.SetCommitter(committer)
+	registry.SetConfirmTimeout(200 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := registry.RegisterWithReceipt(context.Background(), newTestIdentity(t, "did:qlink:gina").registerRequest(t))
+		done <- err
+	}()
+
+	// 提交阻塞期间注册表仍可读取已写入本地的文档
+	<-committer.started
+	if _, err := registry.Resolve("did:qlink:gina"); err != nil {
+		t.Errorf("提交期间应能解析本地已写入的DID: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("兼容模式下提交超时不应返回错误: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("提交应在确认超时后返回")
+	}
+}
+
+func TestRegistryRejectsUnsignedAndForgedUpdates(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	grace := newTestIdentity(t, "did:qlink:grace")
+	if _, err := registry.Register(grace.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	service := []types.Service{{ID: grace.did + "#hub", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}}
+	assertCode := func(err error, code string) {
+		t.Helper()
+		var didErr *DIDError
+		if !errors.As(err, &didErr) || didErr.Code != code {
+			t.Fatalf("期望错误码 %s, 实际: %v", code, err)
+		}
+	}
+
+	// 未签名
+	_, err := registry.Update(&UpdateRequest{DID: grace.did, Service: service})
+	assertCode(err, "PROOF_REQUIRED")
+
+	// 其他密钥签名
+	req := &UpdateRequest{DID: grace.did, Service: service}
+	req.Proof = newTestIdentity(t, grace.did).proof(t, req.SigningPayload(), "authentication")
+	_, err = registry.Update(req)
+	assertCode(err, "INVALID_PROOF")
+
+	// 签名内容与请求不符
+	req.Proof = grace.proof(t, RevokeSigningPayload(grace.did), "authentication")
+	_, err = registry.Update(req)
+	assertCode(err, "INVALID_PROOF")
+
+	// 正确签名
+	req.Proof = grace.proof(t, req.SigningPayload(), "authentication")
+	doc, err := registry.Update(req)
+	if err != nil {
+		t.Fatalf("签名正确的更新应成功: %v", err)
+	}
+	if len(doc.Service) != 1 {
+		t.Errorf("服务未更新: %v", doc.Service)
+	}
+
+	// 重放同一证明
+	_, err = registry.Update(req)
+	assertCode(err, "INVALID_PROOF")
+
+	// 撤销需要authentication证明
+	err = registry.Revoke(grace.did, grace.proof(t, RevokeSigningPayload(grace.did), "assertionMethod"))
+	assertCode(err, "INVALID_PROOF")
+	if err := registry.Revoke(grace.did, grace.revokeProof(t)); err != nil {
+		t.Fatalf("撤销DID失败: %v", err)
+	}
+}
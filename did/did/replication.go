@@ -0,0 +1,149 @@
+package did
+
+import (
+	"fmt"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// RegistryState 注册表的完整状态，用作共识层状态机快照
+type RegistryState struct {
+	Documents []*types.DIDDocument       `json:"documents"`
+	History   map[string][]*VersionEntry `json:"history"`
+}
+
+// ApplyOperation 应用由共识层复制的已提交操作
+// 操作已在提议节点上完成校验和签名验证，这里只检查是否与本地状态一致：
+// 操作记录的 PreviousHash 必须等于当前最新版本的哈希，基于过期版本授权的操作（例如并发更新、
+// 密钥轮换前签发的更新）在每个节点上都以同样的方式被拒绝。
+// txHash 为日志位置，集群中每个节点对同一操作记录相同的历史版本。
+// 节点重启后共识层会重放已提交的条目，历史中已有同一位置的版本时直接返回
+func (r *DIDRegistry) ApplyOperation(op *types.DIDOperation, txHash string) error {
+	if op == nil || op.DID == "" || op.Document == nil || op.Document.ID != op.DID {
+		return &DIDError{
+			Type:    ErrorTypeValidation,
+			Code:    "INVALID_OPERATION",
+			Message: "DID操作缺少文档或DID不一致",
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history, err := r.loadHistory(op.DID)
+	if err != nil {
+		return err
+	}
+	if txHash != "" {
+		for _, entry := range history {
+			if entry.TxHash == txHash {
+				return nil
+			}
+		}
+	}
+
+	exists := r.exists(op.DID)
+	switch op.Operation {
+	case OperationCreate:
+		if exists {
+			return &DIDError{
+				Type:    ErrorTypeConflict,
+				Code:    "DID_EXISTS",
+				Message: "DID已存在",
+				Details: op.DID,
+			}
+		}
+	case OperationUpdate, OperationDeactivate:
+		if !exists {
+			return &DIDError{
+				Type:    ErrorTypeNotFound,
+				Code:    "DID_NOT_FOUND",
+				Message: "DID不存在",
+				Details: op.DID,
+			}
+		}
+	default:
+		return &DIDError{
+			Type:    ErrorTypeValidation,
+			Code:    "INVALID_OPERATION",
+			Message: "不支持的DID操作",
+			Details: op.Operation,
+		}
+	}
+
+	head := ""
+	if len(history) > 0 {
+		head = history[len(history)-1].Hash
+	}
+	if op.PreviousHash != head {
+		return &DIDError{
+			Type:    ErrorTypeConflict,
+			Code:    "STALE_OPERATION",
+			Message: "DID操作基于的版本已不是最新版本",
+			Details: op.DID,
+		}
+	}
+
+	return r.applyLocal(op, txHash)
+}
+
+// ExportState 导出全部DID文档及其历史版本
+func (r *DIDRegistry) ExportState() (*RegistryState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dids, err := r.storage.QueryDIDs(map[string]interface{}{})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	docs, err := r.loadDocuments(dids)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &RegistryState{Documents: docs, History: make(map[string][]*VersionEntry, len(docs))}
+	for _, doc := range docs {
+		history, err := r.loadHistory(doc.ID)
+		if err != nil {
+			return nil, err
+		}
+		state.History[doc.ID] = history
+	}
+	return state, nil
+}
+
+// RestoreState 将注册表快进到快照状态
+// 已提交的状态只会向前增长，因此每个DID的本地历史与快照历史必须一方是另一方的前缀：
+// 本地落后时追加缺少的版本，本地已包含快照（节点重启后存储领先于共识层快照）时保持不变
+func (r *DIDRegistry) RestoreState(state *RegistryState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, doc := range state.Documents {
+		local, err := r.loadHistory(doc.ID)
+		if err != nil {
+			return err
+		}
+
+		snapshot := state.History[doc.ID]
+		for i := 0; i < len(local) && i < len(snapshot); i++ {
+			if local[i].Hash != snapshot[i].Hash {
+				return historyError("SNAPSHOT_DIVERGED", fmt.Sprintf("%s 版本 %s 与快照不一致", doc.ID, local[i].VersionID))
+			}
+		}
+		if len(local) >= len(snapshot) {
+			continue
+		}
+
+		if err := r.saveDocument(doc); err != nil {
+			return err
+		}
+		for _, entry := range snapshot[len(local):] {
+			if err := r.storage.PutDIDHistory(doc.ID, entry); err != nil {
+				return storageError(err)
+			}
+		}
+	}
+	return nil
+}
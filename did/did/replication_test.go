@@ -0,0 +1,45 @@
+package did
+
+import (
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestApplyOperationRejectsStaleBase(t *testing.T) {
+	leader := NewDIDRegistry(nil)
+	follower := NewDIDRegistry(nil)
+
+	jack := newTestIdentity(t, "did:qlink:jack")
+	doc, err := leader.Register(jack.registerRequest(t))
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	create := &types.DIDOperation{Operation: OperationCreate, DID: jack.did, Document: doc}
+	if err := follower.ApplyOperation(create, "raft-1-1"); err != nil {
+		t.Fatalf("应用创建操作失败: %v", err)
+	}
+	history, _ := follower.History(jack.did)
+	base := history[0].Hash
+
+	// 两个更新都基于版本1授权，只有先提交的一个生效
+	first := &types.DIDOperation{Operation: OperationUpdate, DID: jack.did, Document: doc, PreviousHash: base}
+	second := &types.DIDOperation{Operation: OperationDeactivate, DID: jack.did, Document: doc, PreviousHash: base}
+	if err := follower.ApplyOperation(first, "raft-1-2"); err != nil {
+		t.Fatalf("应用更新操作失败: %v", err)
+	}
+	if err := follower.ApplyOperation(second, "raft-1-3"); didErrorCode(err) != "STALE_OPERATION" {
+		t.Errorf("基于过期版本的操作应被拒绝: %v", err)
+	}
+
+	// 缺少基准版本的更新同样被拒绝，重放已应用的条目不报错
+	if err := follower.ApplyOperation(&types.DIDOperation{Operation: OperationUpdate, DID: jack.did, Document: doc}, "raft-1-4"); didErrorCode(err) != "STALE_OPERATION" {
+		t.Errorf("缺少基准版本的更新应被拒绝: %v", err)
+	}
+	if err := follower.ApplyOperation(first, "raft-1-2"); err != nil {
+		t.Errorf("重放已应用的条目不应报错: %v", err)
+	}
+	if history, _ := follower.History(jack.did); len(history) != 2 {
+		t.Errorf("应只记录两个版本: %d", len(history))
+	}
+}
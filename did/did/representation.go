@@ -0,0 +1,136 @@
+package did
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/ugorji/go/codec"
+)
+
+// DID文档表示和解析结果的内容类型（W3C DID Core 6、DID Resolution 7.1）
+const (
+	ContentTypeDIDCBOR       = "application/did+cbor"
+	ContentTypeDIDResolution = `application/ld+json;profile="https://w3id.org/did-resolution"`
+
+	// DIDResolutionProfile 解析结果的JSON-LD profile及上下文
+	DIDResolutionProfile = "https://w3id.org/did-resolution"
+	DIDResolutionContext = "https://w3id.org/did-resolution/v1"
+)
+
+// 解析错误（W3C DID Resolution didResolutionMetadata.error）
+const (
+	ResolutionErrorInvalidDID                 = "invalidDid"
+	ResolutionErrorNotFound                   = "notFound"
+	ResolutionErrorMethodNotSupported         = "methodNotSupported"
+	ResolutionErrorRepresentationNotSupported = "representationNotSupported"
+	ResolutionErrorInvalidDIDDocument         = "invalidDidDocument"
+	ResolutionErrorInternal                   = "internalError"
+)
+
+// NegotiateRepresentation 按Accept头选择响应的内容类型
+// 返回 ContentTypeDIDResolution 表示返回完整解析结果，否则返回DID文档的表示；
+// 没有可接受的类型时返回false
+func NegotiateRepresentation(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ContentTypeDIDResolution, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok && strings.Trim(q, "0.") == "" {
+			continue // q=0 表示不可接受
+		}
+
+		switch mediaType {
+		case "application/ld+json":
+			if strings.Contains(params["profile"], DIDResolutionProfile) {
+				return ContentTypeDIDResolution, true
+			}
+			return ContentTypeDIDLDJSON, true
+		case ContentTypeDIDLDJSON, ContentTypeDIDJSON, ContentTypeDIDCBOR:
+			return mediaType, true
+		case "application/json", "application/*", "*/*":
+			return ContentTypeDIDResolution, true
+		}
+	}
+
+	return "", false
+}
+
+// MarshalResolutionResult 将解析结果序列化为 application/ld+json;profile=did-resolution
+func MarshalResolutionResult(result *ResolutionResult) ([]byte, error) {
+	return json.Marshal(struct {
+		Context string `json:"@context"`
+		*ResolutionResult
+	}{DIDResolutionContext, result})
+}
+
+// MarshalDocument 按内容类型生成DID文档的表示
+// application/did+json 和 application/did+cbor 不包含 @context
+func MarshalDocument(doc *types.DIDDocument, contentType string) ([]byte, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("序列化DID文档失败: %w", err)
+	}
+
+	switch contentType {
+	case ContentTypeDIDLDJSON:
+		return data, nil
+	case ContentTypeDIDJSON, ContentTypeDIDCBOR:
+	default:
+		return nil, fmt.Errorf("不支持的DID文档表示: %s", contentType)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("解析DID文档失败: %w", err)
+	}
+	delete(fields, "@context")
+
+	if contentType == ContentTypeDIDJSON {
+		return json.Marshal(fields)
+	}
+
+	// 使用确定性编码，相同文档总是得到相同字节
+	handle := &codec.CborHandle{}
+	handle.Canonical = true
+
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, handle).Encode(cborValue(fields)); err != nil {
+		return nil, fmt.Errorf("CBOR编码DID文档失败: %w", err)
+	}
+	return out, nil
+}
+
+// cborValue 将JSON数字转换为CBOR整数或浮点数
+func cborValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = cborValue(item)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = cborValue(v[i])
+		}
+		return v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
@@ -0,0 +1,110 @@
+package did
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/ugorji/go/codec"
+)
+
+func TestNegotiateRepresentation(t *testing.T) {
+	tests := map[string]string{
+		"":                                ContentTypeDIDResolution,
+		"*/*":                             ContentTypeDIDResolution,
+		"application/json":                ContentTypeDIDResolution,
+		"application/did+ld+json":         ContentTypeDIDLDJSON,
+		"application/did+json":            ContentTypeDIDJSON,
+		"application/did+cbor":            ContentTypeDIDCBOR,
+		"application/ld+json":             ContentTypeDIDLDJSON,
+		"text/html, application/did+json": ContentTypeDIDJSON,
+		`application/ld+json;profile="https://w3id.org/did-resolution"`: ContentTypeDIDResolution,
+		"application/did+cbor;q=0, application/did+json":                ContentTypeDIDJSON,
+	}
+	for accept, want := range tests {
+		got, ok := NegotiateRepresentation(accept)
+		if !ok || got != want {
+			t.Errorf("Accept %q: 期望 %s, 实际 %s (%v)", accept, want, got, ok)
+		}
+	}
+
+	if _, ok := NegotiateRepresentation("text/html, image/png"); ok {
+		t.Error("不支持的类型应协商失败")
+	}
+}
+
+func TestMarshalDocument(t *testing.T) {
+	doc := &types.DIDDocument{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      "did:qlink:alice",
+		Service: []types.Service{{ID: "#web", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}
+
+	ldJSON, err := MarshalDocument(doc, ContentTypeDIDLDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(ldJSON, &fields); err != nil || fields["@context"] == nil {
+		t.Errorf("JSON-LD表示应包含@context: %s", ldJSON)
+	}
+
+	plainJSON, err := MarshalDocument(doc, ContentTypeDIDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = nil
+	if err := json.Unmarshal(plainJSON, &fields); err != nil || fields["@context"] != nil || fields["id"] != doc.ID {
+		t.Errorf("JSON表示不应包含@context: %s", plainJSON)
+	}
+
+	cbor, err := MarshalDocument(doc, ContentTypeDIDCBOR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := codec.NewDecoderBytes(cbor, &codec.CborHandle{}).Decode(&decoded); err != nil {
+		t.Fatalf("CBOR解码失败: %v", err)
+	}
+	if decoded["id"] != doc.ID || decoded["@context"] != nil {
+		t.Errorf("CBOR表示不正确: %v", decoded)
+	}
+	again, _ := MarshalDocument(doc, ContentTypeDIDCBOR)
+	if string(again) != string(cbor) {
+		t.Error("CBOR编码应当是确定性的")
+	}
+
+	if _, err := MarshalDocument(doc, "text/html"); err == nil {
+		t.Error("不支持的表示应返回错误")
+	}
+}
+
+func TestResolveDeactivatedDID(t *testing.T) {
+	registry := NewDIDRegistry(nil)
+	bob := newTestIdentity(t, "did:qlink:bob")
+	if _, err := registry.Register(bob.registerRequest(t)); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	if err := registry.Revoke(bob.did, bob.revokeProof(t)); err != nil {
+		t.Fatalf("撤销DID失败: %v", err)
+	}
+
+	resolver := NewDIDResolver(config.DefaultConfig(), registry, nil)
+	result, err := resolver.Resolve(bob.did)
+	if err != nil || result.DIDResolutionMetadata.Error != "" {
+		t.Fatalf("解析已撤销的DID失败: %+v, %v", result.DIDResolutionMetadata, err)
+	}
+	if !result.DIDDocumentMetadata.Deactivated {
+		t.Error("已撤销的DID应标记为deactivated")
+	}
+
+	data, err := MarshalResolutionResult(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields["@context"] != DIDResolutionContext || fields["didDocument"] == nil {
+		t.Errorf("解析结果表示不正确: %s", data)
+	}
+}
@@ -1,10 +1,14 @@
 package did
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/qujing226/QLink/did/blockchain"
 	"github.com/qujing226/QLink/pkg/config"
@@ -17,6 +21,9 @@ type DIDResolver struct {
 	config   *config.Config
 	registry *DIDRegistry
 	storage  *blockchain.StorageManager
+
+	driversMu sync.RWMutex
+	drivers   map[string]MethodDriver
 }
 
 // ResolutionResult DID解析结果
@@ -34,47 +41,65 @@ type ResolutionMetadata struct {
 
 // DocumentMetadata 文档元数据
 type DocumentMetadata struct {
-	Created     string `json:"created,omitempty"`
-	Updated     string `json:"updated,omitempty"`
-	Deactivated bool   `json:"deactivated,omitempty"`
+	Created       string `json:"created,omitempty"`
+	Updated       string `json:"updated,omitempty"`
+	Deactivated   bool   `json:"deactivated,omitempty"`
+	VersionID     string `json:"versionId,omitempty"`
+	NextUpdate    string `json:"nextUpdate,omitempty"`
+	NextVersionID string `json:"nextVersionId,omitempty"`
 }
 
 // NewDIDResolver 创建DID解析器
 func NewDIDResolver(cfg *config.Config, reg *DIDRegistry, storageManager *blockchain.StorageManager) *DIDResolver {
-	return &DIDResolver{
+	r := &DIDResolver{
 		config:   cfg,
 		registry: reg,
 		storage:  storageManager,
+		drivers:  make(map[string]MethodDriver),
+	}
+
+	r.RegisterDriver(&qlinkDriver{resolver: r})
+	r.RegisterDriver(NewKeyDriver())
+	r.RegisterDriver(NewPeerDriver())
+	// did:web 会让节点按DID访问任意主机，须在配置中显式开启
+	if cfg != nil && cfg.DID != nil && cfg.DID.Resolver != nil && cfg.DID.Resolver.EnableWeb {
+		r.RegisterDriver(NewWebDriver(nil))
 	}
+	return r
 }
 
 // Resolve 解析DID
+// 支持 versionId 和 versionTime 查询参数，例如 did:qlink:abc?versionTime=2026-01-01T00:00:00Z
 func (r *DIDResolver) Resolve(didStr string) (*ResolutionResult, error) {
+	return r.ResolveContext(context.Background(), didStr)
+}
+
+// ResolveContext 解析DID，按DID方法分发给已注册的驱动
+func (r *DIDResolver) ResolveContext(ctx context.Context, didStr string) (*ResolutionResult, error) {
 	log.Printf("解析DID: %s", didStr)
 
+	didStr, query, err := splitVersionQuery(didStr)
+	if err != nil {
+		return resolutionError("invalidDidUrl"), nil
+	}
+
 	// 验证DID格式
 	if err := r.validateDIDFormat(didStr); err != nil {
-		return &ResolutionResult{
-			DIDResolutionMetadata: &ResolutionMetadata{
-				ContentType: "application/did+ld+json",
-				Error:       "invalidDid",
-			},
-		}, nil
+		return resolutionError(ResolutionErrorInvalidDID), nil
 	}
 
 	// 解析DID方法
-	method := r.extractMethod(didStr)
-	switch method {
-	case "qlink":
-		return r.resolveQlinkDID(didStr)
-	default:
-		return &ResolutionResult{
-			DIDResolutionMetadata: &ResolutionMetadata{
-				ContentType: "application/did+ld+json",
-				Error:       "methodNotSupported",
-			},
-		}, nil
+	driver, ok := r.getDriver(r.extractMethod(didStr))
+	if !ok {
+		return resolutionError(ResolutionErrorMethodNotSupported), nil
+	}
+
+	var opts ResolutionOptions
+	if query != nil {
+		opts.VersionID = query.versionID
+		opts.VersionTime = query.versionTime
 	}
+	return driver.Resolve(ctx, didStr, opts)
 }
 
 // resolveQlinkDID 解析QLink DID
@@ -82,16 +107,17 @@ func (r *DIDResolver) resolveQlinkDID(didStr string) (*ResolutionResult, error)
 	// 首先尝试从链上解析
 	doc, err := r.registry.Resolve(didStr)
 	if err == nil {
+		metadata := newDocumentMetadata(doc)
+		if latest, _, err := r.registry.ResolveVersion(didStr, "", nil); err == nil {
+			metadata.VersionID = latest.VersionID
+		}
+
 		return &ResolutionResult{
 			DIDDocument: doc,
 			DIDResolutionMetadata: &ResolutionMetadata{
 				ContentType: "application/did+ld+json",
 			},
-			DIDDocumentMetadata: &DocumentMetadata{
-				Created:     doc.Created.Format("2006-01-02T15:04:05Z"),
-				Updated:     doc.Updated.Format("2006-01-02T15:04:05Z"),
-				Deactivated: doc.Status == "revoked",
-			},
+			DIDDocumentMetadata: metadata,
 		}, nil
 	}
 
@@ -103,11 +129,7 @@ func (r *DIDResolver) resolveQlinkDID(didStr string) (*ResolutionResult, error)
 			DIDResolutionMetadata: &ResolutionMetadata{
 				ContentType: "application/did+ld+json",
 			},
-			DIDDocumentMetadata: &DocumentMetadata{
-				Created:     offchainDoc.Created.Format("2006-01-02T15:04:05Z"),
-				Updated:     offchainDoc.Updated.Format("2006-01-02T15:04:05Z"),
-				Deactivated: offchainDoc.Status == "revoked",
-			},
+			DIDDocumentMetadata: newDocumentMetadata(offchainDoc),
 		}, nil
 	}
 
@@ -120,6 +142,85 @@ func (r *DIDResolver) resolveQlinkDID(didStr string) (*ResolutionResult, error)
 	}, nil
 }
 
+// versionQuery 版本查询参数
+type versionQuery struct {
+	versionID   string
+	versionTime *time.Time
+}
+
+// resolveQlinkVersion 按版本解析QLink DID
+func (r *DIDResolver) resolveQlinkVersion(didStr string, query *versionQuery) (*ResolutionResult, error) {
+	entry, next, err := r.registry.ResolveVersion(didStr, query.versionID, query.versionTime)
+	if err != nil {
+		errorCode := "notFound"
+		if didErr, ok := err.(*DIDError); ok && didErr.Type == ErrorTypeValidation {
+			errorCode = "invalidDidUrl"
+		}
+		return &ResolutionResult{
+			DIDResolutionMetadata: &ResolutionMetadata{
+				ContentType: "application/did+ld+json",
+				Error:       errorCode,
+			},
+		}, nil
+	}
+
+	metadata := newDocumentMetadata(entry.Document)
+	metadata.VersionID = entry.VersionID
+	if next != nil {
+		metadata.NextUpdate = formatMetadataTime(&next.VersionTime)
+		metadata.NextVersionID = next.VersionID
+	}
+
+	return &ResolutionResult{
+		DIDDocument: entry.Document,
+		DIDResolutionMetadata: &ResolutionMetadata{
+			ContentType: "application/did+ld+json",
+		},
+		DIDDocumentMetadata: metadata,
+	}, nil
+}
+
+// splitVersionQuery 拆分DID中的版本查询参数，没有查询部分时返回nil
+func splitVersionQuery(didStr string) (string, *versionQuery, error) {
+	base, rawQuery, found := strings.Cut(didStr, "?")
+	if !found {
+		return didStr, nil, nil
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := &versionQuery{versionID: values.Get("versionId")}
+	if raw := values.Get("versionTime"); raw != "" {
+		versionTime, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return "", nil, fmt.Errorf("无效的versionTime: %s", raw)
+		}
+		query.versionTime = &versionTime
+	}
+
+	return base, query, nil
+}
+
+// newDocumentMetadata 根据文档生成元数据
+func newDocumentMetadata(doc *types.DIDDocument) *DocumentMetadata {
+	return &DocumentMetadata{
+		Created:     formatMetadataTime(doc.Created),
+		Updated:     formatMetadataTime(doc.Updated),
+		Deactivated: doc.Status == "revoked" || doc.Deactivated,
+	}
+}
+
+// formatMetadataTime 按XML Datetime（UTC，精确到秒）格式化元数据时间
+func formatMetadataTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
 // validateDIDFormat 验证DID格式
 func (r *DIDResolver) validateDIDFormat(didStr string) error {
 	if !strings.HasPrefix(didStr, "did:") {
@@ -145,51 +246,32 @@ func (r *DIDResolver) extractMethod(didStr string) string {
 
 // ResolveVerificationMethod 解析验证方法
 func (r *DIDResolver) ResolveVerificationMethod(didURL string) (*types.VerificationMethod, error) {
-	// 解析DID URL
-	parts := strings.Split(didURL, "#")
-	if len(parts) != 2 {
+	parsed, err := ParseDIDURL(didURL)
+	if err != nil || parsed.Fragment == "" {
 		return nil, utils.WrapValidationError(fmt.Errorf("无效的DID URL格式"), didURL)
 	}
 
-	didStr := parts[0]
-	fragment := parts[1]
-
-	// 解析DID文档
-	result, err := r.Resolve(didStr)
+	result, err := r.Dereference(didURL)
 	if err != nil {
 		return nil, err
 	}
 
-	if result.DIDDocument == nil {
-		return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "DID_DOCUMENT_NOT_FOUND",
-			"DID文档不存在", didStr)
+	switch result.DereferencingMetadata.Error {
+	case "":
+	case DereferenceErrorInvalidDIDURL:
+		return nil, utils.WrapValidationError(fmt.Errorf("无效的DID URL格式"), didURL)
+	default:
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND",
+			"验证方法不存在", parsed.Fragment)
 	}
 
-	// 查找验证方法
-	for _, vm := range result.DIDDocument.VerificationMethod {
-		if strings.HasSuffix(vm.ID, "#"+fragment) {
-			return &vm, nil
-		}
+	vm, ok := result.ContentStream.(*types.VerificationMethod)
+	if !ok {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND",
+			"验证方法不存在", parsed.Fragment)
 	}
 
-	return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND",
-		"验证方法不存在", fragment)
-}
-
-// GetSupportedMethods 获取支持的DID方法
-func (r *DIDResolver) GetSupportedMethods() []string {
-	return []string{"qlink"}
-}
-
-// IsSupported 检查是否支持指定的DID方法
-func (r *DIDResolver) IsSupported(method string) bool {
-	supportedMethods := r.GetSupportedMethods()
-	for _, supported := range supportedMethods {
-		if supported == method {
-			return true
-		}
-	}
-	return false
+	return vm, nil
 }
 
 // resolveFromOffchain 从链下存储解析
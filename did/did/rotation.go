@@ -0,0 +1,313 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// OperationRotateKey 密钥轮换的签名操作名，链上仍以 OperationUpdate 记录
+const OperationRotateKey = "rotateKey"
+
+// rotatableRelationships 轮换时可以转移给新密钥的验证关系
+var rotatableRelationships = []string{
+	"authentication",
+	"assertionMethod",
+	"keyAgreement",
+	"capabilityInvocation",
+	"capabilityDelegation",
+}
+
+// RotateKeyRequest 密钥轮换请求
+// 新验证方法接替 RetireKeyID 的验证关系，旧密钥标记为退役但仍保留在文档中；
+// 证明必须由当前的authentication密钥签发，文档有预轮换承诺时则必须由与承诺匹配的新密钥签发
+type RotateKeyRequest struct {
+	DID                   string                   `json:"did"`
+	NewVerificationMethod types.VerificationMethod `json:"verificationMethod"`
+	RetireKeyID           string                   `json:"retireKeyId"`
+	// Relationships 新密钥承担的验证关系，为空时继承旧密钥的全部验证关系
+	Relationships []string `json:"relationships,omitempty"`
+	// NextKeyCommitment 新的预轮换承诺，文档已有承诺时必填
+	NextKeyCommitment string       `json:"nextKeyCommitment,omitempty"`
+	Proof             *types.Proof `json:"proof"`
+}
+
+// SigningPayload 返回密钥轮换需要签名的内容
+func (req *RotateKeyRequest) SigningPayload() *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation:          OperationRotateKey,
+		DID:                req.DID,
+		VerificationMethod: []types.VerificationMethod{req.NewVerificationMethod},
+		RetireKeyID:        req.RetireKeyID,
+		Relationships:      req.Relationships,
+		NextKeyCommitment:  req.NextKeyCommitment,
+	}
+}
+
+// RotateKey 轮换DID的密钥
+func (r *DIDRegistry) RotateKey(ctx context.Context, req *RotateKeyRequest) (*types.DIDDocument, *CommitReceipt, error) {
+	op, receipt, err := r.apply(ctx, req.DID, func() (*types.DIDOperation, error) {
+		doc, err := r.loadDocument(req.DID)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc.Status == "revoked" {
+			return nil, &DIDError{
+				Type:    ErrorTypeValidation,
+				Code:    "DID_REVOKED",
+				Message: "DID已被撤销",
+				Details: req.DID,
+			}
+		}
+
+		commitment := doc.NextKeyCommitment
+		if commitment == "" {
+			if err := r.authorize(doc, req.SigningPayload(), req.Proof, r.verifier.VerifyRotatePermission); err != nil {
+				return nil, proofError(err)
+			}
+			if err := setNextKeyCommitment(doc, req.NextKeyCommitment); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := rotateKey(doc, req); err != nil {
+			return nil, err
+		}
+
+		// 预轮换：在轮换后的文档上验证新密钥的证明，并替换为新的承诺
+		if commitment != "" {
+			if err := r.verifyPreRotation(doc, commitment, req.SigningPayload(), req.Proof, req.NextKeyCommitment); err != nil {
+				return nil, err
+			}
+			doc.NextKeyCommitment = req.NextKeyCommitment
+		}
+
+		now := time.Now()
+		doc.Updated = &now
+		doc.Proof = req.Proof
+
+		return &types.DIDOperation{
+			Operation: OperationUpdate,
+			DID:       req.DID,
+			Document:  doc,
+			Proof:     req.Proof,
+		}, nil
+	})
+	if err != nil {
+		return nil, receipt, err
+	}
+
+	return op.Document, receipt, nil
+}
+
+// rotateKey 在文档上执行轮换：添加新密钥、转移验证关系并退役旧密钥
+func rotateKey(doc *types.DIDDocument, req *RotateKeyRequest) error {
+	newVM := req.NewVerificationMethod
+	if newVM.ID == "" || newVM.Type == "" || (newVM.PublicKeyJwk == nil && newVM.PublicKeyMultibase == "") {
+		return rotationError("NEW_KEY_INVALID", "新验证方法缺少id、type或公钥", newVM.ID)
+	}
+	if newVM.Controller != doc.ID {
+		return rotationError("NEW_KEY_INVALID", "新验证方法的控制者必须是DID本身", newVM.Controller)
+	}
+	if newVM.Revoked != nil {
+		return rotationError("NEW_KEY_INVALID", "新验证方法不能是退役状态", newVM.ID)
+	}
+	if indexOfVerificationMethod(doc, newVM.ID) >= 0 {
+		return &DIDError{
+			Type:    ErrorTypeConflict,
+			Code:    "KEY_EXISTS",
+			Message: "验证方法ID已存在",
+			Details: newVM.ID,
+		}
+	}
+
+	oldIndex := indexOfVerificationMethod(doc, req.RetireKeyID)
+	if oldIndex < 0 {
+		return &DIDError{
+			Type:    ErrorTypeNotFound,
+			Code:    "KEY_NOT_FOUND",
+			Message: "要退役的验证方法不存在",
+			Details: req.RetireKeyID,
+		}
+	}
+	oldVM := &doc.VerificationMethod[oldIndex]
+	if oldVM.Revoked != nil {
+		return rotationError("KEY_ALREADY_RETIRED", "验证方法已退役", oldVM.ID)
+	}
+
+	relationships := req.Relationships
+	if len(relationships) == 0 {
+		for _, name := range rotatableRelationships {
+			if containsReference(doc, *relationshipRefs(doc, name), oldVM.ID) {
+				relationships = append(relationships, name)
+			}
+		}
+	}
+	for _, name := range relationships {
+		if relationshipRefs(doc, name) == nil {
+			return rotationError("INVALID_RELATIONSHIP", "无效的验证关系", name)
+		}
+	}
+
+	// 旧密钥退出所有验证关系，新密钥加入指定的验证关系
+	for _, name := range rotatableRelationships {
+		refs := relationshipRefs(doc, name)
+		kept := make([]string, 0, len(*refs)+1)
+		for _, ref := range *refs {
+			if !sameReference(doc, ref, oldVM.ID) {
+				kept = append(kept, ref)
+			}
+		}
+		if containsString(relationships, name) {
+			kept = append(kept, newVM.ID)
+		}
+		*refs = kept
+	}
+
+	if len(doc.Authentication) == 0 {
+		return rotationError("AUTHENTICATION_REQUIRED", "轮换后文档必须至少保留一个authentication密钥", req.RetireKeyID)
+	}
+
+	now := time.Now()
+	oldVM.Revoked = &now
+	doc.VerificationMethod = append(doc.VerificationMethod, newVM)
+	return nil
+}
+
+// replaceVerificationMethods 用更新请求中的验证方法替换文档中的验证方法
+// 保留的密钥维持原有的验证关系，新密钥只加入authentication和assertionMethod；
+// 被删除或已退役的密钥从所有验证关系中移除，已退役的密钥保持退役状态
+func replaceVerificationMethods(doc *types.DIDDocument, vms []types.VerificationMethod) {
+	replaced := make([]types.VerificationMethod, len(vms))
+	copy(replaced, vms)
+
+	kept := make(map[string]bool, len(replaced))
+	var added []string
+	for i := range replaced {
+		index := indexOfVerificationMethod(doc, replaced[i].ID)
+		if index < 0 || !samePublicKey(&doc.VerificationMethod[index], &replaced[i]) {
+			// 新密钥，或同一ID换了公钥，都不继承原有的验证关系
+			if replaced[i].Revoked == nil {
+				added = append(added, replaced[i].ID)
+			}
+			continue
+		}
+		if doc.VerificationMethod[index].Revoked != nil {
+			replaced[i].Revoked = doc.VerificationMethod[index].Revoked
+		}
+		if replaced[i].Revoked == nil {
+			kept[doc.VerificationMethod[index].ID] = true
+		}
+	}
+
+	for _, name := range rotatableRelationships {
+		refs := relationshipRefs(doc, name)
+		retained := make([]string, 0, len(*refs))
+		for _, ref := range *refs {
+			if index := indexOfVerificationMethod(doc, ref); index >= 0 && kept[doc.VerificationMethod[index].ID] {
+				retained = append(retained, ref)
+			}
+		}
+		*refs = retained
+	}
+	doc.Authentication = append(doc.Authentication, added...)
+	doc.AssertionMethod = append(doc.AssertionMethod, added...)
+	doc.VerificationMethod = replaced
+}
+
+// relationshipRefs 返回验证关系列表的指针，名称无效时返回nil
+func relationshipRefs(doc *types.DIDDocument, name string) *[]string {
+	switch name {
+	case "authentication":
+		return &doc.Authentication
+	case "assertionMethod":
+		return &doc.AssertionMethod
+	case "keyAgreement":
+		return &doc.KeyAgreement
+	case "capabilityInvocation":
+		return &doc.CapabilityInvocation
+	case "capabilityDelegation":
+		return &doc.CapabilityDelegation
+	}
+	return nil
+}
+
+// indexOfVerificationMethod 查找验证方法下标，兼容绝对ID和相对ID
+func indexOfVerificationMethod(doc *types.DIDDocument, id string) int {
+	for i := range doc.VerificationMethod {
+		if sameReference(doc, doc.VerificationMethod[i].ID, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameReference 判断两个验证方法引用是否指向同一个ID
+func sameReference(doc *types.DIDDocument, a, b string) bool {
+	abs := func(id string) string {
+		if strings.HasPrefix(id, "#") {
+			return doc.ID + id
+		}
+		return id
+	}
+	return abs(a) == abs(b)
+}
+
+// containsReference 检查引用列表中是否包含指定的验证方法
+func containsReference(doc *types.DIDDocument, refs []string, id string) bool {
+	for _, ref := range refs {
+		if sameReference(doc, ref, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NextKeyID 返回文档中下一个未被占用的 #key-N 验证方法ID
+func NextKeyID(doc *types.DIDDocument) string {
+	for i := len(doc.VerificationMethod) + 1; ; i++ {
+		id := fmt.Sprintf("%s#key-%d", doc.ID, i)
+		if indexOfVerificationMethod(doc, id) < 0 {
+			return id
+		}
+	}
+}
+
+// NewKeyVerificationMethod 由混合密钥对生成JsonWebKey2020验证方法
+func NewKeyVerificationMethod(didStr, id string, keyPair *crypto.HybridKeyPair) (*types.VerificationMethod, error) {
+	jwk, err := keyPair.ToJWK()
+	if err != nil {
+		return nil, fmt.Errorf("转换公钥为JWK失败: %w", err)
+	}
+
+	return &types.VerificationMethod{
+		ID:           id,
+		Type:         "JsonWebKey2020",
+		Controller:   didStr,
+		PublicKeyJwk: jwk,
+	}, nil
+}
+
+// rotationError 创建密钥轮换校验错误
+func rotationError(code, message, details string) error {
+	return &DIDError{
+		Type:    ErrorTypeValidation,
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+}
@@ -0,0 +1,184 @@
+package did
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestRegistryRotateKey(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	registry := NewDIDRegistry(nil)
+	current, err := registry.Register(regReq)
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	didStr := builder.GetDID()
+	oldKeyID := builder.GetKeyID()
+	oldBuilder, err := NewDIDDocumentBuilderFromKeyPair(builder.GetKeyPair())
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	historical, err := oldBuilder.signOperation(&types.DIDOperationPayload{Operation: "attest", DID: didStr}, "assertionMethod")
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	newKeyPair, err := crypto.GenerateHybridKeyPair()
+	if err != nil {
+		t.Fatalf("生成密钥对失败: %v", err)
+	}
+	req, err := builder.CreateRotationRequest(current, newKeyPair)
+	if err != nil {
+		t.Fatalf("创建轮换请求失败: %v", err)
+	}
+
+	// 证明覆盖新验证方法，替换公钥后验证失败
+	tampered := *req
+	otherKeyPair, _ := crypto.GenerateHybridKeyPair()
+	otherVM, _ := NewKeyVerificationMethod(didStr, req.NewVerificationMethod.ID, otherKeyPair)
+	tampered.NewVerificationMethod = *otherVM
+	if _, _, err := registry.RotateKey(context.Background(), &tampered); err == nil {
+		t.Fatal("被篡改的轮换请求不应通过验证")
+	}
+
+	doc, _, err := registry.RotateKey(context.Background(), req)
+	if err != nil {
+		t.Fatalf("密钥轮换失败: %v", err)
+	}
+
+	newKeyID := didStr + "#key-2"
+	if builder.GetKeyID() != newKeyID {
+		t.Errorf("构建器应切换到新密钥: %s", builder.GetKeyID())
+	}
+	if len(doc.VerificationMethod) != 2 {
+		t.Fatalf("旧密钥应保留在文档中: %+v", doc.VerificationMethod)
+	}
+	if doc.VerificationMethod[0].Revoked == nil {
+		t.Error("旧密钥应标记为退役")
+	}
+	for _, refs := range [][]string{doc.Authentication, doc.AssertionMethod} {
+		if containsReference(doc, refs, oldKeyID) || !containsReference(doc, refs, newKeyID) {
+			t.Errorf("验证关系未转移给新密钥: %v", refs)
+		}
+	}
+
+	// 旧密钥不能再更新文档，新密钥可以
+	if updateReq, err := oldBuilder.CreateUpdateRequest(nil); err != nil {
+		t.Fatalf("创建更新请求失败: %v", err)
+	} else if _, err := registry.Update(updateReq); err == nil {
+		t.Error("退役的密钥不应通过验证")
+	}
+	updateReq, err := builder.CreateUpdateRequest(nil)
+	if err != nil {
+		t.Fatalf("创建更新请求失败: %v", err)
+	}
+	if _, err := registry.Update(updateReq); err != nil {
+		t.Errorf("新密钥更新文档失败: %v", err)
+	}
+
+	// 退役前签发的证明仍可作为历史证明验证
+	verifier := crypto.NewSignatureVerifier()
+	payload := &types.DIDOperationPayload{Operation: "attest", DID: didStr}
+	if err := verifier.VerifyProof(payload, historical, &doc.VerificationMethod[0]); err == nil {
+		t.Error("退役的验证方法不应通过当前证明验证")
+	}
+	if err := verifier.VerifyHistoricalProof(payload, historical, &doc.VerificationMethod[0]); err != nil {
+		t.Errorf("退役前的证明应通过历史验证: %v", err)
+	}
+
+	// 已退役的密钥不能再次轮换
+	req, _ = oldBuilder.CreateRotationRequest(doc, otherKeyPair)
+	if _, _, err := registry.RotateKey(context.Background(), req); err == nil {
+		t.Error("退役的密钥不应发起轮换")
+	}
+}
+
+func TestRotateKeyKeepsAuthentication(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	doc, err := builder.BuildDocument()
+	if err != nil {
+		t.Fatalf("构建文档失败: %v", err)
+	}
+
+	newKeyPair, _ := crypto.GenerateHybridKeyPair()
+	newVM, _ := NewKeyVerificationMethod(doc.ID, NextKeyID(doc), newKeyPair)
+	req := &RotateKeyRequest{
+		DID:                   doc.ID,
+		NewVerificationMethod: *newVM,
+		RetireKeyID:           builder.GetKeyID(),
+		Relationships:         []string{"keyAgreement"},
+	}
+	err = rotateKey(doc, req)
+	if didErr, ok := err.(*DIDError); !ok || didErr.Code != "AUTHENTICATION_REQUIRED" {
+		t.Errorf("轮换后没有authentication密钥应被拒绝: %v", err)
+	}
+
+	req.Relationships = []string{"unknown"}
+	if err := rotateKey(doc, req); err == nil {
+		t.Error("无效的验证关系应被拒绝")
+	}
+}
+
+func TestUpdateKeepsExistingRelationships(t *testing.T) {
+	didStr := "did:qlink:relationships"
+	vm := func(id string) types.VerificationMethod {
+		keyPair, err := crypto.GenerateHybridKeyPair()
+		if err != nil {
+			t.Fatalf("生成密钥对失败: %v", err)
+		}
+		method, err := NewKeyVerificationMethod(didStr, didStr+id, keyPair)
+		if err != nil {
+			t.Fatalf("创建验证方法失败: %v", err)
+		}
+		return *method
+	}
+	primary, issuer, agreement := vm("#key-1"), vm("#key-2"), vm("#key-3")
+	doc := &types.DIDDocument{
+		ID:                   didStr,
+		VerificationMethod:   []types.VerificationMethod{primary, issuer, agreement},
+		Authentication:       []string{primary.ID},
+		AssertionMethod:      []string{primary.ID, issuer.ID},
+		KeyAgreement:         []string{agreement.ID},
+		CapabilityInvocation: []string{primary.ID},
+		CapabilityDelegation: []string{primary.ID},
+	}
+
+	// 只用于签发凭证的密钥不能借更新获得委托权限，被删除的密钥从keyAgreement中移除
+	added := vm("#key-4")
+	replaceVerificationMethods(doc, []types.VerificationMethod{primary, issuer, added})
+
+	expected := map[string][]string{
+		"authentication":       {primary.ID, added.ID},
+		"assertionMethod":      {primary.ID, issuer.ID, added.ID},
+		"keyAgreement":         {},
+		"capabilityInvocation": {primary.ID},
+		"capabilityDelegation": {primary.ID},
+	}
+	for name, want := range expected {
+		got := *relationshipRefs(doc, name)
+		if len(got) != len(want) {
+			t.Errorf("%s 不正确: %v, 期望 %v", name, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s 不正确: %v, 期望 %v", name, got, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// 常用服务类型
+const (
+	// ServiceTypeDIDCommMessaging DIDComm v2 消息端点，serviceEndpoint 为带uri的对象
+	ServiceTypeDIDCommMessaging = "DIDCommMessaging"
+	// ServiceTypeLinkedDomains 关联域名（DIF Well Known DID Configuration），端点为HTTPS源
+	ServiceTypeLinkedDomains = "LinkedDomains"
+)
+
+// 单个服务变更的签名操作名，链上仍以 OperationUpdate 记录
+const (
+	OperationAddService    = "addService"
+	OperationRemoveService = "removeService"
+	OperationSetService    = "setService"
+)
+
+// ServiceSigningPayload 返回添加服务需要签名的内容
+func ServiceSigningPayload(didStr string, service types.Service) *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation: OperationAddService,
+		DID:       didStr,
+		Service:   []types.Service{service},
+	}
+}
+
+// SetServiceSigningPayload 返回添加或替换服务需要签名的内容
+func SetServiceSigningPayload(didStr string, service types.Service) *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation: OperationSetService,
+		DID:       didStr,
+		Service:   []types.Service{service},
+	}
+}
+
+// RemoveServiceSigningPayload 返回移除服务需要签名的内容
+func RemoveServiceSigningPayload(didStr, serviceID string) *types.DIDOperationPayload {
+	return &types.DIDOperationPayload{
+		Operation: OperationRemoveService,
+		DID:       didStr,
+		ServiceID: serviceID,
+	}
+}
+
+// AddService 向DID文档添加一个服务，证明需由文档或其控制者的认证方法对 ServiceSigningPayload 签名
+func (r *DIDRegistry) AddService(ctx context.Context, didStr string, service types.Service, proof *types.Proof) (*types.DIDDocument, *CommitReceipt, error) {
+	return r.patchServices(ctx, didStr, ServiceSigningPayload(didStr, service), proof, func(doc *types.DIDDocument) error {
+		if err := ValidateService(didStr, &service); err != nil {
+			return err
+		}
+		if findService(doc, serviceFragment(didStr, service.ID)) != nil {
+			return &DIDError{
+				Type:    ErrorTypeConflict,
+				Code:    "SERVICE_EXISTS",
+				Message: "服务已存在",
+				Details: service.ID,
+			}
+		}
+
+		doc.Service = append(doc.Service, service)
+		return nil
+	})
+}
+
+// SetService 添加服务，同ID的服务已存在时整体替换，证明需对 SetServiceSigningPayload 签名
+// 用于需要反复发布新版本的服务端点，例如状态列表凭证
+func (r *DIDRegistry) SetService(ctx context.Context, didStr string, service types.Service, proof *types.Proof) (*types.DIDDocument, *CommitReceipt, error) {
+	return r.patchServices(ctx, didStr, SetServiceSigningPayload(didStr, service), proof, func(doc *types.DIDDocument) error {
+		if err := ValidateService(didStr, &service); err != nil {
+			return err
+		}
+
+		fragment := serviceFragment(didStr, service.ID)
+		for i := range doc.Service {
+			if matchesFragment(doc, doc.Service[i].ID, fragment) {
+				doc.Service[i] = service
+				return nil
+			}
+		}
+		doc.Service = append(doc.Service, service)
+		return nil
+	})
+}
+
+// RemoveService 从DID文档移除一个服务，证明需由文档中的认证方法对 RemoveServiceSigningPayload 签名
+func (r *DIDRegistry) RemoveService(ctx context.Context, didStr, serviceID string, proof *types.Proof) (*types.DIDDocument, *CommitReceipt, error) {
+	return r.patchServices(ctx, didStr, RemoveServiceSigningPayload(didStr, serviceID), proof, func(doc *types.DIDDocument) error {
+		fragment := serviceFragment(didStr, serviceID)
+		for i := range doc.Service {
+			if matchesFragment(doc, doc.Service[i].ID, fragment) {
+				doc.Service = append(doc.Service[:i], doc.Service[i+1:]...)
+				return nil
+			}
+		}
+
+		return &DIDError{
+			Type:    ErrorTypeNotFound,
+			Code:    "SERVICE_NOT_FOUND",
+			Message: "服务不存在",
+			Details: serviceID,
+		}
+	})
+}
+
+// patchServices 校验证明后修改服务列表，并作为一次更新操作提交
+func (r *DIDRegistry) patchServices(ctx context.Context, didStr string, payload *types.DIDOperationPayload, proof *types.Proof, patch func(doc *types.DIDDocument) error) (*types.DIDDocument, *CommitReceipt, error) {
+	op, receipt, err := r.apply(ctx, didStr, func() (*types.DIDOperation, error) {
+		doc, err := r.loadDocument(didStr)
+		if err != nil {
+			return nil, err
+		}
+
+		if doc.Status == "revoked" {
+			return nil, &DIDError{
+				Type:    ErrorTypeValidation,
+				Code:    "DID_REVOKED",
+				Message: "DID已被撤销",
+				Details: didStr,
+			}
+		}
+
+		if err := r.authorize(doc, payload, proof, r.verifier.VerifyUpdatePermission); err != nil {
+			return nil, proofError(err)
+		}
+
+		if err := patch(doc); err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		doc.Updated = &now
+		doc.Proof = proof
+
+		return &types.DIDOperation{
+			Operation: OperationUpdate,
+			DID:       didStr,
+			Document:  doc,
+			Proof:     proof,
+		}, nil
+	})
+	if err != nil {
+		return nil, receipt, err
+	}
+
+	return op.Document, receipt, nil
+}
+
+// serviceFragment 取服务ID的片段部分，兼容绝对ID和相对ID
+func serviceFragment(didStr, serviceID string) string {
+	if fragment, ok := strings.CutPrefix(serviceID, didStr+"#"); ok {
+		return fragment
+	}
+	return strings.TrimPrefix(serviceID, "#")
+}
+
+// validateServices 校验服务列表，服务ID不能重复
+func validateServices(didStr string, services []types.Service) error {
+	seen := make(map[string]bool, len(services))
+	for i := range services {
+		if err := ValidateService(didStr, &services[i]); err != nil {
+			return err
+		}
+
+		fragment := serviceFragment(didStr, services[i].ID)
+		if seen[fragment] {
+			return serviceError(services[i].ID, "服务ID重复")
+		}
+		seen[fragment] = true
+	}
+	return nil
+}
+
+// ValidateService 校验服务
+// 服务ID必须是本DID下的片段（did#xxx 或 #xxx）；DIDCommMessaging 和 LinkedDomains 还会校验端点格式
+func ValidateService(didStr string, service *types.Service) error {
+	if service.ID == "" || service.Type == "" || service.ServiceEndpoint == nil {
+		return serviceError(service.ID, "服务的id、type和serviceEndpoint不能为空")
+	}
+
+	fragment := serviceFragment(didStr, service.ID)
+	if fragment == service.ID || fragment == "" || validateURLPart(fragment, "/?") != nil {
+		return serviceError(service.ID, "服务ID必须是本DID下的片段")
+	}
+
+	// 统一成JSON形式，兼容结构体和 map[string]interface{} 表示的端点
+	var endpoint interface{}
+	if err := remarshal(service.ServiceEndpoint, &endpoint); err != nil {
+		return serviceError(service.ID, "无效的serviceEndpoint")
+	}
+
+	switch service.Type {
+	case ServiceTypeDIDCommMessaging:
+		return validateDIDCommEndpoint(service.ID, endpoint)
+	case ServiceTypeLinkedDomains:
+		return validateLinkedDomainsEndpoint(service.ID, endpoint)
+	default:
+		if _, ok := serviceEndpointURI(endpoint); !ok {
+			if _, isMap := endpoint.(map[string]interface{}); !isMap {
+				return serviceError(service.ID, "无效的serviceEndpoint")
+			}
+		}
+		return nil
+	}
+}
+
+// validateDIDCommEndpoint 校验DIDComm v2端点：{uri, accept?, routingKeys?} 或其数组
+func validateDIDCommEndpoint(serviceID string, endpoint interface{}) error {
+	switch v := endpoint.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return serviceError(serviceID, "DIDCommMessaging端点不能为空")
+		}
+		for _, item := range v {
+			if err := validateDIDCommEndpoint(serviceID, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		uri, _ := v["uri"].(string)
+		if !isDIDCommURI(uri) {
+			return serviceError(serviceID, "DIDCommMessaging端点需要http(s)、ws(s)或DID URL形式的uri")
+		}
+		for _, key := range []string{"accept", "routingKeys"} {
+			list, ok := v[key]
+			if !ok {
+				continue
+			}
+			items, ok := list.([]interface{})
+			if !ok {
+				return serviceError(serviceID, key+"必须是字符串数组")
+			}
+			for _, item := range items {
+				if s, ok := item.(string); !ok || s == "" {
+					return serviceError(serviceID, key+"必须是字符串数组")
+				}
+			}
+		}
+		return nil
+	default:
+		return serviceError(serviceID, "DIDCommMessaging端点必须是对象")
+	}
+}
+
+// isDIDCommURI 判断是否为DIDComm可用的端点URI
+func isDIDCommURI(uri string) bool {
+	if strings.HasPrefix(uri, "did:") {
+		_, err := ParseDIDURL(uri)
+		return err == nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+		return true
+	}
+	return false
+}
+
+// validateLinkedDomainsEndpoint 校验LinkedDomains端点：HTTPS源字符串、源数组或 {"origins": [...]}
+func validateLinkedDomainsEndpoint(serviceID string, endpoint interface{}) error {
+	var origins []interface{}
+	switch v := endpoint.(type) {
+	case string:
+		origins = []interface{}{v}
+	case []interface{}:
+		origins = v
+	case map[string]interface{}:
+		origins, _ = v["origins"].([]interface{})
+	}
+	if len(origins) == 0 {
+		return serviceError(serviceID, "LinkedDomains端点必须包含至少一个源")
+	}
+
+	for _, item := range origins {
+		origin, _ := item.(string)
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "https" || u.Host == "" || (u.Path != "" && u.Path != "/") ||
+			u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return serviceError(serviceID, fmt.Sprintf("LinkedDomains端点必须是HTTPS源: %v", item))
+		}
+	}
+	return nil
+}
+
+// serviceError 创建服务校验错误
+func serviceError(serviceID, message string) error {
+	return &DIDError{
+		Type:    ErrorTypeValidation,
+		Code:    "INVALID_SERVICE",
+		Message: message,
+		Details: serviceID,
+	}
+}
+
+// remarshal 通过JSON往返将src转换为dst
+func remarshal(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
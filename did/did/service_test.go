@@ -0,0 +1,154 @@
+package did
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+func TestValidateService(t *testing.T) {
+	const didStr = "did:qlink:alice"
+
+	valid := []types.Service{
+		{ID: didStr + "#didcomm", Type: ServiceTypeDIDCommMessaging, ServiceEndpoint: map[string]interface{}{
+			"uri": "wss://relay.example.com/ws", "accept": []string{"didcomm/v2"}, "routingKeys": []string{"did:example:mediator#key-1"},
+		}},
+		{ID: "#relay", Type: ServiceTypeDIDCommMessaging, ServiceEndpoint: []interface{}{map[string]interface{}{"uri": "did:example:mediator"}}},
+		{ID: "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com"},
+		{ID: "#origins", Type: ServiceTypeLinkedDomains, ServiceEndpoint: map[string]interface{}{"origins": []string{"https://a.example", "https://b.example/"}}},
+		{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: map[string]interface{}{"instances": []string{"https://hub.example.com"}}},
+	}
+	for _, service := range valid {
+		if err := ValidateService(didStr, &service); err != nil {
+			t.Errorf("服务 %s 应当有效: %v", service.ID, err)
+		}
+	}
+
+	invalid := []types.Service{
+		{ID: "", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+		{ID: "did:qlink:bob#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com"},
+		{ID: "#a b", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com"},
+		{ID: "#didcomm", Type: ServiceTypeDIDCommMessaging, ServiceEndpoint: "https://example.com/didcomm"},
+		{ID: "#didcomm", Type: ServiceTypeDIDCommMessaging, ServiceEndpoint: map[string]interface{}{"uri": "ftp://example.com"}},
+		{ID: "#didcomm", Type: ServiceTypeDIDCommMessaging, ServiceEndpoint: map[string]interface{}{"uri": "https://example.com", "accept": "didcomm/v2"}},
+		{ID: "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "http://example.com"},
+		{ID: "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com/path"},
+		{ID: "#web", Type: ServiceTypeLinkedDomains, ServiceEndpoint: map[string]interface{}{"origins": []string{}}},
+	}
+	for _, service := range invalid {
+		if err := ValidateService(didStr, &service); err == nil {
+			t.Errorf("服务应当无效: %+v", service)
+		}
+	}
+}
+
+func TestBuilderAddService(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+
+	builder.AddService(ServiceTypeDIDCommMessaging, "https://relay.example.com/didcomm").
+		AddDIDCommService("wss://relay.example.com/ws", []string{"did:example:mediator#key-1"}).
+		AddService(ServiceTypeLinkedDomains, "https://example.com")
+
+	doc, err := builder.BuildDocument()
+	if err != nil {
+		t.Fatalf("构建文档失败: %v", err)
+	}
+	wantIDs := []string{"#didcomm", "#didcomm-1", "#linked-domain"}
+	if len(doc.Service) != len(wantIDs) {
+		t.Fatalf("服务数量不正确: %+v", doc.Service)
+	}
+	for i, id := range wantIDs {
+		if doc.Service[i].ID != builder.GetDID()+id {
+			t.Errorf("服务ID不正确: 期望 %s, 实际 %s", builder.GetDID()+id, doc.Service[i].ID)
+		}
+	}
+
+	// 无效的服务在构建时报错
+	builder.AddService(ServiceTypeLinkedDomains, "http://insecure.example.com")
+	if _, err := builder.BuildDocument(); err == nil {
+		t.Error("无效的服务应导致构建失败")
+	}
+}
+
+func TestRegistryPatchServices(t *testing.T) {
+	builder, err := NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	builder.AddService(ServiceTypeLinkedDomains, "https://example.com")
+
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	registry := NewDIDRegistry(nil)
+	if _, err := registry.Register(regReq); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+
+	didStr := builder.GetDID()
+	relay := types.Service{
+		ID:              didStr + "#relay",
+		Type:            ServiceTypeDIDCommMessaging,
+		ServiceEndpoint: map[string]interface{}{"uri": "wss://relay.example.com/ws"},
+	}
+
+	proof, err := builder.SignAddService(relay)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	// 证明只覆盖被签名的服务
+	tampered := relay
+	tampered.ServiceEndpoint = map[string]interface{}{"uri": "wss://attacker.example.com/ws"}
+	if _, _, err := registry.AddService(context.Background(), didStr, tampered, proof); err == nil {
+		t.Fatal("被篡改的服务不应通过验证")
+	}
+
+	doc, _, err := registry.AddService(context.Background(), didStr, relay, proof)
+	if err != nil {
+		t.Fatalf("添加服务失败: %v", err)
+	}
+	if len(doc.Service) != 2 || findService(doc, "relay") == nil {
+		t.Fatalf("服务未添加: %+v", doc.Service)
+	}
+
+	proof, _ = builder.SignAddService(relay)
+	if _, _, err := registry.AddService(context.Background(), didStr, relay, proof); err == nil {
+		t.Error("重复的服务ID应被拒绝")
+	}
+
+	// SetService 替换同ID的服务
+	relay.ServiceEndpoint = map[string]interface{}{"uri": "wss://relay2.example.com/ws"}
+	proof, _ = builder.SignSetService(relay)
+	if doc, _, err = registry.SetService(context.Background(), didStr, relay, proof); err != nil {
+		t.Fatalf("替换服务失败: %v", err)
+	}
+	if len(doc.Service) != 2 || findService(doc, "relay").ServiceEndpoint.(map[string]interface{})["uri"] != "wss://relay2.example.com/ws" {
+		t.Fatalf("服务未替换: %+v", doc.Service)
+	}
+
+	// 移除全部服务
+	for _, id := range []string{"#relay", didStr + "#linked-domain"} {
+		proof, err := builder.SignRemoveService(id)
+		if err != nil {
+			t.Fatalf("签名失败: %v", err)
+		}
+		if doc, _, err = registry.RemoveService(context.Background(), didStr, id, proof); err != nil {
+			t.Fatalf("移除服务 %s 失败: %v", id, err)
+		}
+	}
+	if len(doc.Service) != 0 {
+		t.Errorf("服务应已全部移除: %+v", doc.Service)
+	}
+
+	proof, _ = builder.SignRemoveService("#relay")
+	_, _, err = registry.RemoveService(context.Background(), didStr, "#relay", proof)
+	if didErr, ok := err.(*DIDError); !ok || didErr.Code != "SERVICE_NOT_FOUND" {
+		t.Errorf("移除不存在的服务应返回SERVICE_NOT_FOUND: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/QLink/pkg/consensus"
+)
+
+// AddMemberRequest 添加集群成员请求，learner 为真时加入为不参与投票的Learner
+type AddMemberRequest struct {
+	ID      string `json:"id" binding:"required"`
+	Address string `json:"address"`
+	Learner bool   `json:"learner"`
+}
+
+// TransferLeaderRequest 领导权转移请求
+type TransferLeaderRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// SetConsensusManager 设置共识管理器，集群成员变更通过其Raft日志提交
+func (s *Server) SetConsensusManager(cm *consensus.ConsensusManager) {
+	s.consensus = cm
+}
+
+// requireConsensus 检查是否配置了共识管理器
+func (s *Server) requireConsensus(c *gin.Context) bool {
+	if s.consensus == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未启用共识，无法管理集群成员"})
+		return false
+	}
+	return true
+}
+
+// defaultMembershipTimeout 未配置提案超时时等待成员变更提交的时间
+const defaultMembershipTimeout = 30 * time.Second
+
+// membershipChange 执行成员变更，等待变更提交或超过共识提案超时后返回
+func (s *Server) membershipChange(c *gin.Context, change func(ctx context.Context) error) error {
+	timeout := defaultMembershipTimeout
+	if s.config != nil && s.config.Consensus != nil && s.config.Consensus.ProposalTimeout > 0 {
+		timeout = s.config.Consensus.ProposalTimeout
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	return change(ctx)
+}
+
+// membershipError 成员变更失败的响应，等待提交超时时变更可能稍后仍会生效
+func (s *Server) membershipError(c *gin.Context, action string, err error) {
+	status := http.StatusConflict
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	c.JSON(status, gin.H{"error": action + "失败: " + err.Error(), "leader": s.consensus.GetLeaderID()})
+}
+
+// membershipResponse 当前成员配置和Leader
+func (s *Server) membershipResponse() gin.H {
+	return gin.H{
+		"leader":     s.consensus.GetLeaderID(),
+		"membership": s.consensus.GetMembership(),
+	}
+}
+
+// 获取集群成员
+func (s *Server) getClusterMembers(c *gin.Context) {
+	if !s.requireConsensus(c) {
+		return
+	}
+	c.JSON(http.StatusOK, s.membershipResponse())
+}
+
+// 添加集群成员，成员变更提交后返回新的成员配置
+func (s *Server) addClusterMember(c *gin.Context) {
+	if !s.requireConsensus(c) {
+		return
+	}
+
+	var req AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := s.membershipChange(c, func(ctx context.Context) error {
+		if req.Learner {
+			return s.consensus.AddLearner(ctx, req.ID, req.Address)
+		}
+		return s.consensus.AddPeer(ctx, req.ID, req.Address)
+	})
+	if err != nil {
+		s.membershipError(c, "添加成员", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.membershipResponse())
+}
+
+// 将已追上日志的Learner提升为投票成员
+func (s *Server) promoteClusterMember(c *gin.Context) {
+	if !s.requireConsensus(c) {
+		return
+	}
+
+	err := s.membershipChange(c, func(ctx context.Context) error {
+		return s.consensus.AddPeer(ctx, c.Param("id"), "")
+	})
+	if err != nil {
+		s.membershipError(c, "提升成员", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.membershipResponse())
+}
+
+// 移除集群成员
+func (s *Server) removeClusterMember(c *gin.Context) {
+	if !s.requireConsensus(c) {
+		return
+	}
+
+	err := s.membershipChange(c, func(ctx context.Context) error {
+		return s.consensus.RemovePeer(ctx, c.Param("id"))
+	})
+	if err != nil {
+		s.membershipError(c, "移除成员", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.membershipResponse())
+}
+
+// 转移领导权
+func (s *Server) transferLeadership(c *gin.Context) {
+	if !s.requireConsensus(c) {
+		return
+	}
+
+	var req TransferLeaderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.consensus.TransferLeadership(req.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "转移领导权失败: " + err.Error(), "leader": s.consensus.GetLeaderID()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "领导权转移已开始", "target": req.ID})
+}
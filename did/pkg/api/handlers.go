@@ -1,11 +1,14 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/types"
 )
 
 // 批量注册DID
@@ -265,3 +268,165 @@ func (s *Server) getConsensusStatus(c *gin.Context) {
 		"commit_index": 0,
 	})
 }
+
+// AddServiceRequest 添加服务请求
+// proof 需以authentication目的对 did.ServiceSigningPayload 签名
+type AddServiceRequest struct {
+	Service *types.Service `json:"service" binding:"required"`
+	Proof   *types.Proof   `json:"proof" binding:"required"`
+}
+
+// RemoveServiceRequest 移除服务请求
+// proof 需以authentication目的对 did.RemoveServiceSigningPayload 签名
+type RemoveServiceRequest struct {
+	ServiceID string       `json:"serviceId" binding:"required"`
+	Proof     *types.Proof `json:"proof" binding:"required"`
+}
+
+// 添加DID服务
+func (s *Server) addDIDService(c *gin.Context) {
+	fullDID := didFromParam(c.Param("id"))
+
+	var req AddServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, receipt, err := s.registry.AddService(c.Request.Context(), fullDID, *req.Service, req.Proof)
+	if err != nil {
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("添加服务失败: %v", err), "transaction": receipt})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "服务添加成功",
+		"did":         fullDID,
+		"document":    doc,
+		"transaction": receipt,
+	})
+}
+
+// 移除DID服务
+func (s *Server) removeDIDService(c *gin.Context) {
+	fullDID := didFromParam(c.Param("id"))
+
+	var req RemoveServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, receipt, err := s.registry.RemoveService(c.Request.Context(), fullDID, req.ServiceID, req.Proof)
+	if err != nil {
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("移除服务失败: %v", err), "transaction": receipt})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "服务移除成功",
+		"did":         fullDID,
+		"document":    doc,
+		"transaction": receipt,
+	})
+}
+
+// RotateKeyRequest 密钥轮换请求
+// proof 需以authentication目的对 did.RotateKeyRequest.SigningPayload 签名；
+// 文档有预轮换承诺时由新密钥签名，并提交新的 nextKeyCommitment
+type RotateKeyRequest struct {
+	VerificationMethod *types.VerificationMethod `json:"verificationMethod" binding:"required"`
+	RetireKeyID        string                    `json:"retireKeyId" binding:"required"`
+	Relationships      []string                  `json:"relationships,omitempty"`
+	NextKeyCommitment  string                    `json:"nextKeyCommitment,omitempty"`
+	Proof              *types.Proof              `json:"proof" binding:"required"`
+}
+
+// 轮换DID密钥
+func (s *Server) rotateDIDKey(c *gin.Context) {
+	fullDID := didFromParam(c.Param("id"))
+
+	var req RotateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, receipt, err := s.registry.RotateKey(c.Request.Context(), &did.RotateKeyRequest{
+		DID:                   fullDID,
+		NewVerificationMethod: *req.VerificationMethod,
+		RetireKeyID:           req.RetireKeyID,
+		Relationships:         req.Relationships,
+		NextKeyCommitment:     req.NextKeyCommitment,
+		Proof:                 req.Proof,
+	})
+	if err != nil {
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("密钥轮换失败: %v", err), "transaction": receipt})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "密钥轮换成功",
+		"did":         fullDID,
+		"document":    doc,
+		"transaction": receipt,
+	})
+}
+
+// SetControllersRequest 变更控制者请求
+// proof 需以capabilityDelegation目的对 did.ControllerSigningPayload 签名，签名者可以是DID主体或现有控制者；
+// 文档有预轮换承诺时 nextKey 为承诺的密钥，proof 必须由它签发
+type SetControllersRequest struct {
+	Controller []string                  `json:"controller"`
+	NextKey    *types.VerificationMethod `json:"nextKey,omitempty"`
+	Proof      *types.Proof              `json:"proof" binding:"required"`
+}
+
+// 变更DID控制者
+func (s *Server) setDIDControllers(c *gin.Context) {
+	fullDID := didFromParam(c.Param("id"))
+
+	var req SetControllersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc, receipt, err := s.registry.SetControllersWithNextKey(c.Request.Context(), fullDID, req.Controller, req.NextKey, req.Proof)
+	if err != nil {
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("变更控制者失败: %v", err), "transaction": receipt})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "控制者变更成功",
+		"did":         fullDID,
+		"document":    doc,
+		"transaction": receipt,
+	})
+}
+
+// 列出由该DID直接控制的DID
+func (s *Server) listControlledDIDs(c *gin.Context) {
+	fullDID := didFromParam(c.Param("id"))
+
+	docs, err := s.registry.ListByController(fullDID)
+	if err != nil {
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("获取受控DID失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"controller": fullDID,
+		"dids":       docs,
+		"count":      len(docs),
+	})
+}
+
+// didFromParam 路径参数可以是完整DID或 did:qlink 的方法特定标识符
+func didFromParam(id string) string {
+	if strings.HasPrefix(id, "did:") {
+		return id
+	}
+	return "did:qlink:" + id
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/QLink/did"
+)
+
+// identifiersPath 兼容DIF Universal Resolver驱动接口的路径前缀
+const identifiersPath = "/1.0/identifiers/"
+
+// resolveIdentifier 按Universal Resolver接口解析DID
+// GET /1.0/identifiers/{did}[?versionId=...|versionTime=...]
+// Accept 决定返回DID文档的表示还是完整的解析结果
+func (s *Server) resolveIdentifier(c *gin.Context) {
+	didStr := identifierFromPath(c.Request.URL)
+
+	// 透传版本查询参数
+	versionQuery := url.Values{}
+	for _, key := range []string{"versionId", "versionTime"} {
+		if value := c.Query(key); value != "" {
+			versionQuery.Set(key, value)
+		}
+	}
+	if len(versionQuery) > 0 {
+		didStr += "?" + versionQuery.Encode()
+	}
+
+	contentType, ok := did.NegotiateRepresentation(c.GetHeader("Accept"))
+	if !ok {
+		writeResolutionResult(c, http.StatusNotAcceptable, &did.ResolutionResult{
+			DIDResolutionMetadata: &did.ResolutionMetadata{Error: did.ResolutionErrorRepresentationNotSupported},
+		})
+		return
+	}
+
+	result, err := s.resolver.ResolveContext(c.Request.Context(), didStr)
+	if err != nil {
+		writeResolutionResult(c, http.StatusInternalServerError, &did.ResolutionResult{
+			DIDResolutionMetadata: &did.ResolutionMetadata{Error: did.ResolutionErrorInternal},
+		})
+		return
+	}
+
+	status := resolutionStatus(result)
+	if result.DIDResolutionMetadata.Error != "" {
+		writeResolutionResult(c, status, result)
+		return
+	}
+
+	// 解析结果本身使用JSON-LD表示的文档
+	if contentType == did.ContentTypeDIDResolution {
+		result.DIDResolutionMetadata.ContentType = did.ContentTypeDIDLDJSON
+		writeResolutionResult(c, status, result)
+		return
+	}
+
+	data, err := did.MarshalDocument(result.DIDDocument, contentType)
+	if err != nil {
+		writeResolutionResult(c, http.StatusInternalServerError, &did.ResolutionResult{
+			DIDResolutionMetadata: &did.ResolutionMetadata{Error: did.ResolutionErrorInternal},
+		})
+		return
+	}
+	c.Data(status, contentType, data)
+}
+
+// identifierFromPath 从原始路径中取出DID
+// 保留DID中的百分号编码（如 did:web:example.com%3A8443），整体编码的DID则解码一次
+func identifierFromPath(u *url.URL) string {
+	raw := strings.TrimPrefix(u.EscapedPath(), identifiersPath)
+	if strings.HasPrefix(raw, "did:") {
+		return raw
+	}
+	if decoded, err := url.PathUnescape(raw); err == nil {
+		return decoded
+	}
+	return raw
+}
+
+// resolutionStatus 将解析结果映射为HTTP状态码
+func resolutionStatus(result *did.ResolutionResult) int {
+	switch result.DIDResolutionMetadata.Error {
+	case "":
+		if result.DIDDocumentMetadata != nil && result.DIDDocumentMetadata.Deactivated {
+			return http.StatusGone
+		}
+		return http.StatusOK
+	case did.ResolutionErrorInvalidDID, did.DereferenceErrorInvalidDIDURL:
+		return http.StatusBadRequest
+	case did.ResolutionErrorNotFound:
+		return http.StatusNotFound
+	case did.ResolutionErrorRepresentationNotSupported:
+		return http.StatusNotAcceptable
+	case did.ResolutionErrorMethodNotSupported:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeResolutionResult 以 application/ld+json;profile=did-resolution 返回解析结果
+func writeResolutionResult(c *gin.Context, status int, result *did.ResolutionResult) {
+	if result.DIDDocumentMetadata == nil {
+		result.DIDDocumentMetadata = &did.DocumentMetadata{}
+	}
+	data, err := did.MarshalResolutionResult(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("序列化解析结果失败: %v", err)})
+		return
+	}
+	c.Data(status, did.ContentTypeDIDResolution, data)
+}
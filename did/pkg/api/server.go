@@ -8,9 +8,11 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -24,6 +26,7 @@ import (
 	"github.com/qujing226/QLink/did/crypto"
 	blockchainPkg "github.com/qujing226/QLink/pkg/blockchain"
 	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/consensus"
 	"github.com/qujing226/QLink/pkg/types"
 )
 
@@ -35,6 +38,7 @@ type Server struct {
 	registry       *did.DIDRegistry
 	resolver       *did.DIDResolver
 	blockchain     *blockchainPkg.Blockchain // 添加区块链实例
+	consensus      *consensus.ConsensusManager
 
 	// 分布式网络相关
 	nodeID     string
@@ -227,6 +231,9 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 	// 监控指标端点
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Universal Resolver兼容接口
+	router.GET(identifiersPath+":did", s.resolveIdentifier)
+
 	// API版本组
 	v1 := router.Group("/api/v1")
 	{
@@ -250,8 +257,14 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 			did.DELETE("/revoke/:did", s.revokeDID)
 			did.POST("/generate", s.generateDID)
 			did.GET("/list", s.listDIDs) // 新增DID列表端点
+			did.GET("/dereference", s.dereferenceDIDURL)
 			did.GET("/:id/document", s.getDIDDocument)
 			did.GET("/:id/lattice-key", s.getLatticePublicKey) // 新增格基公钥获取接口
+			did.POST("/:id/services", s.addDIDService)
+			did.DELETE("/:id/services", s.removeDIDService)
+			did.POST("/:id/keys/rotate", s.rotateDIDKey)
+			did.PUT("/:id/controllers", s.setDIDControllers)
+			did.GET("/:id/controlled", s.listControlledDIDs)
 
 			// 批量操作
 			did.POST("/batch/register", s.batchRegisterDID)
@@ -266,6 +279,13 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 			auth.POST("/verify", s.verifyToken)
 		}
 
+		// 可验证凭证
+		vc := v1.Group("/vc")
+		{
+			vc.POST("/verify", s.verifyCredential)
+			vc.POST("/status", s.updateCredentialStatus)
+		}
+
 		// 节点信息和集群管理
 		node := v1.Group("/node")
 		{
@@ -283,6 +303,11 @@ func (s *Server) setupRoutes(router *gin.Engine) {
 			cluster.GET("/status", s.getClusterStatus)
 			cluster.POST("/sync", s.triggerSync)
 			cluster.GET("/consensus", s.getConsensusStatus)
+			cluster.GET("/members", s.getClusterMembers)
+			cluster.POST("/members", s.addClusterMember)
+			cluster.POST("/members/:id/promote", s.promoteClusterMember)
+			cluster.DELETE("/members/:id", s.removeClusterMember)
+			cluster.POST("/leader/transfer", s.transferLeadership)
 		}
 	}
 }
@@ -436,10 +461,10 @@ func (s *Server) getTxPoolStats(c *gin.Context) {
 }
 
 // RegisterDIDRequest 注册DID请求
+// document 需携带由其自身验证方法签发的 JsonWebSignature2020 或 CompositeSignature2025 证明
 type RegisterDIDRequest struct {
-	DID       string                 `json:"did" binding:"required"`
-	Document  map[string]interface{} `json:"document" binding:"required"`
-	Signature string                 `json:"signature" binding:"required"`
+	DID      string                 `json:"did" binding:"required"`
+	Document map[string]interface{} `json:"document" binding:"required"`
 }
 
 // 注册DID
@@ -491,23 +516,41 @@ func (s *Server) registerDID(c *gin.Context) {
 		}
 	}
 
-	// 构造注册请求
+	// 验证文档自签名
+	if err := verifyDocumentProof(req.DID, req.Document, verificationMethods); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("文档证明验证失败: %v", err)})
+		return
+	}
+
+	// 构造注册请求，预轮换承诺和控制者受文档自签名保护
 	regReq := &did.RegisterRequest{
 		DID:                req.DID,
 		VerificationMethod: verificationMethods,
 	}
+	if commitment, ok := req.Document["nextKeyCommitment"].(string); ok {
+		regReq.NextKeyCommitment = commitment
+	}
+	if controller, exists := req.Document["controller"]; exists {
+		var controllers types.StringOrSet
+		if err := remarshal(controller, &controllers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的controller: %v", err)})
+			return
+		}
+		regReq.Controller = controllers
+	}
 
 	// 注册DID到注册表
-	doc, err := s.registry.Register(regReq)
+	doc, receipt, err := s.registry.RegisterWithReceipt(c.Request.Context(), regReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("注册DID失败: %v", err)})
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("注册DID失败: %v", err), "transaction": receipt})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message":  "DID注册成功",
-		"did":      req.DID,
-		"document": doc,
+		"message":     "DID注册成功",
+		"did":         req.DID,
+		"document":    doc,
+		"transaction": receipt,
 	})
 }
 
@@ -522,6 +565,17 @@ func (s *Server) resolveDID(c *gin.Context) {
 	// 构造完整的DID
 	fullDID := fmt.Sprintf("did:qlink:%s", didID)
 
+	// 透传版本查询参数，支持解析历史版本
+	versionQuery := url.Values{}
+	for _, key := range []string{"versionId", "versionTime"} {
+		if value := c.Query(key); value != "" {
+			versionQuery.Set(key, value)
+		}
+	}
+	if len(versionQuery) > 0 {
+		fullDID += "?" + versionQuery.Encode()
+	}
+
 	// 解析DID
 	result, err := s.resolver.Resolve(fullDID)
 	if err != nil {
@@ -536,10 +590,67 @@ func (s *Server) resolveDID(c *gin.Context) {
 	})
 }
 
+// 解引用DID URL
+// GET /api/v1/did/dereference?didUrl=did:qlink:abc%3Fservice%3Dmessaging%26relativeRef%3D%2Finbox
+func (s *Server) dereferenceDIDURL(c *gin.Context) {
+	didURL := c.Query("didUrl")
+	if didURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"dereferencingMetadata": did.DereferencingMetadata{Error: did.DereferenceErrorInvalidDIDURL},
+		})
+		return
+	}
+
+	result, err := s.resolver.DereferenceWithOptions(didURL, did.DereferenceOptions{
+		Accept: dereferenceAccept(c.GetHeader("Accept")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解引用DID URL失败: %v", err)})
+		return
+	}
+
+	status := http.StatusOK
+	switch result.DereferencingMetadata.Error {
+	case "":
+	case did.DereferenceErrorInvalidDIDURL:
+		status = http.StatusBadRequest
+	case did.DereferenceErrorNotFound:
+		status = http.StatusNotFound
+	case did.DereferenceErrorContentTypeNotSupported:
+		status = http.StatusNotAcceptable
+	default:
+		status = http.StatusNotImplemented
+	}
+
+	c.JSON(status, result)
+}
+
+// dereferenceAccept 从Accept头中选出解引用的内容类型
+// 通用的JSON或通配类型使用默认表示，否则取第一个媒体类型
+func dereferenceAccept(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	var first string
+	for _, part := range strings.Split(header, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if mediaType == "*/*" || mediaType == "application/json" {
+			return ""
+		}
+		if first == "" {
+			first = mediaType
+		}
+	}
+
+	return first
+}
+
 // UpdateDIDRequest 更新DID请求
+// proof 需由当前文档中的验证方法对 types.DIDOperationPayload 签名
 type UpdateDIDRequest struct {
-	Document  map[string]interface{} `json:"document" binding:"required"`
-	Signature string                 `json:"signature" binding:"required"`
+	Document map[string]interface{} `json:"document" binding:"required"`
+	Proof    *types.Proof           `json:"proof" binding:"required"`
 }
 
 // 更新DID
@@ -559,35 +670,48 @@ func (s *Server) updateDID(c *gin.Context) {
 	// 使用完整的DID，不要添加前缀
 	fullDID := didID
 
+	// 解析文档中需要更新的验证方法和服务
+	var changes struct {
+		VerificationMethod []types.VerificationMethod `json:"verificationMethod"`
+		Service            []types.Service            `json:"service"`
+		NextKeyCommitment  string                     `json:"nextKeyCommitment"`
+	}
+	if err := remarshal(req.Document, &changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的DID文档: %v", err)})
+		return
+	}
+
 	// 构造更新请求
 	updateReq := &did.UpdateRequest{
-		DID: fullDID,
-		// TODO: 从req.Document中解析VerificationMethod和Service
-		Proof: &types.Proof{
-			Type:       "JsonWebSignature2020",
-			Created:    time.Now(),
-			ProofValue: req.Signature,
-		},
+		DID:                fullDID,
+		VerificationMethod: changes.VerificationMethod,
+		Service:            changes.Service,
+		NextKeyCommitment:  changes.NextKeyCommitment,
+		Proof:              req.Proof,
 	}
 
 	// 更新DID
-	doc, err := s.registry.Update(updateReq)
+	doc, receipt, err := s.registry.UpdateWithReceipt(c.Request.Context(), updateReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新DID失败: %v", err)})
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("更新DID失败: %v", err), "transaction": receipt})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "DID更新成功",
-		"did":      fullDID,
-		"document": doc,
+		"message":     "DID更新成功",
+		"did":         fullDID,
+		"document":    doc,
+		"transaction": receipt,
 	})
 }
 
 // RevokeDIDRequest 撤销DID请求
+// proof 需以authentication目的对 did.RevokeSigningPayload 签名；
+// 文档有预轮换承诺时 nextKey 为承诺的密钥，proof 必须由它签发
 type RevokeDIDRequest struct {
-	Signature string `json:"signature" binding:"required"`
-	Reason    string `json:"reason,omitempty"`
+	Proof   *types.Proof              `json:"proof" binding:"required"`
+	NextKey *types.VerificationMethod `json:"nextKey,omitempty"`
+	Reason  string                    `json:"reason,omitempty"`
 }
 
 // 撤销DID
@@ -607,27 +731,84 @@ func (s *Server) revokeDID(c *gin.Context) {
 	// 使用完整的DID，不要添加前缀
 	fullDID := didID
 
-	// 构造撤销证明
-	proof := &types.Proof{
-		Type:       "JsonWebSignature2020",
-		Created:    time.Now(),
-		ProofValue: req.Signature,
-	}
-
 	// 撤销DID
-	err := s.registry.Revoke(fullDID, proof)
+	receipt, err := s.registry.RevokeWithNextKey(c.Request.Context(), fullDID, req.NextKey, req.Proof)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("撤销DID失败: %v", err)})
+		c.JSON(registryErrorStatus(err), gin.H{"error": fmt.Sprintf("撤销DID失败: %v", err), "transaction": receipt})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "DID撤销成功",
-		"did":     fullDID,
-		"reason":  req.Reason,
+		"message":     "DID撤销成功",
+		"did":         fullDID,
+		"reason":      req.Reason,
+		"transaction": receipt,
 	})
 }
 
+// registryErrorStatus 将注册表错误映射为HTTP状态码
+func registryErrorStatus(err error) int {
+	var didErr *did.DIDError
+	if !errors.As(err, &didErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch didErr.Type {
+	case did.ErrorTypeValidation:
+		return http.StatusBadRequest
+	case did.ErrorTypeUnauthorized:
+		return http.StatusUnauthorized
+	case did.ErrorTypeNotFound:
+		return http.StatusNotFound
+	case did.ErrorTypeConflict:
+		return http.StatusConflict
+	case did.ErrorTypeBlockchain:
+		return http.StatusBadGateway
+	case did.ErrorTypePending:
+		// 操作已进入共识日志但结果未知，客户端应稍后查询而不是重试
+		return http.StatusAccepted
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// verifyDocumentProof 验证注册文档的证明，证明必须由文档自身声明的验证方法签发
+func verifyDocumentProof(didStr string, document map[string]interface{}, verificationMethods []types.VerificationMethod) error {
+	rawProof, exists := document["proof"]
+	if !exists {
+		return fmt.Errorf("文档缺少proof")
+	}
+
+	var proof types.Proof
+	if err := remarshal(rawProof, &proof); err != nil {
+		return fmt.Errorf("无效的proof: %w", err)
+	}
+
+	for i := range verificationMethods {
+		vm := &verificationMethods[i]
+		if vm.ID != proof.VerificationMethod {
+			continue
+		}
+
+		verifier := crypto.NewSignatureVerifier()
+		if err := verifier.VerifyController(didStr, vm); err != nil {
+			return err
+		}
+		return verifier.VerifyProofSignature(document, &proof, vm)
+	}
+
+	return fmt.Errorf("验证方法不存在: %s", proof.VerificationMethod)
+}
+
+// remarshal 通过JSON往返将通用结构转换为目标类型
+func remarshal(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
 // 获取节点信息
 func (s *Server) getNodeInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -749,9 +930,22 @@ func (s *Server) validateDIDFormat(didStr string) bool {
 	return len(didStr) > 0 && didStr[:10] == "did:qlink:"
 }
 
-// listDIDs 获取所有DID列表
+// listDIDs 获取DID列表
+// 支持查询参数 controller（按控制器）、status（按状态）、q（关键字搜索），未指定时返回全部
 func (s *Server) listDIDs(c *gin.Context) {
-	docs, err := s.registry.List()
+	var docs []*types.DIDDocument
+	var err error
+
+	switch {
+	case c.Query("controller") != "":
+		docs, err = s.registry.ListByController(c.Query("controller"))
+	case c.Query("status") != "":
+		docs, err = s.registry.ListByStatus(c.Query("status"))
+	case c.Query("q") != "":
+		docs, err = s.registry.Search(c.Query("q"))
+	default:
+		docs, err = s.registry.List()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取DID列表失败: " + err.Error(),
@@ -890,7 +1084,7 @@ func (s *Server) verifyLatticeSignature(signature, challenge, did string) bool {
 	// 尝试解析为JSON格式的HybridSignature
 	var hybridSig struct {
 		ECDSASignature string `json:"ecdsa_signature"`
-		KyberProof     string `json:"kyber_proof,omitempty"`
+		MLDSASignature string `json:"mldsa_signature,omitempty"`
 	}
 	
 	// 首先尝试解析为JSON格式（真实签名）
@@ -933,7 +1127,7 @@ func (s *Server) verifyLatticeSignature(signature, challenge, did string) bool {
 // verifyHybridSignature 验证真实的混合签名
 func (s *Server) verifyHybridSignature(sig *struct {
 	ECDSASignature string `json:"ecdsa_signature"`
-	KyberProof     string `json:"kyber_proof,omitempty"`
+	MLDSASignature string `json:"mldsa_signature,omitempty"`
 }, challenge, did string) bool {
 	log.Printf("开始验证混合签名 - DID: %s", did)
 	log.Printf("质询内容: %s", challenge)
@@ -956,12 +1150,12 @@ func (s *Server) verifyHybridSignature(sig *struct {
 	log.Printf("解码后的ECDSA签名长度: %d bytes", len(ecdsaBytes))
 	log.Printf("解码后的ECDSA签名hex: %x", ecdsaBytes)
 	
-	// 解码Kyber证明（如果存在）
-	var kyberBytes []byte
-	if sig.KyberProof != "" {
-		kyberBytes, err = base64.StdEncoding.DecodeString(sig.KyberProof)
+	// 解码ML-DSA-65签名（组合密钥必须提供）
+	var mldsaBytes []byte
+	if sig.MLDSASignature != "" {
+		mldsaBytes, err = base64.StdEncoding.DecodeString(sig.MLDSASignature)
 		if err != nil {
-			log.Printf("解码Kyber证明失败: %v", err)
+			log.Printf("解码ML-DSA-65签名失败: %v", err)
 			return false
 		}
 	}
@@ -969,7 +1163,7 @@ func (s *Server) verifyHybridSignature(sig *struct {
 	// 创建HybridSignature对象
 	hybridSignature := &crypto.HybridSignature{
 		ECDSASignature: ecdsaBytes,
-		KyberProof:     kyberBytes,
+		MLDSASignature: mldsaBytes,
 	}
 	
 	// 使用真实的密码学验证
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+	"github.com/qujing226/QLink/pkg/vc"
+)
+
+// UpdateCredentialStatusRequest 发布状态列表请求
+// 签发者在客户端用 vc.Issuer 设置状态位并签名新的状态列表凭证（assertionMethod），再以authentication目的对
+// did.SetServiceSigningPayload(签发者DID, vc.StatusListService(凭证)) 签名，节点只验证并提交，不接触私钥
+type UpdateCredentialStatusRequest struct {
+	StatusListCredential *vc.Credential `json:"statusListCredential" binding:"required"`
+	Proof                *types.Proof   `json:"proof" binding:"required"`
+}
+
+// VerifyCredentialRequest 验证凭证请求，credential、jwt、sdJwt 三选一
+type VerifyCredentialRequest struct {
+	Credential *vc.Credential `json:"credential"`
+	JWT        string         `json:"jwt"`
+	SDJWT      string         `json:"sdJwt"`
+}
+
+// 验证可验证凭证，SD-JWT 只返回已披露的声明
+func (s *Server) verifyCredential(c *gin.Context) {
+	var req VerifyCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verifier := vc.NewVerifier(s.resolver)
+
+	var (
+		cred   *vc.Credential
+		format string
+		err    error
+	)
+	switch {
+	case req.Credential != nil:
+		cred, format = req.Credential, vc.FormatLDP
+		err = verifier.Verify(cred)
+	case req.JWT != "":
+		format = vc.FormatJWT
+		cred, err = verifier.VerifyJWT(req.JWT)
+	case req.SDJWT != "":
+		format = vc.FormatSDJWT
+		cred, err = verifier.VerifySDJWT(req.SDJWT)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "credential、jwt、sdJwt 必须提供一个"})
+		return
+	}
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"verified": false, "format": format, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"verified":   true,
+		"format":     format,
+		"issuer":     cred.Issuer,
+		"credential": cred,
+	})
+}
+
+// 发布签发者签名的状态列表凭证，用于撤销、恢复或暂停凭证
+func (s *Server) updateCredentialStatus(c *gin.Context) {
+	var req UpdateCredentialStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verifier := vc.NewVerifier(s.resolver)
+	receipt, err := vc.PublishStatusList(c.Request.Context(), s.registry, verifier, req.StatusListCredential, req.Proof)
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": fmt.Sprintf("更新凭证状态失败: %v", err), "transaction": receipt})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "凭证状态更新成功",
+		"statusListCredential": req.StatusListCredential,
+		"transaction":          receipt,
+	})
+}
+
+// credentialErrorStatus 将凭证错误映射为HTTP状态码，注册表错误沿用 registryErrorStatus
+func credentialErrorStatus(err error) int {
+	var didErr *did.DIDError
+	if errors.As(err, &didErr) {
+		return registryErrorStatus(err)
+	}
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) {
+		return http.StatusBadRequest
+	}
+
+	switch appErr.Type {
+	case utils.ErrorTypeNotFound:
+		return http.StatusNotFound
+	case utils.ErrorTypeConflict:
+		return http.StatusConflict
+	case utils.ErrorTypeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
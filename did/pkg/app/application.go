@@ -12,6 +12,7 @@ import (
     "github.com/qujing226/QLink/pkg/config"
     "github.com/qujing226/QLink/pkg/consensus"
     "github.com/qujing226/QLink/pkg/network"
+    "github.com/qujing226/QLink/pkg/storage"
     syncpkg "github.com/qujing226/QLink/pkg/sync"
 )
 
@@ -20,6 +21,7 @@ type Application struct {
     config           *config.Config
     mu               sync.RWMutex
     storageManager   *didblockchain.StorageManager
+    didStorage       *storage.DIDStorage
     didRegistry      *did.DIDRegistry
     didResolver      *did.DIDResolver
     blockchain       didblockchain.BlockchainInterface
@@ -59,8 +61,12 @@ func (app *Application) Initialize() error {
 		return fmt.Errorf("初始化区块链失败: %v", err)
 	}
 
-	// 3. 初始化DID注册表和解析器
-	app.didRegistry = did.NewDIDRegistry(app.blockchain)
+	// 3. 初始化DID存储、注册表和解析器
+	app.didStorage, err = storage.NewStorageFactory().CreateDIDStorage(storage.NewFileStorageConfig(app.config, "did"))
+	if err != nil {
+		return fmt.Errorf("初始化DID存储失败: %v", err)
+	}
+	app.didRegistry = did.NewDIDRegistryWithStorage(app.blockchain, app.didStorage)
 	app.didResolver = did.NewDIDResolver(app.config, app.didRegistry, app.storageManager)
 
 	// 4. 初始化网络组件
@@ -78,8 +84,32 @@ func (app *Application) Initialize() error {
 		consensusConfig := &consensus.ManagerConfig{
 			NodeID:           app.config.GetNodeID(),
 			DefaultConsensus: consensus.ConsensusTypeRaft,
+			RaftConfig:       app.config.Consensus.Raft,
+			SnapshotEntries:  app.config.Consensus.SnapshotInterval,
+			MaxLogEntries:    app.config.Consensus.MaxLogEntries,
+			ConsensusConfig:  app.config.Consensus,
 		}
 		app.consensusManager = consensus.NewConsensusManager(consensusConfig, app.p2pNetwork)
+		app.consensusManager.SetDIDRegistry(app.didRegistry)
+		if err := app.consensusManager.Initialize(); err != nil {
+			return fmt.Errorf("初始化共识管理器失败: %v", err)
+		}
+	}
+
+	// replicated 模式下DID操作经Raft提交，由共识集成器在每个节点上写入注册表
+	if app.config.DID != nil {
+		commitMode, err := did.ParseCommitMode(app.config.DID.CommitMode)
+		if err != nil {
+			return fmt.Errorf("初始化DID注册表失败: %v", err)
+		}
+		if commitMode == did.CommitModeReplicated && app.consensusManager != nil {
+			if err := app.didRegistry.SetCommitter(app.consensusManager.DIDCommitter()); err != nil {
+				return fmt.Errorf("初始化DID注册表失败: %v", err)
+			}
+		}
+		if err := app.didRegistry.SetCommitMode(commitMode); err != nil {
+			return fmt.Errorf("初始化DID注册表失败: %v", err)
+		}
 	}
 
 	// 6. 初始化同步器
@@ -99,6 +129,9 @@ func (app *Application) Initialize() error {
             app.didResolver,
             nil, // 暂时传nil
         )
+        if app.apiServer != nil && app.consensusManager != nil {
+            app.apiServer.SetConsensusManager(app.consensusManager)
+        }
     }
 
 	log.Println("应用程序初始化完成")
@@ -180,6 +213,13 @@ func (app *Application) Stop() error {
 		}
 	}
 
+	// 关闭DID存储
+	if app.didStorage != nil {
+		if err := app.didStorage.Close(); err != nil {
+			log.Printf("关闭DID存储失败: %v", err)
+		}
+	}
+
 	log.Println("应用程序停止完成")
 	return nil
 }
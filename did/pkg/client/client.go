@@ -2,13 +2,17 @@ package client
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
 )
 
 // Client QLink客户端
@@ -16,6 +20,7 @@ type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	keyPair    *crypto.HybridKeyPair
+	keyID      string // 密钥在DID文档中的验证方法片段，如 #key-1
 }
 
 // NewClient 创建新的客户端
@@ -25,6 +30,7 @@ func NewClient(baseURL string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		keyID: "#key-1",
 	}
 }
 
@@ -50,11 +56,42 @@ func (c *Client) GetKeyPair() *crypto.HybridKeyPair {
 	return c.keyPair
 }
 
+// SetKeyPair 设置签名密钥对及其验证方法ID（片段如 #key-2，或完整的DID URL）
+func (c *Client) SetKeyPair(keyPair *crypto.HybridKeyPair, keyID string) {
+	c.keyPair = keyPair
+	if keyID != "" {
+		c.keyID = keyID
+	}
+}
+
+// LoadKey 用口令从加密密钥库解锁DID的当前密钥，作为客户端的签名密钥
+func (c *Client) LoadKey(keystore *crypto.Keystore, did, passphrase string) error {
+	keyPair, key, err := keystore.Unlock(did, passphrase)
+	if err != nil {
+		return fmt.Errorf("解锁密钥失败: %w", err)
+	}
+
+	c.SetKeyPair(keyPair, key.KeyID)
+	return nil
+}
+
+// GetKeyID 获取当前签名密钥的验证方法片段
+func (c *Client) GetKeyID() string {
+	return c.keyID
+}
+
+// verificationMethodID 返回签名密钥在指定DID文档中的完整验证方法ID
+func (c *Client) verificationMethodID(did string) string {
+	if strings.HasPrefix(c.keyID, "did:") {
+		return c.keyID
+	}
+	return did + c.keyID
+}
+
 // RegisterDIDRequest 注册DID请求
 type RegisterDIDRequest struct {
-	DID       string                 `json:"did"`
-	Document  map[string]interface{} `json:"document"`
-	Signature string                 `json:"signature"`
+	DID      string                 `json:"did"`
+	Document map[string]interface{} `json:"document"`
 }
 
 // RegisterDIDResponse 注册DID响应
@@ -70,28 +107,31 @@ func (c *Client) RegisterDID(did string, document map[string]interface{}) (*Regi
 		return nil, fmt.Errorf("密钥对未初始化")
 	}
 
-	// 序列化文档用于签名
-	docBytes, err := json.Marshal(document)
-	if err != nil {
-		return nil, fmt.Errorf("序列化文档失败: %w", err)
+	// 对规范化后的文档签名，证明附加在文档上
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: c.verificationMethodID(did),
+		ProofPurpose:       "assertionMethod",
 	}
-
-	// 签名
-	signature, err := c.keyPair.Sign(docBytes)
-	if err != nil {
+	if err := c.keyPair.SignProof(document, proof); err != nil {
 		return nil, fmt.Errorf("签名失败: %w", err)
 	}
 
+	signed := make(map[string]interface{}, len(document)+1)
+	for key, value := range document {
+		signed[key] = value
+	}
+	signed["proof"] = proof
+
 	// 构造请求
 	req := RegisterDIDRequest{
-		DID:       did,
-		Document:  document,
-		Signature: fmt.Sprintf("%x", signature),
+		DID:      did,
+		Document: signed,
 	}
 
 	// 发送HTTP请求
 	var resp RegisterDIDResponse
-	err = c.post("/api/v1/did/register", req, &resp)
+	err := c.post("/api/v1/did/register", req, &resp)
 	if err != nil {
 		return nil, fmt.Errorf("注册DID失败: %w", err)
 	}
@@ -126,8 +166,8 @@ func (c *Client) ResolveDID(did string) (*ResolveDIDResponse, error) {
 
 // UpdateDIDRequest 更新DID请求
 type UpdateDIDRequest struct {
-	Document  map[string]interface{} `json:"document"`
-	Signature string                 `json:"signature"`
+	Document map[string]interface{} `json:"document"`
+	Proof    *types.Proof           `json:"proof"`
 }
 
 // UpdateDIDResponse 更新DID响应
@@ -143,16 +183,17 @@ func (c *Client) UpdateDID(did string, document map[string]interface{}) (*Update
 		return nil, fmt.Errorf("密钥对未初始化")
 	}
 
-	// 序列化文档用于签名
-	docBytes, err := json.Marshal(document)
-	if err != nil {
-		return nil, fmt.Errorf("序列化文档失败: %w", err)
+	// 签名内容与服务端从文档中解析出的更新内容一致
+	payload := &types.DIDOperationPayload{}
+	if err := remarshal(document, payload); err != nil {
+		return nil, fmt.Errorf("解析文档失败: %w", err)
 	}
+	payload.Operation = "update"
+	payload.DID = did
 
-	// 签名
-	signature, err := c.keyPair.Sign(docBytes)
+	proof, err := c.newProof(did, payload, "authentication")
 	if err != nil {
-		return nil, fmt.Errorf("签名失败: %w", err)
+		return nil, err
 	}
 
 	// 提取DID ID部分
@@ -163,8 +204,8 @@ func (c *Client) UpdateDID(did string, document map[string]interface{}) (*Update
 
 	// 构造请求
 	req := UpdateDIDRequest{
-		Document:  document,
-		Signature: fmt.Sprintf("%x", signature),
+		Document: document,
+		Proof:    proof,
 	}
 
 	// 发送HTTP请求
@@ -179,8 +220,8 @@ func (c *Client) UpdateDID(did string, document map[string]interface{}) (*Update
 
 // RevokeDIDRequest 撤销DID请求
 type RevokeDIDRequest struct {
-	Signature string `json:"signature"`
-	Reason    string `json:"reason,omitempty"`
+	Proof  *types.Proof `json:"proof"`
+	Reason string       `json:"reason,omitempty"`
 }
 
 // RevokeDIDResponse 撤销DID响应
@@ -197,10 +238,13 @@ func (c *Client) RevokeDID(did string, reason string) (*RevokeDIDResponse, error
 	}
 
 	// 签名撤销请求
-	signData := []byte(fmt.Sprintf("revoke:%s:%s", did, reason))
-	signature, err := c.keyPair.Sign(signData)
+	payload := &types.DIDOperationPayload{
+		Operation: "deactivate",
+		DID:       did,
+	}
+	proof, err := c.newProof(did, payload, "authentication")
 	if err != nil {
-		return nil, fmt.Errorf("签名失败: %w", err)
+		return nil, err
 	}
 
 	// 提取DID ID部分
@@ -211,8 +255,8 @@ func (c *Client) RevokeDID(did string, reason string) (*RevokeDIDResponse, error
 
 	// 构造请求
 	req := RevokeDIDRequest{
-		Signature: fmt.Sprintf("%x", signature),
-		Reason:    reason,
+		Proof:  proof,
+		Reason: reason,
 	}
 
 	// 发送HTTP请求
@@ -225,6 +269,224 @@ func (c *Client) RevokeDID(did string, reason string) (*RevokeDIDResponse, error
 	return &resp, nil
 }
 
+// ServiceResponse DID文档变更（服务、控制者）响应
+type ServiceResponse struct {
+	Message  string      `json:"message"`
+	DID      string      `json:"did"`
+	Document interface{} `json:"document"`
+}
+
+// AddService 向DID文档添加一个服务
+func (c *Client) AddService(did string, service types.Service) (*ServiceResponse, error) {
+	if c.keyPair == nil {
+		return nil, fmt.Errorf("密钥对未初始化")
+	}
+
+	payload := &types.DIDOperationPayload{
+		Operation: "addService",
+		DID:       did,
+		Service:   []types.Service{service},
+	}
+	proof, err := c.newProof(did, payload, "authentication")
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"service": service,
+		"proof":   proof,
+	}
+
+	var resp ServiceResponse
+	if err := c.post(fmt.Sprintf("/api/v1/did/%s/services", did), req, &resp); err != nil {
+		return nil, fmt.Errorf("添加服务失败: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// RemoveService 从DID文档移除一个服务
+func (c *Client) RemoveService(did string, serviceID string) (*ServiceResponse, error) {
+	if c.keyPair == nil {
+		return nil, fmt.Errorf("密钥对未初始化")
+	}
+
+	payload := &types.DIDOperationPayload{
+		Operation: "removeService",
+		DID:       did,
+		ServiceID: serviceID,
+	}
+	proof, err := c.newProof(did, payload, "authentication")
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"serviceId": serviceID,
+		"proof":     proof,
+	}
+
+	var resp ServiceResponse
+	if err := c.delete(fmt.Sprintf("/api/v1/did/%s/services", did), req, &resp); err != nil {
+		return nil, fmt.Errorf("移除服务失败: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// SetControllers 替换DID的控制者列表，证明以capabilityDelegation目的签名
+func (c *Client) SetControllers(did string, controllers []string) (*ServiceResponse, error) {
+	if c.keyPair == nil {
+		return nil, fmt.Errorf("密钥对未初始化")
+	}
+
+	payload := &types.DIDOperationPayload{
+		Operation:  "setController",
+		DID:        did,
+		Controller: controllers,
+	}
+	proof, err := c.newProof(did, payload, "capabilityDelegation")
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"controller": controllers,
+		"proof":      proof,
+	}
+
+	var resp ServiceResponse
+	if err := c.put(fmt.Sprintf("/api/v1/did/%s/controllers", did), req, &resp); err != nil {
+		return nil, fmt.Errorf("变更控制者失败: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// ControlledDIDsResponse 受控DID列表响应
+type ControlledDIDsResponse struct {
+	Controller string        `json:"controller"`
+	DIDs       []interface{} `json:"dids"`
+	Count      int           `json:"count"`
+}
+
+// ListControlledDIDs 列出由指定DID直接控制的DID
+func (c *Client) ListControlledDIDs(did string) (*ControlledDIDsResponse, error) {
+	var resp ControlledDIDsResponse
+	if err := c.get(fmt.Sprintf("/api/v1/did/%s/controlled", did), &resp); err != nil {
+		return nil, fmt.Errorf("获取受控DID失败: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// RotateKeyResponse 密钥轮换响应
+type RotateKeyResponse struct {
+	Message  string      `json:"message"`
+	DID      string      `json:"did"`
+	KeyID    string      `json:"keyId"`
+	Document interface{} `json:"document"`
+}
+
+// RotateKey 用新密钥对替换当前密钥，当前密钥在文档中标记为退役
+// 轮换成功后客户端改用新密钥签名
+func (c *Client) RotateKey(did string, newKeyPair *crypto.HybridKeyPair) (*RotateKeyResponse, error) {
+	if c.keyPair == nil {
+		return nil, fmt.Errorf("密钥对未初始化")
+	}
+
+	resolved, err := c.ResolveDID(did)
+	if err != nil {
+		return nil, err
+	}
+	var doc types.DIDDocument
+	if err := remarshal(resolved.DIDDocument, &doc); err != nil {
+		return nil, fmt.Errorf("解析DID文档失败: %w", err)
+	}
+
+	jwk, err := newKeyPair.ToJWK()
+	if err != nil {
+		return nil, fmt.Errorf("转换公钥为JWK失败: %w", err)
+	}
+	keyID := nextKeyID(&doc)
+	newVM := types.VerificationMethod{
+		ID:           did + keyID,
+		Type:         "JsonWebKey2020",
+		Controller:   did,
+		PublicKeyJwk: jwk,
+	}
+
+	retireKeyID := c.verificationMethodID(did)
+	payload := &types.DIDOperationPayload{
+		Operation:          "rotateKey",
+		DID:                did,
+		VerificationMethod: []types.VerificationMethod{newVM},
+		RetireKeyID:        retireKeyID,
+	}
+	proof, err := c.newProof(did, payload, "authentication")
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"verificationMethod": newVM,
+		"retireKeyId":        retireKeyID,
+		"proof":              proof,
+	}
+
+	var resp RotateKeyResponse
+	if err := c.post(fmt.Sprintf("/api/v1/did/%s/keys/rotate", did), req, &resp); err != nil {
+		return nil, fmt.Errorf("密钥轮换失败: %w", err)
+	}
+
+	c.keyPair = newKeyPair
+	c.keyID = keyID
+	resp.KeyID = keyID
+	return &resp, nil
+}
+
+// nextKeyID 返回文档中下一个未被占用的 #key-N 片段
+func nextKeyID(doc *types.DIDDocument) string {
+	used := make(map[string]bool, len(doc.VerificationMethod))
+	for _, vm := range doc.VerificationMethod {
+		used[strings.TrimPrefix(vm.ID, doc.ID)] = true
+	}
+	for i := len(doc.VerificationMethod) + 1; ; i++ {
+		if id := fmt.Sprintf("#key-%d", i); !used[id] {
+			return id
+		}
+	}
+}
+
+// newProof 使用客户端当前密钥为操作内容生成证明
+func (c *Client) newProof(did string, payload interface{}, purpose string) (*types.Proof, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %w", err)
+	}
+
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: c.verificationMethodID(did),
+		ProofPurpose:       purpose,
+		Nonce:              hex.EncodeToString(nonce),
+	}
+	if err := c.keyPair.SignProof(payload, proof); err != nil {
+		return nil, fmt.Errorf("签名失败: %w", err)
+	}
+
+	return proof, nil
+}
+
+// remarshal 通过JSON往返将通用结构转换为目标类型
+func remarshal(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
 // GenerateDIDResponse 生成DID响应
 type GenerateDIDResponse struct {
 	DID     string `json:"did"`
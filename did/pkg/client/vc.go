@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/vc"
+)
+
+// CredentialStatusResponse 发布状态列表响应
+type CredentialStatusResponse struct {
+	Message              string         `json:"message"`
+	StatusListCredential *vc.Credential `json:"statusListCredential"`
+	Transaction          interface{}    `json:"transaction"`
+}
+
+// Issuer 返回以客户端当前密钥签发凭证的签发者
+// 凭证在本地签名，私钥不会发送到节点；签名密钥须在签发者DID文档的assertionMethod中
+func (c *Client) Issuer(did string) (*vc.Issuer, error) {
+	if c.keyPair == nil {
+		return nil, fmt.Errorf("密钥对未初始化")
+	}
+	return vc.NewIssuer(did, c.verificationMethodID(did), c.keyPair), nil
+}
+
+// SetCredentialStatus 设置签发者状态列表 listID 中第 index 位并发布新版本
+// 新的状态列表凭证和DID服务更新的证明都在本地签名，签名密钥须同时在assertionMethod和authentication中
+func (c *Client) SetCredentialStatus(did, listID, purpose string, index int, value bool) (*CredentialStatusResponse, error) {
+	issuer, err := c.Issuer(did)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := c.ResolveDID(did)
+	if err != nil {
+		return nil, err
+	}
+	var doc types.DIDDocument
+	if err := remarshal(resolved.DIDDocument, &doc); err != nil {
+		return nil, fmt.Errorf("解析DID文档失败: %w", err)
+	}
+
+	cred, err := issuer.UpdateStatusList(&doc, listID, purpose, index, value)
+	if err != nil {
+		return nil, fmt.Errorf("签发状态列表失败: %w", err)
+	}
+	proof, err := issuer.SignStatusListService(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	req := map[string]interface{}{
+		"statusListCredential": cred,
+		"proof":                proof,
+	}
+
+	var resp CredentialStatusResponse
+	if err := c.post("/api/v1/vc/status", req, &resp); err != nil {
+		return nil, fmt.Errorf("更新凭证状态失败: %w", err)
+	}
+	return &resp, nil
+}
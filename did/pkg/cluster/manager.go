@@ -13,12 +13,21 @@ import (
 	syncpkg "github.com/qujing226/QLink/pkg/sync"
 )
 
+// Membership Raft成员变更，各方法在变更提交后返回，*consensus.ConsensusManager 实现了该接口
+type Membership interface {
+	AddLearner(ctx context.Context, id, address string) error
+	AddPeer(ctx context.Context, id, address string) error
+	RemovePeer(ctx context.Context, id string) error
+}
+
+var _ Membership = (*consensus.ConsensusManager)(nil)
+
 // ClusterManager 集群管理器
 type ClusterManager struct {
 	nodeID       string
 	clusterID    string
 	p2pNetwork   *network.P2PNetwork
-	raftNode     *consensus.RaftNode
+	membership   Membership
 	synchronizer *syncpkg.Synchronizer
 
 	// 集群状态
@@ -27,6 +36,7 @@ type ClusterManager struct {
 
 	// 节点管理
 	nodes      map[string]*NodeInfo
+	changing   map[string]struct{} // 成员变更尚未提交的节点
 	nodesMutex sync.RWMutex
 
 	// 配置
@@ -119,7 +129,7 @@ type LeaveRequest struct {
 
 // NewClusterManager 创建集群管理器
 func NewClusterManager(nodeID, clusterID string, p2pNetwork *network.P2PNetwork,
-	raftNode *consensus.RaftNode, synchronizer *syncpkg.Synchronizer, cfg *config.ClusterConfig) *ClusterManager {
+	membership Membership, synchronizer *syncpkg.Synchronizer, cfg *config.ClusterConfig) *ClusterManager {
 
 	if cfg == nil {
 		cfg = &config.ClusterConfig{
@@ -142,10 +152,11 @@ func NewClusterManager(nodeID, clusterID string, p2pNetwork *network.P2PNetwork,
 		nodeID:       nodeID,
 		clusterID:    clusterID,
 		p2pNetwork:   p2pNetwork,
-		raftNode:     raftNode,
+		membership:   membership,
 		synchronizer: synchronizer,
 		config:       cfg,
 		nodes:        make(map[string]*NodeInfo),
+		changing:     make(map[string]struct{}),
 		clusterState: &ClusterState{
 			ID:         clusterID,
 			Status:     ClusterStatusInitializing,
@@ -254,56 +265,133 @@ func (cm *ClusterManager) LeaveCluster(reason string) error {
 }
 
 // AddNode 添加节点到集群
-func (cm *ClusterManager) AddNode(nodeInfo *NodeInfo) error {
+// 节点先作为Learner通过Raft日志加入，追上日志后由 PromoteNode 提升为投票成员；
+// 等待提交期间不持有节点锁，变更提交后才记入节点列表
+func (cm *ClusterManager) AddNode(ctx context.Context, nodeInfo *NodeInfo) error {
 	cm.nodesMutex.Lock()
-	defer cm.nodesMutex.Unlock()
-
 	if _, exists := cm.nodes[nodeInfo.ID]; exists {
+		cm.nodesMutex.Unlock()
 		return fmt.Errorf("节点已存在: %s", nodeInfo.ID)
 	}
-
-	// 检查集群容量
-	if len(cm.nodes) >= cm.config.MaxNodes {
+	// 检查集群容量，正在变更的节点也计入
+	if len(cm.nodes)+len(cm.changing) >= cm.config.MaxNodes {
+		cm.nodesMutex.Unlock()
 		return fmt.Errorf("集群已达到最大节点数: %d", cm.config.MaxNodes)
 	}
+	err := cm.beginChangeLocked(nodeInfo.ID)
+	cm.nodesMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer cm.endChange(nodeInfo.ID)
+
+	if cm.membership != nil {
+		address := fmt.Sprintf("%s:%d", nodeInfo.Address, nodeInfo.Port)
+		if err := cm.membership.AddLearner(ctx, nodeInfo.ID, address); err != nil {
+			return fmt.Errorf("提交成员变更失败: %w", err)
+		}
+	}
 
+	cm.nodesMutex.Lock()
 	cm.nodes[nodeInfo.ID] = nodeInfo
+	count := len(cm.nodes)
+	cm.nodesMutex.Unlock()
 
 	// 添加到P2P网络
 	cm.p2pNetwork.AddPeer(nodeInfo.ID, nodeInfo.Address, nodeInfo.Port)
-
-	// 更新集群状态
-	cm.stateMutex.Lock()
-	cm.clusterState.NodeCount = len(cm.nodes)
-	cm.clusterState.LastUpdate = time.Now()
-	cm.stateMutex.Unlock()
+	cm.updateNodeCount(count)
 
 	log.Printf("节点 %s 已添加到集群", nodeInfo.ID)
 	return nil
 }
 
-// RemoveNode 从集群移除节点
-func (cm *ClusterManager) RemoveNode(nodeID string) error {
+// RemoveNode 从集群移除节点，变更提交后才从节点列表删除
+func (cm *ClusterManager) RemoveNode(ctx context.Context, nodeID string) error {
 	cm.nodesMutex.Lock()
-	defer cm.nodesMutex.Unlock()
-
 	if _, exists := cm.nodes[nodeID]; !exists {
+		cm.nodesMutex.Unlock()
 		return fmt.Errorf("节点不存在: %s", nodeID)
 	}
+	err := cm.beginChangeLocked(nodeID)
+	cm.nodesMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer cm.endChange(nodeID)
 
+	if cm.membership != nil {
+		if err := cm.membership.RemovePeer(ctx, nodeID); err != nil {
+			return fmt.Errorf("提交成员变更失败: %w", err)
+		}
+	}
+
+	cm.nodesMutex.Lock()
 	delete(cm.nodes, nodeID)
+	count := len(cm.nodes)
+	cm.nodesMutex.Unlock()
 
 	// 从P2P网络移除
 	cm.p2pNetwork.RemovePeer(nodeID)
+	cm.updateNodeCount(count)
 
-	// 更新集群状态
+	log.Printf("节点 %s 已从集群移除", nodeID)
+	return nil
+}
+
+// PromoteNode 将已追上日志的Learner提升为投票成员，变更提交后才标记为活跃
+func (cm *ClusterManager) PromoteNode(ctx context.Context, nodeID string) error {
+	if cm.membership == nil {
+		return fmt.Errorf("Raft节点未初始化")
+	}
+
+	cm.nodesMutex.Lock()
+	node, exists := cm.nodes[nodeID]
+	if !exists {
+		cm.nodesMutex.Unlock()
+		return fmt.Errorf("节点不存在: %s", nodeID)
+	}
+	address := fmt.Sprintf("%s:%d", node.Address, node.Port)
+	err := cm.beginChangeLocked(nodeID)
+	cm.nodesMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer cm.endChange(nodeID)
+
+	if err := cm.membership.AddPeer(ctx, nodeID, address); err != nil {
+		return fmt.Errorf("提交成员变更失败: %w", err)
+	}
+
+	cm.nodesMutex.Lock()
+	node.Status = NodeStatusActive
+	cm.nodesMutex.Unlock()
+
+	log.Printf("节点 %s 已提升为投票成员", nodeID)
+	return nil
+}
+
+// beginChangeLocked 标记节点的成员变更开始，同一节点同时只能有一个变更，调用方须持有 nodesMutex
+func (cm *ClusterManager) beginChangeLocked(nodeID string) error {
+	if _, busy := cm.changing[nodeID]; busy {
+		return fmt.Errorf("节点 %s 的成员变更正在进行", nodeID)
+	}
+	cm.changing[nodeID] = struct{}{}
+	return nil
+}
+
+// endChange 清除节点的成员变更标记
+func (cm *ClusterManager) endChange(nodeID string) {
+	cm.nodesMutex.Lock()
+	delete(cm.changing, nodeID)
+	cm.nodesMutex.Unlock()
+}
+
+// updateNodeCount 更新集群状态中的节点数
+func (cm *ClusterManager) updateNodeCount(count int) {
 	cm.stateMutex.Lock()
-	cm.clusterState.NodeCount = len(cm.nodes)
+	cm.clusterState.NodeCount = count
 	cm.clusterState.LastUpdate = time.Now()
 	cm.stateMutex.Unlock()
-
-	log.Printf("节点 %s 已从集群移除", nodeID)
-	return nil
 }
 
 // GetClusterStatus 获取集群状态
@@ -532,7 +620,7 @@ func (cm *ClusterManager) ElectLeader() error {
 
 	// 这里应该调用Raft选举逻辑
 	// 简化实现，直接设置当前节点为Leader
-	if cm.raftNode != nil {
+	if cm.membership != nil {
 		// 实际应该调用raft选举
 		log.Printf("Raft选举逻辑待实现")
 	}
@@ -553,7 +641,10 @@ func (cm *ClusterManager) HandleNodeJoin(req *JoinRequest) (*JoinResponse, error
 	}
 
 	// 检查集群容量
-	if len(cm.nodes) >= cm.config.MaxNodes {
+	cm.nodesMutex.RLock()
+	full := len(cm.nodes) >= cm.config.MaxNodes
+	cm.nodesMutex.RUnlock()
+	if full {
 		return &JoinResponse{
 			Accepted: false,
 			Reason:   "cluster full",
@@ -573,8 +664,10 @@ func (cm *ClusterManager) HandleNodeJoin(req *JoinRequest) (*JoinResponse, error
 		Metadata:     req.Metadata,
 	}
 
-	// 添加节点
-	if err := cm.AddNode(nodeInfo); err != nil {
+	// 添加节点，等待成员变更提交
+	ctx, cancel := context.WithTimeout(context.Background(), cm.config.JoinTimeout)
+	defer cancel()
+	if err := cm.AddNode(ctx, nodeInfo); err != nil {
 		return &JoinResponse{
 			Accepted: false,
 			Reason:   err.Error(),
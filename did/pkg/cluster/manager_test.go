@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/network"
+)
+
+// blockingMembership 成员变更阻塞到 release 关闭，模拟等待Raft提交
+type blockingMembership struct {
+	started chan string
+	release chan struct{}
+	err     error
+}
+
+func (m *blockingMembership) wait(ctx context.Context, id string) error {
+	m.started <- id
+	select {
+	case <-m.release:
+		return m.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *blockingMembership) AddLearner(ctx context.Context, id, address string) error {
+	return m.wait(ctx, id)
+}
+
+func (m *blockingMembership) AddPeer(ctx context.Context, id, address string) error {
+	return m.wait(ctx, id)
+}
+
+func (m *blockingMembership) RemovePeer(ctx context.Context, id string) error {
+	return m.wait(ctx, id)
+}
+
+// TestMembershipChangeCommitsBeforeUpdate 等待提交期间不持有节点锁，变更提交后才更新节点列表
+func TestMembershipChangeCommitsBeforeUpdate(t *testing.T) {
+	membership := &blockingMembership{started: make(chan string, 1), release: make(chan struct{})}
+	cm := NewClusterManager("node1", "cluster", network.NewP2PNetwork("node1", "127.0.0.1", 0, nil), membership, nil, nil)
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	go func() { done <- cm.AddNode(ctx, &NodeInfo{ID: "node2", Address: "127.0.0.1", Port: 9002}) }()
+	<-membership.started
+
+	listed := make(chan int, 1)
+	go func() { listed <- len(cm.GetNodes()) }()
+	select {
+	case n := <-listed:
+		if n != 0 {
+			t.Errorf("变更提交前不应记入节点列表: %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待提交期间不应持有节点锁")
+	}
+	if err := cm.AddNode(ctx, &NodeInfo{ID: "node2", Address: "127.0.0.1", Port: 9002}); err == nil {
+		t.Error("同一节点的成员变更正在进行时应拒绝")
+	}
+
+	close(membership.release)
+	if err := <-done; err != nil {
+		t.Fatalf("添加节点失败: %v", err)
+	}
+	if nodes := cm.GetNodes(); len(nodes) != 1 || nodes[0].ID != "node2" {
+		t.Errorf("变更提交后应记入节点列表: %+v", nodes)
+	}
+	if status := cm.GetClusterStatus(); status.NodeCount != 1 {
+		t.Errorf("节点数不正确: %d", status.NodeCount)
+	}
+
+	// 变更未提交时节点列表保持不变
+	membership.err = errors.New("日志条目已被覆盖")
+	membership.release = make(chan struct{})
+	close(membership.release)
+	go func() { done <- cm.RemoveNode(ctx, "node2") }()
+	<-membership.started
+	if err := <-done; err == nil {
+		t.Fatal("变更未提交时应返回错误")
+	}
+	if nodes := cm.GetNodes(); len(nodes) != 1 {
+		t.Errorf("变更未提交时不应移除节点: %+v", nodes)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	membership.release = make(chan struct{})
+	go func() { done <- cm.PromoteNode(timeout, "node2") }()
+	<-membership.started
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("等待提交超时应返回上下文错误: %v", err)
+	}
+	if nodes := cm.GetNodes(); nodes[0].Status != NodeStatusJoining {
+		t.Errorf("变更未提交时不应提升节点: %v", nodes[0].Status)
+	}
+}
@@ -80,8 +80,8 @@ type ConsensusConfig struct {
 	ElectionTimeout     time.Duration `json:"election_timeout" yaml:"election_timeout"`
 	HeartbeatTimeout    time.Duration `json:"heartbeat_timeout" yaml:"heartbeat_timeout"`
 	LogRetention        int           `json:"log_retention" yaml:"log_retention"`
-	SnapshotInterval    int           `json:"snapshot_interval" yaml:"snapshot_interval"`
-	MaxLogEntries       int           `json:"max_log_entries" yaml:"max_log_entries"`
+	SnapshotInterval    int           `json:"snapshot_interval" yaml:"snapshot_interval"` // 应用多少条Raft日志条目后生成快照
+	MaxLogEntries       int           `json:"max_log_entries" yaml:"max_log_entries"`     // 内存中Raft日志条目的上限，超过时立即生成快照
 	Authorities         []string      `json:"authorities" yaml:"authorities"`
 	BlockTime           int           `json:"block_time" yaml:"block_time"`
 	ProposalTimeout     time.Duration `json:"proposal_timeout" yaml:"proposal_timeout"`
@@ -93,11 +93,14 @@ type ConsensusConfig struct {
 
 // RaftConfig Raft共识配置
 type RaftConfig struct {
-	Port             int           `json:"port" yaml:"port"`
-	DataDir          string        `json:"data_dir" yaml:"data_dir"`
-	SnapshotInterval time.Duration `json:"snapshot_interval" yaml:"snapshot_interval"`
-	HeartbeatTimeout time.Duration `json:"heartbeat_timeout" yaml:"heartbeat_timeout"`
-	ElectionTimeout  time.Duration `json:"election_timeout" yaml:"election_timeout"`
+	Port               int           `json:"port" yaml:"port"`
+	DataDir            string        `json:"data_dir" yaml:"data_dir"`
+	SnapshotInterval   time.Duration `json:"snapshot_interval" yaml:"snapshot_interval"` // 定期为新应用的条目生成快照
+	HeartbeatTimeout   time.Duration `json:"heartbeat_timeout" yaml:"heartbeat_timeout"`
+	ElectionTimeout    time.Duration `json:"election_timeout" yaml:"election_timeout"`
+	DisablePreVote     bool          `json:"disable_pre_vote" yaml:"disable_pre_vote"`         // 关闭发起选举前的预投票
+	DisableCheckQuorum bool          `json:"disable_check_quorum" yaml:"disable_check_quorum"` // 关闭Leader的多数可达检查和投票租约
+	Join               bool          `json:"join" yaml:"join"`                                 // 作为新成员加入已有集群，等待Leader通过成员变更加入
 }
 
 // ClusterConfig 集群配置
@@ -128,6 +131,7 @@ type DIDConfig struct {
 	ChainID         string          `json:"chain_id" yaml:"chain_id"`
 	RegistryAddress string          `json:"registry_address" yaml:"registry_address"`
 	RegistryFile    string          `json:"registry_file" yaml:"registry_file"` // 兼容旧配置
+	CommitMode      string          `json:"commit_mode" yaml:"commit_mode"`     // local_first、atomic 或 replicated
 	Resolver        *ResolverConfig `json:"resolver,omitempty" yaml:"resolver,omitempty"`
 }
 
@@ -162,6 +166,8 @@ type SyncConfig struct {
 type ResolverConfig struct {
 	CacheTTL     time.Duration `json:"cache_ttl" yaml:"cache_ttl"`
 	MaxCacheSize int           `json:"max_cache_size" yaml:"max_cache_size"`
+	// EnableWeb 启用did:web解析；解析时节点会按DID中的域名访问外部主机，默认关闭
+	EnableWeb bool `json:"enable_web" yaml:"enable_web"`
 }
 
 // APIConfig API配置
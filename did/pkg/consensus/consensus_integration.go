@@ -15,11 +15,14 @@ import (
 )
 
 // ConsensusIntegration 共识集成器
+// 作为Raft节点的状态机包装DID状态机，并实现 did.OperationCommitter，
+// 注册表以 replicated 模式写入时，DID操作经Raft提交后由每个节点的状态机应用
 type ConsensusIntegration struct {
-	nodeID      string
-	raftNode    *RaftNode
-	didRegistry *did.DIDRegistry
-	p2pNetwork  *network.P2PNetwork
+	nodeID       string
+	raftNode     *RaftNode
+	didRegistry  *did.DIDRegistry
+	stateMachine StateMachine
+	p2pNetwork   *network.P2PNetwork
 
 	// 状态管理
 	state      ConsensusState
@@ -57,6 +60,12 @@ type Proposal struct {
 	Status      ProposalStatus  `json:"status"`
 	Votes       map[string]bool `json:"votes"`
 	CommitIndex int64           `json:"commit_index"`
+
+	// 本节点提议的提案在条目应用后关闭 done，err 为状态机的应用结果
+	index   int64 // 条目在Raft日志中的索引，Term 为条目的任期
+	done    chan struct{}
+	err     error
+	receipt string
 }
 
 // ProposalType 提案类型
@@ -89,7 +98,19 @@ type DIDOperation = types.DIDOperation
 func NewConsensusIntegration(nodeID string, raftNode *RaftNode, didRegistry *did.DIDRegistry,
 	p2pNetwork *network.P2PNetwork, cfg *config.ConsensusConfig) *ConsensusIntegration {
 
-	return &ConsensusIntegration{
+	// 配置中未设置提案超时或待处理提案上限时使用默认值，否则所有提案都会被拒绝
+	normalized := config.ConsensusConfig{}
+	if cfg != nil {
+		normalized = *cfg
+	}
+	if normalized.ProposalTimeout <= 0 {
+		normalized.ProposalTimeout = 30 * time.Second
+	}
+	if normalized.MaxPendingProposals <= 0 {
+		normalized.MaxPendingProposals = 100
+	}
+
+	ci := &ConsensusIntegration{
 		nodeID:      nodeID,
 		raftNode:    raftNode,
 		didRegistry: didRegistry,
@@ -103,18 +124,22 @@ func NewConsensusIntegration(nodeID string, raftNode *RaftNode, didRegistry *did
 			LastUpdate:       time.Now(),
 		},
 		proposals: make(map[string]*Proposal),
-		config:    cfg,
+		config:    &normalized,
 		stopCh:    make(chan struct{}),
 	}
+	if didRegistry != nil {
+		ci.stateMachine = NewDIDStateMachine(didRegistry)
+	}
+	raftNode.SetStateMachine(ci)
+
+	return ci
 }
 
 // Start 启动共识集成器
 func (ci *ConsensusIntegration) Start(ctx context.Context) error {
 	log.Printf("启动共识集成器，节点ID: %s", ci.nodeID)
 
-	// 注册网络消息处理器
-	ci.p2pNetwork.RegisterMessageHandler(network.MessageTypeConsensus, ci.handleConsensusMessage)
-
+	// 共识消息由Raft节点处理，已提交的提案通过 Apply 回调到达
 	// 启动状态监控
 	go ci.stateMonitor(ctx)
 
@@ -145,9 +170,7 @@ func (ci *ConsensusIntegration) ProposeOperation(opType ProposalType, data inter
 	}
 
 	// 检查待处理提案数量
-	ci.proposalsMutex.RLock()
-	pendingCount := len(ci.proposals)
-	ci.proposalsMutex.RUnlock()
+	pendingCount := len(ci.GetPendingProposals())
 
 	if pendingCount >= ci.config.MaxPendingProposals {
 		return nil, fmt.Errorf("待处理提案过多: %d", pendingCount)
@@ -163,7 +186,15 @@ func (ci *ConsensusIntegration) ProposeOperation(opType ProposalType, data inter
 		Timestamp: time.Now(),
 		Status:    ProposalStatusPending,
 		Votes:     make(map[string]bool),
+		done:      make(chan struct{}),
+	}
+
+	// 日志中只保存不可变的提案内容，提案状态由本节点维护
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化提案数据失败: %w", err)
 	}
+	command := &proposalCommand{ID: proposal.ID, Type: opType, Data: payload}
 
 	// 保存提案
 	ci.proposalsMutex.Lock()
@@ -171,13 +202,15 @@ func (ci *ConsensusIntegration) ProposeOperation(opType ProposalType, data inter
 	ci.proposalsMutex.Unlock()
 
 	// 提交到Raft
-	err := ci.raftNode.Submit(proposal)
+	index, term, err := ci.raftNode.Propose(command)
+	ci.proposalsMutex.Lock()
 	if err != nil {
-		ci.proposalsMutex.Lock()
 		delete(ci.proposals, proposal.ID)
 		ci.proposalsMutex.Unlock()
 		return nil, fmt.Errorf("提交提案到Raft失败: %w", err)
 	}
+	proposal.index, proposal.Term = index, term
+	ci.proposalsMutex.Unlock()
 
 	log.Printf("提案已提交: %s, 类型: %d", proposal.ID, proposal.Type)
 	return proposal, nil
@@ -339,142 +372,132 @@ func (ci *ConsensusIntegration) processTimeoutProposals() {
 	}
 }
 
-// handleConsensusMessage 处理共识消息
-func (ci *ConsensusIntegration) handleConsensusMessage(peer *network.Peer, msg *network.Message) error {
-	// 将interface{}转换为[]byte
-	dataBytes, err := json.Marshal(msg.Data)
-	if err != nil {
-		return fmt.Errorf("序列化消息数据失败: %w", err)
-	}
-
-	var proposal Proposal
-	if err := json.Unmarshal(dataBytes, &proposal); err != nil {
-		return fmt.Errorf("解析共识消息失败: %w", err)
+// CommitDIDOperation 通过Raft提交DID操作，等待该条目在本节点应用后返回回执
+func (ci *ConsensusIntegration) CommitDIDOperation(ctx context.Context, op *types.DIDOperation) (*did.CommitReceipt, error) {
+	receipt := &did.CommitReceipt{
+		Operation:   op.Operation,
+		DID:         op.DID,
+		Status:      types.TransactionStatusPending,
+		SubmittedAt: time.Now(),
 	}
 
-	return ci.handleProposal(&proposal)
-}
-
-// handleProposal 处理提案
-func (ci *ConsensusIntegration) handleProposal(proposal *Proposal) error {
-	log.Printf("处理提案: %s, 类型: %d", proposal.ID, proposal.Type)
-
-	// 验证提案
-	if err := ci.validateProposal(proposal); err != nil {
-		return fmt.Errorf("提案验证失败: %w", err)
+	var proposalType ProposalType
+	switch op.Operation {
+	case did.OperationCreate:
+		proposalType = ProposalTypeDIDCreate
+	case did.OperationUpdate:
+		proposalType = ProposalTypeDIDUpdate
+	case did.OperationDeactivate:
+		proposalType = ProposalTypeDIDDeactivate
+	default:
+		return nil, fmt.Errorf("不支持的DID操作: %s", op.Operation)
 	}
 
-	// 执行提案
-	switch proposal.Type {
-	case ProposalTypeDIDCreate, ProposalTypeDIDUpdate, ProposalTypeDIDDeactivate:
-		return ci.handleDIDProposal(proposal)
-	case ProposalTypeNodeJoin, ProposalTypeNodeLeave:
-		return ci.handleNodeProposal(proposal)
-	case ProposalTypeConfigUpdate:
-		return ci.handleConfigProposal(proposal)
-	default:
-		return fmt.Errorf("未知提案类型: %d", proposal.Type)
+	proposal, err := ci.ProposeOperation(proposalType, op)
+	if err != nil {
+		receipt.Status = types.TransactionStatusFailed
+		receipt.Error = err.Error()
+		return receipt, err
 	}
-}
 
-// validateProposal 验证提案
-func (ci *ConsensusIntegration) validateProposal(proposal *Proposal) error {
-	// 基本验证
-	if proposal.ID == "" || proposal.Proposer == "" {
-		return fmt.Errorf("提案ID或提议者为空")
+	select {
+	case <-proposal.done:
+	case <-ctx.Done():
+		// 条目已在日志中，之后仍可能提交并在各节点应用，结果未知
+		ci.proposalsMutex.RLock()
+		index, term := proposal.index, proposal.Term
+		ci.proposalsMutex.RUnlock()
+		receipt.TxHash = entryPosition(term, index)
+		receipt.Error = ctx.Err().Error()
+		return receipt, &did.CommitPendingError{Position: receipt.TxHash, Settled: ci.settled(index, term), Err: ctx.Err()}
 	}
 
-	// 任期验证
-	currentTerm := ci.getCurrentTerm()
-	if proposal.Term > currentTerm {
-		return fmt.Errorf("提案任期过新: %d > %d", proposal.Term, currentTerm)
+	ci.proposalsMutex.RLock()
+	receipt.TxHash = proposal.receipt
+	err = proposal.err
+	ci.proposalsMutex.RUnlock()
+	if err != nil {
+		receipt.Status = types.TransactionStatusFailed
+		receipt.Error = err.Error()
+		return receipt, err
 	}
 
-	return nil
+	now := time.Now()
+	receipt.Status = types.TransactionStatusConfirmed
+	receipt.ConfirmedAt = &now
+	return receipt, nil
 }
 
-// handleDIDProposal 处理DID提案
-func (ci *ConsensusIntegration) handleDIDProposal(proposal *Proposal) error {
-	didOp, ok := proposal.Data.(*DIDOperation)
-	if !ok {
-		return fmt.Errorf("无效的DID操作数据")
-	}
+// settled 返回在索引 index、任期 term 的条目提交并应用、被其他条目覆盖或集成器停止后关闭的通道
+func (ci *ConsensusIntegration) settled(index, term int64) <-chan struct{} {
+	settled := make(chan struct{})
+	go func() {
+		defer close(settled)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-ci.stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		// 条目在提交时已同步应用到状态机，覆盖时返回错误，两种情况结果都已确定
+		ci.raftNode.WaitCommitted(ctx, index, term)
+	}()
+	return settled
+}
 
-	switch didOp.Operation {
-	case "register":
-		// 创建注册请求
-		registerReq := &did.RegisterRequest{
-			DID:                didOp.DID,
-			VerificationMethod: didOp.Document.VerificationMethod,
-			Service:            didOp.Document.Service,
-		}
-		_, err := ci.didRegistry.Register(registerReq)
-		if err != nil {
-			return fmt.Errorf("注册DID失败: %w", err)
-		}
-		log.Printf("DID创建成功: %s", didOp.DID)
+// AppliesCommittedOperations 已提交的DID操作由 Apply 写入注册表，注册表可以使用 replicated 写入模式
+func (ci *ConsensusIntegration) AppliesCommittedOperations() {}
 
-	case "update":
-		// 创建更新请求
-		updateReq := &did.UpdateRequest{
-			DID:                didOp.DID,
-			VerificationMethod: didOp.Document.VerificationMethod,
-			Service:            didOp.Document.Service,
-			Proof:              didOp.Document.Proof,
-		}
-		_, err := ci.didRegistry.Update(updateReq)
-		if err != nil {
-			return fmt.Errorf("更新DID失败: %w", err)
-		}
-		log.Printf("DID更新成功: %s", didOp.DID)
+// Apply 将已提交的条目应用到DID状态机，并完成本节点提议的对应提案
+func (ci *ConsensusIntegration) Apply(entry LogEntry) error {
+	var err error
+	if ci.stateMachine != nil {
+		err = ci.stateMachine.Apply(entry)
+	}
 
-	case "revoke":
-		err := ci.didRegistry.Revoke(didOp.DID, didOp.Document.Proof)
-		if err != nil {
-			return fmt.Errorf("撤销DID失败: %w", err)
-		}
-		log.Printf("DID停用成功: %s", didOp.DID)
+	ci.stateMutex.Lock()
+	ci.state.LastCommitIndex = entry.Index
+	ci.stateMutex.Unlock()
 
-	default:
-		return fmt.Errorf("不支持的DID操作: %s", didOp.Operation)
+	cmd, decodeErr := decodeProposalCommand(entry.Command)
+	if decodeErr != nil {
+		return err
 	}
 
-	// 更新提案状态
 	ci.proposalsMutex.Lock()
-	if storedProposal, exists := ci.proposals[proposal.ID]; exists {
-		storedProposal.Status = ProposalStatusCommitted
+	if proposal, exists := ci.proposals[cmd.ID]; exists && proposal.receipt == "" {
+		proposal.CommitIndex = entry.Index
+		proposal.receipt = entryPosition(entry.Term, entry.Index)
+		proposal.err = err
+		if err != nil {
+			proposal.Status = ProposalStatusRejected
+		} else {
+			proposal.Status = ProposalStatusCommitted
+		}
+		close(proposal.done)
 	}
 	ci.proposalsMutex.Unlock()
 
-	return nil
+	return err
 }
 
-// handleNodeProposal 处理节点提案
-func (ci *ConsensusIntegration) handleNodeProposal(proposal *Proposal) error {
-	log.Printf("处理节点提案: %s", proposal.ID)
-
-	// 更新提案状态
-	ci.proposalsMutex.Lock()
-	if storedProposal, exists := ci.proposals[proposal.ID]; exists {
-		storedProposal.Status = ProposalStatusCommitted
+// Snapshot 导出DID状态机快照
+func (ci *ConsensusIntegration) Snapshot() ([]byte, error) {
+	if ci.stateMachine == nil {
+		return nil, fmt.Errorf("未配置DID状态机")
 	}
-	ci.proposalsMutex.Unlock()
-
-	return nil
+	return ci.stateMachine.Snapshot()
 }
 
-// handleConfigProposal 处理配置提案
-func (ci *ConsensusIntegration) handleConfigProposal(proposal *Proposal) error {
-	log.Printf("处理配置提案: %s", proposal.ID)
-
-	// 更新提案状态
-	ci.proposalsMutex.Lock()
-	if storedProposal, exists := ci.proposals[proposal.ID]; exists {
-		storedProposal.Status = ProposalStatusCommitted
+// Restore 从快照恢复DID状态机
+func (ci *ConsensusIntegration) Restore(snapshot []byte) error {
+	if ci.stateMachine == nil {
+		return fmt.Errorf("未配置DID状态机")
 	}
-	ci.proposalsMutex.Unlock()
-
-	return nil
+	return ci.stateMachine.Restore(snapshot)
 }
 
 // GetStatus 获取共识状态
@@ -0,0 +1,176 @@
+package consensus
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// SetElectionPolicy 设置选举策略，应在 Start 之前调用
+// preVote: 发起选举前先确认能获得多数选票，避免重新加入的节点提高任期打断集群；
+// checkQuorum: Leader在一个选举超时内未收到多数节点的响应时退位，Follower在Leader租约内拒绝投票
+func (rn *RaftNode) SetElectionPolicy(preVote, checkQuorum bool) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	rn.preVote = preVote
+	rn.checkQuorum = checkQuorum
+}
+
+// tick 定时检查选举超时，Leader发送心跳并检查多数节点是否仍然可达
+func (rn *RaftNode) tick() {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	now := time.Now()
+	if rn.State == Leader {
+		rn.checkLeadershipTransfer(now)
+		if rn.checkQuorum && !now.Before(rn.electionDeadline) {
+			if !rn.hasActiveQuorum(now) {
+				log.Printf("Leader %s 在选举超时内未收到多数节点的响应，退位为Follower，任期: %d", rn.id, rn.term)
+				rn.State = Follower
+				rn.leaderID = ""
+				rn.leadTransferee = ""
+				rn.resetElectionTimeout()
+				return
+			}
+			rn.electionDeadline = now.Add(rn.electionTimeout)
+		}
+		rn.broadcastAppendEntries()
+		return
+	}
+
+	if now.Before(rn.electionDeadline) {
+		return
+	}
+	// Learner和已被移除的节点不发起选举
+	if !rn.membership.IsVoter(rn.id) {
+		rn.resetElectionTimeout()
+		return
+	}
+	rn.campaign()
+}
+
+// resetElectionTimeout 重新随机选举超时并放弃进行中的预投票，调用方需持有锁
+// 超时在 [electionTimeout, 2*electionTimeout) 内随机，使节点很少同时超时而反复分票
+func (rn *RaftNode) resetElectionTimeout() {
+	timeout := rn.electionTimeout
+	if timeout > 0 {
+		timeout += time.Duration(rand.Int63n(int64(timeout)))
+	}
+	rn.electionDeadline = time.Now().Add(timeout)
+	rn.preVotes = nil
+}
+
+// campaign 选举超时后发起选举，启用PreVote时先进行预投票，调用方需持有锁
+func (rn *RaftNode) campaign() {
+	rn.leaderID = ""
+	if !rn.preVote {
+		rn.startElection(false)
+		return
+	}
+
+	rn.resetElectionTimeout()
+	rn.preVotes = map[string]bool{rn.id: true}
+	if rn.hasQuorum(rn.voteCount(rn.preVotes)) {
+		rn.startElection(false)
+		return
+	}
+
+	log.Printf("节点 %s 开始预投票，任期: %d", rn.id, rn.term+1)
+
+	// 预投票使用下一个任期，但不改变本节点的任期和投票
+	req := &RequestVoteRequest{
+		Term:         rn.term + 1,
+		CandidateID:  rn.id,
+		LastLogIndex: rn.getLastLogIndex(),
+		LastLogTerm:  rn.getLastLogTerm(),
+	}
+	for peerID := range rn.peers {
+		if rn.membership.IsVoter(peerID) {
+			rn.send(peerID, "pre_vote", req)
+		}
+	}
+}
+
+// inLease 是否处于Leader租约内：本节点是Leader，或在最短选举超时内收到过Leader的消息，调用方需持有锁
+func (rn *RaftNode) inLease() bool {
+	if rn.State == Leader {
+		return true
+	}
+	return rn.leaderID != "" && time.Since(rn.lastLeaderContact) < rn.electionTimeout
+}
+
+// hasActiveQuorum Leader在最近一个选举超时内是否收到过多数节点的响应，调用方需持有锁
+func (rn *RaftNode) hasActiveQuorum(now time.Time) bool {
+	active := map[string]bool{rn.id: true}
+	for peerID := range rn.peers {
+		if now.Sub(rn.lastAck[peerID]) < rn.electionTimeout {
+			active[peerID] = true
+		}
+	}
+	return rn.hasQuorum(rn.voteCount(active))
+}
+
+// handlePreVote 处理预投票请求，不改变本节点的任期和投票
+func (rn *RaftNode) handlePreVote(req *RequestVoteRequest) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	resp := &RequestVoteResponse{PeerID: rn.id, Term: rn.term}
+
+	// 仍能收到Leader消息时拒绝，候选人的日志也必须不落后
+	if req.Term > rn.term && !rn.inLease() && rn.isLogUpToDate(req.LastLogIndex, req.LastLogTerm) {
+		resp.Term = req.Term
+		resp.VoteGranted = true
+		log.Printf("节点 %s 同意候选人 %s 的预投票，任期: %d", rn.id, req.CandidateID, req.Term)
+	}
+
+	rn.send(req.CandidateID, "pre_vote_response", resp)
+}
+
+// handlePreVoteMessage 处理预投票消息
+func (rn *RaftNode) handlePreVoteMessage(data interface{}) error {
+	var req RequestVoteRequest
+	if err := decodeRaftMessage(data, &req); err != nil {
+		log.Printf("解析预投票请求失败: %v", err)
+		return err
+	}
+
+	rn.handlePreVote(&req)
+	return nil
+}
+
+// handlePreVoteResponse 处理预投票响应，获得多数同意后发起正式选举
+func (rn *RaftNode) handlePreVoteResponse(data interface{}) error {
+	var resp RequestVoteResponse
+	if err := decodeRaftMessage(data, &resp); err != nil {
+		return err
+	}
+	if resp.PeerID == "" {
+		return fmt.Errorf("预投票响应缺少peer_id")
+	}
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	// 被拒绝且对方任期更大，说明本节点已落后
+	if !resp.VoteGranted && resp.Term > rn.term {
+		rn.stepDown(resp.Term)
+		return nil
+	}
+
+	if rn.preVotes == nil || !resp.VoteGranted || resp.Term != rn.term+1 {
+		return nil
+	}
+	if _, exists := rn.peers[resp.PeerID]; !exists {
+		return nil
+	}
+
+	rn.preVotes[resp.PeerID] = true
+	if rn.hasQuorum(rn.voteCount(rn.preVotes)) {
+		rn.startElection(false)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package consensus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestElectionTimerAndCheckQuorum 心跳使Leader保持稳定，被隔离的Leader退位，重新加入的节点不会打断新Leader
+func TestElectionTimerAndCheckQuorum(t *testing.T) {
+	c := newReplicatedCluster("node1", "node2", "node3")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range c.nodes {
+		c.start(t, ctx, i)
+	}
+
+	leader := c.waitLeader(t, -1)
+	_, term, _ := c.nodes[leader].GetState()
+
+	// 持续收到心跳的Follower不会发起选举
+	time.Sleep(500 * time.Millisecond)
+	if _, current, isLeader := c.nodes[leader].GetState(); !isLeader || current != term {
+		t.Fatalf("Leader应保持稳定: 任期 %d -> %d, Leader=%v", term, current, isLeader)
+	}
+
+	// 隔离Leader：它在一个选举超时后退位，其余节点选出新Leader
+	c.disconnect(leader)
+	waitFor(t, "被隔离的Leader退位", func() bool {
+		_, _, isLeader := c.nodes[leader].GetState()
+		return !isLeader
+	})
+	newLeader := c.waitLeader(t, leader)
+	_, newTerm, _ := c.nodes[newLeader].GetState()
+	if newTerm <= term {
+		t.Fatalf("新Leader的任期应更大: %d <= %d", newTerm, term)
+	}
+
+	// 被隔离期间预投票失败，任期不变，重新加入后直接跟随新Leader
+	time.Sleep(300 * time.Millisecond)
+	if _, isolatedTerm, _ := c.nodes[leader].GetState(); isolatedTerm != term {
+		t.Fatalf("被隔离的节点不应提高任期: %d -> %d", term, isolatedTerm)
+	}
+	c.connect(leader)
+	waitFor(t, "重新加入的节点跟随新Leader", func() bool {
+		return c.nodes[leader].GetLeader() == c.ids[newLeader]
+	})
+	if _, current, isLeader := c.nodes[newLeader].GetState(); !isLeader || current != newTerm {
+		t.Fatalf("重新加入的节点不应打断新Leader: 任期 %d -> %d, Leader=%v", newTerm, current, isLeader)
+	}
+}
+
+// waitLeader 等待 exclude 之外的节点成为Leader，返回其下标
+func (c *replicatedCluster) waitLeader(t *testing.T, exclude int) int {
+	t.Helper()
+	leader := -1
+	waitFor(t, "选出Leader", func() bool {
+		for i, node := range c.nodes {
+			if _, _, isLeader := node.GetState(); isLeader && i != exclude {
+				leader = i
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/qujing226/QLink/did"
 	"github.com/qujing226/QLink/pkg/config"
 	"github.com/qujing226/QLink/pkg/network"
 )
@@ -21,6 +22,10 @@ type ConsensusManager struct {
 	monitor  *ConsensusMonitor
 	switcher *ConsensusSwitcher
 
+	// DID注册表及其共识集成器，集成器作为Raft状态机应用已提交的DID操作
+	didRegistry *did.DIDRegistry
+	integration *ConsensusIntegration
+
 	// 网络通信
 	p2pNetwork *network.P2PNetwork
 
@@ -51,6 +56,13 @@ type ManagerConfig struct {
 	// Raft配置
 	RaftConfig *config.RaftConfig `json:"raft_config"`
 
+	// 快照策略：应用多少条目后生成快照、内存中日志条目的上限（0表示不限制）
+	SnapshotEntries int `json:"snapshot_entries"`
+	MaxLogEntries   int `json:"max_log_entries"`
+
+	// 提案超时和待处理提案上限，DID操作经共识集成器提交时使用
+	ConsensusConfig *config.ConsensusConfig `json:"consensus_config"`
+
 	// PoA配置
 	PoAConfig *PoAConfig `json:"poa_config"`
 }
@@ -89,6 +101,24 @@ func NewConsensusManager(cfg *ManagerConfig, p2pNetwork *network.P2PNetwork) *Co
 	}
 }
 
+// SetDIDRegistry 设置DID注册表，应在 Initialize 之前调用
+// 设置后 Initialize 为Raft节点创建共识集成器，已提交的DID操作由它应用到注册表
+func (cm *ConsensusManager) SetDIDRegistry(registry *did.DIDRegistry) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.didRegistry = registry
+}
+
+// DIDCommitter 返回经Raft提交DID操作的提交器，未设置DID注册表或尚未初始化时为nil
+func (cm *ConsensusManager) DIDCommitter() did.OperationCommitter {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if cm.integration == nil {
+		return nil
+	}
+	return cm.integration
+}
+
 // Initialize 初始化共识管理器
 func (cm *ConsensusManager) Initialize() error {
 	cm.mu.Lock()
@@ -101,11 +131,34 @@ func (cm *ConsensusManager) Initialize() error {
 	if cm.config.RaftConfig != nil {
 		// 应用Raft配置 - 直接设置字段
 		cm.raftNode.mu.Lock()
-		cm.raftNode.electionTimeout = cm.config.RaftConfig.ElectionTimeout
-		cm.raftNode.heartbeatInterval = cm.config.RaftConfig.HeartbeatTimeout
+		if cm.config.RaftConfig.ElectionTimeout > 0 {
+			cm.raftNode.electionTimeout = cm.config.RaftConfig.ElectionTimeout
+		}
+		if cm.config.RaftConfig.HeartbeatTimeout > 0 {
+			cm.raftNode.heartbeatInterval = cm.config.RaftConfig.HeartbeatTimeout
+		}
+		cm.raftNode.dataDir = cm.config.RaftConfig.DataDir
+		cm.raftNode.snapshotInterval = cm.config.RaftConfig.SnapshotInterval
+		cm.raftNode.preVote = !cm.config.RaftConfig.DisablePreVote
+		cm.raftNode.checkQuorum = !cm.config.RaftConfig.DisableCheckQuorum
+		if cm.config.RaftConfig.Join {
+			delete(cm.raftNode.baseMembership.Voters, cm.raftNode.id)
+			cm.raftNode.rebuildMembership()
+		}
 		cm.raftNode.mu.Unlock()
-		log.Printf("应用Raft配置: 选举超时=%v, 心跳间隔=%v",
-			cm.config.RaftConfig.ElectionTimeout, cm.config.RaftConfig.HeartbeatTimeout)
+		log.Printf("应用Raft配置: 选举超时=%v, 心跳间隔=%v, 数据目录=%s, PreVote=%v, CheckQuorum=%v",
+			cm.config.RaftConfig.ElectionTimeout, cm.config.RaftConfig.HeartbeatTimeout, cm.config.RaftConfig.DataDir,
+			cm.raftNode.preVote, cm.raftNode.checkQuorum)
+	}
+
+	cm.raftNode.mu.Lock()
+	cm.raftNode.snapshotEntries = int64(cm.config.SnapshotEntries)
+	cm.raftNode.maxLogEntries = int64(cm.config.MaxLogEntries)
+	cm.raftNode.mu.Unlock()
+
+	// 共识集成器作为Raft状态机，已提交的DID操作在每个节点上应用到注册表，快照也基于它生成
+	if cm.didRegistry != nil {
+		cm.integration = NewConsensusIntegration(cm.config.NodeID, cm.raftNode, cm.didRegistry, cm.p2pNetwork, cm.config.ConsensusConfig)
 	}
 
 	// 创建PoA节点
@@ -161,6 +214,11 @@ func (cm *ConsensusManager) Start(ctx context.Context) error {
 		if err := cm.raftNode.Start(ctx); err != nil {
 			return fmt.Errorf("启动Raft节点失败: %v", err)
 		}
+		if cm.integration != nil {
+			if err := cm.integration.Start(ctx); err != nil {
+				return fmt.Errorf("启动共识集成器失败: %v", err)
+			}
+		}
 	case ConsensusTypePoA:
 		if err := cm.poaNode.Start(ctx); err != nil {
 			return fmt.Errorf("启动PoA节点失败: %v", err)
@@ -197,6 +255,10 @@ func (cm *ConsensusManager) Stop() error {
 		currentConsensus.Stop()
 	}
 
+	if cm.integration != nil {
+		cm.integration.Stop()
+	}
+
 	// 停止监控器
 	if cm.monitor != nil {
 		cm.monitor.Stop()
@@ -471,11 +533,11 @@ func (cm *ConsensusManager) GetSwitchState() *SwitchState {
 	return cm.switcher.GetSwitchState()
 }
 
-// AddPeer 添加对等节点
-func (cm *ConsensusManager) AddPeer(id, address string) error {
-	// 添加到Raft节点
-	if cm.raftNode != nil {
-		cm.raftNode.AddPeer(id, address)
+// AddPeer 添加投票成员或提升已追上日志的Learner，作为成员变更提交到Raft日志，只能在Leader上调用
+// 变更提交后返回，ctx 结束时变更可能仍在复制中
+func (cm *ConsensusManager) AddPeer(ctx context.Context, id, address string) error {
+	if err := cm.changeMembership(ctx, &ConfChange{Type: ConfChangeAddVoter, NodeID: id, Address: address}); err != nil {
+		return err
 	}
 
 	// TODO: 添加到PoA节点（如果需要）
@@ -484,11 +546,20 @@ func (cm *ConsensusManager) AddPeer(id, address string) error {
 	return nil
 }
 
-// RemovePeer 移除对等节点
-func (cm *ConsensusManager) RemovePeer(id string) error {
-	// 从Raft节点移除
-	if cm.raftNode != nil {
-		cm.raftNode.RemovePeer(id)
+// AddLearner 添加不参与投票的Learner，追上日志后通过 AddPeer 提升为投票成员，变更提交后返回
+func (cm *ConsensusManager) AddLearner(ctx context.Context, id, address string) error {
+	if err := cm.changeMembership(ctx, &ConfChange{Type: ConfChangeAddLearner, NodeID: id, Address: address}); err != nil {
+		return err
+	}
+
+	log.Printf("添加Learner: %s (%s)", id, address)
+	return nil
+}
+
+// RemovePeer 移除成员，作为成员变更提交到Raft日志，只能在Leader上调用，变更提交后返回
+func (cm *ConsensusManager) RemovePeer(ctx context.Context, id string) error {
+	if err := cm.changeMembership(ctx, &ConfChange{Type: ConfChangeRemoveNode, NodeID: id}); err != nil {
+		return err
 	}
 
 	// TODO: 从PoA节点移除（如果需要）
@@ -497,6 +568,34 @@ func (cm *ConsensusManager) RemovePeer(id string) error {
 	return nil
 }
 
+// changeMembership 提出成员变更并等待其提交
+func (cm *ConsensusManager) changeMembership(ctx context.Context, cc *ConfChange) error {
+	if cm.raftNode == nil {
+		return fmt.Errorf("Raft节点未初始化")
+	}
+	index, term, err := cm.raftNode.ProposeConfChange(cc)
+	if err != nil {
+		return err
+	}
+	return cm.raftNode.WaitCommitted(ctx, index, term)
+}
+
+// TransferLeadership 将Raft领导权转移给指定的投票成员
+func (cm *ConsensusManager) TransferLeadership(id string) error {
+	if cm.raftNode == nil {
+		return fmt.Errorf("Raft节点未初始化")
+	}
+	return cm.raftNode.TransferLeadership(id)
+}
+
+// GetMembership 获取Raft集群当前的成员配置
+func (cm *ConsensusManager) GetMembership() *Membership {
+	if cm.raftNode == nil {
+		return nil
+	}
+	return cm.raftNode.GetMembership()
+}
+
 // IsLeader 检查是否为领导者
 func (cm *ConsensusManager) IsLeader() bool {
 	currentType := cm.switcher.GetCurrentType()
@@ -525,15 +624,8 @@ func (cm *ConsensusManager) GetLeaderID() string {
 	switch cm.config.DefaultConsensus {
 	case ConsensusTypeRaft:
 		if cm.raftNode != nil {
-			// 从Raft节点状态获取领导者信息
-			state, _, isLeader := cm.raftNode.GetState()
-			if isLeader {
-				return cm.config.NodeID
-			}
-			// 如果不是领导者，返回空字符串
-			// 在实际实现中，可能需要从Raft日志或网络消息中获取当前领导者ID
-			_ = state // 避免未使用变量警告
-			return ""
+			// Leader返回自己，Follower返回最近一次收到其消息的Leader
+			return cm.raftNode.GetLeader()
 		}
 	case ConsensusTypePoA:
 		if cm.poaNode != nil {
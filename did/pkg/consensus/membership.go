@@ -0,0 +1,395 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// ConfChangeType 成员变更类型
+type ConfChangeType string
+
+const (
+	ConfChangeAddLearner ConfChangeType = "add_learner" // 加入为只复制日志、不参与投票的Learner
+	ConfChangeAddVoter   ConfChangeType = "add_voter"   // 加入为投票成员，或提升已追上日志的Learner
+	ConfChangeRemoveNode ConfChangeType = "remove_node" // 移除投票成员或Learner
+)
+
+// ConfChange 成员变更，作为日志条目复制，每次只变更一个节点
+type ConfChange struct {
+	Type    ConfChangeType `json:"type"`
+	NodeID  string         `json:"node_id"`
+	Address string         `json:"address,omitempty"`
+}
+
+// Membership 集群成员配置（包括本节点），节点ID映射到地址
+type Membership struct {
+	Voters   map[string]string `json:"voters"`
+	Learners map[string]string `json:"learners"`
+}
+
+// TimeoutNowRequest 领导权转移请求，目标节点收到后立即发起选举
+type TimeoutNowRequest struct {
+	Term     int64  `json:"term"`
+	LeaderID string `json:"leader_id"`
+}
+
+// newMembership 创建空的成员配置
+func newMembership() *Membership {
+	return &Membership{
+		Voters:   make(map[string]string),
+		Learners: make(map[string]string),
+	}
+}
+
+// clone 深拷贝成员配置
+func (m *Membership) clone() *Membership {
+	c := newMembership()
+	for id, address := range m.Voters {
+		c.Voters[id] = address
+	}
+	for id, address := range m.Learners {
+		c.Learners[id] = address
+	}
+	return c
+}
+
+// apply 应用一个成员变更
+func (m *Membership) apply(cc *ConfChange) {
+	switch cc.Type {
+	case ConfChangeAddLearner:
+		m.Learners[cc.NodeID] = cc.Address
+	case ConfChangeAddVoter:
+		address := cc.Address
+		if address == "" {
+			address = m.Learners[cc.NodeID]
+		}
+		delete(m.Learners, cc.NodeID)
+		m.Voters[cc.NodeID] = address
+	case ConfChangeRemoveNode:
+		delete(m.Voters, cc.NodeID)
+		delete(m.Learners, cc.NodeID)
+	}
+}
+
+// IsVoter 是否为投票成员
+func (m *Membership) IsVoter(id string) bool {
+	_, ok := m.Voters[id]
+	return ok
+}
+
+// IsLearner 是否为Learner
+func (m *Membership) IsLearner(id string) bool {
+	_, ok := m.Learners[id]
+	return ok
+}
+
+// decodeConfChange 解码日志条目中的成员变更，Leader本地保存的是 *ConfChange，经网络复制后为JSON对象
+func decodeConfChange(command interface{}) (*ConfChange, error) {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("序列化成员变更失败: %w", err)
+	}
+
+	var cc ConfChange
+	if err := json.Unmarshal(data, &cc); err != nil {
+		return nil, fmt.Errorf("解析成员变更失败: %w", err)
+	}
+	return &cc, nil
+}
+
+// SetJoining 本节点作为新成员加入已有集群，应在 Start 之前调用
+// 初始成员不包括本节点，在Leader将本节点加入的成员变更复制过来之前只接收日志，不发起选举
+func (rn *RaftNode) SetJoining() {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	delete(rn.baseMembership.Voters, rn.id)
+	rn.rebuildMembership()
+}
+
+// AddPeer 添加投票成员
+// 启动前添加的是初始成员，集群中所有节点的初始成员必须相同；启动后作为成员变更提交到日志，
+// 只能在Leader上调用，目标为Learner时将其提升为投票成员
+func (rn *RaftNode) AddPeer(id, address string) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if !rn.started {
+		delete(rn.baseMembership.Learners, id)
+		rn.baseMembership.Voters[id] = address
+		rn.rebuildMembership()
+		return nil
+	}
+	_, err := rn.proposeConfChange(&ConfChange{Type: ConfChangeAddVoter, NodeID: id, Address: address})
+	return err
+}
+
+// AddLearner 添加只复制日志、不参与投票和多数派计算的Learner，追上日志后可通过 AddPeer 提升为投票成员
+func (rn *RaftNode) AddLearner(id, address string) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if !rn.started {
+		delete(rn.baseMembership.Voters, id)
+		rn.baseMembership.Learners[id] = address
+		rn.rebuildMembership()
+		return nil
+	}
+	_, err := rn.proposeConfChange(&ConfChange{Type: ConfChangeAddLearner, NodeID: id, Address: address})
+	return err
+}
+
+// RemovePeer 移除投票成员或Learner，启动前从初始成员中移除，启动后作为成员变更提交到日志
+// 移除Leader自己时，Leader在该变更提交后退位
+func (rn *RaftNode) RemovePeer(id string) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if !rn.started {
+		delete(rn.baseMembership.Voters, id)
+		delete(rn.baseMembership.Learners, id)
+		rn.rebuildMembership()
+		return nil
+	}
+	_, err := rn.proposeConfChange(&ConfChange{Type: ConfChangeRemoveNode, NodeID: id})
+	return err
+}
+
+// ProposeConfChange 在Leader上提出成员变更，返回变更条目的索引和任期，可通过 WaitCommitted 等待其提交
+func (rn *RaftNode) ProposeConfChange(cc *ConfChange) (int64, int64, error) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if !rn.started {
+		return 0, 0, fmt.Errorf("Raft节点未启动")
+	}
+	index, err := rn.proposeConfChange(cc)
+	return index, rn.term, err
+}
+
+// GetMembership 获取当前生效的成员配置
+func (rn *RaftNode) GetMembership() *Membership {
+	rn.mu.RLock()
+	defer rn.mu.RUnlock()
+
+	return rn.membership.clone()
+}
+
+// proposeConfChange 校验并追加成员变更条目，调用方需持有锁
+// 变更在追加到日志时即生效。每次只变更一个节点，新旧配置的多数派必然相交；上一个变更提交前不能提出新的变更，
+// Leader也必须已在当前任期提交过条目，否则可能与之前任期未提交的变更冲突
+func (rn *RaftNode) proposeConfChange(cc *ConfChange) (int64, error) {
+	if rn.State != Leader {
+		return 0, fmt.Errorf("只有Leader可以变更集群成员")
+	}
+	if rn.leadTransferee != "" {
+		return 0, fmt.Errorf("正在将领导权转移给 %s", rn.leadTransferee)
+	}
+	if rn.confIndex > rn.commitIndex {
+		return 0, fmt.Errorf("上一个成员变更（索引 %d）尚未提交", rn.confIndex)
+	}
+	if rn.termAt(rn.commitIndex) != rn.term {
+		return 0, fmt.Errorf("Leader尚未在当前任期提交条目，请稍后重试")
+	}
+	if cc.NodeID == "" {
+		return 0, fmt.Errorf("节点ID不能为空")
+	}
+
+	m := rn.membership
+	switch cc.Type {
+	case ConfChangeAddLearner:
+		if m.IsVoter(cc.NodeID) || m.IsLearner(cc.NodeID) {
+			return 0, fmt.Errorf("节点 %s 已是集群成员", cc.NodeID)
+		}
+	case ConfChangeAddVoter:
+		if m.IsVoter(cc.NodeID) {
+			return 0, fmt.Errorf("节点 %s 已是投票成员", cc.NodeID)
+		}
+		// Learner追上日志后再提升，避免新的投票成员拖慢提交
+		if m.IsLearner(cc.NodeID) && rn.matchIndex[cc.NodeID] < rn.commitIndex {
+			return 0, fmt.Errorf("Learner %s 尚未追上日志: %d/%d", cc.NodeID, rn.matchIndex[cc.NodeID], rn.commitIndex)
+		}
+	case ConfChangeRemoveNode:
+		if !m.IsVoter(cc.NodeID) && !m.IsLearner(cc.NodeID) {
+			return 0, fmt.Errorf("节点 %s 不是集群成员", cc.NodeID)
+		}
+		if m.IsVoter(cc.NodeID) && len(m.Voters) == 1 {
+			return 0, fmt.Errorf("不能移除最后一个投票成员")
+		}
+	default:
+		return 0, fmt.Errorf("未知的成员变更类型: %s", cc.Type)
+	}
+
+	entry := LogEntry{
+		Term:      rn.term,
+		Index:     rn.getLastLogIndex() + 1,
+		Type:      types.LogEntryTypeConfChange,
+		Command:   cc,
+		Timestamp: time.Now(),
+	}
+	if err := rn.appendLog(entry); err != nil {
+		return 0, fmt.Errorf("写入日志失败: %w", err)
+	}
+	log.Printf("Leader %s 提出成员变更: %s %s, 索引=%d", rn.id, cc.Type, cc.NodeID, entry.Index)
+
+	rn.advanceCommitIndex()
+	rn.broadcastAppendEntries()
+	return entry.Index, nil
+}
+
+// membershipAt 索引 index 处生效的成员配置及最后一个成员变更的索引，index 不小于快照索引，调用方需持有锁
+func (rn *RaftNode) membershipAt(index int64) (*Membership, int64) {
+	m := rn.baseMembership.clone()
+	confIndex := rn.snapshotIndex
+	for _, entry := range rn.log {
+		if entry.Index > index {
+			break
+		}
+		if entry.Type != types.LogEntryTypeConfChange {
+			continue
+		}
+		cc, err := decodeConfChange(entry.Command)
+		if err != nil {
+			log.Printf("节点 %s 跳过无效的成员变更条目 %d: %v", rn.id, entry.Index, err)
+			continue
+		}
+		m.apply(cc)
+		confIndex = entry.Index
+	}
+	return m, confIndex
+}
+
+// rebuildMembership 在快照（或初始）成员配置上依次应用日志中的成员变更，调用方需持有锁
+// 日志追加、截断或压缩后调用，被截断的变更随之撤销
+func (rn *RaftNode) rebuildMembership() {
+	m, confIndex := rn.membershipAt(rn.getLastLogIndex())
+	rn.membership = m
+	rn.confIndex = confIndex
+
+	members := make(map[string]string, len(m.Voters)+len(m.Learners))
+	for id, address := range m.Voters {
+		members[id] = address
+	}
+	for id, address := range m.Learners {
+		members[id] = address
+	}
+	delete(members, rn.id)
+
+	// 同步需要复制日志的对等节点
+	for id := range rn.peers {
+		if _, ok := members[id]; !ok {
+			delete(rn.peers, id)
+			delete(rn.nextIndex, id)
+			delete(rn.matchIndex, id)
+			delete(rn.lastAck, id)
+		}
+	}
+	for id, address := range members {
+		if peer, ok := rn.peers[id]; ok {
+			peer.Address = address
+			continue
+		}
+		rn.peers[id] = &PeerConnection{NodeID: id, Address: address, Active: true}
+		if rn.State == Leader {
+			rn.nextIndex[id] = rn.getLastLogIndex() + 1
+			rn.matchIndex[id] = 0
+		}
+	}
+}
+
+// hasConfChange 条目中是否有成员变更
+func hasConfChange(entries []LogEntry) bool {
+	for _, entry := range entries {
+		if entry.Type == types.LogEntryTypeConfChange {
+			return true
+		}
+	}
+	return false
+}
+
+// stepDownIfRemoved 移除Leader自己的变更提交后退位，调用方需持有锁
+func (rn *RaftNode) stepDownIfRemoved() {
+	if rn.State != Leader || rn.membership.IsVoter(rn.id) || rn.commitIndex < rn.confIndex {
+		return
+	}
+
+	log.Printf("Leader %s 已被移出集群，退位", rn.id)
+	rn.State = Follower
+	rn.leaderID = ""
+	rn.resetElectionTimeout()
+}
+
+// TransferLeadership 将领导权转移给指定的投票成员
+// Leader停止接受新的提议，目标节点追上日志后通知其立即发起选举；一个选举超时内未完成则放弃转移
+func (rn *RaftNode) TransferLeadership(target string) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if rn.State != Leader {
+		return fmt.Errorf("只有Leader可以转移领导权")
+	}
+	if target == rn.id {
+		return fmt.Errorf("节点 %s 已是Leader", target)
+	}
+	if !rn.membership.IsVoter(target) {
+		return fmt.Errorf("节点 %s 不是投票成员", target)
+	}
+	if rn.leadTransferee != "" {
+		return fmt.Errorf("正在将领导权转移给 %s", rn.leadTransferee)
+	}
+
+	rn.leadTransferee = target
+	rn.transferDeadline = time.Now().Add(rn.electionTimeout)
+	log.Printf("Leader %s 开始将领导权转移给 %s", rn.id, target)
+
+	if rn.matchIndex[target] == rn.getLastLogIndex() {
+		rn.sendTimeoutNow(target)
+	} else {
+		rn.sendAppendEntriesTo(target)
+	}
+	return nil
+}
+
+// sendTimeoutNow 通知领导权转移的目标节点立即发起选举，调用方需持有锁
+func (rn *RaftNode) sendTimeoutNow(target string) {
+	log.Printf("Leader %s 通知 %s 立即发起选举", rn.id, target)
+	rn.send(target, "timeout_now", &TimeoutNowRequest{Term: rn.term, LeaderID: rn.id})
+}
+
+// checkLeadershipTransfer 领导权转移超时后恢复接受提议，调用方需持有锁
+func (rn *RaftNode) checkLeadershipTransfer(now time.Time) {
+	if rn.leadTransferee == "" || now.Before(rn.transferDeadline) {
+		return
+	}
+	log.Printf("Leader %s 向 %s 转移领导权超时，放弃转移", rn.id, rn.leadTransferee)
+	rn.leadTransferee = ""
+}
+
+// handleTimeoutNow 处理领导权转移请求，跳过预投票立即发起选举
+func (rn *RaftNode) handleTimeoutNow(req *TimeoutNowRequest) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if req.Term != rn.term || req.LeaderID != rn.leaderID || !rn.membership.IsVoter(rn.id) {
+		return
+	}
+
+	log.Printf("节点 %s 收到Leader %s 的领导权转移请求", rn.id, req.LeaderID)
+	rn.startElection(true)
+}
+
+// handleTimeoutNowMessage 处理领导权转移消息
+func (rn *RaftNode) handleTimeoutNowMessage(data interface{}) error {
+	var req TimeoutNowRequest
+	if err := decodeRaftMessage(data, &req); err != nil {
+		log.Printf("解析领导权转移请求失败: %v", err)
+		return err
+	}
+
+	rn.handleTimeoutNow(&req)
+	return nil
+}
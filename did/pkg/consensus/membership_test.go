@@ -0,0 +1,136 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestMembershipChanges 新节点作为Learner追上日志后提升为投票成员，Leader移除自己后退位，领导权可转移给指定节点
+func TestMembershipChanges(t *testing.T) {
+	c := newReplicatedCluster("node1", "node2", "node3")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range c.nodes {
+		c.start(t, ctx, i)
+	}
+
+	leader := c.waitLeader(t, -1)
+	c.register(t, leader)
+
+	// 新节点以集群的初始成员启动，被加入前不参与选举
+	c.addNode("node4", c.ids).SetJoining()
+	c.start(t, ctx, 3)
+
+	retry(t, "添加Learner", func() error { return c.nodes[leader].AddLearner("node4", "node4") })
+	if err := c.nodes[leader].AddLearner("node5", "node5"); err == nil {
+		t.Fatal("上一个成员变更提交前不应接受新的变更")
+	}
+	c.waitConverged(t, leader, c.registries[3], "node4")
+	if m := c.nodes[leader].GetMembership(); len(m.Voters) != 3 || !m.IsLearner("node4") {
+		t.Fatalf("node4 应为Learner: %+v", m)
+	}
+
+	// Learner追上日志后提升为投票成员，所有节点的成员配置一致
+	retry(t, "提升Learner", func() error { return c.nodes[leader].AddPeer("node4", "") })
+	waitFor(t, "所有节点看到node4成为投票成员", func() bool {
+		for _, node := range c.nodes {
+			if m := node.GetMembership(); len(m.Voters) != 4 || !m.IsVoter("node4") {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Leader移除自己，变更提交后退位，其余节点选出新Leader
+	retry(t, "移除Leader", func() error { return c.nodes[leader].RemovePeer(c.ids[leader]) })
+	waitFor(t, "被移除的Leader退位", func() bool {
+		_, _, isLeader := c.nodes[leader].GetState()
+		return !isLeader
+	})
+	newLeader := c.waitLeader(t, leader)
+	if m := c.nodes[newLeader].GetMembership(); len(m.Voters) != 3 || m.IsVoter(c.ids[leader]) {
+		t.Fatalf("新Leader的成员配置不正确: %+v", m)
+	}
+
+	// 领导权转移给另一个投票成员
+	target := -1
+	for i := range c.nodes {
+		if i != leader && i != newLeader {
+			target = i
+			break
+		}
+	}
+	retry(t, "转移领导权", func() error { return c.nodes[newLeader].TransferLeadership(c.ids[target]) })
+	waitFor(t, c.ids[target]+"成为Leader", func() bool {
+		_, _, isLeader := c.nodes[target].GetState()
+		return isLeader
+	})
+
+	// 新配置下仍能提交DID操作
+	c.register(t, target)
+	for i := range c.nodes {
+		if i != leader && i != target {
+			c.waitConverged(t, target, c.registries[i], c.ids[i])
+		}
+	}
+}
+
+// TestWaitConfChangeCommitted 成员变更提交后等待返回，多数节点不可达时等待超时
+func TestWaitConfChangeCommitted(t *testing.T) {
+	c := newReplicatedCluster("node1", "node2", "node3")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range c.nodes {
+		c.start(t, ctx, i)
+	}
+	leader := c.waitLeader(t, -1)
+	node := c.nodes[leader]
+
+	var index, term int64
+	retry(t, "添加Learner", func() (err error) {
+		index, term, err = node.ProposeConfChange(&ConfChange{Type: ConfChangeAddLearner, NodeID: "node4", Address: "node4"})
+		return err
+	})
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	if err := node.WaitCommitted(waitCtx, index, term); err != nil {
+		t.Fatalf("等待成员变更提交失败: %v", err)
+	}
+	if m := node.GetMembership(); !m.IsLearner("node4") {
+		t.Fatalf("node4 应为Learner: %+v", m)
+	}
+
+	// 断开两个Follower后变更无法提交，等待在超时后返回
+	for i := range c.nodes {
+		if i != leader {
+			c.disconnect(i)
+		}
+	}
+	index, term, err := node.ProposeConfChange(&ConfChange{Type: ConfChangeRemoveNode, NodeID: "node4"})
+	if err != nil {
+		t.Fatalf("上一个变更提交后应接受新的变更: %v", err)
+	}
+	shortCtx, shortCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer shortCancel()
+	if err := node.WaitCommitted(shortCtx, index, term); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("多数节点不可达时应等待超时: %v", err)
+	}
+}
+
+// retry 重试直到操作成功，成员变更需要等待上一个变更提交
+func retry(t *testing.T, what string, op func() error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := op()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s失败: %v", what, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -5,38 +5,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/qujing226/QLink/pkg/network"
 	"github.com/qujing226/QLink/pkg/types"
 )
 
+// maxAppendEntries 单个追加条目请求携带的最大日志条目数
+const maxAppendEntries = 64
+
+// RaftTransport Raft消息传输，*network.P2PNetwork 满足该接口
+type RaftTransport interface {
+	SendMessage(peerID string, msgType network.MessageType, data interface{}) error
+	RegisterMessageHandler(msgType network.MessageType, handler network.MessageHandler)
+}
+
 // RaftNode Raft节点
 type RaftNode struct {
 	id       string
-	peers    map[string]*PeerConnection
-	State    NodeState // 改为公开字段以便测试
+	peers    map[string]*PeerConnection // 除本节点外的成员（投票成员和Learner），由 membership 派生
+	State    NodeState                  // 改为公开字段以便测试
 	term     int64
 	votedFor string
-	log      []LogEntry
+	leaderID string
+	log      []LogEntry // 快照之后的条目，log[i].Index == snapshotIndex+1+i
+
+	// 最新快照，snapshotIndex 及之前的条目已从日志中删除
+	snapshot      *Snapshot
+	snapshotIndex int64
+	snapshotTerm  int64
+
+	// 快照策略，见 SetSnapshotPolicy
+	snapshotEntries  int64
+	maxLogEntries    int64
+	snapshotInterval time.Duration
+
+	// 成员配置：baseMembership 为快照中（没有快照时为初始）的成员，membership 为在其上应用日志中
+	// 全部成员变更后的结果，confIndex 为最后一个成员变更条目的索引
+	baseMembership *Membership
+	membership     *Membership
+	confIndex      int64
+	started        bool
+
+	// 领导权转移的目标节点，转移期间Leader不接受新的提议
+	leadTransferee   string
+	transferDeadline time.Time
 
 	// Leader状态
 	commitIndex int64
 	lastApplied int64
+	committed   chan struct{} // 提交索引推进时关闭并替换，用于等待条目提交
 
 	// Leader状态（仅Leader使用）
 	nextIndex  map[string]int64
 	matchIndex map[string]int64
 
-	// 超时配置
+	// 候选者在当前任期获得的选票，预投票阶段获得的同意（nil表示不在预投票阶段）
+	votes    map[string]bool
+	preVotes map[string]bool
+
+	// 超时配置：选举超时在 [electionTimeout, 2*electionTimeout) 内随机
 	electionTimeout   time.Duration
 	heartbeatInterval time.Duration
 
+	// 选举定时器：Follower和候选者到 electionDeadline 仍未收到Leader消息时发起选举，
+	// Leader到期时检查多数节点是否可达
+	electionDeadline  time.Time
+	lastLeaderContact time.Time
+	lastAck           map[string]time.Time // Leader收到各节点响应的时间
+
+	// 选举策略，见 SetElectionPolicy
+	preVote     bool
+	checkQuorum bool
+
 	// 网络
-	p2pNetwork *network.P2PNetwork
+	transport RaftTransport
+
+	// 已提交条目按顺序应用到状态机
+	stateMachine StateMachine
+
+	// 持久化：dataDir 为空时日志、任期和投票只保存在内存中
+	dataDir string
+	wal     *WAL
 
 	// 同步
 	mu sync.RWMutex
@@ -73,88 +124,198 @@ type AppendEntriesRequest struct {
 }
 
 // AppendEntriesResponse 追加条目响应
+// 成功时 MatchIndex 为与Leader一致的最后索引，失败时为Leader重试的提示位置
 type AppendEntriesResponse struct {
-	Term    int64 `json:"term"`
-	Success bool  `json:"success"`
+	PeerID     string `json:"peer_id"`
+	Term       int64  `json:"term"`
+	Success    bool   `json:"success"`
+	MatchIndex int64  `json:"match_index"`
 }
 
-// RequestVoteRequest 请求投票请求
+// RequestVoteRequest 请求投票请求，LeadershipTransfer 表示由领导权转移发起的选举
 type RequestVoteRequest struct {
-	Term         int64  `json:"term"`
-	CandidateID  string `json:"candidate_id"`
-	LastLogIndex int64  `json:"last_log_index"`
-	LastLogTerm  int64  `json:"last_log_term"`
+	Term               int64  `json:"term"`
+	CandidateID        string `json:"candidate_id"`
+	LastLogIndex       int64  `json:"last_log_index"`
+	LastLogTerm        int64  `json:"last_log_term"`
+	LeadershipTransfer bool   `json:"leadership_transfer,omitempty"`
 }
 
 // RequestVoteResponse 请求投票响应
 type RequestVoteResponse struct {
-	Term        int64 `json:"term"`
-	VoteGranted bool  `json:"vote_granted"`
+	PeerID      string `json:"peer_id"`
+	Term        int64  `json:"term"`
+	VoteGranted bool   `json:"vote_granted"`
 }
 
 // NewRaftNode 创建新的Raft节点
 func NewRaftNode(id string, p2pNetwork *network.P2PNetwork) *RaftNode {
+	rn := NewRaftNodeWithTransport(id, nil)
+	if p2pNetwork != nil {
+		rn.transport = p2pNetwork
+	}
+	return rn
+}
+
+// NewRaftNodeWithTransport 使用指定的消息传输创建Raft节点
+func NewRaftNodeWithTransport(id string, transport RaftTransport) *RaftNode {
+	base := newMembership()
+	base.Voters[id] = ""
+
 	return &RaftNode{
 		id:                id,
 		peers:             make(map[string]*PeerConnection),
 		State:             Follower,
 		term:              0,
 		log:               make([]LogEntry, 0),
+		baseMembership:    base,
+		membership:        base.clone(),
 		nextIndex:         make(map[string]int64),
 		matchIndex:        make(map[string]int64),
-		electionTimeout:   time.Duration(150+rand.Intn(150)) * time.Millisecond,
+		lastAck:           make(map[string]time.Time),
+		electionTimeout:   150 * time.Millisecond,
 		heartbeatInterval: 50 * time.Millisecond,
-		p2pNetwork:        p2pNetwork,
+		preVote:           true,
+		checkQuorum:       true,
+		transport:         transport,
 		appendEntriesCh:   make(chan *AppendEntriesRequest, 100),
 		requestVoteCh:     make(chan *RequestVoteRequest, 100),
 		stopCh:            make(chan struct{}),
+		committed:         make(chan struct{}),
 	}
 }
 
+// SetStateMachine 设置状态机，应在 Start 之前调用
+func (rn *RaftNode) SetStateMachine(sm StateMachine) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	rn.stateMachine = sm
+}
+
+// SetDataDir 设置WAL目录，应在 Start 之前调用
+func (rn *RaftNode) SetDataDir(dir string) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	rn.dataDir = dir
+}
+
 // Start 启动Raft节点
 func (rn *RaftNode) Start(ctx context.Context) error {
 	log.Printf("启动Raft节点: %s", rn.id)
 
+	if err := rn.recover(); err != nil {
+		return err
+	}
+
+	// 启动后成员只能通过日志变更
+	rn.mu.Lock()
+	rn.started = true
+	rn.mu.Unlock()
+
 	// 注册Raft消息处理器
-	if rn.p2pNetwork != nil {
-		rn.p2pNetwork.RegisterMessageHandler(network.MessageTypeConsensus, rn.handleNetworkMessage)
+	if rn.transport != nil {
+		rn.transport.RegisterMessageHandler(network.MessageTypeConsensus, rn.handleNetworkMessage)
 	}
 
 	go rn.run(ctx)
+	if rn.snapshotInterval > 0 {
+		go rn.runSnapshotTimer(rn.snapshotInterval, rn.stopCh)
+	}
 	return nil
 }
 
 // Stop 停止Raft节点
 func (rn *RaftNode) Stop() error {
 	close(rn.stopCh)
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if rn.wal != nil {
+		return rn.wal.Close()
+	}
 	return nil
 }
 
-// AddPeer 添加对等节点
-func (rn *RaftNode) AddPeer(id, address string) {
+// recover 从WAL恢复任期、投票和日志
+// 提交索引不持久化，重启后由Leader重新告知，已提交的条目会再次应用到状态机
+func (rn *RaftNode) recover() error {
 	rn.mu.Lock()
 	defer rn.mu.Unlock()
 
-	rn.peers[id] = &PeerConnection{
-		NodeID:  id,
-		Address: address,
-		Active:  true,
+	if rn.dataDir == "" || rn.wal != nil {
+		return nil
+	}
+
+	wal, err := OpenWAL(rn.dataDir, DefaultSegmentSize)
+	if err != nil {
+		return err
+	}
+	hardState, snapshot, entries, err := wal.Recover()
+	if err != nil {
+		wal.Close()
+		return fmt.Errorf("恢复Raft日志失败: %w", err)
 	}
 
-	if rn.State == Leader {
-		rn.nextIndex[id] = rn.getLastLogIndex() + 1
-		rn.matchIndex[id] = 0
+	// 快照包含的条目都已提交，先恢复状态机再重放之后的条目
+	if snapshot != nil {
+		if rn.stateMachine != nil {
+			if err := rn.stateMachine.Restore(snapshot.Data); err != nil {
+				wal.Close()
+				return fmt.Errorf("恢复Raft快照失败: %w", err)
+			}
+		}
+		rn.compactLog(snapshot)
+		rn.commitIndex = snapshot.Index
+		rn.lastApplied = snapshot.Index
 	}
+
+	rn.wal = wal
+	rn.term = hardState.Term
+	rn.votedFor = hardState.VotedFor
+	rn.log = append(make([]LogEntry, 0, len(entries)), entries...)
+	rn.rebuildMembership()
+
+	log.Printf("节点 %s 从WAL恢复: 任期=%d, 投票=%s, 快照索引=%d, 日志条目=%d, 投票成员=%d",
+		rn.id, rn.term, rn.votedFor, rn.snapshotIndex, len(rn.log), len(rn.membership.Voters))
+	return nil
 }
 
-// RemovePeer 移除对等节点
-func (rn *RaftNode) RemovePeer(id string) {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
+// persistHardState 持久化任期和投票，必须在发送依赖它们的消息之前调用，调用方需持有锁
+func (rn *RaftNode) persistHardState() error {
+	if rn.wal == nil {
+		return nil
+	}
+	return rn.wal.SaveHardState(HardState{Term: rn.term, VotedFor: rn.votedFor})
+}
 
-	delete(rn.peers, id)
-	delete(rn.nextIndex, id)
-	delete(rn.matchIndex, id)
+// appendLog 持久化并追加日志条目，调用方需持有锁
+func (rn *RaftNode) appendLog(entries ...LogEntry) error {
+	if rn.wal != nil {
+		if err := rn.wal.Append(entries); err != nil {
+			return err
+		}
+	}
+	rn.log = append(rn.log, entries...)
+	if hasConfChange(entries) {
+		rn.rebuildMembership()
+	}
+	return nil
+}
+
+// truncateLog 删除索引不小于 index 的日志条目，调用方需持有锁
+func (rn *RaftNode) truncateLog(index int64) error {
+	if rn.wal != nil {
+		if err := rn.wal.TruncateFrom(index); err != nil {
+			return err
+		}
+	}
+	rn.log = rn.log[:rn.logPosition(index)]
+	if rn.confIndex >= index {
+		rn.rebuildMembership()
+	}
+	return nil
 }
 
 // GetState 获取节点状态
@@ -165,34 +326,61 @@ func (rn *RaftNode) GetState() (NodeState, int64, bool) {
 	return rn.State, rn.term, rn.State == Leader
 }
 
+// GetLeader 获取当前已知的Leader
+func (rn *RaftNode) GetLeader() string {
+	rn.mu.RLock()
+	defer rn.mu.RUnlock()
+
+	return rn.leaderID
+}
+
 // Submit 提交命令
 func (rn *RaftNode) Submit(command interface{}) error {
+	_, _, err := rn.Propose(command)
+	return err
+}
+
+// Propose 将命令追加到Leader日志并开始复制，返回条目的索引和任期
+// 返回不代表命令已提交，条目提交后由状态机的 Apply 得到通知
+func (rn *RaftNode) Propose(command interface{}) (int64, int64, error) {
 	rn.mu.Lock()
 	defer rn.mu.Unlock()
 
 	if rn.State != Leader {
-		return fmt.Errorf("只有Leader可以接受命令")
+		return 0, 0, fmt.Errorf("只有Leader可以接受命令")
+	}
+	if rn.leadTransferee != "" {
+		return 0, 0, fmt.Errorf("正在将领导权转移给 %s", rn.leadTransferee)
 	}
 
 	// 创建新的日志条目
 	entry := LogEntry{
-		Term:    rn.term,
-		Index:   rn.getLastLogIndex() + 1,
-		Command: command,
+		Term:      rn.term,
+		Index:     rn.getLastLogIndex() + 1,
+		Command:   command,
+		Timestamp: time.Now(),
 	}
 
-	rn.log = append(rn.log, entry)
-	log.Printf("Leader %s 添加日志条目: %+v", rn.id, entry)
+	if err := rn.appendLog(entry); err != nil {
+		return 0, 0, fmt.Errorf("写入日志失败: %w", err)
+	}
+	log.Printf("Leader %s 添加日志条目: 索引=%d, 任期=%d", rn.id, entry.Index, entry.Term)
 
-	// 立即发送给所有followers
-	go rn.sendAppendEntries()
+	// 单节点集群无需复制即可提交
+	rn.advanceCommitIndex()
+	rn.broadcastAppendEntries()
 
-	return nil
+	return entry.Index, entry.Term, nil
 }
 
 // run 主运行循环
 func (rn *RaftNode) run(ctx context.Context) {
-	ticker := time.NewTicker(rn.electionTimeout)
+	rn.mu.Lock()
+	rn.resetElectionTimeout()
+	interval := rn.heartbeatInterval
+	rn.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -202,7 +390,7 @@ func (rn *RaftNode) run(ctx context.Context) {
 		case <-rn.stopCh:
 			return
 		case <-ticker.C:
-			rn.handleElectionTimeout()
+			rn.tick()
 		case req := <-rn.appendEntriesCh:
 			rn.handleAppendEntries(req)
 		case req := <-rn.requestVoteCh:
@@ -211,165 +399,166 @@ func (rn *RaftNode) run(ctx context.Context) {
 	}
 }
 
-// handleElectionTimeout 处理选举超时
-func (rn *RaftNode) handleElectionTimeout() {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
-
-	if rn.State == Leader {
-		// Leader发送心跳
-		go rn.sendHeartbeat()
+// startElection 开始选举，调用方需持有锁
+// transfer 为真时是领导权转移发起的选举，其他节点不受Leader租约限制
+func (rn *RaftNode) startElection(transfer bool) {
+	if !rn.membership.IsVoter(rn.id) {
 		return
 	}
 
-	// 开始选举
-	rn.startElection()
-}
-
-// startElection 开始选举
-func (rn *RaftNode) startElection() {
-	rn.mu.Lock()
 	rn.State = Candidate
 	rn.term++
 	rn.votedFor = rn.id
-	currentTerm := rn.term
-	totalPeers := len(rn.peers) + 1 // 包括自己
-	rn.mu.Unlock()
+	rn.leaderID = ""
+	rn.votes = map[string]bool{rn.id: true}
+	rn.resetElectionTimeout()
+
+	// 投票给自己之前必须先持久化，否则重启后可能在同一任期再次投票
+	if err := rn.persistHardState(); err != nil {
+		log.Printf("节点 %s 持久化选举状态失败，放弃选举: %v", rn.id, err)
+		rn.State = Follower
+		return
+	}
 
-	log.Printf("节点 %s 开始选举，任期: %d", rn.id, currentTerm)
+	log.Printf("节点 %s 开始选举，任期: %d", rn.id, rn.term)
 
-	votes := int32(1) // 投票给自己，使用原子操作
+	if rn.hasQuorum(rn.voteCount(rn.votes)) {
+		rn.becomeLeader()
+		return
+	}
 
-	// 向所有peers请求投票
+	req := &RequestVoteRequest{
+		Term:               rn.term,
+		CandidateID:        rn.id,
+		LastLogIndex:       rn.getLastLogIndex(),
+		LastLogTerm:        rn.getLastLogTerm(),
+		LeadershipTransfer: transfer,
+	}
 	for peerID := range rn.peers {
-		go func(id string) {
-			if rn.requestVote(id) {
-				newVotes := atomic.AddInt32(&votes, 1)
-				rn.mu.Lock()
-				// 检查是否仍然是候选者且任期未变
-				if int(newVotes) > totalPeers/2 && rn.State == Candidate && rn.term == currentTerm {
-					rn.becomeLeader()
-				}
-				rn.mu.Unlock()
-			}
-		}(peerID)
+		if rn.membership.IsVoter(peerID) {
+			rn.send(peerID, "request_vote", req)
+		}
 	}
 }
 
-// becomeLeader 成为Leader
+// becomeLeader 成为Leader，调用方需持有锁
 func (rn *RaftNode) becomeLeader() {
 	rn.State = Leader
+	rn.leaderID = rn.id
 	log.Printf("节点 %s 成为Leader，任期: %d", rn.id, rn.term)
 
-	// 初始化Leader状态
+	// 初始化Leader状态，一个选举超时后开始检查多数节点是否可达
+	rn.lastAck = make(map[string]time.Time)
+	rn.electionDeadline = time.Now().Add(rn.electionTimeout)
+	rn.leadTransferee = ""
 	for peerID := range rn.peers {
 		rn.nextIndex[peerID] = rn.getLastLogIndex() + 1
 		rn.matchIndex[peerID] = 0
 	}
 
+	// 追加本任期的空条目：之前任期的条目随之尽快提交，成员变更也要求Leader已在本任期提交过条目
+	noop := LogEntry{
+		Term:      rn.term,
+		Index:     rn.getLastLogIndex() + 1,
+		Type:      types.LogEntryTypeNoop,
+		Timestamp: time.Now(),
+	}
+	if err := rn.appendLog(noop); err != nil {
+		log.Printf("Leader %s 写入空条目失败: %v", rn.id, err)
+	}
+	rn.advanceCommitIndex()
+
 	// 立即发送心跳
-	go rn.sendHeartbeat()
+	rn.broadcastAppendEntries()
 }
 
-// sendHeartbeat 发送心跳
-func (rn *RaftNode) sendHeartbeat() {
-	rn.mu.RLock()
-	defer rn.mu.RUnlock()
-
-	if rn.State != Leader {
-		return
+// stepDown 发现更高任期时转为Follower，调用方需持有锁
+func (rn *RaftNode) stepDown(term int64) {
+	// Leader和候选者退位后重新计时，Follower只在收到Leader消息或投出选票时重置
+	if rn.State != Follower {
+		rn.resetElectionTimeout()
+	}
+	rn.term = term
+	rn.State = Follower
+	rn.votedFor = ""
+	rn.leaderID = ""
+	rn.preVotes = nil
+	rn.leadTransferee = ""
+	if err := rn.persistHardState(); err != nil {
+		log.Printf("节点 %s 持久化任期失败: %v", rn.id, err)
 	}
+}
 
-	for peerID := range rn.peers {
-		go rn.sendAppendEntriesToPeer(peerID, []LogEntry{})
-	}
+// hasQuorum 判断票数是否达到投票成员的多数，Learner不计入
+func (rn *RaftNode) hasQuorum(count int) bool {
+	return count > len(rn.membership.Voters)/2
 }
 
-// sendAppendEntries 发送追加条目
-func (rn *RaftNode) sendAppendEntries() {
-	rn.mu.RLock()
-	defer rn.mu.RUnlock()
+// voteCount 统计节点集合中的投票成员数
+func (rn *RaftNode) voteCount(nodes map[string]bool) int {
+	count := 0
+	for id := range nodes {
+		if rn.membership.IsVoter(id) {
+			count++
+		}
+	}
+	return count
+}
 
+// broadcastAppendEntries 向所有peers发送追加条目（无新条目时即心跳），调用方需持有锁
+func (rn *RaftNode) broadcastAppendEntries() {
 	if rn.State != Leader {
 		return
 	}
 
 	for peerID := range rn.peers {
-		nextIdx := rn.nextIndex[peerID]
-		if nextIdx <= rn.getLastLogIndex() {
-			entries := rn.log[nextIdx:]
-			go rn.sendAppendEntriesToPeer(peerID, entries)
-		}
+		rn.sendAppendEntriesTo(peerID)
 	}
 }
 
-// sendAppendEntriesToPeer 向指定节点发送追加条目
-func (rn *RaftNode) sendAppendEntriesToPeer(peerID string, entries []LogEntry) {
-	if rn.p2pNetwork == nil {
-		log.Printf("P2P网络未初始化，无法发送追加条目到节点 %s", peerID)
+// sendAppendEntriesTo 向指定节点发送从其 nextIndex 开始的日志条目，调用方需持有锁
+// 需要的条目已被快照压缩时改为发送快照
+func (rn *RaftNode) sendAppendEntriesTo(peerID string) {
+	nextIdx := min(rn.nextIndex[peerID], rn.getLastLogIndex()+1)
+	if nextIdx < 1 {
+		nextIdx = 1
+	}
+	if nextIdx <= rn.snapshotIndex {
+		rn.sendSnapshotTo(peerID)
 		return
 	}
+	prevIndex := nextIdx - 1
+
+	end := min(rn.getLastLogIndex(), prevIndex+maxAppendEntries)
+	entries := make([]LogEntry, 0, end-prevIndex)
+	entries = append(entries, rn.log[rn.logPosition(nextIdx):rn.logPosition(end)+1]...)
 
-	// 构造追加条目请求
 	req := &AppendEntriesRequest{
 		Term:         rn.term,
 		LeaderID:     rn.id,
-		PrevLogIndex: rn.getPrevLogIndex(peerID),
-		PrevLogTerm:  rn.getPrevLogTerm(peerID),
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  rn.termAt(prevIndex),
 		Entries:      entries,
 		LeaderCommit: rn.commitIndex,
 	}
-
-	// 发送消息
-	if err := rn.p2pNetwork.SendMessage(peerID, network.MessageTypeConsensus, map[string]interface{}{
-		"type": "append_entries",
-		"data": req,
-	}); err != nil {
-		log.Printf("发送追加条目到节点 %s 失败: %v", peerID, err)
-		// 处理发送失败，可能需要重试或调整nextIndex
-		if nextIndex, exists := rn.nextIndex[peerID]; exists && nextIndex > 1 {
-			rn.nextIndex[peerID] = nextIndex - 1
-		}
-		return
-	}
-
-	log.Printf("向节点 %s 发送追加条目，条目数量: %d", peerID, len(entries))
+	rn.send(peerID, "append_entries", req)
 }
 
-// requestVote 向指定节点请求投票
-func (rn *RaftNode) requestVote(peerID string) bool {
-	if rn.p2pNetwork == nil {
-		log.Printf("P2P网络未初始化，无法向节点 %s 请求投票", peerID)
-		return false
-	}
-
-	// 获取当前任期和日志信息
-	rn.mu.RLock()
-	currentTerm := rn.term
-	lastLogIndex := rn.getLastLogIndex()
-	lastLogTerm := rn.getLastLogTerm()
-	candidateID := rn.id
-	rn.mu.RUnlock()
-
-	// 构造投票请求
-	req := &RequestVoteRequest{
-		Term:         currentTerm,
-		CandidateID:  candidateID,
-		LastLogIndex: lastLogIndex,
-		LastLogTerm:  lastLogTerm,
-	}
-
-	// 发送消息
-	if err := rn.p2pNetwork.SendMessage(peerID, network.MessageTypeConsensus, map[string]interface{}{
-		"type": "request_vote",
-		"data": req,
-	}); err != nil {
-		log.Printf("向节点 %s 请求投票失败: %v", peerID, err)
-		return false
+// send 异步发送Raft消息，避免在持有锁时等待网络或重入消息处理
+func (rn *RaftNode) send(peerID, msgType string, data interface{}) {
+	if rn.transport == nil {
+		log.Printf("P2P网络未初始化，无法向节点 %s 发送 %s", peerID, msgType)
+		return
 	}
 
-	log.Printf("向节点 %s 请求投票", peerID)
-	return true // 这里应该等待响应，简化实现先返回true
+	go func() {
+		if err := rn.transport.SendMessage(peerID, network.MessageTypeConsensus, map[string]interface{}{
+			"type": msgType,
+			"data": data,
+		}); err != nil {
+			log.Printf("向节点 %s 发送 %s 失败: %v", peerID, msgType, err)
+		}
+	}()
 }
 
 // handleAppendEntries 处理追加条目请求
@@ -377,85 +566,154 @@ func (rn *RaftNode) handleAppendEntries(req *AppendEntriesRequest) {
 	rn.mu.Lock()
 	defer rn.mu.Unlock()
 
+	resp := &AppendEntriesResponse{PeerID: rn.id, Term: rn.term}
+
 	// 如果请求的任期小于当前任期，拒绝
 	if req.Term < rn.term {
+		rn.send(req.LeaderID, "append_entries_response", resp)
 		return
 	}
 
-	// 如果请求的任期大于当前任期，更新任期并成为follower
+	// 如果请求的任期大于当前任期，更新任期；同任期的候选者承认新Leader
 	if req.Term > rn.term {
-		rn.term = req.Term
-		rn.votedFor = ""
-		rn.State = Follower
+		rn.stepDown(req.Term)
+	}
+	rn.State = Follower
+	rn.leaderID = req.LeaderID
+	resp.Term = rn.term
+	if err := rn.persistHardState(); err != nil {
+		log.Printf("节点 %s 持久化任期失败: %v", rn.id, err)
+		return
 	}
 
 	// 重置选举超时
 	rn.resetElectionTimeout()
-
-	// 检查日志一致性
-	if req.PrevLogIndex > 0 {
-		// 检查前一个日志条目是否存在且任期匹配
-		if req.PrevLogIndex > rn.getLastLogIndex() ||
-			(req.PrevLogIndex > 0 && rn.log[req.PrevLogIndex-1].Term != req.PrevLogTerm) {
-			log.Printf("节点 %s 日志不一致，拒绝追加条目", rn.id)
+	rn.lastLeaderContact = time.Now()
+
+	// 快照包含的条目已提交，与Leader一致，跳过这部分条目
+	matchIndex := req.PrevLogIndex + int64(len(req.Entries))
+	if req.PrevLogIndex < rn.snapshotIndex {
+		if matchIndex <= rn.snapshotIndex {
+			resp.Success = true
+			resp.MatchIndex = matchIndex
+			rn.send(req.LeaderID, "append_entries_response", resp)
 			return
 		}
+		req.Entries = req.Entries[rn.snapshotIndex-req.PrevLogIndex:]
+		req.PrevLogIndex, req.PrevLogTerm = rn.snapshotIndex, rn.snapshotTerm
 	}
 
-	// 删除冲突的日志条目
-	if len(req.Entries) > 0 {
-		// 找到第一个冲突的条目
-		conflictIndex := -1
-		for i, entry := range req.Entries {
-			logIndex := req.PrevLogIndex + int64(i) + 1
-			if logIndex <= rn.getLastLogIndex() {
-				if rn.log[logIndex-1].Term != entry.Term {
-					conflictIndex = i
-					break
-				}
-			} else {
-				conflictIndex = i
-				break
-			}
-		}
-
-		// 如果有冲突，删除冲突及之后的所有条目
-		if conflictIndex >= 0 {
-			newLogLength := req.PrevLogIndex + int64(conflictIndex)
-			if newLogLength < int64(len(rn.log)) {
-				rn.log = rn.log[:newLogLength]
-			}
+	// 检查日志一致性：前一个日志条目必须存在且任期匹配
+	if req.PrevLogIndex > rn.getLastLogIndex() || rn.termAt(req.PrevLogIndex) != req.PrevLogTerm {
+		log.Printf("节点 %s 日志不一致，拒绝追加条目", rn.id)
+		resp.MatchIndex = min(rn.getLastLogIndex(), req.PrevLogIndex-1)
+		rn.send(req.LeaderID, "append_entries_response", resp)
+		return
+	}
 
-			// 追加新的条目
-			for i := conflictIndex; i < len(req.Entries); i++ {
-				rn.log = append(rn.log, req.Entries[i])
+	// 找到第一个冲突或缺少的条目，删除冲突及之后的所有条目后追加，落盘后才响应
+	for i, entry := range req.Entries {
+		logIndex := req.PrevLogIndex + int64(i) + 1
+		if logIndex <= rn.getLastLogIndex() && rn.termAt(logIndex) == entry.Term {
+			continue
+		}
+		if logIndex <= rn.getLastLogIndex() {
+			if err := rn.truncateLog(logIndex); err != nil {
+				log.Printf("节点 %s 删除冲突日志失败: %v", rn.id, err)
+				return
 			}
 		}
+		if err := rn.appendLog(req.Entries[i:]...); err != nil {
+			log.Printf("节点 %s 写入日志失败: %v", rn.id, err)
+			return
+		}
+		break
 	}
 
-	// 更新提交索引
+	// 更新提交索引，只能提交与Leader确认一致的部分
 	if req.LeaderCommit > rn.commitIndex {
-		rn.commitIndex = min(req.LeaderCommit, rn.getLastLogIndex())
+		rn.commitIndex = max(rn.commitIndex, min(req.LeaderCommit, matchIndex))
 		// 应用已提交的日志条目
 		rn.applyCommittedEntries()
 	}
 
-	log.Printf("节点 %s 成功处理来自Leader %s 的追加条目请求", rn.id, req.LeaderID)
+	resp.Success = true
+	resp.MatchIndex = matchIndex
+	rn.send(req.LeaderID, "append_entries_response", resp)
 }
 
-// resetElectionTimeout 重置选举超时
-func (rn *RaftNode) resetElectionTimeout() {
-	// 这里应该重置选举超时定时器
-	// 简化实现，实际应该有定时器管理
+// advanceCommitIndex Leader将多数节点已复制的当前任期条目标记为已提交，调用方需持有锁
+// 之前任期的条目随当前任期条目一起提交
+func (rn *RaftNode) advanceCommitIndex() {
+	if rn.State != Leader {
+		return
+	}
+
+	for n := rn.getLastLogIndex(); n > rn.commitIndex; n-- {
+		if rn.termAt(n) != rn.term {
+			break
+		}
+
+		replicated := map[string]bool{rn.id: true}
+		for peerID := range rn.peers {
+			if rn.matchIndex[peerID] >= n {
+				replicated[peerID] = true
+			}
+		}
+		if rn.hasQuorum(rn.voteCount(replicated)) {
+			rn.commitIndex = n
+			rn.applyCommittedEntries()
+			return
+		}
+	}
 }
 
-// applyCommittedEntries 应用已提交的日志条目
+// applyCommittedEntries 按顺序将已提交的日志条目应用到状态机，调用方需持有锁
 func (rn *RaftNode) applyCommittedEntries() {
 	for rn.lastApplied < rn.commitIndex {
 		rn.lastApplied++
-		entry := rn.log[rn.lastApplied-1]
-		log.Printf("应用日志条目 %d: %v", rn.lastApplied, entry.Command)
-		// 这里应该将命令应用到状态机
+		entry := rn.log[rn.logPosition(rn.lastApplied)]
+		if rn.stateMachine == nil || entry.Type != types.LogEntryTypeCommand {
+			continue
+		}
+		if err := rn.stateMachine.Apply(entry); err != nil {
+			log.Printf("节点 %s 应用日志条目 %d 失败: %v", rn.id, entry.Index, err)
+		}
+	}
+
+	rn.stepDownIfRemoved()
+	rn.maybeSnapshot()
+	rn.notifyCommitted()
+}
+
+// notifyCommitted 唤醒等待条目提交的调用方，调用方需持有锁
+func (rn *RaftNode) notifyCommitted() {
+	close(rn.committed)
+	rn.committed = make(chan struct{})
+}
+
+// WaitCommitted 等待索引为 index、任期为 term 的条目提交，ctx 结束时返回超时错误
+// 条目被其他任期的条目覆盖时返回错误，已压缩进快照的条目视为已提交
+func (rn *RaftNode) WaitCommitted(ctx context.Context, index, term int64) error {
+	for {
+		rn.mu.RLock()
+		committed := rn.commitIndex >= index
+		current := rn.termAt(index)
+		notify := rn.committed
+		rn.mu.RUnlock()
+
+		if current != 0 && current != term {
+			return fmt.Errorf("日志条目 %d 已被任期 %d 的条目覆盖", index, current)
+		}
+		if committed {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return fmt.Errorf("等待日志条目 %d 提交超时: %w", index, ctx.Err())
+		}
 	}
 }
 
@@ -472,45 +730,72 @@ func (rn *RaftNode) handleRequestVote(req *RequestVoteRequest) {
 	rn.mu.Lock()
 	defer rn.mu.Unlock()
 
-	// 如果请求的任期小于当前任期，拒绝投票
-	if req.Term < rn.term {
+	// 启用CheckQuorum时，Leader租约内的投票请求来自无法联系Leader的节点，不更新任期也不投票；领导权转移除外
+	if rn.checkQuorum && !req.LeadershipTransfer && req.Term > rn.term && rn.inLease() {
+		log.Printf("节点 %s 处于Leader %s 的租约内，拒绝候选人 %s 的投票请求", rn.id, rn.leaderID, req.CandidateID)
+		rn.send(req.CandidateID, "request_vote_response", &RequestVoteResponse{PeerID: rn.id, Term: rn.term})
 		return
 	}
 
 	// 如果请求的任期大于当前任期，更新任期
 	if req.Term > rn.term {
-		rn.term = req.Term
-		rn.votedFor = ""
-		rn.State = Follower
+		rn.stepDown(req.Term)
 	}
 
-	// 投票逻辑
-	if (rn.votedFor == "" || rn.votedFor == req.CandidateID) &&
+	resp := &RequestVoteResponse{PeerID: rn.id, Term: rn.term}
+
+	// 投票逻辑：任期不小于当前任期、本任期未投给他人且候选人日志不落后
+	if req.Term == rn.term &&
+		(rn.votedFor == "" || rn.votedFor == req.CandidateID) &&
 		rn.isLogUpToDate(req.LastLogIndex, req.LastLogTerm) {
 		rn.votedFor = req.CandidateID
+		resp.VoteGranted = true
+		rn.resetElectionTimeout()
+	}
+
+	// 任期和投票落盘后才能响应
+	if err := rn.persistHardState(); err != nil {
+		log.Printf("节点 %s 持久化投票失败: %v", rn.id, err)
+		return
+	}
+	if resp.VoteGranted {
 		log.Printf("节点 %s 投票给候选人 %s", rn.id, req.CandidateID)
 	}
+
+	rn.send(req.CandidateID, "request_vote_response", resp)
 }
 
-// isLogUpToDate 检查日志是否是最新的
+// isLogUpToDate 检查候选人的日志是否至少与本节点一样新
 func (rn *RaftNode) isLogUpToDate(lastLogIndex, lastLogTerm int64) bool {
-	if len(rn.log) == 0 {
-		return true
-	}
-
-	lastEntry := rn.log[len(rn.log)-1]
-	return lastLogTerm > lastEntry.Term ||
-		(lastLogTerm == lastEntry.Term && lastLogIndex >= lastEntry.Index)
+	lastTerm := rn.getLastLogTerm()
+	return lastLogTerm > lastTerm ||
+		(lastLogTerm == lastTerm && lastLogIndex >= rn.getLastLogIndex())
 }
 
 // getLastLogIndex 获取最后一个日志条目的索引
 func (rn *RaftNode) getLastLogIndex() int64 {
 	if len(rn.log) == 0 {
-		return 0
+		return rn.snapshotIndex
 	}
 	return rn.log[len(rn.log)-1].Index
 }
 
+// logPosition 指定索引的条目在 rn.log 中的位置
+func (rn *RaftNode) logPosition(index int64) int64 {
+	return index - rn.snapshotIndex - 1
+}
+
+// termAt 获取指定索引条目的任期，快照位置返回快照的任期，已压缩或不存在时返回0
+func (rn *RaftNode) termAt(index int64) int64 {
+	if index == rn.snapshotIndex {
+		return rn.snapshotTerm
+	}
+	if index < rn.snapshotIndex || index > rn.getLastLogIndex() {
+		return 0
+	}
+	return rn.log[rn.logPosition(index)].Term
+}
+
 // GetPeers 获取对等节点列表
 func (rn *RaftNode) GetPeers() map[string]*PeerConnection {
 	rn.mu.RLock()
@@ -533,49 +818,36 @@ func (rn *RaftNode) GetStatus() map[string]interface{} {
 	defer rn.mu.RUnlock()
 
 	return map[string]interface{}{
-		"id":           rn.id,
-		"state":        rn.getStateString(),
-		"term":         rn.term,
-		"voted_for":    rn.votedFor,
-		"log_length":   len(rn.log),
-		"commit_index": rn.commitIndex,
-		"last_applied": rn.lastApplied,
-		"peer_count":   len(rn.peers),
+		"id":             rn.id,
+		"state":          rn.getStateString(),
+		"term":           rn.term,
+		"voted_for":      rn.votedFor,
+		"leader":         rn.leaderID,
+		"log_length":     len(rn.log),
+		"snapshot_index": rn.snapshotIndex,
+		"commit_index":   rn.commitIndex,
+		"last_applied":   rn.lastApplied,
+		"peer_count":     len(rn.peers),
+		"voters":         len(rn.membership.Voters),
+		"learners":       len(rn.membership.Learners),
 	}
 }
 
 // getLastLogTerm 获取最后一个日志条目的任期
 func (rn *RaftNode) getLastLogTerm() int64 {
 	if len(rn.log) == 0 {
-		return 0
+		return rn.snapshotTerm
 	}
 	return rn.log[len(rn.log)-1].Term
 }
 
-// getPrevLogIndex 获取指定peer的前一个日志索引
-func (rn *RaftNode) getPrevLogIndex(peerID string) int64 {
-	if nextIndex, exists := rn.nextIndex[peerID]; exists {
-		return nextIndex - 1
-	}
-	return rn.getLastLogIndex()
-}
-
-// getPrevLogTerm 获取指定peer的前一个日志任期
-func (rn *RaftNode) getPrevLogTerm(peerID string) int64 {
-	prevIndex := rn.getPrevLogIndex(peerID)
-	if prevIndex < 0 || prevIndex >= int64(len(rn.log)) {
-		return 0
-	}
-	return rn.log[prevIndex].Term
-}
-
 // handleNetworkMessage 处理网络消息
 func (rn *RaftNode) handleNetworkMessage(peer *network.Peer, msg *network.Message) error {
 	// 输入验证
 	if peer == nil {
 		return fmt.Errorf("对等节点不能为空")
 	}
-	
+
 	if msg == nil {
 		return fmt.Errorf("消息不能为空")
 	}
@@ -596,7 +868,8 @@ func (rn *RaftNode) handleNetworkMessage(peer *network.Peer, msg *network.Messag
 	}
 
 	// 验证消息类型
-	validTypes := []string{"append_entries", "request_vote", "append_entries_response", "request_vote_response"}
+	validTypes := []string{"append_entries", "request_vote", "pre_vote", "install_snapshot", "timeout_now",
+		"append_entries_response", "request_vote_response", "pre_vote_response", "install_snapshot_response"}
 	isValidType := false
 	for _, validType := range validTypes {
 		if msgType == validType {
@@ -622,24 +895,39 @@ func (rn *RaftNode) handleNetworkMessage(peer *network.Peer, msg *network.Messag
 		return rn.handleAppendEntriesResponse(data["data"])
 	case "request_vote_response":
 		return rn.handleRequestVoteResponse(data["data"])
+	case "pre_vote":
+		return rn.handlePreVoteMessage(data["data"])
+	case "pre_vote_response":
+		return rn.handlePreVoteResponse(data["data"])
+	case "install_snapshot":
+		return rn.handleInstallSnapshotMessage(data["data"])
+	case "install_snapshot_response":
+		return rn.handleInstallSnapshotResponse(data["data"])
+	case "timeout_now":
+		return rn.handleTimeoutNowMessage(data["data"])
 	default:
 		return fmt.Errorf("未知的消息类型: %s", msgType)
 	}
 }
 
-// handleAppendEntriesMessage 处理追加条目消息
-func (rn *RaftNode) handleAppendEntriesMessage(data interface{}) error {
-	// 将interface{}转换为AppendEntriesRequest
+// decodeRaftMessage 将网络消息中的数据解码为Raft请求或响应
+func decodeRaftMessage(data interface{}, v interface{}) error {
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
-		log.Printf("序列化追加条目请求失败: %v", err)
-		return fmt.Errorf("序列化追加条目请求失败: %w", err)
+		return fmt.Errorf("序列化Raft消息失败: %w", err)
 	}
+	if err := json.Unmarshal(dataBytes, v); err != nil {
+		return fmt.Errorf("反序列化Raft消息失败: %w", err)
+	}
+	return nil
+}
 
+// handleAppendEntriesMessage 处理追加条目消息
+func (rn *RaftNode) handleAppendEntriesMessage(data interface{}) error {
 	var req AppendEntriesRequest
-	if err := json.Unmarshal(dataBytes, &req); err != nil {
-		log.Printf("反序列化追加条目请求失败: %v", err)
-		return fmt.Errorf("反序列化追加条目请求失败: %w", err)
+	if err := decodeRaftMessage(data, &req); err != nil {
+		log.Printf("解析追加条目请求失败: %v", err)
+		return err
 	}
 
 	rn.handleAppendEntries(&req)
@@ -648,17 +936,10 @@ func (rn *RaftNode) handleAppendEntriesMessage(data interface{}) error {
 
 // handleRequestVoteMessage 处理投票请求消息
 func (rn *RaftNode) handleRequestVoteMessage(data interface{}) error {
-	// 将interface{}转换为RequestVoteRequest
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("序列化投票请求失败: %v", err)
-		return fmt.Errorf("序列化投票请求失败: %w", err)
-	}
-
 	var req RequestVoteRequest
-	if err := json.Unmarshal(dataBytes, &req); err != nil {
-		log.Printf("反序列化投票请求失败: %v", err)
-		return fmt.Errorf("反序列化投票请求失败: %w", err)
+	if err := decodeRaftMessage(data, &req); err != nil {
+		log.Printf("解析投票请求失败: %v", err)
+		return err
 	}
 
 	rn.handleRequestVote(&req)
@@ -667,120 +948,94 @@ func (rn *RaftNode) handleRequestVoteMessage(data interface{}) error {
 
 // handleAppendEntriesResponse 处理追加条目响应
 func (rn *RaftNode) handleAppendEntriesResponse(data interface{}) error {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
-
-	// 只有领导者才处理追加条目响应
-	if rn.State != Leader {
-		return nil
+	var resp AppendEntriesResponse
+	if err := decodeRaftMessage(data, &resp); err != nil {
+		return err
 	}
-
-	// 解析响应数据
-	responseData, ok := data.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid append entries response format")
+	if resp.PeerID == "" {
+		return fmt.Errorf("追加条目响应缺少peer_id")
 	}
 
-	peerID, ok := responseData["peer_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing peer_id in response")
-	}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
 
-	term, ok := responseData["term"].(float64)
-	if !ok {
-		return fmt.Errorf("missing term in response")
+	// 如果响应的任期更大，转为跟随者
+	if resp.Term > rn.term {
+		rn.stepDown(resp.Term)
+		return nil
 	}
 
-	success, ok := responseData["success"].(bool)
-	if !ok {
-		return fmt.Errorf("missing success in response")
+	// 只有领导者才处理本任期的追加条目响应
+	if rn.State != Leader || resp.Term != rn.term {
+		return nil
 	}
-
-	// 如果响应的任期更大，转为跟随者
-	if int64(term) > rn.term {
-		rn.term = int64(term)
-		rn.State = Follower
-		rn.votedFor = ""
+	if _, exists := rn.peers[resp.PeerID]; !exists {
 		return nil
 	}
+	rn.lastAck[resp.PeerID] = time.Now()
 
-	// 更新匹配索引
-	if success {
-		// 成功时更新nextIndex和matchIndex
-		if nextIdx, exists := rn.nextIndex[peerID]; exists {
-			rn.matchIndex[peerID] = nextIdx - 1
-			rn.nextIndex[peerID] = nextIdx
+	if resp.Success {
+		// 成功时更新matchIndex和nextIndex，并尝试推进提交索引
+		if resp.MatchIndex > rn.matchIndex[resp.PeerID] {
+			rn.matchIndex[resp.PeerID] = resp.MatchIndex
 		}
+		rn.nextIndex[resp.PeerID] = rn.matchIndex[resp.PeerID] + 1
 
-		// 应用已提交的日志条目
-		rn.applyCommittedEntries()
-	} else {
-		// 失败时递减nextIndex
-		if nextIdx, exists := rn.nextIndex[peerID]; exists && nextIdx > 1 {
-			rn.nextIndex[peerID] = nextIdx - 1
+		// 领导权转移的目标追上日志后通知其发起选举
+		if resp.PeerID == rn.leadTransferee && rn.matchIndex[resp.PeerID] == rn.getLastLogIndex() {
+			rn.sendTimeoutNow(resp.PeerID)
+		}
+
+		commitIndex := rn.commitIndex
+		rn.advanceCommitIndex()
+		if rn.commitIndex > commitIndex {
+			// 通知Followers新的提交索引
+			rn.broadcastAppendEntries()
+		} else if rn.nextIndex[resp.PeerID] <= rn.getLastLogIndex() {
+			// 继续追赶落后的节点
+			rn.sendAppendEntriesTo(resp.PeerID)
 		}
+		return nil
 	}
 
-	log.Printf("处理来自节点 %s 的追加条目响应: term=%d, success=%v", peerID, int64(term), success)
+	// 失败时回退nextIndex后重试
+	rn.nextIndex[resp.PeerID] = max(1, min(rn.nextIndex[resp.PeerID]-1, resp.MatchIndex+1))
+	rn.sendAppendEntriesTo(resp.PeerID)
 	return nil
 }
 
 // handleRequestVoteResponse 处理投票响应
 func (rn *RaftNode) handleRequestVoteResponse(data interface{}) error {
-	rn.mu.Lock()
-	defer rn.mu.Unlock()
-
-	// 只有候选者才处理投票响应
-	if rn.State != Candidate {
-		return nil
+	var resp RequestVoteResponse
+	if err := decodeRaftMessage(data, &resp); err != nil {
+		return err
 	}
-
-	// 解析响应数据
-	responseData, ok := data.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid request vote response format")
+	if resp.PeerID == "" {
+		return fmt.Errorf("投票响应缺少peer_id")
 	}
 
-	peerID, ok := responseData["peer_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing peer_id in response")
-	}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
 
-	term, ok := responseData["term"].(float64)
-	if !ok {
-		return fmt.Errorf("missing term in response")
+	// 如果响应的任期更大，转为跟随者
+	if resp.Term > rn.term {
+		rn.stepDown(resp.Term)
+		return nil
 	}
 
-	voteGranted, ok := responseData["vote_granted"].(bool)
-	if !ok {
-		return fmt.Errorf("missing vote_granted in response")
+	// 只有候选者才处理本任期的投票响应
+	if rn.State != Candidate || resp.Term != rn.term || !resp.VoteGranted {
+		return nil
 	}
-
-	// 如果响应的任期更大，转为跟随者
-	if int64(term) > rn.term {
-		rn.term = int64(term)
-		rn.State = Follower
-		rn.votedFor = ""
+	if _, exists := rn.peers[resp.PeerID]; !exists {
 		return nil
 	}
 
-	// 如果获得投票，检查是否获得多数票
-	if voteGranted {
-		voteCount := 1 // 包括自己的票
-		for _, peer := range rn.peers {
-			if peer.Active {
-				voteCount++
-			}
-		}
-
-		// 如果获得多数票，成为领导者
-		majority := (len(rn.peers)+1)/2 + 1
-		if voteCount >= majority {
-			rn.becomeLeader()
-		}
+	// 获得多数票后成为领导者
+	rn.votes[resp.PeerID] = true
+	if rn.hasQuorum(rn.voteCount(rn.votes)) {
+		rn.becomeLeader()
 	}
-
-	log.Printf("处理来自节点 %s 的投票响应: term=%d, vote_granted=%v", peerID, int64(term), voteGranted)
 	return nil
 }
 
@@ -130,18 +130,7 @@ func (ra *RaftAdapter) GetLeader() string {
 	ra.mu.RLock()
 	defer ra.mu.RUnlock()
 
-	state, _, isLeader := ra.raftNode.GetState()
-	if isLeader {
-		return ra.raftNode.id
-	}
-
-	// 如果不是Leader，返回空字符串或已知的Leader ID
-	// 在实际实现中，可以维护Leader信息
-	if state == Follower {
-		return ra.raftNode.votedFor // 简化处理
-	}
-
-	return ""
+	return ra.raftNode.GetLeader()
 }
 
 // GetNodes 获取所有节点列表
@@ -251,8 +240,7 @@ func (ra *RaftAdapter) AddPeer(nodeID, address string) error {
 	ra.mu.Lock()
 	defer ra.mu.Unlock()
 
-	ra.raftNode.AddPeer(nodeID, address)
-	return nil
+	return ra.raftNode.AddPeer(nodeID, address)
 }
 
 // RemovePeer 移除对等节点
@@ -260,8 +248,7 @@ func (ra *RaftAdapter) RemovePeer(nodeID string) error {
 	ra.mu.Lock()
 	defer ra.mu.Unlock()
 
-	ra.raftNode.RemovePeer(nodeID)
-	return nil
+	return ra.raftNode.RemovePeer(nodeID)
 }
 
 // IsRunning 检查是否正在运行
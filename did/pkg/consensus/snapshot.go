@@ -0,0 +1,344 @@
+package consensus
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const walSnapshotFile = "snapshot"
+
+// Snapshot 状态机快照，包含到 Index（含）为止全部已提交条目的效果及该位置生效的成员配置
+type Snapshot struct {
+	Index      int64       `json:"index"`
+	Term       int64       `json:"term"`
+	Data       []byte      `json:"data"`
+	Membership *Membership `json:"membership,omitempty"`
+}
+
+// InstallSnapshotRequest 安装快照请求，Leader在Follower需要的条目已被压缩时发送
+type InstallSnapshotRequest struct {
+	Term              int64       `json:"term"`
+	LeaderID          string      `json:"leader_id"`
+	LastIncludedIndex int64       `json:"last_included_index"`
+	LastIncludedTerm  int64       `json:"last_included_term"`
+	Data              []byte      `json:"data"`
+	Membership        *Membership `json:"membership,omitempty"`
+}
+
+// InstallSnapshotResponse 安装快照响应，MatchIndex 为Follower安装后与Leader一致的最后索引
+type InstallSnapshotResponse struct {
+	PeerID     string `json:"peer_id"`
+	Term       int64  `json:"term"`
+	MatchIndex int64  `json:"match_index"`
+}
+
+// SaveSnapshot 持久化快照，替换之前的快照
+func (w *WAL) SaveSnapshot(snapshot *Snapshot) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+	if err := w.writeFile(walSnapshotFile, snapshot); err != nil {
+		return fmt.Errorf("写入Raft快照失败: %w", err)
+	}
+	return nil
+}
+
+// ReleaseTo 删除全部条目都不大于 index 的段，这些条目已包含在快照中
+func (w *WAL) ReleaseTo(index int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+
+	released := false
+	for len(w.segments) > 1 && w.segments[1].first <= index+1 {
+		if err := os.Remove(w.segments[0].path); err != nil {
+			return fmt.Errorf("删除WAL段失败: %w", err)
+		}
+		w.segments = w.segments[1:]
+		released = true
+	}
+	if !released {
+		return nil
+	}
+	return syncDir(w.dir)
+}
+
+// Reset 删除全部条目，之后从 index+1 开始追加，用于Follower安装了超过本地日志的快照
+func (w *WAL) Reset(index int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+	if err := w.reset(index); err != nil {
+		w.failed = fmt.Errorf("WAL已不可用: %w", err)
+		return err
+	}
+	return nil
+}
+
+func (w *WAL) reset(index int64) error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+
+	segments, err := w.listSegments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if err := os.Remove(segment.path); err != nil {
+			return fmt.Errorf("删除WAL段失败: %w", err)
+		}
+	}
+
+	w.segments = nil
+	w.lastIndex = index
+	return w.openTail()
+}
+
+// readSnapshot 读取持久化的快照，不存在时返回nil
+func (w *WAL) readSnapshot() (*Snapshot, error) {
+	var snapshot Snapshot
+	found, err := w.readFile(walSnapshotFile, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("读取Raft快照失败: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+// SetSnapshotPolicy 设置快照策略，应在 Start 之前调用
+// entries: 自上次快照后应用了多少条目时生成快照；maxEntries: 内存中日志条目的上限，超过时立即生成快照；
+// interval: 定期检查并为新应用的条目生成快照。取值为0表示不使用对应的条件
+func (rn *RaftNode) SetSnapshotPolicy(entries, maxEntries int64, interval time.Duration) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	rn.snapshotEntries = entries
+	rn.maxLogEntries = maxEntries
+	rn.snapshotInterval = interval
+}
+
+// maybeSnapshot 在满足快照策略时生成快照，调用方需持有锁
+func (rn *RaftNode) maybeSnapshot() {
+	applied := rn.lastApplied - rn.snapshotIndex
+	if applied <= 0 {
+		return
+	}
+	if (rn.snapshotEntries > 0 && applied >= rn.snapshotEntries) ||
+		(rn.maxLogEntries > 0 && int64(len(rn.log)) > rn.maxLogEntries) {
+		rn.takeSnapshot()
+	}
+}
+
+// takeSnapshot 为已应用的状态生成快照并压缩日志，调用方需持有锁
+// 状态机只在持有锁时应用条目，因此快照恰好对应 lastApplied
+func (rn *RaftNode) takeSnapshot() {
+	if rn.stateMachine == nil || rn.lastApplied <= rn.snapshotIndex {
+		return
+	}
+
+	data, err := rn.stateMachine.Snapshot()
+	if err != nil {
+		log.Printf("节点 %s 生成快照失败: %v", rn.id, err)
+		return
+	}
+	membership, _ := rn.membershipAt(rn.lastApplied)
+	snapshot := &Snapshot{Index: rn.lastApplied, Term: rn.termAt(rn.lastApplied), Data: data, Membership: membership}
+
+	if rn.wal != nil {
+		if err := rn.wal.SaveSnapshot(snapshot); err != nil {
+			log.Printf("节点 %s 保存快照失败: %v", rn.id, err)
+			return
+		}
+	}
+	rn.compactLog(snapshot)
+
+	if rn.wal != nil {
+		if err := rn.wal.ReleaseTo(snapshot.Index); err != nil {
+			log.Printf("节点 %s 清理WAL失败: %v", rn.id, err)
+		}
+	}
+	log.Printf("节点 %s 生成快照: 索引=%d, 任期=%d, 剩余日志条目=%d", rn.id, snapshot.Index, snapshot.Term, len(rn.log))
+}
+
+// compactLog 丢弃快照已包含的日志条目，调用方需持有锁
+func (rn *RaftNode) compactLog(snapshot *Snapshot) {
+	if snapshot.Index <= rn.getLastLogIndex() && rn.termAt(snapshot.Index) == snapshot.Term {
+		// 保留快照之后的条目，复制到新的切片以释放旧条目
+		rn.log = append([]LogEntry(nil), rn.log[rn.logPosition(snapshot.Index)+1:]...)
+	} else {
+		rn.log = make([]LogEntry, 0)
+	}
+
+	rn.snapshot = snapshot
+	rn.snapshotIndex = snapshot.Index
+	rn.snapshotTerm = snapshot.Term
+	if snapshot.Membership != nil {
+		rn.baseMembership = snapshot.Membership.clone()
+	}
+	rn.rebuildMembership()
+}
+
+// runSnapshotTimer 定期为新应用的条目生成快照
+func (rn *RaftNode) runSnapshotTimer(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			rn.mu.Lock()
+			rn.takeSnapshot()
+			rn.mu.Unlock()
+		}
+	}
+}
+
+// sendSnapshotTo 向需要的条目已被压缩的节点发送快照，调用方需持有锁
+func (rn *RaftNode) sendSnapshotTo(peerID string) {
+	if rn.snapshot == nil {
+		return
+	}
+
+	req := &InstallSnapshotRequest{
+		Term:              rn.term,
+		LeaderID:          rn.id,
+		LastIncludedIndex: rn.snapshot.Index,
+		LastIncludedTerm:  rn.snapshot.Term,
+		Data:              rn.snapshot.Data,
+		Membership:        rn.snapshot.Membership,
+	}
+	log.Printf("Leader %s 向节点 %s 发送快照: 索引=%d", rn.id, peerID, req.LastIncludedIndex)
+	rn.send(peerID, "install_snapshot", req)
+}
+
+// handleInstallSnapshot 处理安装快照请求
+func (rn *RaftNode) handleInstallSnapshot(req *InstallSnapshotRequest) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	resp := &InstallSnapshotResponse{PeerID: rn.id, Term: rn.term}
+	if req.Term < rn.term {
+		rn.send(req.LeaderID, "install_snapshot_response", resp)
+		return
+	}
+
+	if req.Term > rn.term {
+		rn.stepDown(req.Term)
+	}
+	rn.State = Follower
+	rn.leaderID = req.LeaderID
+	resp.Term = rn.term
+	if err := rn.persistHardState(); err != nil {
+		log.Printf("节点 %s 持久化任期失败: %v", rn.id, err)
+		return
+	}
+	rn.resetElectionTimeout()
+	rn.lastLeaderContact = time.Now()
+
+	// 已应用到快照位置时无需安装
+	if req.LastIncludedIndex <= rn.lastApplied {
+		resp.MatchIndex = req.LastIncludedIndex
+		rn.send(req.LeaderID, "install_snapshot_response", resp)
+		return
+	}
+
+	if rn.stateMachine != nil {
+		if err := rn.stateMachine.Restore(req.Data); err != nil {
+			log.Printf("节点 %s 恢复快照失败: %v", rn.id, err)
+			return
+		}
+	}
+
+	snapshot := &Snapshot{Index: req.LastIncludedIndex, Term: req.LastIncludedTerm, Data: req.Data, Membership: req.Membership}
+	keepTail := snapshot.Index <= rn.getLastLogIndex() && rn.termAt(snapshot.Index) == snapshot.Term
+	if rn.wal != nil {
+		if err := rn.wal.SaveSnapshot(snapshot); err != nil {
+			log.Printf("节点 %s 保存快照失败: %v", rn.id, err)
+			return
+		}
+		// 快照之后的条目与Leader一致时保留，否则整个日志作废
+		var err error
+		if keepTail {
+			err = rn.wal.ReleaseTo(snapshot.Index)
+		} else {
+			err = rn.wal.Reset(snapshot.Index)
+		}
+		if err != nil {
+			log.Printf("节点 %s 清理WAL失败: %v", rn.id, err)
+			return
+		}
+	}
+	rn.compactLog(snapshot)
+	rn.commitIndex = max(rn.commitIndex, snapshot.Index)
+	rn.lastApplied = snapshot.Index
+	rn.notifyCommitted()
+
+	log.Printf("节点 %s 安装来自Leader %s 的快照: 索引=%d, 任期=%d", rn.id, req.LeaderID, snapshot.Index, snapshot.Term)
+	resp.MatchIndex = snapshot.Index
+	rn.send(req.LeaderID, "install_snapshot_response", resp)
+}
+
+// handleInstallSnapshotMessage 处理安装快照消息
+func (rn *RaftNode) handleInstallSnapshotMessage(data interface{}) error {
+	var req InstallSnapshotRequest
+	if err := decodeRaftMessage(data, &req); err != nil {
+		log.Printf("解析安装快照请求失败: %v", err)
+		return err
+	}
+
+	rn.handleInstallSnapshot(&req)
+	return nil
+}
+
+// handleInstallSnapshotResponse 处理安装快照响应
+func (rn *RaftNode) handleInstallSnapshotResponse(data interface{}) error {
+	var resp InstallSnapshotResponse
+	if err := decodeRaftMessage(data, &resp); err != nil {
+		return err
+	}
+	if resp.PeerID == "" {
+		return fmt.Errorf("安装快照响应缺少peer_id")
+	}
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	if resp.Term > rn.term {
+		rn.stepDown(resp.Term)
+		return nil
+	}
+	if rn.State != Leader || resp.Term != rn.term {
+		return nil
+	}
+	if _, exists := rn.peers[resp.PeerID]; !exists {
+		return nil
+	}
+	rn.lastAck[resp.PeerID] = time.Now()
+
+	if resp.MatchIndex > rn.matchIndex[resp.PeerID] {
+		rn.matchIndex[resp.PeerID] = resp.MatchIndex
+	}
+	rn.nextIndex[resp.PeerID] = rn.matchIndex[resp.PeerID] + 1
+	rn.advanceCommitIndex()
+	if rn.nextIndex[resp.PeerID] <= rn.getLastLogIndex() {
+		rn.sendAppendEntriesTo(resp.PeerID)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/config"
+)
+
+// TestSnapshotCompactionAndInstall Leader压缩日志后，新加入的Follower通过InstallSnapshot追上，重启的节点从快照恢复
+func TestSnapshotCompactionAndInstall(t *testing.T) {
+	dir := t.TempDir()
+
+	c := newReplicatedCluster("node1", "node2", "node3")
+	leader := c.nodes[0]
+	leader.SetDataDir(dir)
+	leader.SetSnapshotPolicy(2, 0, 0)
+	if err := leader.recover(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+
+	// node3 暂不在线，node1 和 node2 构成多数
+	c.connect(0)
+	c.connect(1)
+	// 当选后的空条目占用索引1，之后的注册占用索引2-6
+	c.elect(t, 0)
+	for i := 0; i < 5; i++ {
+		c.register(t, 0)
+	}
+
+	leader.mu.RLock()
+	snapshotIndex, logLength := leader.snapshotIndex, len(leader.log)
+	leader.mu.RUnlock()
+	if snapshotIndex != 6 || logLength != 0 {
+		t.Fatalf("Leader应已压缩日志: 快照索引=%d, 日志条目=%d", snapshotIndex, logLength)
+	}
+	c.waitConverged(t, 0, c.registries[1], "node2")
+
+	// node3 需要的条目已被压缩，只能通过快照追上
+	c.connect(2)
+	leader.mu.Lock()
+	leader.broadcastAppendEntries()
+	leader.mu.Unlock()
+	c.waitConverged(t, 0, c.registries[2], "node3")
+
+	c.nodes[2].mu.RLock()
+	installed := c.nodes[2].snapshotIndex
+	c.nodes[2].mu.RUnlock()
+	if installed != 6 {
+		t.Errorf("node3 应安装快照: 快照索引=%d", installed)
+	}
+
+	// 快照之后的条目继续通过日志复制
+	c.register(t, 0)
+	c.waitConverged(t, 0, c.registries[2], "node3")
+
+	// 重启后从快照和WAL恢复
+	leader.Stop()
+	registry := did.NewDIDRegistry(nil)
+	restarted := NewRaftNodeWithTransport("node1", nil)
+	NewConsensusIntegration("node1", restarted, registry, nil, &config.ConsensusConfig{ProposalTimeout: time.Second})
+	restarted.SetDataDir(dir)
+	if err := restarted.recover(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if restarted.snapshotIndex != 6 || restarted.getLastLogIndex() != 7 {
+		t.Fatalf("恢复的日志不正确: 快照索引=%d, 最后索引=%d", restarted.snapshotIndex, restarted.getLastLogIndex())
+	}
+	docs, err := registry.List()
+	if err != nil || len(docs) != 5 {
+		t.Fatalf("应从快照恢复5个DID: %d, %v", len(docs), err)
+	}
+	restarted.Stop()
+}
@@ -0,0 +1,92 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// StateMachine 由Raft驱动的复制状态机
+// 每个节点按日志顺序对已提交条目调用 Apply，相同的日志在所有节点上得到相同的状态；
+// Apply 返回的错误表示该条目被确定性地拒绝（例如重复注册），不会中断后续条目的应用
+type StateMachine interface {
+	Apply(entry LogEntry) error
+	Snapshot() ([]byte, error)
+	Restore(snapshot []byte) error
+}
+
+// proposalCommand 日志条目中的提案，Leader本地保存的是 *Proposal，经网络复制后为JSON对象
+type proposalCommand struct {
+	ID   string          `json:"id"`
+	Type ProposalType    `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// decodeProposalCommand 解码日志条目中的提案
+func decodeProposalCommand(command interface{}) (*proposalCommand, error) {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, fmt.Errorf("序列化日志命令失败: %w", err)
+	}
+
+	var cmd proposalCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, fmt.Errorf("解析日志命令失败: %w", err)
+	}
+	return &cmd, nil
+}
+
+// entryPosition 日志条目的位置，作为DID操作的提交回执
+func entryPosition(term, index int64) string {
+	return fmt.Sprintf("raft-%d-%d", term, index)
+}
+
+// DIDStateMachine 将已提交的DID提案应用到注册表
+type DIDStateMachine struct {
+	registry *did.DIDRegistry
+}
+
+// NewDIDStateMachine 创建DID状态机
+func NewDIDStateMachine(registry *did.DIDRegistry) *DIDStateMachine {
+	return &DIDStateMachine{registry: registry}
+}
+
+// Apply 解码条目中的 types.DIDOperation 并写入注册表，非DID提案被忽略
+func (sm *DIDStateMachine) Apply(entry LogEntry) error {
+	cmd, err := decodeProposalCommand(entry.Command)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.Type {
+	case ProposalTypeDIDCreate, ProposalTypeDIDUpdate, ProposalTypeDIDDeactivate:
+	default:
+		return nil
+	}
+
+	var op types.DIDOperation
+	if err := json.Unmarshal(cmd.Data, &op); err != nil {
+		return fmt.Errorf("解析DID操作失败: %w", err)
+	}
+	return sm.registry.ApplyOperation(&op, entryPosition(entry.Term, entry.Index))
+}
+
+// Snapshot 导出注册表中的全部DID文档和历史版本
+func (sm *DIDStateMachine) Snapshot() ([]byte, error) {
+	state, err := sm.registry.ExportState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// Restore 将注册表快进到快照状态
+func (sm *DIDStateMachine) Restore(snapshot []byte) error {
+	var state did.RegistryState
+	if err := json.Unmarshal(snapshot, &state); err != nil {
+		return fmt.Errorf("解析状态机快照失败: %w", err)
+	}
+	return sm.registry.RestoreState(&state)
+}
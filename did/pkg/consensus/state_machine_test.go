@@ -0,0 +1,356 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/config"
+	"github.com/qujing226/QLink/pkg/network"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// memoryNetwork 进程内的消息网络，消息经JSON往返后异步投递，与P2P网络的行为一致
+type memoryNetwork struct {
+	mu       sync.RWMutex
+	handlers map[string]network.MessageHandler
+}
+
+type memoryTransport struct {
+	id      string
+	network *memoryNetwork
+}
+
+func (t *memoryTransport) SendMessage(peerID string, msgType network.MessageType, data interface{}) error {
+	t.network.mu.RLock()
+	handler := t.network.handlers[peerID]
+	connected := t.network.handlers[t.id] != nil
+	t.network.mu.RUnlock()
+	if handler == nil || !connected {
+		return fmt.Errorf("节点 %s 不可达", peerID)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return err
+	}
+
+	go handler(&network.Peer{ID: t.id}, &network.Message{Type: msgType, From: t.id, To: peerID, Data: decoded})
+	return nil
+}
+
+func (t *memoryTransport) RegisterMessageHandler(msgType network.MessageType, handler network.MessageHandler) {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	t.network.handlers[t.id] = handler
+}
+
+// replicatedCluster 通过进程内网络连接的Raft节点，每个节点的注册表以 replicated 模式写入
+type replicatedCluster struct {
+	network    *memoryNetwork
+	ids        []string
+	nodes      []*RaftNode
+	registries []*did.DIDRegistry
+	transports []*memoryTransport
+}
+
+func newReplicatedCluster(ids ...string) *replicatedCluster {
+	c := &replicatedCluster{network: &memoryNetwork{handlers: make(map[string]network.MessageHandler)}}
+	for _, id := range ids {
+		c.addNode(id, ids)
+	}
+	return c
+}
+
+// addNode 创建节点，members 为其初始成员
+func (c *replicatedCluster) addNode(id string, members []string) *RaftNode {
+	transport := &memoryTransport{id: id, network: c.network}
+	node := NewRaftNodeWithTransport(id, transport)
+	for _, peer := range members {
+		if peer != id {
+			node.AddPeer(peer, peer)
+		}
+	}
+
+	cfg := &config.ConsensusConfig{ProposalTimeout: 5 * time.Second, MaxPendingProposals: 10}
+	registry := did.NewDIDRegistry(nil)
+	registry.SetCommitter(NewConsensusIntegration(id, node, registry, nil, cfg))
+	if err := registry.SetCommitMode(did.CommitModeReplicated); err != nil {
+		panic(err)
+	}
+
+	c.ids = append(c.ids, id)
+	c.nodes = append(c.nodes, node)
+	c.registries = append(c.registries, registry)
+	c.transports = append(c.transports, transport)
+	return node
+}
+
+// start 以较短的超时启动节点，由选举定时器选出Leader
+func (c *replicatedCluster) start(t *testing.T, ctx context.Context, i int) {
+	node := c.nodes[i]
+	node.mu.Lock()
+	node.electionTimeout = 100 * time.Millisecond
+	node.heartbeatInterval = 20 * time.Millisecond
+	node.mu.Unlock()
+	if err := node.Start(ctx); err != nil {
+		t.Fatalf("启动节点失败: %v", err)
+	}
+	t.Cleanup(func() { node.Stop() })
+}
+
+// connect 让节点开始接收消息
+func (c *replicatedCluster) connect(i int) {
+	c.transports[i].RegisterMessageHandler(network.MessageTypeConsensus, c.nodes[i].handleNetworkMessage)
+}
+
+// disconnect 断开节点，发往该节点和由该节点发出的消息都会丢失
+func (c *replicatedCluster) disconnect(i int) {
+	c.transports[i].network.mu.Lock()
+	defer c.transports[i].network.mu.Unlock()
+	delete(c.transports[i].network.handlers, c.ids[i])
+}
+
+// elect 不启动选举定时器，由指定节点发起一次选举
+func (c *replicatedCluster) elect(t *testing.T, i int) {
+	c.nodes[i].mu.Lock()
+	c.nodes[i].startElection(false)
+	c.nodes[i].mu.Unlock()
+	waitFor(t, c.ids[i]+"成为Leader", func() bool {
+		_, _, isLeader := c.nodes[i].GetState()
+		return isLeader
+	})
+}
+
+// register 通过指定节点注册一个新DID
+func (c *replicatedCluster) register(t *testing.T, i int) (*did.DIDDocumentBuilder, *did.RegisterRequest) {
+	t.Helper()
+	builder, err := did.NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	req, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	if _, err := c.registries[i].Register(req); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	return builder, req
+}
+
+// waitConverged 等待注册表与Leader的文档和历史一致
+func (c *replicatedCluster) waitConverged(t *testing.T, leader int, registry *did.DIDRegistry, name string) {
+	t.Helper()
+	want, err := c.registries[leader].ExportState()
+	if err != nil {
+		t.Fatalf("导出状态失败: %v", err)
+	}
+	waitFor(t, name+" 应用全部操作", func() bool {
+		got, err := registry.ExportState()
+		if err != nil || len(got.Documents) != len(want.Documents) {
+			return false
+		}
+		for didStr, history := range want.History {
+			if len(got.History[didStr]) != len(history) ||
+				got.History[didStr][len(history)-1].Hash != history[len(history)-1].Hash {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// TestDIDStateMachineReplication 三节点集群中经Raft提交的DID操作在所有节点的注册表上一致
+func TestDIDStateMachineReplication(t *testing.T) {
+	c := newReplicatedCluster("node1", "node2", "node3")
+	ids, registries := c.ids, c.registries
+	for i := range ids {
+		c.connect(i)
+	}
+
+	c.elect(t, 0)
+
+	// Follower不能提交DID操作
+	builder, err := did.NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	regReq, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	if _, err := registries[1].Register(regReq); err == nil {
+		t.Fatal("Follower上的注册应失败")
+	}
+	if _, err := registries[1].Resolve(regReq.DID); err == nil {
+		t.Fatal("失败的注册不应写入本地存储")
+	}
+
+	// 通过Leader注册和更新DID
+	if _, err := registries[0].Register(regReq); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	updateReq, err := builder.CreateUpdateRequest([]types.Service{{
+		ID: regReq.DID + "#web", Type: did.ServiceTypeLinkedDomains, ServiceEndpoint: "https://example.com",
+	}})
+	if err != nil {
+		t.Fatalf("创建更新请求失败: %v", err)
+	}
+	if _, err := registries[0].Update(updateReq); err != nil {
+		t.Fatalf("更新DID失败: %v", err)
+	}
+
+	c.register(t, 0)
+
+	// 所有节点收敛到相同的DID集合和历史
+	want, err := registries[0].ExportState()
+	if err != nil {
+		t.Fatalf("导出状态失败: %v", err)
+	}
+	if len(want.Documents) != 2 || len(want.History[regReq.DID]) != 2 {
+		t.Fatalf("Leader状态不正确: %d 个文档, %d 个版本", len(want.Documents), len(want.History[regReq.DID]))
+	}
+	for i, registry := range registries[1:] {
+		c.waitConverged(t, 0, registry, ids[i+1])
+
+		doc, err := registry.Resolve(regReq.DID)
+		if err != nil || len(doc.Service) != 1 {
+			t.Errorf("%s 上的DID文档不正确: %+v, %v", ids[i+1], doc, err)
+		}
+	}
+
+	// 快照可以恢复落后的注册表
+	snapshot, err := NewDIDStateMachine(registries[0]).Snapshot()
+	if err != nil {
+		t.Fatalf("导出快照失败: %v", err)
+	}
+	fresh := did.NewDIDRegistry(nil)
+	if err := NewDIDStateMachine(fresh).Restore(snapshot); err != nil {
+		t.Fatalf("恢复快照失败: %v", err)
+	}
+	if err := fresh.VerifyHistory(regReq.DID); err != nil {
+		t.Errorf("恢复后的历史应完整: %v", err)
+	}
+}
+
+// TestCommitPendingUntilApplied 等待确认超时的操作仍在Raft日志中，注册表返回结果未知并保持待确认状态，条目提交后解除
+func TestCommitPendingUntilApplied(t *testing.T) {
+	c := newReplicatedCluster("node1", "node2", "node3")
+	for i := range c.ids {
+		c.connect(i)
+	}
+	c.elect(t, 0)
+
+	// Follower不可达时条目无法提交
+	c.disconnect(1)
+	c.disconnect(2)
+	c.registries[0].SetConfirmTimeout(100 * time.Millisecond)
+
+	builder, err := did.NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	req, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	var didErr *did.DIDError
+	if _, err := c.registries[0].Register(req); !errors.As(err, &didErr) || didErr.Code != "COMMIT_PENDING" {
+		t.Fatalf("等待确认超时应返回结果未知: %v", err)
+	}
+	if _, err := c.registries[0].Register(req); !errors.As(err, &didErr) || didErr.Code != "DID_OPERATION_PENDING" {
+		t.Fatalf("结果确定前应拒绝同一DID的新操作: %v", err)
+	}
+
+	// Follower恢复后Leader复制条目，操作在所有节点应用，待确认状态随之解除
+	c.connect(1)
+	c.connect(2)
+	c.nodes[0].mu.Lock()
+	c.nodes[0].broadcastAppendEntries()
+	c.nodes[0].mu.Unlock()
+	waitFor(t, "条目提交并应用", func() bool {
+		_, err := c.registries[0].Resolve(req.DID)
+		return err == nil
+	})
+	waitFor(t, "待确认状态解除", func() bool {
+		_, err := c.registries[0].Register(req)
+		return errors.As(err, &didErr) && didErr.Code != "DID_OPERATION_PENDING"
+	})
+	c.waitConverged(t, 0, c.registries[1], c.ids[1])
+}
+
+// TestConsensusManagerAppliesDIDOperations 共识管理器为注册表创建共识集成器，DID操作经Raft提交后写入注册表
+func TestConsensusManagerAppliesDIDOperations(t *testing.T) {
+	registry := did.NewDIDRegistry(nil)
+	manager := NewConsensusManager(&ManagerConfig{
+		NodeID:           "node1",
+		DefaultConsensus: ConsensusTypeRaft,
+		RaftConfig: &config.RaftConfig{
+			ElectionTimeout:  100 * time.Millisecond,
+			HeartbeatTimeout: 20 * time.Millisecond,
+			DataDir:          t.TempDir(),
+		},
+	}, nil)
+	if manager.DIDCommitter() != nil {
+		t.Fatal("初始化前不应有提交器")
+	}
+	manager.SetDIDRegistry(registry)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("初始化共识管理器失败: %v", err)
+	}
+	if err := registry.SetCommitter(manager.DIDCommitter()); err != nil {
+		t.Fatalf("设置提交器失败: %v", err)
+	}
+	if err := registry.SetCommitMode(did.CommitModeReplicated); err != nil {
+		t.Fatalf("共识集成器应可用于replicated模式: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("启动共识管理器失败: %v", err)
+	}
+	defer manager.Stop()
+	waitFor(t, "单节点成为Leader", func() bool {
+		_, _, isLeader := manager.raftNode.GetState()
+		return isLeader
+	})
+
+	builder, err := did.NewDIDDocumentBuilder()
+	if err != nil {
+		t.Fatalf("创建文档构建器失败: %v", err)
+	}
+	req, err := builder.CreateRegistrationRequest()
+	if err != nil {
+		t.Fatalf("创建注册请求失败: %v", err)
+	}
+	if _, err := registry.Register(req); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	history, err := registry.History(req.DID)
+	if err != nil || len(history) != 1 || history[0].TxHash == "" {
+		t.Fatalf("DID应由Raft状态机写入并记录日志位置: %+v, %v", history, err)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("等待超时: %s", what)
+}
@@ -0,0 +1,528 @@
+package consensus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultSegmentSize WAL段文件的默认大小上限，超过后滚动到新段
+	DefaultSegmentSize = 16 * 1024 * 1024
+
+	walSegmentExt     = ".wal"
+	walHardStateFile  = "hardstate"
+	walRecordHeader   = 8 // 4字节长度 + 4字节CRC
+	walMaxRecordBytes = 64 * 1024 * 1024
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTornRecord 记录不完整或校验失败，通常是写入过程中崩溃留下的
+var errTornRecord = errors.New("WAL记录不完整或校验失败")
+
+// HardState Raft必须在响应RPC之前持久化的状态
+type HardState struct {
+	Term     int64  `json:"term"`
+	VotedFor string `json:"voted_for"`
+}
+
+// walSegment 一个段文件，first 为其中第一个条目的索引
+type walSegment struct {
+	first int64
+	path  string
+}
+
+// WAL Raft预写日志
+// 日志条目按索引顺序写入分段文件（<第一个条目索引>.wal），每条记录为
+// [长度 uint32][CRC32-C uint32][JSON编码的条目]，任期和投票单独保存在 hardstate 文件中；
+// 每次写入在返回前 fsync，返回成功即表示已落盘
+type WAL struct {
+	dir         string
+	segmentSize int64
+
+	mu        sync.Mutex
+	segments  []walSegment
+	file      *os.File // 最后一个段，用于追加
+	size      int64
+	lastIndex int64
+	hardState HardState
+
+	// 写入失败后文件内容与内存状态可能不一致，之后的写入全部拒绝
+	failed error
+}
+
+// OpenWAL 打开目录下的WAL，目录不存在时创建
+func OpenWAL(dir string, segmentSize int64) (*WAL, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建WAL目录失败: %w", err)
+	}
+	return &WAL{dir: dir, segmentSize: segmentSize}, nil
+}
+
+// Recover 读取持久化的状态、最新快照和快照之后的日志条目
+// 最后一个段末尾的不完整记录（写入时崩溃）会被截断，其他位置的损坏返回错误
+func (w *WAL) Recover() (HardState, *Snapshot, []LogEntry, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	hardState, err := w.readHardState()
+	if err != nil {
+		return HardState{}, nil, nil, err
+	}
+	w.hardState = hardState
+
+	snapshot, err := w.readSnapshot()
+	if err != nil {
+		return HardState{}, nil, nil, err
+	}
+
+	segments, err := w.listSegments()
+	if err != nil {
+		return HardState{}, nil, nil, err
+	}
+
+	var entries []LogEntry
+	for i, segment := range segments {
+		segmentEntries, offsets, err := readSegment(segment.path)
+		last := i == len(segments)-1
+		if err != nil && !(last && errors.Is(err, errTornRecord)) {
+			return HardState{}, nil, nil, fmt.Errorf("读取WAL段 %s 失败: %w", filepath.Base(segment.path), err)
+		}
+		if err != nil {
+			// 丢弃未完整写入的尾部，这些条目从未被确认
+			if err := truncateFile(segment.path, offsets[len(segmentEntries)]); err != nil {
+				return HardState{}, nil, nil, err
+			}
+		}
+
+		if len(entries) > 0 && entries[len(entries)-1].Index != segment.first-1 {
+			return HardState{}, nil, nil, fmt.Errorf("WAL段 %s 与前一个段不连续", filepath.Base(segment.path))
+		}
+		for j, entry := range segmentEntries {
+			if entry.Index != segment.first+int64(j) {
+				return HardState{}, nil, nil, fmt.Errorf("WAL段 %s 中的条目索引不连续: %d", filepath.Base(segment.path), entry.Index)
+			}
+		}
+		entries = append(entries, segmentEntries...)
+	}
+
+	w.segments = segments
+	if len(entries) > 0 {
+		w.lastIndex = entries[len(entries)-1].Index
+	}
+	if snapshot == nil {
+		if err := w.openTail(); err != nil {
+			return HardState{}, nil, nil, err
+		}
+		return hardState, nil, entries, nil
+	}
+
+	// 只保留快照之后且与快照一致的条目；安装快照后、清理日志前崩溃时，旧日志整体作废
+	discard := w.lastIndex < snapshot.Index
+	for len(entries) > 0 && entries[0].Index <= snapshot.Index {
+		if entries[0].Index == snapshot.Index && entries[0].Term != snapshot.Term {
+			discard = true
+		}
+		entries = entries[1:]
+	}
+	if len(entries) > 0 && entries[0].Index != snapshot.Index+1 {
+		return HardState{}, nil, nil, fmt.Errorf("WAL缺少快照 %d 之后的条目", snapshot.Index)
+	}
+	if discard {
+		entries = nil
+		if err := w.reset(snapshot.Index); err != nil {
+			return HardState{}, nil, nil, err
+		}
+		return hardState, snapshot, entries, nil
+	}
+
+	if err := w.openTail(); err != nil {
+		return HardState{}, nil, nil, err
+	}
+	return hardState, snapshot, entries, nil
+}
+
+// SaveHardState 持久化任期和投票，通过临时文件和重命名保证原子替换
+func (w *WAL) SaveHardState(state HardState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+	if state == w.hardState {
+		return nil
+	}
+
+	if err := w.writeFile(walHardStateFile, state); err != nil {
+		return fmt.Errorf("写入Raft状态失败: %w", err)
+	}
+
+	w.hardState = state
+	return nil
+}
+
+// Append 追加日志条目，条目索引必须紧接在已写入的最后一个条目之后
+func (w *WAL) Append(entries []LogEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if w.file == nil {
+		return fmt.Errorf("WAL未恢复，不能写入")
+	}
+	if err := w.append(entries); err != nil {
+		w.failed = fmt.Errorf("WAL已不可用: %w", err)
+		return err
+	}
+	return nil
+}
+
+func (w *WAL) append(entries []LogEntry) error {
+	for _, entry := range entries {
+		if entry.Index != w.lastIndex+1 {
+			return fmt.Errorf("WAL条目索引不连续: 期望 %d, 实际 %d", w.lastIndex+1, entry.Index)
+		}
+		if w.size >= w.segmentSize {
+			if err := w.rollSegment(entry.Index); err != nil {
+				return err
+			}
+		}
+
+		record, err := encodeRecord(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := w.file.Write(record); err != nil {
+			return fmt.Errorf("写入WAL失败: %w", err)
+		}
+		w.size += int64(len(record))
+		w.lastIndex = entry.Index
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("同步WAL失败: %w", err)
+	}
+	return nil
+}
+
+// TruncateFrom 删除索引不小于 index 的全部条目，用于Follower丢弃与Leader冲突的日志
+func (w *WAL) TruncateFrom(index int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return w.failed
+	}
+	if index > w.lastIndex {
+		return nil
+	}
+	if err := w.truncateFrom(index); err != nil {
+		w.failed = fmt.Errorf("WAL已不可用: %w", err)
+		return err
+	}
+	return nil
+}
+
+func (w *WAL) truncateFrom(index int64) error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+
+	// 删除完全位于截断点之后的段
+	for len(w.segments) > 0 && w.segments[len(w.segments)-1].first >= index {
+		last := w.segments[len(w.segments)-1]
+		if err := os.Remove(last.path); err != nil {
+			return fmt.Errorf("删除WAL段失败: %w", err)
+		}
+		w.segments = w.segments[:len(w.segments)-1]
+	}
+
+	// 截断包含截断点的段
+	if len(w.segments) > 0 {
+		last := w.segments[len(w.segments)-1]
+		entries, offsets, err := readSegment(last.path)
+		if err != nil {
+			return fmt.Errorf("读取WAL段 %s 失败: %w", filepath.Base(last.path), err)
+		}
+		keep := index - last.first
+		if keep < int64(len(entries)) {
+			if err := truncateFile(last.path, offsets[keep]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+
+	w.lastIndex = index - 1
+	return w.openTail()
+}
+
+// Close 关闭WAL
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// openTail 打开最后一个段用于追加，没有段时创建第一个段
+func (w *WAL) openTail() error {
+	if len(w.segments) == 0 {
+		return w.rollSegment(w.lastIndex + 1)
+	}
+
+	path := w.segments[len(w.segments)-1].path
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开WAL段失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开WAL段失败: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rollSegment 关闭当前段并创建以 first 开始的新段
+func (w *WAL) rollSegment(first int64) error {
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("同步WAL失败: %w", err)
+		}
+		w.file.Close()
+		w.file = nil
+	}
+
+	path := filepath.Join(w.dir, segmentName(first))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("创建WAL段失败: %w", err)
+	}
+	if err := syncDir(w.dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	w.segments = append(w.segments, walSegment{first: first, path: path})
+	w.file = file
+	w.size = 0
+	return nil
+}
+
+// writeFile 将一条记录原子地写入目录下的文件：写临时文件并 fsync 后重命名
+func (w *WAL) writeFile(name string, v interface{}) error {
+	record, err := encodeRecord(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(w.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(record); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(w.dir, name)); err != nil {
+		return err
+	}
+	return syncDir(w.dir)
+}
+
+// readFile 读取 writeFile 写入的记录，文件不存在时返回 false
+func (w *WAL) readFile(name string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	payload, err := decodeRecord(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// listSegments 按第一个条目索引升序列出段文件
+func (w *WAL) listSegments() ([]walSegment, error) {
+	names, err := filepath.Glob(filepath.Join(w.dir, "*"+walSegmentExt))
+	if err != nil {
+		return nil, fmt.Errorf("列出WAL段失败: %w", err)
+	}
+
+	segments := make([]walSegment, 0, len(names))
+	for _, name := range names {
+		first, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), walSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, walSegment{first: first, path: name})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+	return segments, nil
+}
+
+// readHardState 读取持久化的任期和投票，文件不存在时返回零值
+func (w *WAL) readHardState() (HardState, error) {
+	var state HardState
+	if _, err := w.readFile(walHardStateFile, &state); err != nil {
+		return state, fmt.Errorf("读取Raft状态失败: %w", err)
+	}
+	return state, nil
+}
+
+// readSegment 读取段中的全部条目，offsets[i] 为第i条记录的起始位置，末尾多一个读取结束的位置
+// 遇到不完整的记录时返回已读取的条目和 errTornRecord
+func readSegment(path string) ([]LogEntry, []int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var (
+		entries []LogEntry
+		offsets = []int64{0}
+		offset  int64
+	)
+	for {
+		payload, err := decodeRecord(reader)
+		if err == io.EOF {
+			return entries, offsets, nil
+		}
+		if err != nil {
+			return entries, offsets, err
+		}
+
+		var entry LogEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			return entries, offsets, fmt.Errorf("%w: %v", errTornRecord, err)
+		}
+		entries = append(entries, entry)
+		offset += int64(walRecordHeader + len(payload))
+		offsets = append(offsets, offset)
+	}
+}
+
+// encodeRecord 编码一条带长度和CRC的记录
+func encodeRecord(v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("序列化WAL记录失败: %w", err)
+	}
+
+	record := make([]byte, walRecordHeader+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, walCRCTable))
+	copy(record[walRecordHeader:], payload)
+	return record, nil
+}
+
+// decodeRecord 读取一条记录，读到文件末尾时返回 io.EOF
+func decodeRecord(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, walRecordHeader)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errTornRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > walMaxRecordBytes {
+		return nil, errTornRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, errTornRecord
+	}
+	if crc32.Checksum(payload, walCRCTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errTornRecord
+	}
+	return payload, nil
+}
+
+func segmentName(first int64) string {
+	return fmt.Sprintf("%020d%s", first, walSegmentExt)
+}
+
+// truncateFile 将文件截断到 size 并同步
+func truncateFile(path string, size int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("截断WAL段失败: %w", err)
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("截断WAL段失败: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("同步WAL段失败: %w", err)
+	}
+	return nil
+}
+
+// syncDir 同步目录，使文件的创建、删除和重命名落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("同步WAL目录失败: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("同步WAL目录失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package consensus
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWALRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	// 较小的段大小使条目分布在多个段中
+	wal, err := OpenWAL(dir, 256)
+	if err != nil {
+		t.Fatalf("打开WAL失败: %v", err)
+	}
+	if _, _, entries, err := wal.Recover(); err != nil || len(entries) != 0 {
+		t.Fatalf("空WAL恢复不正确: %v, %v", entries, err)
+	}
+
+	if err := wal.SaveHardState(HardState{Term: 2, VotedFor: "node2"}); err != nil {
+		t.Fatalf("保存Raft状态失败: %v", err)
+	}
+	for i := int64(1); i <= 10; i++ {
+		if err := wal.Append([]LogEntry{{Term: 1, Index: i, Command: map[string]interface{}{"n": i}}}); err != nil {
+			t.Fatalf("追加条目 %d 失败: %v", i, err)
+		}
+	}
+	if err := wal.Append([]LogEntry{{Term: 1, Index: 12}}); err == nil {
+		t.Fatal("不连续的条目应被拒绝")
+	}
+
+	// 截断冲突的条目后写入新任期的条目
+	wal, err = OpenWAL(dir, 256)
+	if err != nil {
+		t.Fatalf("打开WAL失败: %v", err)
+	}
+	if _, _, _, err := wal.Recover(); err != nil {
+		t.Fatalf("恢复WAL失败: %v", err)
+	}
+	if err := wal.TruncateFrom(4); err != nil {
+		t.Fatalf("截断WAL失败: %v", err)
+	}
+	if err := wal.Append([]LogEntry{{Term: 2, Index: 4}, {Term: 2, Index: 5}}); err != nil {
+		t.Fatalf("追加条目失败: %v", err)
+	}
+	wal.Close()
+
+	segments, _ := (&WAL{dir: dir}).listSegments()
+	if len(segments) < 2 {
+		t.Fatalf("条目应分布在多个段中: %d", len(segments))
+	}
+
+	// 模拟写入时崩溃：最后一个段末尾是不完整的记录
+	tail, err := os.OpenFile(segments[len(segments)-1].path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("打开段失败: %v", err)
+	}
+	tail.Write([]byte{0, 0, 0, 42, 1, 2})
+	tail.Close()
+
+	wal, err = OpenWAL(dir, 256)
+	if err != nil {
+		t.Fatalf("打开WAL失败: %v", err)
+	}
+	defer wal.Close()
+	hardState, _, entries, err := wal.Recover()
+	if err != nil {
+		t.Fatalf("恢复WAL失败: %v", err)
+	}
+	if hardState != (HardState{Term: 2, VotedFor: "node2"}) {
+		t.Errorf("Raft状态不正确: %+v", hardState)
+	}
+	if len(entries) != 5 || entries[3].Term != 2 || entries[4].Index != 5 {
+		t.Fatalf("恢复的条目不正确: %+v", entries)
+	}
+	if entries[0].Command.(map[string]interface{})["n"] != float64(1) {
+		t.Errorf("条目内容不正确: %+v", entries[0])
+	}
+	if err := wal.Append([]LogEntry{{Term: 2, Index: 6}}); err != nil {
+		t.Fatalf("截断不完整记录后应可继续追加: %v", err)
+	}
+}
+
+func TestRaftNodeRecoversFromWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	node := NewRaftNodeWithTransport("node1", nil)
+	node.SetDataDir(dir)
+	if err := node.recover(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+
+	// 在任期3投票给node2，再作为单节点集群当选并写入日志（当选后的空条目和两个提议）
+	node.handleRequestVote(&RequestVoteRequest{Term: 3, CandidateID: "node2"})
+	node.mu.Lock()
+	node.startElection(false)
+	node.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if _, _, err := node.Propose(map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("提议失败: %v", err)
+		}
+	}
+	node.Stop()
+
+	restarted := NewRaftNodeWithTransport("node1", nil)
+	restarted.SetDataDir(dir)
+	if err := restarted.recover(); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if restarted.term != 4 || restarted.votedFor != "node1" || restarted.getLastLogIndex() != 3 {
+		t.Fatalf("恢复的状态不正确: 任期=%d, 投票=%s, 日志=%d", restarted.term, restarted.votedFor, len(restarted.log))
+	}
+
+	// 重启后不能在已投票的任期再投给其他候选人
+	restarted.handleRequestVote(&RequestVoteRequest{Term: 4, CandidateID: "node3", LastLogIndex: 3, LastLogTerm: 4})
+	if restarted.votedFor != "node1" {
+		t.Errorf("同一任期不应重复投票: %s", restarted.votedFor)
+	}
+	restarted.Stop()
+}
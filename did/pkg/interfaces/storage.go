@@ -103,6 +103,8 @@ type DIDStorage interface {
 	// DID统计
 	GetDIDCount() (int64, error)
 	GetDIDsByController(controller string) ([]string, error)
+	GetDIDsByStatus(status string) ([]string, error)
+	SearchDIDs(keyword string) ([]string, error)
 }
 
 // CacheStorage 缓存存储接口
@@ -0,0 +1,501 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/interfaces"
+	bolt "go.etcd.io/bbolt"
+)
+
+// defaultBoltBucket 默认的数据桶名称
+var defaultBoltBucket = []byte("qlink")
+
+// BoltStorage 基于BoltDB的持久化存储实现
+type BoltStorage struct {
+	path    string
+	bucket  []byte
+	noSync  bool
+	timeout time.Duration
+
+	db    *bolt.DB
+	mu    sync.RWMutex
+	stats interfaces.StorageStats
+}
+
+// NewBoltStorage 创建新的BoltDB存储实例，需要调用Open后才能使用
+func NewBoltStorage(path string) *BoltStorage {
+	return &BoltStorage{
+		path:    path,
+		bucket:  defaultBoltBucket,
+		timeout: time.Second,
+		stats: interfaces.StorageStats{
+			LastUpdated: time.Now(),
+		},
+	}
+}
+
+// SetNoSync 设置是否跳过每次提交后的fsync（提高写入性能，但掉电可能丢失最近的写入）
+func (bs *BoltStorage) SetNoSync(noSync bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.noSync = noSync
+	if bs.db != nil {
+		bs.db.NoSync = noSync
+	}
+}
+
+// Path 获取数据文件路径
+func (bs *BoltStorage) Path() string {
+	return bs.path
+}
+
+// Open 打开存储
+func (bs *BoltStorage) Open() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.db != nil {
+		return nil
+	}
+
+	if bs.path == "" {
+		return fmt.Errorf("BoltDB存储路径不能为空")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bs.path), 0755); err != nil {
+		return fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	db, err := bolt.Open(bs.path, 0600, &bolt.Options{Timeout: bs.timeout})
+	if err != nil {
+		return fmt.Errorf("打开BoltDB失败: %w", err)
+	}
+	db.NoSync = bs.noSync
+
+	// 确保数据桶存在
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bs.bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("创建数据桶失败: %w", err)
+	}
+
+	bs.db = db
+	return nil
+}
+
+// Close 关闭存储
+func (bs *BoltStorage) Close() error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.db == nil {
+		return nil
+	}
+
+	err := bs.db.Close()
+	bs.db = nil
+	return err
+}
+
+// Get 获取数据
+func (bs *BoltStorage) Get(key []byte) ([]byte, error) {
+	db, err := bs.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		bs.updateStats(func(stats *interfaces.StorageStats) {
+			stats.ReadCount++
+			stats.AvgReadTime = (stats.AvgReadTime + time.Since(start)) / 2
+		})
+	}()
+
+	var value []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bs.bucket).Get(key)
+		if v == nil {
+			return fmt.Errorf("键不存在: %s", string(key))
+		}
+		// BoltDB返回的切片只在事务内有效，需要复制
+		value = copyBytes(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Put 存储数据
+func (bs *BoltStorage) Put(key, value []byte) error {
+	db, err := bs.getDB()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		bs.updateStats(func(stats *interfaces.StorageStats) {
+			stats.WriteCount++
+			stats.AvgWriteTime = (stats.AvgWriteTime + time.Since(start)) / 2
+		})
+	}()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bs.bucket).Put(key, value)
+	})
+	if err != nil {
+		bs.recordError(err)
+		return fmt.Errorf("写入数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// Delete 删除数据
+func (bs *BoltStorage) Delete(key []byte) error {
+	db, err := bs.getDB()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		bs.updateStats(func(stats *interfaces.StorageStats) {
+			stats.DeleteCount++
+		})
+	}()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bs.bucket).Delete(key)
+	})
+	if err != nil {
+		bs.recordError(err)
+		return fmt.Errorf("删除数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// Has 检查键是否存在
+func (bs *BoltStorage) Has(key []byte) (bool, error) {
+	db, err := bs.getDB()
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(bs.bucket).Get(key) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+// Batch 创建批量操作
+func (bs *BoltStorage) Batch() interfaces.Batch {
+	return &BoltBatch{
+		storage: bs,
+		ops:     make([]batchOp, 0),
+	}
+}
+
+// Iterator 创建迭代器
+// 迭代器在创建时对前缀范围内的数据做快照，之后的写入不会影响已创建的迭代器
+func (bs *BoltStorage) Iterator(prefix []byte) interfaces.Iterator {
+	iter := &BoltIterator{index: -1}
+
+	db, err := bs.getDB()
+	if err != nil {
+		return iter
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bs.bucket).Cursor()
+
+		var k, v []byte
+		if len(prefix) == 0 {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(prefix)
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			iter.keys = append(iter.keys, copyBytes(k))
+			iter.values = append(iter.values, copyBytes(v))
+		}
+		return nil
+	})
+
+	return iter
+}
+
+// NewTransaction 创建事务
+// 事务持有BoltDB的写锁直到Commit或Rollback，期间其他写操作会被阻塞
+func (bs *BoltStorage) NewTransaction() (interfaces.Transaction, error) {
+	db, err := bs.getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+
+	return &BoltTransaction{
+		storage: bs,
+		tx:      tx,
+		bucket:  tx.Bucket(bs.bucket),
+	}, nil
+}
+
+// Stats 获取统计信息
+func (bs *BoltStorage) Stats() interfaces.StorageStats {
+	bs.mu.RLock()
+	stats := bs.stats
+	db := bs.db
+	bs.mu.RUnlock()
+
+	if db != nil {
+		db.View(func(tx *bolt.Tx) error {
+			stats.KeyCount = int64(tx.Bucket(bs.bucket).Stats().KeyN)
+			stats.TotalSize = tx.Size()
+			return nil
+		})
+		stats.UsedSize = stats.TotalSize
+	}
+	stats.LastUpdated = time.Now()
+
+	return stats
+}
+
+// getDB 获取已打开的数据库句柄
+func (bs *BoltStorage) getDB() (*bolt.DB, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	if bs.db == nil {
+		return nil, fmt.Errorf("存储已关闭")
+	}
+
+	return bs.db, nil
+}
+
+// updateStats 更新统计信息
+func (bs *BoltStorage) updateStats(updater func(*interfaces.StorageStats)) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	updater(&bs.stats)
+	bs.stats.LastUpdated = time.Now()
+}
+
+// recordError 记录错误信息
+func (bs *BoltStorage) recordError(err error) {
+	bs.updateStats(func(stats *interfaces.StorageStats) {
+		stats.ErrorCount++
+		stats.LastError = err.Error()
+	})
+}
+
+// copyBytes 复制字节切片
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// BoltBatch BoltDB批量操作，Write时在单个事务中原子地应用所有操作
+type BoltBatch struct {
+	storage *BoltStorage
+	ops     []batchOp
+}
+
+func (bb *BoltBatch) Put(key, value []byte) error {
+	bb.ops = append(bb.ops, batchOp{
+		key:   string(key),
+		value: copyBytes(value),
+	})
+	return nil
+}
+
+func (bb *BoltBatch) Delete(key []byte) error {
+	bb.ops = append(bb.ops, batchOp{
+		key:    string(key),
+		delete: true,
+	})
+	return nil
+}
+
+func (bb *BoltBatch) Write() error {
+	db, err := bb.storage.getDB()
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bb.storage.bucket)
+		for _, op := range bb.ops {
+			if op.delete {
+				if err := bucket.Delete([]byte(op.key)); err != nil {
+					return err
+				}
+			} else {
+				if err := bucket.Put([]byte(op.key), op.value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		bb.storage.recordError(err)
+		return fmt.Errorf("批量写入失败: %w", err)
+	}
+
+	return nil
+}
+
+func (bb *BoltBatch) Reset() {
+	bb.ops = bb.ops[:0]
+}
+
+func (bb *BoltBatch) Size() int {
+	return len(bb.ops)
+}
+
+// BoltIterator BoltDB迭代器（基于快照，按键的字节序排列）
+type BoltIterator struct {
+	keys   [][]byte
+	values [][]byte
+	index  int
+}
+
+func (bi *BoltIterator) Valid() bool {
+	return bi.index >= 0 && bi.index < len(bi.keys)
+}
+
+func (bi *BoltIterator) Key() []byte {
+	if !bi.Valid() {
+		return nil
+	}
+	return bi.keys[bi.index]
+}
+
+func (bi *BoltIterator) Value() []byte {
+	if !bi.Valid() {
+		return nil
+	}
+	return bi.values[bi.index]
+}
+
+func (bi *BoltIterator) Next() {
+	bi.index++
+}
+
+func (bi *BoltIterator) Prev() {
+	bi.index--
+}
+
+func (bi *BoltIterator) Seek(key []byte) {
+	// 键已按字节序排列，二分查找第一个不小于key的位置
+	lo, hi := 0, len(bi.keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if bytes.Compare(bi.keys[mid], key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	bi.index = lo
+}
+
+func (bi *BoltIterator) First() {
+	bi.index = 0
+}
+
+func (bi *BoltIterator) Last() {
+	bi.index = len(bi.keys) - 1
+}
+
+func (bi *BoltIterator) Close() error {
+	bi.keys = nil
+	bi.values = nil
+	bi.index = -1
+	return nil
+}
+
+// BoltTransaction BoltDB读写事务
+type BoltTransaction struct {
+	storage *BoltStorage
+	tx      *bolt.Tx
+	bucket  *bolt.Bucket
+	done    bool
+}
+
+func (bt *BoltTransaction) Get(key []byte) ([]byte, error) {
+	if bt.done {
+		return nil, fmt.Errorf("事务已结束")
+	}
+
+	v := bt.bucket.Get(key)
+	if v == nil {
+		return nil, fmt.Errorf("键不存在: %s", string(key))
+	}
+
+	return copyBytes(v), nil
+}
+
+func (bt *BoltTransaction) Put(key, value []byte) error {
+	if bt.done {
+		return fmt.Errorf("事务已结束")
+	}
+	return bt.bucket.Put(key, value)
+}
+
+func (bt *BoltTransaction) Delete(key []byte) error {
+	if bt.done {
+		return fmt.Errorf("事务已结束")
+	}
+	return bt.bucket.Delete(key)
+}
+
+func (bt *BoltTransaction) Commit() error {
+	if bt.done {
+		return fmt.Errorf("事务已结束")
+	}
+	bt.done = true
+
+	if err := bt.tx.Commit(); err != nil {
+		bt.storage.recordError(err)
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return nil
+}
+
+func (bt *BoltTransaction) Rollback() error {
+	if bt.done {
+		return nil
+	}
+	bt.done = true
+
+	return bt.tx.Rollback()
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/qujing226/QLink/pkg/config"
+)
+
+func newTestBoltStorage(t *testing.T) *BoltStorage {
+	t.Helper()
+
+	bs := NewBoltStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err := bs.Open(); err != nil {
+		t.Fatalf("打开BoltDB失败: %v", err)
+	}
+	t.Cleanup(func() { bs.Close() })
+	return bs
+}
+
+func TestBoltStorageBasicOperations(t *testing.T) {
+	bs := newTestBoltStorage(t)
+
+	if err := bs.Put([]byte("k1"), []byte("v1")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	value, err := bs.Get([]byte("k1"))
+	if err != nil || string(value) != "v1" {
+		t.Fatalf("读取结果不正确: %q, %v", value, err)
+	}
+
+	if exists, _ := bs.Has([]byte("k1")); !exists {
+		t.Error("键应当存在")
+	}
+
+	if err := bs.Delete([]byte("k1")); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if _, err := bs.Get([]byte("k1")); err == nil {
+		t.Error("删除后读取应当失败")
+	}
+}
+
+func TestBoltStorageSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reopen.db")
+
+	bs := NewBoltStorage(path)
+	if err := bs.Open(); err != nil {
+		t.Fatalf("打开BoltDB失败: %v", err)
+	}
+	if err := bs.Put([]byte("did:did:qlink:abc"), []byte(`{"id":"did:qlink:abc"}`)); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := bs.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+
+	if _, err := bs.Get([]byte("did:did:qlink:abc")); err == nil {
+		t.Error("关闭后读取应当失败")
+	}
+
+	reopened := NewBoltStorage(path)
+	if err := reopened.Open(); err != nil {
+		t.Fatalf("重新打开BoltDB失败: %v", err)
+	}
+	defer reopened.Close()
+
+	value, err := reopened.Get([]byte("did:did:qlink:abc"))
+	if err != nil || string(value) != `{"id":"did:qlink:abc"}` {
+		t.Fatalf("重启后数据丢失: %q, %v", value, err)
+	}
+}
+
+func TestBoltStorageBatchAndIterator(t *testing.T) {
+	bs := newTestBoltStorage(t)
+
+	batch := bs.Batch()
+	batch.Put([]byte("block:3"), []byte("c"))
+	batch.Put([]byte("block:1"), []byte("a"))
+	batch.Put([]byte("block:2"), []byte("b"))
+	batch.Put([]byte("tx:1"), []byte("t"))
+	if batch.Size() != 4 {
+		t.Errorf("批量操作数量不正确: %d", batch.Size())
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("批量写入失败: %v", err)
+	}
+
+	iter := bs.Iterator([]byte("block:"))
+	defer iter.Close()
+
+	var keys []string
+	for iter.First(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	if len(keys) != 3 || keys[0] != "block:1" || keys[2] != "block:3" {
+		t.Fatalf("前缀迭代结果不正确: %v", keys)
+	}
+
+	iter.Last()
+	if string(iter.Value()) != "c" {
+		t.Errorf("Last定位不正确: %q", iter.Value())
+	}
+	iter.Prev()
+	if string(iter.Key()) != "block:2" {
+		t.Errorf("Prev定位不正确: %q", iter.Key())
+	}
+	iter.Seek([]byte("block:15"))
+	if string(iter.Key()) != "block:2" {
+		t.Errorf("Seek定位不正确: %q", iter.Key())
+	}
+}
+
+func TestBoltStorageTransaction(t *testing.T) {
+	bs := newTestBoltStorage(t)
+
+	tx, err := bs.NewTransaction()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	tx.Put([]byte("a"), []byte("1"))
+	if value, err := tx.Get([]byte("a")); err != nil || string(value) != "1" {
+		t.Errorf("事务内读取不正确: %q, %v", value, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if exists, _ := bs.Has([]byte("a")); exists {
+		t.Error("回滚后数据不应存在")
+	}
+
+	tx, err = bs.NewTransaction()
+	if err != nil {
+		t.Fatalf("开启事务失败: %v", err)
+	}
+	tx.Put([]byte("b"), []byte("2"))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("提交失败: %v", err)
+	}
+	if value, err := bs.Get([]byte("b")); err != nil || string(value) != "2" {
+		t.Errorf("提交后读取不正确: %q, %v", value, err)
+	}
+}
+
+// TestNodeDIDStoragePersists 按 qlink-node 的方式创建DID存储，重启后恢复DID文档和索引
+func TestNodeDIDStoragePersists(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Node.DataDir = t.TempDir()
+	cfg.Storage.Type = "local"
+	cfg.Storage.Path = ""
+
+	factory := NewStorageFactory()
+	didStorage, err := factory.CreateDIDStorage(NewFileStorageConfig(cfg, "did"))
+	if err != nil {
+		t.Fatalf("创建DID存储失败: %v", err)
+	}
+	doc := map[string]interface{}{"id": "did:qlink:abc", "controller": "did:qlink:org", "status": "active"}
+	if err := didStorage.PutDIDDocument("did:qlink:abc", doc); err != nil {
+		t.Fatalf("存储DID文档失败: %v", err)
+	}
+	if err := didStorage.Close(); err != nil {
+		t.Fatalf("关闭存储失败: %v", err)
+	}
+
+	didStorage, err = factory.CreateDIDStorage(NewFileStorageConfig(cfg, "did"))
+	if err != nil {
+		t.Fatalf("重新创建DID存储失败: %v", err)
+	}
+	defer didStorage.Close()
+
+	if _, err := didStorage.GetDIDDocument("did:qlink:abc"); err != nil {
+		t.Fatalf("重启后DID文档丢失: %v", err)
+	}
+	if dids, _ := didStorage.GetDIDsByController("did:qlink:org"); len(dids) != 1 {
+		t.Errorf("重启后控制器索引未重建: %v", dids)
+	}
+}
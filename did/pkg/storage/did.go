@@ -267,8 +267,15 @@ func (ds *DIDStorage) updateIndexes(did string, doc interface{}, isNew bool) err
 		ds.cleanupIndexes(did)
 	}
 
-	// 更新控制器索引
-	if controller, exists := docMap["controller"]; exists {
+	// 更新控制器索引，controller 可以是单个DID或DID数组
+	var controllers []interface{}
+	switch controller := docMap["controller"].(type) {
+	case string:
+		controllers = []interface{}{controller}
+	case []interface{}:
+		controllers = controller
+	}
+	for _, controller := range controllers {
 		if controllerStr, ok := controller.(string); ok {
 			if ds.controllerIndex[controllerStr] == nil {
 				ds.controllerIndex[controllerStr] = make([]string, 0)
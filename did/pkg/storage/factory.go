@@ -2,7 +2,9 @@ package storage
 
 import (
 	"fmt"
+	"path/filepath"
 
+	"github.com/qujing226/QLink/pkg/config"
 	"github.com/qujing226/QLink/pkg/interfaces"
 )
 
@@ -13,8 +15,20 @@ const (
 	StorageTypeMemory     StorageType = "memory"
 	StorageTypeBlockchain StorageType = "blockchain"
 	StorageTypeDID        StorageType = "did"
+	StorageTypeBolt       StorageType = "bolt"
 )
 
+// IsPersistentType 判断配置中的存储类型是否需要落盘
+// config.StorageConfig.Type 中的 "local"、"leveldb" 均由BoltDB实现
+func IsPersistentType(storageType string) bool {
+	switch storageType {
+	case string(StorageTypeBolt), "local", "leveldb":
+		return true
+	default:
+		return false
+	}
+}
+
 // StorageFactory 存储工厂实现
 type StorageFactory struct{}
 
@@ -32,6 +46,8 @@ func (sf *StorageFactory) CreateStorage(storageType StorageType, config interfac
 		return sf.createBlockchainStorage(config)
 	case StorageTypeDID:
 		return sf.createDIDStorage(config)
+	case StorageTypeBolt:
+		return sf.createBoltStorage(config)
 	default:
 		return nil, fmt.Errorf("不支持的存储类型: %s", storageType)
 	}
@@ -42,25 +58,74 @@ func (sf *StorageFactory) createMemoryStorage(config interfaces.StorageConfig) (
 	return NewMemoryStorage(), nil
 }
 
+// createBoltStorage 创建BoltDB持久化存储
+func (sf *StorageFactory) createBoltStorage(config interfaces.StorageConfig) (interfaces.Storage, error) {
+	if err := sf.validateBoltConfig(config); err != nil {
+		return nil, err
+	}
+
+	boltStorage := NewBoltStorage(config.GetPath())
+	if syncEnabled, ok := config.GetConfig()["sync_enabled"].(bool); ok {
+		boltStorage.SetNoSync(!syncEnabled)
+	}
+
+	if err := boltStorage.Open(); err != nil {
+		return nil, err
+	}
+
+	return boltStorage, nil
+}
+
+// createBaseStorage 根据配置创建底层存储，持久化类型使用BoltDB，否则使用内存存储
+func (sf *StorageFactory) createBaseStorage(config interfaces.StorageConfig) (interfaces.Storage, error) {
+	if config != nil && IsPersistentType(config.GetType()) {
+		return sf.createBoltStorage(config)
+	}
+	return NewMemoryStorage(), nil
+}
+
 // createBlockchainStorage 创建区块链存储
 func (sf *StorageFactory) createBlockchainStorage(config interfaces.StorageConfig) (interfaces.Storage, error) {
 	// 创建底层存储
-	baseStorage := NewMemoryStorage()
+	baseStorage, err := sf.createBaseStorage(config)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建区块链存储
 	blockchainStorage := NewBlockchainStorage(baseStorage)
 
+	// 从底层存储恢复已持久化的数据
+	if err := blockchainStorage.LoadFromStorage(); err != nil {
+		baseStorage.Close()
+		return nil, fmt.Errorf("加载区块链数据失败: %w", err)
+	}
+
 	return blockchainStorage, nil
 }
 
 // createDIDStorage 创建DID存储
 func (sf *StorageFactory) createDIDStorage(config interfaces.StorageConfig) (interfaces.Storage, error) {
+	return sf.CreateDIDStorage(config)
+}
+
+// CreateDIDStorage 创建DID存储，持久化类型的配置会从底层存储恢复已有的DID文档
+func (sf *StorageFactory) CreateDIDStorage(config interfaces.StorageConfig) (*DIDStorage, error) {
 	// 创建底层存储
-	baseStorage := NewMemoryStorage()
+	baseStorage, err := sf.createBaseStorage(config)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建DID存储
 	didStorage := NewDIDStorage(baseStorage)
 
+	// 从底层存储恢复已持久化的数据
+	if err := didStorage.LoadFromStorage(); err != nil {
+		baseStorage.Close()
+		return nil, fmt.Errorf("加载DID数据失败: %w", err)
+	}
+
 	return didStorage, nil
 }
 
@@ -70,6 +135,7 @@ func (sf *StorageFactory) GetSupportedTypes() []StorageType {
 		StorageTypeMemory,
 		StorageTypeBlockchain,
 		StorageTypeDID,
+		StorageTypeBolt,
 	}
 }
 
@@ -82,6 +148,8 @@ func (sf *StorageFactory) ValidateConfig(storageType StorageType, config interfa
 		return sf.validateBlockchainConfig(config)
 	case StorageTypeDID:
 		return sf.validateDIDConfig(config)
+	case StorageTypeBolt:
+		return sf.validateBoltConfig(config)
 	default:
 		return fmt.Errorf("不支持的存储类型: %s", storageType)
 	}
@@ -105,6 +173,14 @@ func (sf *StorageFactory) validateDIDConfig(config interfaces.StorageConfig) err
 	return nil
 }
 
+// validateBoltConfig 验证BoltDB存储配置
+func (sf *StorageFactory) validateBoltConfig(config interfaces.StorageConfig) error {
+	if config == nil || config.GetPath() == "" {
+		return fmt.Errorf("BoltDB存储需要配置数据文件路径")
+	}
+	return nil
+}
+
 // CreateStorageWithDefaults 使用默认配置创建存储
 func (sf *StorageFactory) CreateStorageWithDefaults(storageType StorageType) (interfaces.Storage, error) {
 	// 创建默认配置
@@ -153,6 +229,66 @@ func (c *DefaultStorageConfig) Validate() error {
 	return nil
 }
 
+// FileStorageConfig 基于节点配置的持久化存储配置
+type FileStorageConfig struct {
+	storageType string
+	path        string
+	sync        bool
+}
+
+// NewFileStorageConfig 根据节点配置创建名为name的存储配置
+// 数据文件位于 Storage.Path（未配置时为 Node.DataDir）下的 <name>.db
+func NewFileStorageConfig(cfg *config.Config, name string) *FileStorageConfig {
+	fc := &FileStorageConfig{
+		storageType: string(StorageTypeMemory),
+		sync:        true,
+	}
+
+	dir := ""
+	if cfg != nil && cfg.Node != nil {
+		dir = cfg.Node.DataDir
+	}
+	if cfg != nil && cfg.Storage != nil {
+		fc.storageType = cfg.Storage.Type
+		fc.sync = cfg.Storage.Sync
+		if cfg.Storage.Path != "" {
+			dir = cfg.Storage.Path
+		}
+	}
+	if dir != "" {
+		fc.path = filepath.Join(dir, name+".db")
+	}
+
+	return fc
+}
+
+// GetType 获取存储类型
+func (c *FileStorageConfig) GetType() string {
+	return c.storageType
+}
+
+// GetPath 获取存储路径
+func (c *FileStorageConfig) GetPath() string {
+	return c.path
+}
+
+// GetConfig 获取配置映射
+func (c *FileStorageConfig) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"type":         c.storageType,
+		"path":         c.path,
+		"sync_enabled": c.sync,
+	}
+}
+
+// Validate 验证配置
+func (c *FileStorageConfig) Validate() error {
+	if IsPersistentType(c.storageType) && c.path == "" {
+		return fmt.Errorf("持久化存储需要配置数据目录")
+	}
+	return nil
+}
+
 // BatchCreateStorages 批量创建存储实例
 func (sf *StorageFactory) BatchCreateStorages(configs map[string]FactoryStorageConfig) (map[string]interfaces.Storage, error) {
 	storages := make(map[string]interfaces.Storage)
@@ -49,12 +49,22 @@ type PeerInfo struct {
 	Active   bool      `json:"active"`
 }
 
+// LogEntryType 日志条目类型
+type LogEntryType string
+
+const (
+	LogEntryTypeCommand    LogEntryType = ""            // 状态机命令
+	LogEntryTypeNoop       LogEntryType = "noop"        // Leader当选后追加的空条目
+	LogEntryTypeConfChange LogEntryType = "conf_change" // 集群成员变更
+)
+
 // LogEntry 日志条目
 type LogEntry struct {
-	Term      int64       `json:"term"`
-	Index     int64       `json:"index"`
-	Command   interface{} `json:"command"`
-	Timestamp time.Time   `json:"timestamp"`
+	Term      int64        `json:"term"`
+	Index     int64        `json:"index"`
+	Type      LogEntryType `json:"type,omitempty"`
+	Command   interface{}  `json:"command"`
+	Timestamp time.Time    `json:"timestamp"`
 }
 
 // MessageType 消息类型
@@ -170,6 +180,7 @@ func (os OperationStatus) String() string {
 type DIDDocument struct {
 	Context              []string             `json:"@context"`
 	ID                   string               `json:"id"`
+	Controller           StringOrSet          `json:"controller,omitempty"`
 	VerificationMethod   []VerificationMethod `json:"verificationMethod,omitempty"`
 	Authentication       []string             `json:"authentication,omitempty"`
 	AssertionMethod      []string             `json:"assertionMethod,omitempty"`
@@ -181,6 +192,8 @@ type DIDDocument struct {
 	Updated              *time.Time           `json:"updated,omitempty"`
 	Deactivated          bool                 `json:"deactivated,omitempty"`
 	Status               string               `json:"status,omitempty"`
+	NextKeyCommitment    string               `json:"nextKeyCommitment,omitempty"` // 预轮换承诺：下一把轮换密钥的公钥摘要
+	AlgorithmSuites      []string             `json:"algorithmSuites,omitempty"`   // 支持的算法套件，按偏好排序，供对方协商
 	Proof                *Proof               `json:"proof,omitempty"`
 }
 
@@ -189,6 +202,34 @@ func (doc *DIDDocument) ToJSON() ([]byte, error) {
 	return json.Marshal(doc)
 }
 
+// StringOrSet 单个字符串或字符串数组（如DID文档的controller）
+// 只有一个元素时序列化为字符串，多个元素时序列化为数组
+type StringOrSet []string
+
+// MarshalJSON 实现json.Marshaler
+func (s StringOrSet) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]string(s))
+}
+
+// UnmarshalJSON 实现json.Unmarshaler
+func (s *StringOrSet) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringOrSet{single}
+		return nil
+	}
+
+	var set []string
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
 // VerificationMethod 验证方法
 type VerificationMethod struct {
 	ID                 string                 `json:"id"`
@@ -197,6 +238,7 @@ type VerificationMethod struct {
 	PublicKeyJwk       interface{}            `json:"publicKeyJwk,omitempty"`
 	PublicKeyMultibase string                 `json:"publicKeyMultibase,omitempty"`
 	PublicKeyLattice   map[string]interface{} `json:"publicKeyLattice,omitempty"`
+	Revoked            *time.Time             `json:"revoked,omitempty"` // 轮换后退役的时间，退役密钥只用于验证历史签名
 }
 
 // Service 服务端点
@@ -209,12 +251,28 @@ type Service struct {
 // Proof 证明结构
 type Proof struct {
 	Type               string    `json:"type"`
+	Cryptosuite        string    `json:"cryptosuite,omitempty"` // DataIntegrityProof 的密码套件
 	Created            time.Time `json:"created"`
 	VerificationMethod string    `json:"verificationMethod"`
 	ProofPurpose       string    `json:"proofPurpose"`
+	Nonce              string    `json:"nonce,omitempty"`
 	ProofValue         string    `json:"proofValue"`
 }
 
+// DIDOperationPayload DID变更操作的签名内容
+// 更新和撤销请求的证明是对该结构（连同去掉proofValue的证明本身）的签名
+type DIDOperationPayload struct {
+	Operation          string               `json:"operation"`
+	DID                string               `json:"did"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	Service            []Service            `json:"service,omitempty"`
+	ServiceID          string               `json:"serviceId,omitempty"`
+	RetireKeyID        string               `json:"retireKeyId,omitempty"`
+	Relationships      []string             `json:"relationships,omitempty"`
+	NextKeyCommitment  string               `json:"nextKeyCommitment,omitempty"`
+	Controller         []string             `json:"controller,omitempty"`
+}
+
 // TransactionType 交易类型
 type TransactionType string
 
@@ -234,10 +292,11 @@ const (
 )
 
 type DIDOperation struct {
-	Operation string       `json:"operation"` // "create", "update", "deactivate"
-	DID       string       `json:"did"`
-	Document  *DIDDocument `json:"document,omitempty"`
-	Proof     *Proof       `json:"proof,omitempty"`
+	Operation    string       `json:"operation"` // "create", "update", "deactivate"
+	DID          string       `json:"did"`
+	Document     *DIDDocument `json:"document,omitempty"`
+	Proof        *Proof       `json:"proof,omitempty"`
+	PreviousHash string       `json:"previousHash,omitempty"` // 操作基于的最新历史版本哈希，创建时为空
 }
 
 // ConflictEntry 冲突条目
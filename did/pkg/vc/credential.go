@@ -0,0 +1,129 @@
+// Package vc 签发和验证由QLink DID签名的W3C可验证凭证
+// 支持三种格式：带 DataIntegrityProof 的JSON-LD凭证、JWT-VC 和支持选择性披露的SD-JWT
+package vc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// W3C VC数据模型常量
+const (
+	ContextV2                = "https://www.w3.org/ns/credentials/v2"
+	TypeVerifiableCredential = "VerifiableCredential"
+	// ProofPurposeAssertion 凭证证明的目的，签名密钥必须在签发者的assertionMethod中
+	ProofPurposeAssertion = "assertionMethod"
+)
+
+// 凭证格式
+const (
+	FormatLDP   = "ldp"    // JSON-LD + DataIntegrityProof
+	FormatJWT   = "jwt"    // JWT-VC
+	FormatSDJWT = "sd-jwt" // SD-JWT，凭证主体的声明可选择性披露
+)
+
+// Credential W3C可验证凭证（VC数据模型2.0）
+type Credential struct {
+	Context           []string               `json:"@context"`
+	ID                string                 `json:"id,omitempty"`
+	Type              []string               `json:"type"`
+	Issuer            string                 `json:"issuer"`
+	ValidFrom         *time.Time             `json:"validFrom,omitempty"`
+	ValidUntil        *time.Time             `json:"validUntil,omitempty"`
+	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+	CredentialStatus  *CredentialStatus      `json:"credentialStatus,omitempty"`
+	Proof             *types.Proof           `json:"proof,omitempty"`
+}
+
+// NewCredential 创建凭证，类型会自动包含 VerifiableCredential
+func NewCredential(credentialType string, subject map[string]interface{}) *Credential {
+	credTypes := []string{TypeVerifiableCredential}
+	if credentialType != "" && credentialType != TypeVerifiableCredential {
+		credTypes = append(credTypes, credentialType)
+	}
+	return &Credential{
+		Context:           []string{ContextV2},
+		Type:              credTypes,
+		CredentialSubject: subject,
+	}
+}
+
+// SubjectID 凭证主体的ID
+func (c *Credential) SubjectID() string {
+	id, _ := c.CredentialSubject["id"].(string)
+	return id
+}
+
+// Validate 检查凭证结构和有效期
+func (c *Credential) Validate(now time.Time) error {
+	if len(c.Context) == 0 || c.Context[0] != ContextV2 {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_CREDENTIAL_CONTEXT",
+			"凭证的第一个@context必须是VC数据模型2.0", strings.Join(c.Context, ","))
+	}
+	if !containsString(c.Type, TypeVerifiableCredential) {
+		return utils.NewError(utils.ErrorTypeValidation, "INVALID_CREDENTIAL_TYPE", "凭证类型必须包含VerifiableCredential")
+	}
+	if !strings.HasPrefix(c.Issuer, "did:") {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_ISSUER", "签发者必须是DID", c.Issuer)
+	}
+	if len(c.CredentialSubject) == 0 {
+		return utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_SUBJECT_REQUIRED", "凭证主体不能为空")
+	}
+
+	if c.ValidFrom != nil && now.Before(*c.ValidFrom) {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "CREDENTIAL_NOT_YET_VALID",
+			"凭证尚未生效", c.ValidFrom.Format(time.RFC3339))
+	}
+	if c.ValidUntil != nil && now.After(*c.ValidUntil) {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "CREDENTIAL_EXPIRED",
+			"凭证已过期", c.ValidUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// withoutProof 返回去掉证明的凭证副本
+func (c *Credential) withoutProof() *Credential {
+	unsigned := *c
+	unsigned.Proof = nil
+	return &unsigned
+}
+
+// toMap 将凭证转换为JSON对象，用作JWT的vc声明
+func (c *Credential) toMap() (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("序列化凭证失败: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("序列化凭证失败: %w", err)
+	}
+	return m, nil
+}
+
+// credentialFromMap 从JWT的vc声明还原凭证
+func credentialFromMap(m map[string]interface{}) (*Credential, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var c Credential
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_CREDENTIAL", "凭证格式无效", err)
+	}
+	return &c, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
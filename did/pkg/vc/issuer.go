@@ -0,0 +1,190 @@
+package vc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// JWT头部的typ
+const (
+	TypeJWT   = "JWT"
+	TypeSDJWT = "vc+sd-jwt"
+)
+
+// jwtClaims JWT-VC的声明，vc 为不含proof的凭证
+type jwtClaims struct {
+	Issuer    string                 `json:"iss"`
+	Subject   string                 `json:"sub,omitempty"`
+	ID        string                 `json:"jti,omitempty"`
+	IssuedAt  int64                  `json:"iat"`
+	NotBefore int64                  `json:"nbf,omitempty"`
+	Expires   int64                  `json:"exp,omitempty"`
+	SDAlg     string                 `json:"_sd_alg,omitempty"`
+	VC        map[string]interface{} `json:"vc"`
+}
+
+// Issuer 凭证签发者，使用DID的assertionMethod密钥签名
+type Issuer struct {
+	did     string
+	keyID   string
+	keyPair *crypto.HybridKeyPair
+}
+
+// NewIssuer 创建签发者，keyID 可以是完整的DID URL或相对ID（#key-1）
+func NewIssuer(issuerDID, keyID string, keyPair *crypto.HybridKeyPair) *Issuer {
+	if strings.HasPrefix(keyID, "#") {
+		keyID = issuerDID + keyID
+	}
+	return &Issuer{
+		did:     issuerDID,
+		keyID:   keyID,
+		keyPair: keyPair,
+	}
+}
+
+// DID 签发者DID
+func (i *Issuer) DID() string {
+	return i.did
+}
+
+// Issue 签发带 DataIntegrityProof 的凭证
+func (i *Issuer) Issue(c *Credential) (*Credential, error) {
+	cred, err := i.prepare(c)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &types.Proof{
+		Created:            time.Now().UTC().Truncate(time.Second),
+		VerificationMethod: i.keyID,
+		ProofPurpose:       ProofPurposeAssertion,
+	}
+	if err := i.keyPair.SignDataIntegrityProof(cred, proof); err != nil {
+		return nil, fmt.Errorf("签名凭证失败: %w", err)
+	}
+	cred.Proof = proof
+	return cred, nil
+}
+
+// IssueJWT 签发JWT-VC
+func (i *Issuer) IssueJWT(c *Credential) (string, error) {
+	cred, err := i.prepare(c)
+	if err != nil {
+		return "", err
+	}
+	claims, err := i.claims(cred)
+	if err != nil {
+		return "", err
+	}
+	return i.sign(TypeJWT, claims)
+}
+
+// IssueSDJWT 签发SD-JWT，disclosable 中列出的凭证主体声明只以摘要出现，持有者可选择性披露
+// 返回 <JWT>~<披露1>~...~<披露n>~
+func (i *Issuer) IssueSDJWT(c *Credential, disclosable ...string) (string, error) {
+	cred, err := i.prepare(c)
+	if err != nil {
+		return "", err
+	}
+	claims, err := i.claims(cred)
+	if err != nil {
+		return "", err
+	}
+
+	subject, ok := claims.VC["credentialSubject"].(map[string]interface{})
+	if !ok {
+		return "", utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_SUBJECT_REQUIRED", "凭证主体不能为空")
+	}
+
+	var disclosures []string
+	digests := make([]interface{}, 0, len(disclosable))
+	for _, name := range disclosable {
+		value, exists := subject[name]
+		if !exists {
+			return "", utils.NewErrorWithDetails(utils.ErrorTypeValidation, "CLAIM_NOT_FOUND", "凭证主体中没有该声明", name)
+		}
+		if name == "id" {
+			return "", utils.NewError(utils.ErrorTypeValidation, "CLAIM_NOT_DISCLOSABLE", "凭证主体ID不能选择性披露")
+		}
+
+		disclosure, err := newDisclosure(name, value)
+		if err != nil {
+			return "", err
+		}
+		delete(subject, name)
+		disclosures = append(disclosures, disclosure)
+		digests = append(digests, disclosureDigest(disclosure))
+	}
+	subject[sdClaim] = digests
+	claims.SDAlg = sdAlgorithm
+
+	token, err := i.sign(TypeSDJWT, claims)
+	if err != nil {
+		return "", err
+	}
+	return token + "~" + strings.Join(append(disclosures, ""), "~"), nil
+}
+
+// prepare 复制凭证并填写签发者和生效时间
+func (i *Issuer) prepare(c *Credential) (*Credential, error) {
+	if !strings.HasPrefix(i.keyID, i.did+"#") {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "ISSUER_KEY_MISMATCH",
+			"签名密钥不属于签发者", i.keyID)
+	}
+
+	cred := c.withoutProof()
+	if cred.Issuer == "" {
+		cred.Issuer = i.did
+	}
+	if cred.Issuer != i.did {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "ISSUER_MISMATCH",
+			"凭证签发者与签名者不一致", cred.Issuer)
+	}
+	if cred.ValidFrom == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		cred.ValidFrom = &now
+	}
+	if err := cred.Validate(*cred.ValidFrom); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// claims 将凭证映射为JWT-VC声明
+func (i *Issuer) claims(cred *Credential) (*jwtClaims, error) {
+	vc, err := cred.toMap()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &jwtClaims{
+		Issuer:    cred.Issuer,
+		Subject:   cred.SubjectID(),
+		ID:        cred.ID,
+		IssuedAt:  time.Now().Unix(),
+		NotBefore: cred.ValidFrom.Unix(),
+		VC:        vc,
+	}
+	if cred.ValidUntil != nil {
+		claims.Expires = cred.ValidUntil.Unix()
+	}
+	return claims, nil
+}
+
+func (i *Issuer) sign(typ string, claims *jwtClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("序列化JWT声明失败: %w", err)
+	}
+	token, err := i.keyPair.SignJWS(i.keyID, typ, payload)
+	if err != nil {
+		return "", fmt.Errorf("签名JWT失败: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,176 @@
+package vc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// SD-JWT（RFC 9901）常量
+const (
+	sdClaim     = "_sd"
+	sdAlgClaim  = "_sd_alg"
+	sdAlgorithm = "sha-256"
+	saltSize    = 16
+)
+
+// disclosure 已解码的披露：[salt, 声明名, 声明值]
+type disclosure struct {
+	encoded string
+	name    string
+	value   interface{}
+}
+
+// newDisclosure 生成对象属性的披露，返回 base64url(JSON数组)
+func newDisclosure(name string, value interface{}) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("生成盐值失败: %w", err)
+	}
+
+	data, err := json.Marshal([]interface{}{base64.RawURLEncoding.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", fmt.Errorf("序列化披露失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// disclosureDigest 披露的摘要：base64url(SHA-256(ASCII(披露)))
+func disclosureDigest(encoded string) string {
+	digest := sha256.Sum256([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// parseDisclosure 解码对象属性的披露
+func parseDisclosure(encoded string) (*disclosure, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_DISCLOSURE", "披露编码无效", err)
+	}
+
+	var parts []interface{}
+	if err := json.Unmarshal(data, &parts); err != nil || len(parts) != 3 {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_DISCLOSURE", "披露必须是[salt, 声明名, 声明值]")
+	}
+	name, ok := parts[1].(string)
+	if _, saltOK := parts[0].(string); !ok || !saltOK || name == sdClaim || name == "..." {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_DISCLOSURE", "披露的盐值或声明名无效")
+	}
+
+	return &disclosure{encoded: encoded, name: name, value: parts[2]}, nil
+}
+
+// splitSDJWT 拆分 <JWT>~<披露>~...~，不支持密钥绑定JWT
+func splitSDJWT(sdjwt string) (string, []string, error) {
+	parts := strings.Split(sdjwt, "~")
+	if len(parts) < 2 {
+		return "", nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_SD_JWT", "SD-JWT格式无效，应为<JWT>~<披露>~...~")
+	}
+	if parts[len(parts)-1] != "" {
+		return "", nil, utils.NewError(utils.ErrorTypeValidation, "KEY_BINDING_NOT_SUPPORTED", "不支持密钥绑定JWT")
+	}
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+// Disclose 持有者选择要披露的凭证主体声明，返回只包含这些披露的SD-JWT
+func Disclose(sdjwt string, claims ...string) (string, error) {
+	token, encoded, err := splitSDJWT(sdjwt)
+	if err != nil {
+		return "", err
+	}
+
+	selected := []string{token}
+	for _, e := range encoded {
+		d, err := parseDisclosure(e)
+		if err != nil {
+			return "", err
+		}
+		if containsString(claims, d.name) {
+			selected = append(selected, e)
+		}
+	}
+	return strings.Join(append(selected, ""), "~"), nil
+}
+
+// applyDisclosures 用披露替换载荷中 _sd 里的摘要，并移除 _sd 和 _sd_alg
+// 每个披露必须恰好被一个摘要引用，未提供披露的摘要对应的声明保持隐藏
+func applyDisclosures(payload map[string]interface{}, encoded []string) error {
+	if alg, _ := payload[sdAlgClaim].(string); alg != sdAlgorithm {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_SD_ALG", "不支持的SD-JWT摘要算法", alg)
+	}
+	delete(payload, sdAlgClaim)
+
+	disclosures := make(map[string]*disclosure, len(encoded))
+	for _, e := range encoded {
+		d, err := parseDisclosure(e)
+		if err != nil {
+			return err
+		}
+		digest := disclosureDigest(e)
+		if _, exists := disclosures[digest]; exists {
+			return utils.NewError(utils.ErrorTypeValidation, "DUPLICATE_DISCLOSURE", "披露重复")
+		}
+		disclosures[digest] = d
+	}
+
+	if err := expandObject(payload, disclosures); err != nil {
+		return err
+	}
+	if len(disclosures) > 0 {
+		return utils.NewError(utils.ErrorTypeValidation, "UNREFERENCED_DISCLOSURE", "披露没有被签发者签名的摘要引用")
+	}
+	return nil
+}
+
+// expandObject 递归展开对象中的 _sd，已使用的披露从 disclosures 中移除
+func expandObject(obj map[string]interface{}, disclosures map[string]*disclosure) error {
+	if raw, exists := obj[sdClaim]; exists {
+		digests, ok := raw.([]interface{})
+		if !ok {
+			return utils.NewError(utils.ErrorTypeValidation, "INVALID_SD_JWT", "_sd必须是摘要数组")
+		}
+		delete(obj, sdClaim)
+
+		for _, item := range digests {
+			digest, ok := item.(string)
+			if !ok {
+				return utils.NewError(utils.ErrorTypeValidation, "INVALID_SD_JWT", "_sd必须是摘要数组")
+			}
+			d, found := disclosures[digest]
+			if !found {
+				continue
+			}
+			if _, exists := obj[d.name]; exists {
+				return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "DUPLICATE_CLAIM", "披露的声明已存在", d.name)
+			}
+			obj[d.name] = d.value
+			delete(disclosures, digest)
+		}
+	}
+
+	for _, value := range obj {
+		if err := expandValue(value, disclosures); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expandValue(value interface{}, disclosures map[string]*disclosure) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return expandObject(v, disclosures)
+	case []interface{}:
+		for _, item := range v {
+			if err := expandValue(item, disclosures); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
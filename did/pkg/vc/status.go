@@ -0,0 +1,397 @@
+package vc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// StatusList2021 常量
+const (
+	StatusListType           = "StatusList2021"
+	StatusListCredentialType = "StatusList2021Credential"
+	StatusListEntryType      = "StatusList2021Entry"
+	// ServiceTypeStatusList 发布状态列表凭证的DID服务类型，serviceEndpoint 为签名后的状态列表凭证
+	ServiceTypeStatusList = StatusListType
+
+	StatusPurposeRevocation = "revocation"
+	StatusPurposeSuspension = "suspension"
+
+	// DefaultStatusListSize 默认状态列表长度（16KB），保证单个凭证的状态不易被关联
+	DefaultStatusListSize = 131072
+	// maxStatusListSize 解压后的状态列表上限，防止压缩炸弹
+	maxStatusListSize = 16 * DefaultStatusListSize
+)
+
+// CredentialStatus 凭证状态条目（StatusList2021Entry）
+// statusListCredential 指向签发者DID文档中的状态列表服务，例如 did:qlink:abc#status-1
+type CredentialStatus struct {
+	ID                   string `json:"id,omitempty"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
+}
+
+// StatusList 状态位串，第 i 位对应 statusListIndex 为 i 的凭证，1 表示已撤销或已暂停
+type StatusList struct {
+	bits []byte
+	size int
+}
+
+// NewStatusList 创建长度为 size 位的状态列表，size 会向上取整到8的倍数
+func NewStatusList(size int) *StatusList {
+	if size <= 0 {
+		size = DefaultStatusListSize
+	}
+	bytesLen := (size + 7) / 8
+	return &StatusList{bits: make([]byte, bytesLen), size: bytesLen * 8}
+}
+
+// Size 状态列表的位数
+func (l *StatusList) Size() int {
+	return l.size
+}
+
+// Get 返回第 index 位，最左边（首字节最高位）为第0位
+func (l *StatusList) Get(index int) (bool, error) {
+	if err := l.checkIndex(index); err != nil {
+		return false, err
+	}
+	return l.bits[index/8]&(0x80>>(index%8)) != 0, nil
+}
+
+// Set 设置第 index 位
+func (l *StatusList) Set(index int, value bool) error {
+	if err := l.checkIndex(index); err != nil {
+		return err
+	}
+	if value {
+		l.bits[index/8] |= 0x80 >> (index % 8)
+	} else {
+		l.bits[index/8] &^= 0x80 >> (index % 8)
+	}
+	return nil
+}
+
+func (l *StatusList) checkIndex(index int) error {
+	if index < 0 || index >= l.size {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "STATUS_INDEX_OUT_OF_RANGE",
+			"状态索引超出状态列表范围", strconv.Itoa(index))
+	}
+	return nil
+}
+
+// Encode 将位串GZIP压缩后base64url编码，作为 encodedList
+func (l *StatusList) Encode() (string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(l.bits); err != nil {
+		return "", fmt.Errorf("压缩状态列表失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("压缩状态列表失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeStatusList 解码 encodedList
+func DecodeStatusList(encoded string) (*StatusList, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_STATUS_LIST", "状态列表编码无效", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_STATUS_LIST", "状态列表解压失败", err)
+	}
+	defer r.Close()
+
+	bits, err := io.ReadAll(io.LimitReader(r, maxStatusListSize/8+1))
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_STATUS_LIST", "状态列表解压失败", err)
+	}
+	if len(bits) == 0 || len(bits) > maxStatusListSize/8 {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_STATUS_LIST", "状态列表长度无效")
+	}
+	return &StatusList{bits: bits, size: len(bits) * 8}, nil
+}
+
+// StatusRegistry 发布状态列表所需的注册表操作，*did.DIDRegistry 实现了该接口
+type StatusRegistry interface {
+	Resolve(didStr string) (*types.DIDDocument, error)
+	SetService(ctx context.Context, didStr string, service types.Service, proof *types.Proof) (*types.DIDDocument, *did.CommitReceipt, error)
+}
+
+// StatusEntry 返回指向签发者状态列表 listID 中第 index 位的凭证状态条目
+func (i *Issuer) StatusEntry(listID, purpose string, index int) *CredentialStatus {
+	return &CredentialStatus{
+		Type:                 StatusListEntryType,
+		StatusPurpose:        purpose,
+		StatusListIndex:      strconv.Itoa(index),
+		StatusListCredential: i.statusListURL(listID),
+	}
+}
+
+// IssueStatusList 签发状态列表凭证，凭证ID为签发者DID下的状态列表服务ID
+func (i *Issuer) IssueStatusList(listID, purpose string, list *StatusList) (*Credential, error) {
+	if purpose != StatusPurposeRevocation && purpose != StatusPurposeSuspension {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_PURPOSE", "不支持的状态用途", purpose)
+	}
+	encoded, err := list.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	cred := NewCredential(StatusListCredentialType, map[string]interface{}{
+		"type":          StatusListType,
+		"statusPurpose": purpose,
+		"encodedList":   encoded,
+	})
+	cred.ID = i.statusListURL(listID)
+	return i.Issue(cred)
+}
+
+// SetStatus 设置状态列表 listID 中第 index 位并发布新版本
+// 新的状态列表凭证由 assertionMethod 密钥签名，作为 StatusList2021 服务写入签发者DID文档，
+// 该更新由同一密钥以authentication目的签名并经注册表提交上链；状态列表不存在时按默认长度创建
+func (i *Issuer) SetStatus(ctx context.Context, registry StatusRegistry, listID, purpose string, index int, value bool) (*Credential, *did.CommitReceipt, error) {
+	doc, err := registry.Resolve(i.did)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred, err := i.UpdateStatusList(doc, listID, purpose, index, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := i.SignStatusListService(cred)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, receipt, err := registry.SetService(ctx, i.did, StatusListService(cred), proof)
+	if err != nil {
+		return nil, receipt, err
+	}
+	return cred, receipt, nil
+}
+
+// UpdateStatusList 在签发者当前文档 doc 中的状态列表 listID 上设置第 index 位，返回签名后的新状态列表凭证
+// 状态列表不存在时按默认长度创建；返回的凭证尚未发布
+func (i *Issuer) UpdateStatusList(doc *types.DIDDocument, listID, purpose string, index int, value bool) (*Credential, error) {
+	list := NewStatusList(DefaultStatusListSize)
+	if service := findStatusListService(doc, i.statusListURL(listID)); service != nil {
+		current, err := statusListFromService(service)
+		if err != nil {
+			return nil, err
+		}
+		if current.statusPurpose() != purpose {
+			return nil, utils.NewErrorWithDetails(utils.ErrorTypeConflict, "STATUS_PURPOSE_MISMATCH",
+				"状态列表的用途不一致", current.statusPurpose())
+		}
+		if list, err = current.statusList(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := list.Set(index, value); err != nil {
+		return nil, err
+	}
+	return i.IssueStatusList(listID, purpose, list)
+}
+
+// SignStatusListService 以authentication目的为发布状态列表凭证的DID服务更新签名
+func (i *Issuer) SignStatusListService(cred *Credential) (*types.Proof, error) {
+	return i.signOperation(did.SetServiceSigningPayload(i.did, StatusListService(cred)))
+}
+
+// StatusListService 返回发布状态列表凭证的DID服务，服务ID即凭证ID
+func StatusListService(cred *Credential) types.Service {
+	return types.Service{ID: cred.ID, Type: ServiceTypeStatusList, ServiceEndpoint: cred}
+}
+
+// PublishStatusList 发布签发者在客户端签名的状态列表凭证
+// 凭证须由签发者的assertionMethod密钥签名且ID位于签发者DID下，用途须与已发布的同一列表一致；
+// proof 为签发者以authentication目的对 SetServiceSigningPayload 的签名，与其他服务更新相同
+func PublishStatusList(ctx context.Context, registry StatusRegistry, verifier *Verifier, cred *Credential, proof *types.Proof) (*did.CommitReceipt, error) {
+	if err := validateStatusListCredential(cred); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(cred.ID, cred.Issuer+"#") {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL",
+			"状态列表必须是签发者DID文档中的服务", cred.ID)
+	}
+	if err := verifier.verifyProof(cred); err != nil {
+		return nil, err
+	}
+	purpose := cred.statusPurpose()
+	if purpose != StatusPurposeRevocation && purpose != StatusPurposeSuspension {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_PURPOSE", "不支持的状态用途", purpose)
+	}
+	if _, err := cred.statusList(); err != nil {
+		return nil, err
+	}
+
+	doc, err := registry.Resolve(cred.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if service := findStatusListService(doc, cred.ID); service != nil {
+		current, err := statusListFromService(service)
+		if err == nil && current.statusPurpose() != purpose {
+			return nil, utils.NewErrorWithDetails(utils.ErrorTypeConflict, "STATUS_PURPOSE_MISMATCH",
+				"状态列表的用途不一致", current.statusPurpose())
+		}
+	}
+
+	_, receipt, err := registry.SetService(ctx, cred.Issuer, StatusListService(cred), proof)
+	return receipt, err
+}
+
+func (i *Issuer) statusListURL(listID string) string {
+	return i.did + "#" + strings.TrimPrefix(listID, "#")
+}
+
+// signOperation 以authentication目的为DID操作生成带nonce的证明
+func (i *Issuer) signOperation(payload interface{}) (*types.Proof, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %w", err)
+	}
+
+	proof := &types.Proof{
+		Created:            time.Now(),
+		VerificationMethod: i.keyID,
+		ProofPurpose:       "authentication",
+		Nonce:              hex.EncodeToString(nonce),
+	}
+	if err := i.keyPair.SignProof(payload, proof); err != nil {
+		return nil, fmt.Errorf("签名失败: %w", err)
+	}
+	return proof, nil
+}
+
+// checkStatus 检查凭证在签发者状态列表中的状态，未声明 credentialStatus 的凭证不检查
+func (v *Verifier) checkStatus(c *Credential) error {
+	status := c.CredentialStatus
+	if status == nil {
+		return nil
+	}
+	if status.Type != StatusListEntryType {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_STATUS_TYPE", "不支持的凭证状态类型", status.Type)
+	}
+	index, err := strconv.Atoi(status.StatusListIndex)
+	if err != nil {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_INDEX", "状态索引无效", status.StatusListIndex)
+	}
+	// 状态列表必须由凭证签发者自己发布
+	if !strings.HasPrefix(status.StatusListCredential, c.Issuer+"#") {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL",
+			"状态列表必须是签发者DID文档中的服务", status.StatusListCredential)
+	}
+
+	doc, err := v.resolveIssuer(c.Issuer)
+	if err != nil {
+		return err
+	}
+	service := findStatusListService(doc, status.StatusListCredential)
+	if service == nil {
+		return utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "STATUS_LIST_NOT_FOUND", "状态列表不存在", status.StatusListCredential)
+	}
+	listCred, err := statusListFromService(service)
+	if err != nil {
+		return err
+	}
+	if listCred.Issuer != c.Issuer || listCred.ID != status.StatusListCredential {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL",
+			"状态列表凭证与签发者不一致", listCred.ID)
+	}
+	// 状态列表凭证本身不带状态，只验证证明，避免状态列表指向自身时无限递归
+	if err := v.verifyProof(listCred); err != nil {
+		return err
+	}
+	if listCred.statusPurpose() != status.StatusPurpose {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "STATUS_PURPOSE_MISMATCH",
+			"状态列表的用途不一致", listCred.statusPurpose())
+	}
+
+	list, err := listCred.statusList()
+	if err != nil {
+		return err
+	}
+	set, err := list.Get(index)
+	if err != nil {
+		return err
+	}
+	if !set {
+		return nil
+	}
+	if status.StatusPurpose == StatusPurposeSuspension {
+		return utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_SUSPENDED", "凭证已被暂停")
+	}
+	return utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_REVOKED", "凭证已被撤销")
+}
+
+// findStatusListService 在DID文档中查找状态列表服务，兼容绝对ID和相对ID
+func findStatusListService(doc *types.DIDDocument, serviceID string) *types.Service {
+	fragment := serviceID[strings.Index(serviceID, "#")+1:]
+	for i := range doc.Service {
+		service := &doc.Service[i]
+		if service.Type == ServiceTypeStatusList && (service.ID == serviceID || service.ID == "#"+fragment) {
+			return service
+		}
+	}
+	return nil
+}
+
+// statusListFromService 从服务端点还原状态列表凭证
+func statusListFromService(service *types.Service) (*Credential, error) {
+	data, err := json.Marshal(service.ServiceEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("序列化状态列表服务失败: %w", err)
+	}
+	var cred Credential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL", "状态列表凭证格式无效", err)
+	}
+	if err := validateStatusListCredential(&cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// validateStatusListCredential 检查凭证是StatusList2021凭证，状态列表凭证本身不能声明 credentialStatus
+func validateStatusListCredential(cred *Credential) error {
+	if !containsString(cred.Type, StatusListCredentialType) || cred.CredentialSubject["type"] != StatusListType {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL",
+			"凭证不是StatusList2021凭证", cred.ID)
+	}
+	if cred.CredentialStatus != nil {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_STATUS_LIST_CREDENTIAL",
+			"状态列表凭证不能声明credentialStatus", cred.ID)
+	}
+	return nil
+}
+
+func (c *Credential) statusPurpose() string {
+	purpose, _ := c.CredentialSubject["statusPurpose"].(string)
+	return purpose
+}
+
+func (c *Credential) statusList() (*StatusList, error) {
+	encoded, _ := c.CredentialSubject["encodedList"].(string)
+	return DecodeStatusList(encoded)
+}
@@ -0,0 +1,206 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+func TestStatusListEncoding(t *testing.T) {
+	list := NewStatusList(DefaultStatusListSize)
+	for _, index := range []int{0, 9, DefaultStatusListSize - 1} {
+		if err := list.Set(index, true); err != nil {
+			t.Fatalf("设置状态位失败: %v", err)
+		}
+	}
+	if list.bits[0] != 0x80 || list.bits[1] != 0x40 {
+		t.Errorf("第0位应为首字节最高位: %08b %08b", list.bits[0], list.bits[1])
+	}
+	if err := list.Set(DefaultStatusListSize, true); err == nil {
+		t.Error("越界索引应被拒绝")
+	}
+
+	encoded, err := list.Encode()
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	decoded, err := DecodeStatusList(encoded)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if decoded.Size() != DefaultStatusListSize {
+		t.Errorf("状态列表长度不正确: %d", decoded.Size())
+	}
+	for index, want := range map[int]bool{0: true, 1: false, 9: true, DefaultStatusListSize - 1: true} {
+		if got, _ := decoded.Get(index); got != want {
+			t.Errorf("第%d位应为%v", index, want)
+		}
+	}
+}
+
+func TestCredentialRevocation(t *testing.T) {
+	registry := did.NewDIDRegistry(nil)
+	builder, _ := did.NewDIDDocumentBuilder()
+	req, _ := builder.CreateRegistrationRequest()
+	if _, err := registry.Register(req); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	issuer := NewIssuer(builder.GetDID(), "#key-1", builder.GetKeyPair())
+	verifier := NewVerifier(did.NewDIDResolver(nil, registry, nil))
+	ctx := context.Background()
+
+	// 发布空的状态列表
+	if _, _, err := issuer.SetStatus(ctx, registry, "status-1", StatusPurposeRevocation, 0, false); err != nil {
+		t.Fatalf("发布状态列表失败: %v", err)
+	}
+
+	revoked := degreeCredential()
+	revoked.CredentialStatus = issuer.StatusEntry("status-1", StatusPurposeRevocation, 42)
+	cred, err := issuer.Issue(revoked)
+	if err != nil {
+		t.Fatalf("签发凭证失败: %v", err)
+	}
+	token, _ := issuer.IssueJWT(revoked)
+
+	kept := degreeCredential()
+	kept.CredentialStatus = issuer.StatusEntry("status-1", StatusPurposeRevocation, 43)
+	keptCred, _ := issuer.Issue(kept)
+
+	if err := verifier.Verify(cred); err != nil {
+		t.Fatalf("撤销前验证失败: %v", err)
+	}
+
+	listCred, _, err := issuer.SetStatus(ctx, registry, "status-1", StatusPurposeRevocation, 42, true)
+	if err != nil {
+		t.Fatalf("撤销凭证失败: %v", err)
+	}
+	if listCred.ID != builder.GetDID()+"#status-1" {
+		t.Errorf("状态列表凭证ID不正确: %s", listCred.ID)
+	}
+
+	var appErr *utils.AppError
+	if err := verifier.Verify(cred); !errors.As(err, &appErr) || appErr.Code != "CREDENTIAL_REVOKED" {
+		t.Errorf("已撤销的凭证应验证失败: %v", err)
+	}
+	if _, err := verifier.VerifyJWT(token); !errors.As(err, &appErr) || appErr.Code != "CREDENTIAL_REVOKED" {
+		t.Errorf("已撤销的JWT-VC应验证失败: %v", err)
+	}
+	if err := verifier.Verify(keptCred); err != nil {
+		t.Errorf("未撤销的凭证应验证通过: %v", err)
+	}
+
+	if _, _, err := issuer.SetStatus(ctx, registry, "status-1", StatusPurposeSuspension, 1, true); err == nil {
+		t.Error("状态用途不一致时应拒绝更新")
+	}
+}
+
+// TestSelfReferencingStatusList 状态列表凭证的credentialStatus指向自身时应直接拒绝，而不是无限递归
+func TestSelfReferencingStatusList(t *testing.T) {
+	registry := did.NewDIDRegistry(nil)
+	builder, _ := did.NewDIDDocumentBuilder()
+	req, _ := builder.CreateRegistrationRequest()
+	if _, err := registry.Register(req); err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	issuer := NewIssuer(builder.GetDID(), "#key-1", builder.GetKeyPair())
+	verifier := NewVerifier(did.NewDIDResolver(nil, registry, nil))
+
+	encoded, _ := NewStatusList(DefaultStatusListSize).Encode()
+	loop := NewCredential(StatusListCredentialType, map[string]interface{}{
+		"type":          StatusListType,
+		"statusPurpose": StatusPurposeRevocation,
+		"encodedList":   encoded,
+	})
+	loop.ID = issuer.statusListURL("loop")
+	loop.CredentialStatus = issuer.StatusEntry("loop", StatusPurposeRevocation, 0)
+	listCred, err := issuer.Issue(loop)
+	if err != nil {
+		t.Fatalf("签发状态列表凭证失败: %v", err)
+	}
+	service := types.Service{ID: listCred.ID, Type: ServiceTypeStatusList, ServiceEndpoint: listCred}
+	proof, err := issuer.signOperation(did.SetServiceSigningPayload(issuer.did, service))
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if _, _, err := registry.SetService(context.Background(), issuer.did, service, proof); err != nil {
+		t.Fatalf("发布状态列表失败: %v", err)
+	}
+
+	holder := degreeCredential()
+	holder.CredentialStatus = issuer.StatusEntry("loop", StatusPurposeRevocation, 1)
+	cred, err := issuer.Issue(holder)
+	if err != nil {
+		t.Fatalf("签发凭证失败: %v", err)
+	}
+
+	for name, c := range map[string]*Credential{"状态列表凭证": listCred, "引用它的凭证": cred} {
+		done := make(chan error, 1)
+		go func() { done <- verifier.Verify(c) }()
+		select {
+		case err := <-done:
+			var appErr *utils.AppError
+			if !errors.As(err, &appErr) || appErr.Code != "INVALID_STATUS_LIST_CREDENTIAL" {
+				t.Errorf("%s应因状态列表带有credentialStatus被拒绝: %v", name, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s的验证没有返回", name)
+		}
+	}
+}
+
+// TestPublishStatusList 节点发布签发者在客户端签名的状态列表凭证，凭证经JSON往返后证明仍然有效
+func TestPublishStatusList(t *testing.T) {
+	registry := did.NewDIDRegistry(nil)
+	builder, _ := did.NewDIDDocumentBuilder()
+	req, _ := builder.CreateRegistrationRequest()
+	doc, err := registry.Register(req)
+	if err != nil {
+		t.Fatalf("注册DID失败: %v", err)
+	}
+	issuer := NewIssuer(builder.GetDID(), "#key-1", builder.GetKeyPair())
+	verifier := NewVerifier(did.NewDIDResolver(nil, registry, nil))
+	ctx := context.Background()
+
+	listCred, err := issuer.UpdateStatusList(doc, "status-1", StatusPurposeRevocation, 7, true)
+	if err != nil {
+		t.Fatalf("签发状态列表失败: %v", err)
+	}
+	proof, err := issuer.SignStatusListService(listCred)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	data, _ := json.Marshal(listCred)
+	var received Credential
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("解析状态列表凭证失败: %v", err)
+	}
+
+	// 不属于签发者的密钥不能为其发布状态列表
+	otherBuilder, _ := did.NewDIDDocumentBuilder()
+	forged := NewIssuer(builder.GetDID(), "#key-1", otherBuilder.GetKeyPair())
+	forgedCred, err := forged.UpdateStatusList(doc, "status-1", StatusPurposeRevocation, 7, true)
+	if err != nil {
+		t.Fatalf("签发状态列表失败: %v", err)
+	}
+	if _, err := PublishStatusList(ctx, registry, verifier, forgedCred, proof); err == nil {
+		t.Error("不是签发者密钥签名的状态列表应被拒绝")
+	}
+
+	if _, err := PublishStatusList(ctx, registry, verifier, &received, proof); err != nil {
+		t.Fatalf("发布状态列表失败: %v", err)
+	}
+
+	revoked := degreeCredential()
+	revoked.CredentialStatus = issuer.StatusEntry("status-1", StatusPurposeRevocation, 7)
+	cred, _ := issuer.Issue(revoked)
+	var appErr *utils.AppError
+	if err := verifier.Verify(cred); !errors.As(err, &appErr) || appErr.Code != "CREDENTIAL_REVOKED" {
+		t.Errorf("已撤销的凭证应验证失败: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package vc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+)
+
+// staticResolver 返回固定文档的解析器
+type staticResolver map[string]*types.DIDDocument
+
+func (r staticResolver) Resolve(didStr string) (*did.ResolutionResult, error) {
+	doc, ok := r[didStr]
+	if !ok {
+		return &did.ResolutionResult{DIDResolutionMetadata: &did.ResolutionMetadata{Error: did.ResolutionErrorNotFound}}, nil
+	}
+	return &did.ResolutionResult{DIDDocument: doc, DIDResolutionMetadata: &did.ResolutionMetadata{}}, nil
+}
+
+func newTestIssuer(t *testing.T, suite string, resolver staticResolver) *Issuer {
+	t.Helper()
+	builder, err := did.NewDIDDocumentBuilderForSuite(suite)
+	if err != nil {
+		t.Fatalf("创建构建器失败: %v", err)
+	}
+	doc, err := builder.BuildDocument()
+	if err != nil {
+		t.Fatalf("构建文档失败: %v", err)
+	}
+	resolver[doc.ID] = doc
+	return NewIssuer(doc.ID, "#key-1", builder.GetKeyPair())
+}
+
+func degreeCredential() *Credential {
+	return NewCredential("UniversityDegreeCredential", map[string]interface{}{
+		"id":        "did:qlink:holder",
+		"name":      "Alice",
+		"birthDate": "2000-01-01",
+		"degree":    map[string]interface{}{"type": "BachelorDegree"},
+	})
+}
+
+func TestDataIntegrityCredential(t *testing.T) {
+	resolver := staticResolver{}
+	verifier := NewVerifier(resolver)
+
+	for suite, cryptosuite := range map[string]string{
+		crypto.SuiteP256MLKEM768MLDSA65: crypto.CryptosuiteCompositeJCS2025,
+		crypto.SuiteP256MLKEM768:        crypto.CryptosuiteECDSAJCS2019,
+	} {
+		issuer := newTestIssuer(t, suite, resolver)
+		cred, err := issuer.Issue(degreeCredential())
+		if err != nil {
+			t.Fatalf("签发凭证失败: %v", err)
+		}
+		if cred.Proof.Type != crypto.DataIntegrityProofType || cred.Proof.Cryptosuite != cryptosuite {
+			t.Errorf("%s 的证明类型不正确: %s/%s", suite, cred.Proof.Type, cred.Proof.Cryptosuite)
+		}
+		if err := verifier.Verify(cred); err != nil {
+			t.Fatalf("%s 验证凭证失败: %v", suite, err)
+		}
+
+		tampered := *cred
+		tampered.CredentialSubject = map[string]interface{}{"id": "did:qlink:holder", "name": "Mallory"}
+		if err := verifier.Verify(&tampered); err == nil {
+			t.Errorf("%s 篡改凭证主体后应验证失败", suite)
+		}
+	}
+}
+
+func TestJWTCredentialRequiresIssuerKey(t *testing.T) {
+	resolver := staticResolver{}
+	verifier := NewVerifier(resolver)
+	issuer := newTestIssuer(t, crypto.SuiteP256MLKEM768MLDSA65, resolver)
+
+	token, err := issuer.IssueJWT(degreeCredential())
+	if err != nil {
+		t.Fatalf("签发JWT-VC失败: %v", err)
+	}
+	cred, err := verifier.VerifyJWT(token)
+	if err != nil {
+		t.Fatalf("验证JWT-VC失败: %v", err)
+	}
+	if cred.Issuer != issuer.DID() || cred.CredentialSubject["name"] != "Alice" {
+		t.Errorf("JWT-VC内容不正确: %+v", cred)
+	}
+
+	// 冒用其他DID作为签发者
+	other := newTestIssuer(t, crypto.SuiteP256MLKEM768MLDSA65, resolver)
+	forged := NewIssuer(other.DID(), issuer.keyID, issuer.keyPair)
+	if _, err := forged.IssueJWT(degreeCredential()); err == nil {
+		t.Fatal("签名密钥不属于签发者时应拒绝签发")
+	}
+	impostor := NewIssuer(other.DID(), "#key-1", issuer.keyPair)
+	token, _ = impostor.IssueJWT(degreeCredential())
+	if _, err := verifier.VerifyJWT(token); err == nil {
+		t.Error("签名与签发者DID的密钥不匹配时应验证失败")
+	}
+}
+
+func TestSDJWTSelectiveDisclosure(t *testing.T) {
+	resolver := staticResolver{}
+	verifier := NewVerifier(resolver)
+	issuer := newTestIssuer(t, crypto.SuiteP256MLKEM768MLDSA65, resolver)
+
+	sdjwt, err := issuer.IssueSDJWT(degreeCredential(), "name", "birthDate")
+	if err != nil {
+		t.Fatalf("签发SD-JWT失败: %v", err)
+	}
+	if strings.Count(sdjwt, "~") != 3 {
+		t.Fatalf("应包含两个披露: %s", sdjwt)
+	}
+
+	// 持有者只披露姓名
+	presentation, err := Disclose(sdjwt, "name")
+	if err != nil {
+		t.Fatalf("选择披露失败: %v", err)
+	}
+	cred, err := verifier.VerifySDJWT(presentation)
+	if err != nil {
+		t.Fatalf("验证SD-JWT失败: %v", err)
+	}
+	subject := cred.CredentialSubject
+	if subject["name"] != "Alice" || subject["degree"] == nil {
+		t.Errorf("应包含披露的声明和非选择性声明: %+v", subject)
+	}
+	if _, exists := subject["birthDate"]; exists {
+		t.Error("未披露的声明不应出现")
+	}
+	if _, exists := subject["_sd"]; exists {
+		t.Error("验证后应移除_sd")
+	}
+
+	// 伪造的披露没有被签名的摘要引用
+	fake, _ := newDisclosure("birthDate", "1990-01-01")
+	if _, err := verifier.VerifySDJWT(presentation + fake + "~"); err == nil {
+		t.Error("未被引用的披露应被拒绝")
+	}
+}
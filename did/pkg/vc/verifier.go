@@ -0,0 +1,193 @@
+package vc
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/qujing226/QLink/did"
+	"github.com/qujing226/QLink/did/crypto"
+	"github.com/qujing226/QLink/pkg/types"
+	"github.com/qujing226/QLink/pkg/utils"
+)
+
+// Resolver 解析签发者DID，*did.DIDResolver 实现了该接口
+type Resolver interface {
+	Resolve(didStr string) (*did.ResolutionResult, error)
+}
+
+// Verifier 凭证验证器，通过解析签发者DID获取验证密钥
+type Verifier struct {
+	resolver   Resolver
+	signatures *crypto.SignatureVerifier
+}
+
+// NewVerifier 创建凭证验证器
+func NewVerifier(resolver Resolver) *Verifier {
+	return &Verifier{
+		resolver:   resolver,
+		signatures: crypto.NewSignatureVerifier(),
+	}
+}
+
+// Verify 验证带 DataIntegrityProof 的凭证
+// 签名密钥须在签发者的assertionMethod中；已退役的密钥签发于退役之前的凭证仍然有效；
+// 声明了 credentialStatus 的凭证还会检查签发者发布的状态列表
+func (v *Verifier) Verify(c *Credential) error {
+	if err := v.verifyProof(c); err != nil {
+		return err
+	}
+	return v.checkStatus(c)
+}
+
+// verifyProof 验证凭证的有效期和证明，不检查状态列表
+func (v *Verifier) verifyProof(c *Credential) error {
+	if err := c.Validate(time.Now()); err != nil {
+		return err
+	}
+
+	proof := c.Proof
+	if proof == nil {
+		return utils.NewError(utils.ErrorTypeValidation, "PROOF_REQUIRED", "证明不能为空")
+	}
+	if proof.Type != crypto.DataIntegrityProofType {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "UNSUPPORTED_PROOF_TYPE",
+			"凭证证明必须是DataIntegrityProof", proof.Type)
+	}
+	if proof.ProofPurpose != ProofPurposeAssertion {
+		return utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_PROOF_PURPOSE",
+			"凭证证明的目的必须是assertionMethod", proof.ProofPurpose)
+	}
+
+	vm, err := v.issuerKey(c.Issuer, proof.VerificationMethod)
+	if err != nil {
+		return err
+	}
+	return v.signatures.VerifyHistoricalProof(c, proof, vm)
+}
+
+// VerifyJWT 验证JWT-VC并返回其中的凭证
+func (v *Verifier) VerifyJWT(token string) (*Credential, error) {
+	return v.verifyToken(token, TypeJWT, nil)
+}
+
+// VerifySDJWT 验证SD-JWT并返回只包含已披露声明的凭证
+func (v *Verifier) VerifySDJWT(sdjwt string) (*Credential, error) {
+	token, disclosures, err := splitSDJWT(sdjwt)
+	if err != nil {
+		return nil, err
+	}
+	return v.verifyToken(token, TypeSDJWT, disclosures)
+}
+
+// verifyToken 验证JWT签名和时间声明，SD-JWT 会先展开披露
+func (v *Verifier) verifyToken(token, typ string, disclosures []string) (*Credential, error) {
+	header, unverified, err := crypto.ParseJWS(token)
+	if err != nil {
+		return nil, err
+	}
+	if header.Typ != typ {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "INVALID_JWT_TYPE", "JWT类型不正确", header.Typ)
+	}
+
+	var issuer struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(unverified, &issuer); err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWT_CLAIMS", "JWT声明解析失败", err)
+	}
+
+	vm, err := v.issuerKey(issuer.Issuer, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := v.signatures.VerifyJWS(token, vm)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeValidation, "INVALID_JWT_CLAIMS", "JWT声明解析失败", err)
+	}
+	if typ == TypeSDJWT {
+		if err := applyDisclosures(raw, disclosures); err != nil {
+			return nil, err
+		}
+	}
+
+	data, _ := json.Marshal(raw)
+	var claims jwtClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.VC == nil {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "INVALID_JWT_CLAIMS", "JWT缺少vc声明")
+	}
+
+	now := time.Now()
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_NOT_YET_VALID", "凭证尚未生效")
+	}
+	if claims.Expires != 0 && now.After(time.Unix(claims.Expires, 0)) {
+		return nil, utils.NewError(utils.ErrorTypeValidation, "CREDENTIAL_EXPIRED", "凭证已过期")
+	}
+	if vm.Revoked != nil && !time.Unix(claims.IssuedAt, 0).Before(*vm.Revoked) {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "KEY_REVOKED",
+			"凭证签发于验证方法退役之后", vm.ID)
+	}
+
+	cred, err := credentialFromMap(claims.VC)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Issuer != claims.Issuer {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeValidation, "ISSUER_MISMATCH",
+			"vc声明的签发者与iss不一致", cred.Issuer)
+	}
+	if err := cred.Validate(now); err != nil {
+		return nil, err
+	}
+	if err := v.checkStatus(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// issuerKey 解析签发者DID并返回用于验证凭证的验证方法
+func (v *Verifier) issuerKey(issuerDID, keyID string) (*types.VerificationMethod, error) {
+	if strings.HasPrefix(keyID, "#") {
+		keyID = issuerDID + keyID
+	}
+	if issuerDID == "" || !strings.HasPrefix(keyID, issuerDID+"#") {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "ISSUER_KEY_MISMATCH",
+			"签名密钥不属于签发者", keyID)
+	}
+
+	doc, err := v.resolveIssuer(issuerDID)
+	if err != nil {
+		return nil, err
+	}
+	vm := crypto.FindVerificationMethod(doc, keyID)
+	if vm == nil {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "VERIFICATION_METHOD_NOT_FOUND", "验证方法不存在", keyID)
+	}
+	// 退役的密钥已移出验证关系，由调用方按签发时间判断
+	if vm.Revoked == nil && !crypto.HasVerificationRelationship(doc, ProofPurposeAssertion, keyID) {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "INVALID_PROOF_PURPOSE",
+			"验证方法不在签发者的assertionMethod中", keyID)
+	}
+	return vm, nil
+}
+
+// resolveIssuer 解析签发者DID的当前文档，已撤销的签发者不能再证明任何凭证
+func (v *Verifier) resolveIssuer(issuerDID string) (*types.DIDDocument, error) {
+	result, err := v.resolver.Resolve(issuerDID)
+	if err != nil {
+		return nil, utils.NewErrorWithCause(utils.ErrorTypeInternal, "ISSUER_RESOLUTION_FAILED", "解析签发者DID失败", err)
+	}
+	if result.DIDResolutionMetadata != nil && result.DIDResolutionMetadata.Error != "" || result.DIDDocument == nil {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeNotFound, "ISSUER_NOT_FOUND", "签发者DID不存在", issuerDID)
+	}
+	if result.DIDDocumentMetadata != nil && result.DIDDocumentMetadata.Deactivated || result.DIDDocument.Deactivated {
+		return nil, utils.NewErrorWithDetails(utils.ErrorTypeUnauthorized, "ISSUER_DEACTIVATED", "签发者DID已撤销", issuerDID)
+	}
+	return result.DIDDocument, nil
+}
@@ -434,7 +434,7 @@ func (a *authService) resolveDIDDocument(did string) (*DIDDocument, error) {
         DIDDocument map[string]interface{} `json:"did_document"`
     }
     if err := json.NewDecoder(resp.Body).Decode(&resolveResp); err != nil {
-        return nil, fmt.Errorf("failed to decode resolve response: %w")
+        return nil, fmt.Errorf("failed to decode resolve response: %w", err)
     }
 
     // 提取可用于ECDSA验证的公钥
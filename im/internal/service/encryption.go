@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"qlink-im/internal/models"
 	"qlink-im/internal/storage"
+
+	"github.com/qujing226/QLink/spec/pkg/secure"
 )
 
 // EncryptionService 加密服务接口
@@ -21,6 +23,12 @@ type EncryptionService interface {
 	DeriveSessionKey(sharedKey []byte, userDID, friendDID string) []byte
 	// GetOrCreateSession 获取或创建会话
 	GetOrCreateSession(userDID, friendDID string) (*models.Session, error)
+	// SealEnvelope 用发送方密钥把数据加密为发给 recipientDID 的签名信封
+	// recipientJWK 为接收方DID文档中的 publicKeyJwk，信封格式与DID节点、spec客户端互通
+	SealEnvelope(data []byte, senderDID, recipientDID string, senderKey *secure.EnvelopeKeyPair, recipientJWK []byte) (*secure.Envelope, error)
+	// OpenEnvelope 验证发送方签名并解密 senderDID 发给 recipientDID 的信封
+	// senderJWK 须取自 senderDID 的DID文档，信封头部声明的发送方必须是 senderDID
+	OpenEnvelope(env *secure.Envelope, senderDID, recipientDID string, recipientKey *secure.EnvelopeKeyPair, senderJWK []byte) ([]byte, error)
 }
 
 // encryptionService 加密服务实现
@@ -164,4 +172,32 @@ func (s *encryptionService) GetOrCreateSession(userDID, friendDID string) (*mode
 	}
 
 	return newSession, nil
-}
\ No newline at end of file
+}
+
+// SealEnvelope 加密签名信封
+func (s *encryptionService) SealEnvelope(data []byte, senderDID, recipientDID string, senderKey *secure.EnvelopeKeyPair, recipientJWK []byte) (*secure.Envelope, error) {
+	recipient, err := secure.LoadEnvelopePublicKey(recipientJWK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recipient key: %w", err)
+	}
+
+	env, err := senderKey.SealEnvelope(data, senderDID, recipientDID, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal envelope: %w", err)
+	}
+	return env, nil
+}
+
+// OpenEnvelope 解密签名信封
+func (s *encryptionService) OpenEnvelope(env *secure.Envelope, senderDID, recipientDID string, recipientKey *secure.EnvelopeKeyPair, senderJWK []byte) ([]byte, error) {
+	sender, err := secure.LoadEnvelopePublicKey(senderJWK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sender key: %w", err)
+	}
+
+	data, err := recipientKey.OpenEnvelope(env, senderDID, recipientDID, sender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open envelope: %w", err)
+	}
+	return data, nil
+}
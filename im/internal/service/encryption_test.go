@@ -0,0 +1,62 @@
+package service
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/qujing226/QLink/spec/pkg/secure"
+)
+
+// TestEnvelopeSharedVector IM服务能解密DID节点和spec客户端生成的信封（仓库根目录 testdata/envelope-v1.json）
+func TestEnvelopeSharedVector(t *testing.T) {
+	data, err := os.ReadFile("../../../testdata/envelope-v1.json")
+	if err != nil {
+		t.Fatalf("failed to read shared vector: %v", err)
+	}
+	type party struct {
+		DID          string          `json:"did"`
+		PrivateKey   string          `json:"privateKey"`
+		PublicKeyJWK json.RawMessage `json:"publicKeyJwk"`
+	}
+	var vector struct {
+		Plaintext string                      `json:"plaintext"`
+		Sender    party                       `json:"sender"`
+		Recipient party                       `json:"recipient"`
+		Envelopes map[string]*secure.Envelope `json:"envelopes"`
+	}
+	if err := json.Unmarshal(data, &vector); err != nil {
+		t.Fatalf("failed to parse shared vector: %v", err)
+	}
+
+	loadKey := func(p party) *secure.EnvelopeKeyPair {
+		skBytes, _ := hex.DecodeString(p.PrivateKey)
+		kp, err := secure.LoadEnvelopeKey(skBytes)
+		if err != nil {
+			t.Fatalf("failed to load private key: %v", err)
+		}
+		return kp
+	}
+	sender, recipient := loadKey(vector.Sender), loadKey(vector.Recipient)
+	svc := NewEncryptionService(nil)
+
+	for _, producer := range []string{"did", "spec"} {
+		plaintext, err := svc.OpenEnvelope(vector.Envelopes[producer], vector.Sender.DID, vector.Recipient.DID, recipient, vector.Sender.PublicKeyJWK)
+		if err != nil || string(plaintext) != vector.Plaintext {
+			t.Errorf("failed to open envelope from %s: %q, %v", producer, plaintext, err)
+		}
+		if _, err := svc.OpenEnvelope(vector.Envelopes[producer], vector.Recipient.DID, vector.Recipient.DID, recipient, vector.Sender.PublicKeyJWK); err == nil {
+			t.Errorf("%s: sender DID mismatch should be rejected", producer)
+		}
+	}
+
+	env, err := svc.SealEnvelope([]byte(vector.Plaintext), vector.Sender.DID, vector.Recipient.DID, sender, vector.Recipient.PublicKeyJWK)
+	if err != nil {
+		t.Fatalf("failed to seal envelope: %v", err)
+	}
+	plaintext, err := svc.OpenEnvelope(env, vector.Sender.DID, vector.Recipient.DID, recipient, vector.Sender.PublicKeyJWK)
+	if err != nil || string(plaintext) != vector.Plaintext {
+		t.Errorf("failed to open sealed envelope: %q, %v", plaintext, err)
+	}
+}
@@ -28,8 +28,9 @@ type Session struct {
 
 type Client struct {
 	Did      string
-	SignKeys *secure.SignKeyPair
-	KemKeys  *secure.KyberKeyPair
+	Suite    *secure.Suite // 本地密钥所属的算法套件
+	SignKeys secure.Signer
+	KemKeys  secure.KEMKey
 
 	Chain     *blockchain.OptimisticCache
 	RelayAddr string
@@ -45,25 +46,25 @@ type Client struct {
 }
 
 func NewClient(did string, chain *blockchain.OptimisticCache, relayAddr string) (*Client, error) {
-	signKp, err := secure.NewSignKeyPair()
+	suite, err := secure.DefaultSuites.Preferred()
 	if err != nil {
 		return nil, err
 	}
-	kemKp, err := secure.NewKyberKeyPair()
+	signKp, err := suite.GenerateSignKey()
+	if err != nil {
+		return nil, err
+	}
+	kemKp, err := suite.GenerateKEMKey()
 	if err != nil {
 		return nil, err
 	}
 
 	// 注册 DID (Simulated)
-	pk, _ := kemKp.Export()
-	signPk, _ := signKp.Export()
-	doc := make([]byte, len(signPk)+len(pk))
-	copy(doc[0:], signPk)
-	copy(doc[len(signPk):], pk)
-	chain.RegisterDidDoc(did, doc)
+	chain.RegisterDidDoc(did, secure.EncodeDidDoc(suite, signKp.PublicKey(), kemKp.PublicKey()))
 
 	return &Client{
 		Did:           did,
+		Suite:         suite,
 		SignKeys:      signKp,
 		KemKeys:       kemKp,
 		Chain:         chain,
@@ -135,16 +136,18 @@ func (c *Client) Handshake(targetDid string) error {
 	if err != nil {
 		return fmt.Errorf("resolve failed: %w", err)
 	}
-	if len(doc) < 32 {
-		return errors.New("invalid doc")
+	// 按本地套件注册表协商，对方的套件被禁用时拒绝握手
+	suite, _, kemPk, err := secure.DefaultSuites.DecodeDidDoc(doc)
+	if err != nil {
+		return fmt.Errorf("negotiate suite failed: %w", err)
 	}
-	targetKyberPk, err := secure.LoadFromBytes(doc[32:], nil)
+	targetKemPk, err := suite.LoadKEMPublicKey(kemPk)
 	if err != nil {
 		return err
 	}
 
-	// 2. Encapsulate (Kyber)
-	ct, ss, err := targetKyberPk.Encapsulate()
+	// 2. Encapsulate (协商出的套件的 KEM)
+	ct, ss, err := targetKemPk.Encapsulate()
 	if err != nil {
 		return err
 	}
@@ -60,6 +60,12 @@ func (kp *SignKeyPair) Export() ([]byte, []byte) {
 	return pkCopy, skCopy
 }
 
+// PublicKey 返回公钥字节
+func (kp *SignKeyPair) PublicKey() []byte {
+	pk, _ := kp.Export()
+	return pk
+}
+
 // Sign 对消息进行签名
 func (kp *SignKeyPair) Sign(message []byte) ([]byte, error) {
 	if kp.sk == nil {
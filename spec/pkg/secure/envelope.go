@@ -0,0 +1,510 @@
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+// 加密信封
+//
+// 格式与 DID 节点的 did/crypto/envelope.go 相同，DID节点、spec客户端和IM服务可以互相解密：
+// 带发送方组合签名（ES256 + ML-DSA-65）的 JWE，ML-KEM-768 封装的共享密钥经 HKDF-SHA256 派生 KEK，
+// 以 AES-256-KW 包装随机 CEK，内容用 AES-256-GCM 加密并以受保护头部为附加数据。
+// 实现须能解密仓库根目录 testdata/envelope-v1.json 中的共享测试向量。
+const (
+	EnvelopeVersion    = 1
+	EnvelopeAlgorithm  = "ML-KEM-768+A256KW"
+	EnvelopeEncryption = "A256GCM"
+	EnvelopeType       = "qlink-envelope+jwe"
+
+	// EnvelopeSignatureAlgorithm 组合签名算法，也是 ML-DSA-65 签名的上下文
+	EnvelopeSignatureAlgorithm = "ML-DSA-65-ES256"
+)
+
+const (
+	envelopeKeySize   = 32
+	envelopeNonceSize = 12
+	envelopeTagSize   = 16
+
+	// 私钥编码：ECDSA P-256 标量(32) || ML-KEM-768 种子(64) || ML-DSA-65 种子(32)
+	// 不含 ML-DSA-65 的早期 DID 密钥没有最后32字节
+	ecdsaScalarSize              = 32
+	legacyEnvelopePrivateKeySize = ecdsaScalarSize + mlkem.SeedSize
+	envelopePrivateKeySize       = legacyEnvelopePrivateKeySize + mldsa65.SeedSize
+)
+
+// ErrEnvelopeDecrypt 信封无法解密（密文、头部或密钥不匹配）
+var ErrEnvelopeDecrypt = errors.New("envelope decryption failed")
+
+// EnvelopeHeader JWE 受保护头部
+type EnvelopeHeader struct {
+	Algorithm       string `json:"alg"`
+	Encryption      string `json:"enc"`
+	Type            string `json:"typ"`
+	Version         int    `json:"ver"`
+	EncapsulatedKey string `json:"ek"`
+	Sender          string `json:"skid"`
+	Recipient       string `json:"kid"`
+}
+
+// EnvelopeSignature 发送方对信封紧凑序列化的组合签名
+type EnvelopeSignature struct {
+	ECDSA []byte `json:"ecdsa_signature"`
+	MLDSA []byte `json:"mldsa_signature,omitempty"`
+}
+
+// Envelope 加密信封，即 JWE 扁平 JSON 序列化加上发送方签名
+type Envelope struct {
+	Protected    string             `json:"protected"`
+	EncryptedKey string             `json:"encrypted_key"`
+	IV           string             `json:"iv"`
+	Ciphertext   string             `json:"ciphertext"`
+	Tag          string             `json:"tag"`
+	Signature    *EnvelopeSignature `json:"signature"`
+}
+
+// Header 解析受保护头部
+func (env *Envelope) Header() (*EnvelopeHeader, error) {
+	data, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope header encoding: %w", err)
+	}
+	var header EnvelopeHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid envelope header: %w", err)
+	}
+	return &header, nil
+}
+
+// Compact 返回 JWE 紧凑序列化，也是发送方签名的内容
+func (env *Envelope) Compact() string {
+	return strings.Join([]string{env.Protected, env.EncryptedKey, env.IV, env.Ciphertext, env.Tag}, ".")
+}
+
+// EnvelopeKeyPair 信封密钥：ECDSA P-256 与 ML-DSA-65 组合签名，ML-KEM-768 封装内容密钥
+// 与 DID 节点的 HybridKeyPair 使用相同的私钥编码和 publicKeyJwk 格式；
+// 只含公钥时只能向对方加密或验证对方的签名
+type EnvelopeKeyPair struct {
+	ecdsaSK *ecdsa.PrivateKey
+	ecdsaPK *ecdsa.PublicKey
+	kemSK   *mlkem.DecapsulationKey768
+	kemPK   *mlkem.EncapsulationKey768
+	mldsaSK *mldsa65.PrivateKey
+	mldsaPK *mldsa65.PublicKey
+}
+
+// envelopeJWK DID 文档 publicKeyJwk 中的组合公钥
+type envelopeJWK struct {
+	Kty   string `json:"kty"`
+	Alg   string `json:"alg"`
+	Use   string `json:"use"`
+	Crv   string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+	Kyber string `json:"kyber"`
+	MLDSA string `json:"mldsa,omitempty"`
+}
+
+// NewEnvelopeKeyPair 生成新的信封密钥
+func NewEnvelopeKeyPair() (*EnvelopeKeyPair, error) {
+	sk := make([]byte, envelopePrivateKeySize)
+	ecdsaSK, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ecdsa key: %w", err)
+	}
+	scalar, err := ecdsaSK.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	copy(sk, scalar)
+	if _, err := rand.Read(sk[ecdsaScalarSize:]); err != nil {
+		return nil, err
+	}
+	return LoadEnvelopeKey(sk)
+}
+
+// LoadEnvelopeKey 从私钥编码加载密钥，DID 节点 HybridKeyPair.MarshalPrivateKey 的结果可直接加载
+func LoadEnvelopeKey(skBytes []byte) (*EnvelopeKeyPair, error) {
+	if len(skBytes) != envelopePrivateKeySize && len(skBytes) != legacyEnvelopePrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: %d", len(skBytes))
+	}
+
+	ecdsaSK, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), skBytes[:ecdsaScalarSize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ecdsa private key: %w", err)
+	}
+	kemSK, err := mlkem.NewDecapsulationKey768(skBytes[ecdsaScalarSize:legacyEnvelopePrivateKeySize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ml-kem private key: %w", err)
+	}
+
+	kp := &EnvelopeKeyPair{
+		ecdsaSK: ecdsaSK,
+		ecdsaPK: &ecdsaSK.PublicKey,
+		kemSK:   kemSK,
+		kemPK:   kemSK.EncapsulationKey(),
+	}
+	if len(skBytes) == envelopePrivateKeySize {
+		var seed [mldsa65.SeedSize]byte
+		copy(seed[:], skBytes[legacyEnvelopePrivateKeySize:])
+		kp.mldsaPK, kp.mldsaSK = mldsa65.NewKeyFromSeed(&seed)
+	}
+	return kp, nil
+}
+
+// LoadEnvelopePublicKey 从 DID 文档验证方法的 publicKeyJwk 加载对方的公钥
+func LoadEnvelopePublicKey(jwkBytes []byte) (*EnvelopeKeyPair, error) {
+	var jwk envelopeJWK
+	if err := json.Unmarshal(jwkBytes, &jwk); err != nil {
+		return nil, fmt.Errorf("invalid jwk: %w", err)
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported jwk key type: %s/%s", jwk.Kty, jwk.Crv)
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid jwk coordinates")
+	}
+	ecdsaPK, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{0x04}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ecdsa public key: %w", err)
+	}
+	kp := &EnvelopeKeyPair{ecdsaPK: ecdsaPK}
+
+	if jwk.Kyber != "" {
+		kemBytes, err := base64.RawURLEncoding.DecodeString(jwk.Kyber)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ml-kem public key encoding: %w", err)
+		}
+		if kp.kemPK, err = mlkem.NewEncapsulationKey768(kemBytes); err != nil {
+			return nil, fmt.Errorf("invalid ml-kem public key: %w", err)
+		}
+	}
+
+	if jwk.MLDSA != "" {
+		mldsaBytes, err := base64.RawURLEncoding.DecodeString(jwk.MLDSA)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ml-dsa public key encoding: %w", err)
+		}
+		kp.mldsaPK = new(mldsa65.PublicKey)
+		if err := kp.mldsaPK.UnmarshalBinary(mldsaBytes); err != nil {
+			return nil, fmt.Errorf("invalid ml-dsa public key: %w", err)
+		}
+	} else if jwk.Alg == EnvelopeSignatureAlgorithm {
+		// 声明组合签名的公钥缺少 ML-DSA 部分时拒绝，防止降级为单一 ECDSA 签名
+		return nil, errors.New("composite jwk without ml-dsa public key")
+	}
+	return kp, nil
+}
+
+// Export 导出 publicKeyJwk 和私钥编码（只含公钥时私钥为 nil）
+func (kp *EnvelopeKeyPair) Export() ([]byte, []byte) {
+	point, _ := kp.ecdsaPK.Bytes()
+	jwk := envelopeJWK{
+		Kty: "EC",
+		Alg: "ES256",
+		Use: "sig",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}
+	if kp.kemPK != nil {
+		jwk.Kyber = base64.RawURLEncoding.EncodeToString(kp.kemPK.Bytes())
+	}
+	if kp.mldsaPK != nil {
+		jwk.Alg = EnvelopeSignatureAlgorithm
+		jwk.MLDSA = base64.RawURLEncoding.EncodeToString(kp.mldsaPK.Bytes())
+	}
+	pkBytes, _ := json.Marshal(&jwk)
+
+	var skBytes []byte
+	if kp.ecdsaSK != nil && kp.kemSK != nil {
+		scalar, _ := kp.ecdsaSK.Bytes()
+		skBytes = append(scalar, kp.kemSK.Bytes()...)
+		if kp.mldsaSK != nil {
+			skBytes = append(skBytes, kp.mldsaSK.Seed()...)
+		}
+	}
+	return pkBytes, skBytes
+}
+
+// SealEnvelope 将数据加密给接收方的 ML-KEM-768 公钥，并用自己的私钥签名整个信封
+func (kp *EnvelopeKeyPair) SealEnvelope(data []byte, senderDID, recipientDID string, recipient *EnvelopeKeyPair) (*Envelope, error) {
+	if senderDID == "" || recipientDID == "" {
+		return nil, errors.New("sender and recipient DID are required")
+	}
+	if recipient.kemPK == nil {
+		return nil, errors.New("recipient ml-kem public key not loaded")
+	}
+
+	sharedKey, kemCiphertext := recipient.kemPK.Encapsulate()
+	kek, err := deriveEnvelopeKEK(sharedKey, kemCiphertext, senderDID, recipientDID)
+	if err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, envelopeKeySize)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, fmt.Errorf("failed to generate content key: %w", err)
+	}
+	wrappedKey, err := aesKeyWrap(kek, cek)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(&EnvelopeHeader{
+		Algorithm:       EnvelopeAlgorithm,
+		Encryption:      EnvelopeEncryption,
+		Type:            EnvelopeType,
+		Version:         EnvelopeVersion,
+		EncapsulatedKey: base64.RawURLEncoding.EncodeToString(kemCiphertext),
+		Sender:          senderDID,
+		Recipient:       recipientDID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope header: %w", err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	gcm, err := newEnvelopeGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, envelopeNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate iv: %w", err)
+	}
+	sealed := gcm.Seal(nil, iv, data, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-envelopeTagSize], sealed[len(sealed)-envelopeTagSize:]
+
+	env := &Envelope{
+		Protected:    protected,
+		EncryptedKey: base64.RawURLEncoding.EncodeToString(wrappedKey),
+		IV:           base64.RawURLEncoding.EncodeToString(iv),
+		Ciphertext:   base64.RawURLEncoding.EncodeToString(ciphertext),
+		Tag:          base64.RawURLEncoding.EncodeToString(tag),
+	}
+	if env.Signature, err = kp.sign([]byte(env.Compact())); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// OpenEnvelope 验证发送方签名后解密 senderDID 发给 recipientDID 的信封
+// sender 须是从 senderDID 的 DID 文档中解析出的公钥，头部声明的发送方必须与之一致
+func (kp *EnvelopeKeyPair) OpenEnvelope(env *Envelope, senderDID, recipientDID string, sender *EnvelopeKeyPair) ([]byte, error) {
+	if kp.kemSK == nil {
+		return nil, errors.New("ml-kem private key not loaded")
+	}
+	// 先验证签名，未经发送方签名的信封不做解封装
+	if env.Signature == nil || !sender.verify([]byte(env.Compact()), env.Signature) {
+		return nil, errors.New("envelope signature verification failed")
+	}
+
+	header, err := env.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version: %d", header.Version)
+	}
+	if header.Algorithm != EnvelopeAlgorithm || header.Encryption != EnvelopeEncryption {
+		return nil, fmt.Errorf("unsupported envelope algorithm: %s/%s", header.Algorithm, header.Encryption)
+	}
+	if header.Sender != senderDID {
+		return nil, fmt.Errorf("envelope sender mismatch: %s", header.Sender)
+	}
+	if header.Recipient != recipientDID {
+		return nil, fmt.Errorf("envelope recipient mismatch: %s", header.Recipient)
+	}
+
+	kemCiphertext, err := decodeEnvelopeField(header.EncapsulatedKey, mlkem.CiphertextSize768)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := decodeEnvelopeField(env.EncryptedKey, envelopeKeySize+8)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := decodeEnvelopeField(env.IV, envelopeNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := decodeEnvelopeField(env.Tag, envelopeTagSize)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope field encoding: %w", err)
+	}
+
+	sharedKey, err := kp.kemSK.Decapsulate(kemCiphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decapsulate: %w", err)
+	}
+	kek, err := deriveEnvelopeKEK(sharedKey, kemCiphertext, header.Sender, header.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := aesKeyUnwrap(kek, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newEnvelopeGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(env.Protected))
+	if err != nil {
+		return nil, ErrEnvelopeDecrypt
+	}
+	return data, nil
+}
+
+// sign 组合签名：对 SHA-256 摘要的 ECDSA 签名，有 ML-DSA-65 密钥时再对原文签名
+func (kp *EnvelopeKeyPair) sign(data []byte) (*EnvelopeSignature, error) {
+	if kp.ecdsaSK == nil {
+		return nil, errors.New("private key not available")
+	}
+	digest := sha256.Sum256(data)
+	ecdsaSig, err := ecdsa.SignASN1(rand.Reader, kp.ecdsaSK, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign envelope: %w", err)
+	}
+
+	sig := &EnvelopeSignature{ECDSA: ecdsaSig}
+	if kp.mldsaSK != nil {
+		sig.MLDSA = make([]byte, mldsa65.SignatureSize)
+		if err := mldsa65.SignTo(kp.mldsaSK, data, []byte(EnvelopeSignatureAlgorithm), true, sig.MLDSA); err != nil {
+			return nil, fmt.Errorf("failed to sign envelope: %w", err)
+		}
+	}
+	return sig, nil
+}
+
+// verify 验证组合签名，公钥含 ML-DSA-65 时两种签名都必须有效
+func (kp *EnvelopeKeyPair) verify(data []byte, sig *EnvelopeSignature) bool {
+	if kp == nil || kp.ecdsaPK == nil {
+		return false
+	}
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(kp.ecdsaPK, digest[:], sig.ECDSA) {
+		return false
+	}
+	if kp.mldsaPK != nil {
+		return mldsa65.Verify(kp.mldsaPK, data, []byte(EnvelopeSignatureAlgorithm), sig.MLDSA)
+	}
+	return true
+}
+
+// deriveEnvelopeKEK 从 ML-KEM 共享密钥派生密钥加密密钥，并绑定发送方和接收方 DID
+func deriveEnvelopeKEK(sharedKey, kemCiphertext []byte, senderDID, recipientDID string) ([]byte, error) {
+	info := EnvelopeAlgorithm + "\x00" + senderDID + "\x00" + recipientDID
+	kek, err := hkdf.Key(sha256.New, sharedKey, kemCiphertext, info, envelopeKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key encryption key: %w", err)
+	}
+	return kek, nil
+}
+
+func newEnvelopeGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeEnvelopeField(value string, size int) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope field encoding: %w", err)
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("invalid envelope field size: %d", len(data))
+	}
+	return data, nil
+}
+
+// aesKeyWrapIV RFC 3394 默认初始值
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap RFC 3394 AES 密钥包装
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, fmt.Errorf("invalid key size to wrap: %d", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, aesKeyWrapIV)
+	copy(out[8:], key)
+
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, out[:8])
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[i*8:], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap RFC 3394 AES 密钥解包，完整性校验失败时返回 ErrEnvelopeDecrypt
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("invalid wrapped key size: %d", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(buf[8:], out[i*8:i*8+8])
+			block.Decrypt(buf, buf)
+			copy(out[:8], buf[:8])
+			copy(out[i*8:], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(out[:8], aesKeyWrapIV) != 1 {
+		return nil, ErrEnvelopeDecrypt
+	}
+	return out[8:], nil
+}
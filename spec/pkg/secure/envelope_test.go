@@ -0,0 +1,133 @@
+package secure
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// envelopeVector 仓库根目录 testdata/envelope-v1.json，DID节点、spec客户端和IM服务共用
+type envelopeVector struct {
+	Plaintext string               `json:"plaintext"`
+	Sender    envelopeVectorParty  `json:"sender"`
+	Recipient envelopeVectorParty  `json:"recipient"`
+	Envelopes map[string]*Envelope `json:"envelopes"`
+}
+
+type envelopeVectorParty struct {
+	DID          string          `json:"did"`
+	PrivateKey   string          `json:"privateKey"`
+	PublicKeyJWK json.RawMessage `json:"publicKeyJwk"`
+}
+
+func loadEnvelopeVector(t *testing.T) *envelopeVector {
+	t.Helper()
+	data, err := os.ReadFile("../../../testdata/envelope-v1.json")
+	if err != nil {
+		t.Fatalf("failed to read shared vector: %v", err)
+	}
+	var vector envelopeVector
+	if err := json.Unmarshal(data, &vector); err != nil {
+		t.Fatalf("failed to parse shared vector: %v", err)
+	}
+	return &vector
+}
+
+func loadVectorKey(t *testing.T, party envelopeVectorParty) (*EnvelopeKeyPair, *EnvelopeKeyPair) {
+	t.Helper()
+	skBytes, _ := hex.DecodeString(party.PrivateKey)
+	sk, err := LoadEnvelopeKey(skBytes)
+	if err != nil {
+		t.Fatalf("failed to load private key: %v", err)
+	}
+	pk, err := LoadEnvelopePublicKey(party.PublicKeyJWK)
+	if err != nil {
+		t.Fatalf("failed to load public key: %v", err)
+	}
+	return sk, pk
+}
+
+// TestEnvelopeSharedVector 能解密 DID 节点生成的信封，私钥编码和 publicKeyJwk 与 DID 节点一致
+func TestEnvelopeSharedVector(t *testing.T) {
+	vector := loadEnvelopeVector(t)
+	recipient, recipientPub := loadVectorKey(t, vector.Recipient)
+	sender, senderPub := loadVectorKey(t, vector.Sender)
+
+	pkJWK, skBytes := recipient.Export()
+	if hex.EncodeToString(skBytes) != vector.Recipient.PrivateKey {
+		t.Error("exported private key does not match the vector")
+	}
+	var got, want map[string]string
+	json.Unmarshal(pkJWK, &got)
+	json.Unmarshal(vector.Recipient.PublicKeyJWK, &want)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("exported jwk field %s mismatch", k)
+		}
+	}
+
+	for _, producer := range []string{"did", "spec"} {
+		env := vector.Envelopes[producer]
+		if env == nil {
+			t.Fatalf("missing envelope from %s", producer)
+		}
+		plaintext, err := recipient.OpenEnvelope(env, vector.Sender.DID, vector.Recipient.DID, senderPub)
+		if err != nil || string(plaintext) != vector.Plaintext {
+			t.Errorf("failed to open envelope from %s: %q, %v", producer, plaintext, err)
+		}
+	}
+
+	// 本实现生成的信封同样能用向量中的密钥解开
+	env, err := sender.SealEnvelope([]byte(vector.Plaintext), vector.Sender.DID, vector.Recipient.DID, recipientPub)
+	if err != nil {
+		t.Fatalf("failed to seal envelope: %v", err)
+	}
+	if plaintext, err := recipient.OpenEnvelope(env, vector.Sender.DID, vector.Recipient.DID, senderPub); err != nil || string(plaintext) != vector.Plaintext {
+		t.Errorf("failed to open sealed envelope: %q, %v", plaintext, err)
+	}
+}
+
+func TestEnvelopeRejectsForgery(t *testing.T) {
+	sender, _ := NewEnvelopeKeyPair()
+	recipient, _ := NewEnvelopeKeyPair()
+	attacker, _ := NewEnvelopeKeyPair()
+	senderDID, recipientDID, attackerDID := "did:qlink:sender", "did:qlink:recipient", "did:qlink:attacker"
+
+	env, err := sender.SealEnvelope([]byte("hello"), senderDID, recipientDID, recipient)
+	if err != nil {
+		t.Fatalf("failed to seal envelope: %v", err)
+	}
+	if _, err := recipient.OpenEnvelope(env, senderDID, "did:qlink:other", sender); err == nil {
+		t.Error("recipient mismatch should be rejected")
+	}
+	if _, err := recipient.OpenEnvelope(env, senderDID, recipientDID, attacker); err == nil {
+		t.Error("signature from another key should be rejected")
+	}
+
+	// 攻击者用自己的密钥签发声称来自 senderDID 的信封
+	spoofed, _ := attacker.SealEnvelope([]byte("hello"), senderDID, recipientDID, recipient)
+	if _, err := recipient.OpenEnvelope(spoofed, attackerDID, recipientDID, attacker); err == nil {
+		t.Error("sender DID not owning the signing key should be rejected")
+	}
+
+	// 攻击者篡改头部并重新签名，AAD 和 KEK 绑定使解密失败
+	header, _ := env.Header()
+	header.Sender = attackerDID
+	forged := *env
+	headerJSON, _ := json.Marshal(header)
+	forged.Protected = base64.RawURLEncoding.EncodeToString(headerJSON)
+	forged.Signature, _ = attacker.sign([]byte(forged.Compact()))
+	if _, err := recipient.OpenEnvelope(&forged, attackerDID, recipientDID, attacker); !errors.Is(err, ErrEnvelopeDecrypt) {
+		t.Errorf("tampered header should fail decryption: %v", err)
+	}
+
+	// 只有 ECDSA 签名时，含 ML-DSA 公钥的验证方拒绝降级
+	downgraded := *env
+	downgraded.Signature = &EnvelopeSignature{ECDSA: env.Signature.ECDSA}
+	if _, err := recipient.OpenEnvelope(&downgraded, senderDID, recipientDID, sender); err == nil {
+		t.Error("signature without ml-dsa part should be rejected")
+	}
+}
@@ -25,17 +25,15 @@ func NewKyberKeyPair() (*KyberKeyPair, error) {
 }
 
 // LoadFromBytes 从存储/网络加载密钥（用于服务启动阶段）
-// 这是一个“昂贵”的操作，建议只做一次
+// 这是一个“昂贵”的操作，建议只做一次；只传公钥时得到的密钥不能解封装
 func LoadFromBytes(pkBytes, skBytes []byte) (*KyberKeyPair, error) {
-	kp := &KyberKeyPair{
-		pk: new(kyber768.PublicKey),
-		sk: new(kyber768.PrivateKey),
-	}
+	kp := &KyberKeyPair{}
 
 	if len(pkBytes) > 0 {
 		if len(pkBytes) != kyber768.PublicKeySize {
 			return nil, fmt.Errorf("invalid public key size: %d", len(pkBytes))
 		}
+		kp.pk = new(kyber768.PublicKey)
 		kp.pk.Unpack(pkBytes)
 	}
 
@@ -43,6 +41,7 @@ func LoadFromBytes(pkBytes, skBytes []byte) (*KyberKeyPair, error) {
 		if len(skBytes) != kyber768.PrivateKeySize {
 			return nil, fmt.Errorf("invalid private key size: %d", len(skBytes))
 		}
+		kp.sk = new(kyber768.PrivateKey)
 		kp.sk.Unpack(skBytes)
 	}
 
@@ -63,6 +62,12 @@ func (kp *KyberKeyPair) Export() ([]byte, []byte) {
 	return pkBytes, skBytes
 }
 
+// PublicKey 返回公钥字节
+func (kp *KyberKeyPair) PublicKey() []byte {
+	pk, _ := kp.Export()
+	return pk
+}
+
 // Encapsulate 生成共享密钥并封装到当前公钥 (kp.pk)
 // 用于发送方：持有接收方的公钥，生成 (ct, ss)
 func (kp *KyberKeyPair) Encapsulate() (ct []byte, ss []byte, err error) {
@@ -0,0 +1,179 @@
+package secure
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+)
+
+// 握手算法套件标识，与 DID 节点的套件注册表使用相同的命名：QLINK-<签名>-<密钥封装>[-<后量子签名>]
+const (
+	SuiteEd25519MLKEM768 = "QLINK-ED25519-MLKEM768"
+)
+
+// ErrSuiteUnsupported 算法套件未注册或已禁用
+var ErrSuiteUnsupported = errors.New("unsupported algorithm suite")
+
+// Signer 套件的签名私钥
+type Signer interface {
+	PublicKey() []byte
+	Sign(message []byte) ([]byte, error)
+}
+
+// KEMKey 套件的 KEM 密钥，只加载了公钥时只能封装
+type KEMKey interface {
+	PublicKey() []byte
+	Encapsulate() (ct []byte, ss []byte, err error)
+	Decapsulate(ct []byte) (ss []byte, err error)
+}
+
+// Suite 描述握手使用的一组算法及其公钥长度，并提供生成和加载该套件密钥的函数，
+// 客户端据此生成本地密钥、加载对方的 KEM 公钥，不直接依赖具体算法
+type Suite struct {
+	ID                string
+	Signature         string
+	KEM               string
+	SignPublicKeySize int
+	KEMPublicKeySize  int
+
+	GenerateSignKey  func() (Signer, error)
+	GenerateKEMKey   func() (KEMKey, error)
+	LoadKEMPublicKey func(pk []byte) (KEMKey, error)
+}
+
+// SuiteRegistry 握手算法套件注册表
+// 套件按注册顺序排列偏好，Negotiate 选择本地最偏好且对方支持的套件
+type SuiteRegistry struct {
+	mu       sync.RWMutex
+	suites   []*Suite
+	disabled map[string]bool
+}
+
+// DefaultSuites 默认注册表，Client 握手时据此协商
+var DefaultSuites = NewSuiteRegistry(&Suite{
+	ID:                SuiteEd25519MLKEM768,
+	Signature:         "Ed25519",
+	KEM:               "ML-KEM-768",
+	SignPublicKeySize: ed25519.PublicKeySize,
+	KEMPublicKeySize:  kyber768.PublicKeySize,
+	GenerateSignKey:   newEd25519Signer,
+	GenerateKEMKey:    newKyber768Key,
+	LoadKEMPublicKey:  loadKyber768PublicKey,
+})
+
+func newEd25519Signer() (Signer, error) {
+	kp, err := NewSignKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func newKyber768Key() (KEMKey, error) {
+	kp, err := NewKyberKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func loadKyber768PublicKey(pk []byte) (KEMKey, error) {
+	if len(pk) != kyber768.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(pk))
+	}
+	return LoadFromBytes(pk, nil)
+}
+
+// NewSuiteRegistry 创建注册表，suites 按偏好排序
+func NewSuiteRegistry(suites ...*Suite) *SuiteRegistry {
+	return &SuiteRegistry{
+		suites:   suites,
+		disabled: make(map[string]bool),
+	}
+}
+
+// Register 注册新的套件，偏好排在已有套件之后
+func (r *SuiteRegistry) Register(suite *Suite) error {
+	if suite.GenerateSignKey == nil || suite.GenerateKEMKey == nil || suite.LoadKEMPublicKey == nil {
+		return fmt.Errorf("suite %s is missing key functions", suite.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.suites {
+		if s.ID == suite.ID {
+			return fmt.Errorf("suite already registered: %s", suite.ID)
+		}
+	}
+	r.suites = append(r.suites, suite)
+	return nil
+}
+
+// Disable 禁用套件，之后协商不再选择它
+func (r *SuiteRegistry) Disable(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disabled[id] = true
+}
+
+// Preferred 返回本地最偏好的已启用套件
+func (r *SuiteRegistry) Preferred() (*Suite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.suites {
+		if !r.disabled[s.ID] {
+			return s, nil
+		}
+	}
+	return nil, ErrSuiteUnsupported
+}
+
+// Negotiate 从对方提供的套件中选择本地最偏好的套件
+func (r *SuiteRegistry) Negotiate(offered []string) (*Suite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.suites {
+		if r.disabled[s.ID] {
+			continue
+		}
+		for _, id := range offered {
+			if id == s.ID {
+				return s, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrSuiteUnsupported, offered)
+}
+
+// EncodeDidDoc 编码模拟链上的 DID 文档：[1字节套件ID长度][套件ID][签名公钥][KEM公钥]
+func EncodeDidDoc(suite *Suite, signPk, kemPk []byte) []byte {
+	doc := make([]byte, 0, 1+len(suite.ID)+len(signPk)+len(kemPk))
+	doc = append(doc, byte(len(suite.ID)))
+	doc = append(doc, suite.ID...)
+	doc = append(doc, signPk...)
+	return append(doc, kemPk...)
+}
+
+// DecodeDidDoc 解析 DID 文档，按本地注册表协商套件并返回对方的签名公钥和 KEM 公钥
+func (r *SuiteRegistry) DecodeDidDoc(doc []byte) (suite *Suite, signPk, kemPk []byte, err error) {
+	if len(doc) < 1 || len(doc) < 1+int(doc[0]) {
+		return nil, nil, nil, errors.New("invalid doc")
+	}
+	id := string(doc[1 : 1+int(doc[0])])
+	keys := doc[1+int(doc[0]):]
+
+	suite, err = r.Negotiate([]string{id})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(keys) != suite.SignPublicKeySize+suite.KEMPublicKeySize {
+		return nil, nil, nil, fmt.Errorf("invalid doc size for suite %s: %d", suite.ID, len(keys))
+	}
+	return suite, keys[:suite.SignPublicKeySize], keys[suite.SignPublicKeySize:], nil
+}
@@ -0,0 +1,117 @@
+package secure
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testSuite 复用默认套件的密钥函数，只替换标识
+func testSuite(id string) *Suite {
+	s := *DefaultSuites.suites[0]
+	s.ID = id
+	return &s
+}
+
+func TestSuiteRegistryNegotiate(t *testing.T) {
+	a, b := testSuite("QLINK-TEST-A"), testSuite("QLINK-TEST-B")
+	r := NewSuiteRegistry(a)
+	if err := r.Register(b); err != nil {
+		t.Fatalf("failed to register suite: %v", err)
+	}
+	if err := r.Register(testSuite(b.ID)); err == nil {
+		t.Error("duplicate suite should be rejected")
+	}
+	if err := r.Register(&Suite{ID: "QLINK-TEST-EMPTY"}); err == nil {
+		t.Error("suite without key functions should be rejected")
+	}
+
+	if s, err := r.Preferred(); err != nil || s != a {
+		t.Errorf("preferred suite = %v, %v", s, err)
+	}
+	// 按本地偏好选择，而不是对方列出的顺序
+	if s, err := r.Negotiate([]string{b.ID, a.ID}); err != nil || s != a {
+		t.Errorf("negotiated suite = %v, %v", s, err)
+	}
+	if s, err := r.Negotiate([]string{"QLINK-UNKNOWN", b.ID}); err != nil || s != b {
+		t.Errorf("negotiated suite = %v, %v", s, err)
+	}
+	if _, err := r.Negotiate([]string{"QLINK-UNKNOWN"}); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("unknown suite should be unsupported: %v", err)
+	}
+
+	r.Disable(a.ID)
+	if s, err := r.Preferred(); err != nil || s != b {
+		t.Errorf("preferred suite after disable = %v, %v", s, err)
+	}
+	if _, err := r.Negotiate([]string{a.ID}); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("disabled suite should be unsupported: %v", err)
+	}
+	r.Disable(b.ID)
+	if _, err := r.Preferred(); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("no enabled suite should be unsupported: %v", err)
+	}
+}
+
+func TestDecodeDidDoc(t *testing.T) {
+	suite, err := DefaultSuites.Preferred()
+	if err != nil {
+		t.Fatalf("no default suite: %v", err)
+	}
+	signKey, err := suite.GenerateSignKey()
+	if err != nil {
+		t.Fatalf("failed to generate sign key: %v", err)
+	}
+	kemKey, err := suite.GenerateKEMKey()
+	if err != nil {
+		t.Fatalf("failed to generate kem key: %v", err)
+	}
+	doc := EncodeDidDoc(suite, signKey.PublicKey(), kemKey.PublicKey())
+
+	got, signPk, kemPk, err := DefaultSuites.DecodeDidDoc(doc)
+	if err != nil {
+		t.Fatalf("failed to decode doc: %v", err)
+	}
+	if got != suite || !bytes.Equal(signPk, signKey.PublicKey()) || !bytes.Equal(kemPk, kemKey.PublicKey()) {
+		t.Error("decoded doc does not match the encoded keys")
+	}
+
+	// 加载对方的 KEM 公钥封装，持有私钥的一方能解出相同的共享密钥
+	peer, err := got.LoadKEMPublicKey(kemPk)
+	if err != nil {
+		t.Fatalf("failed to load kem public key: %v", err)
+	}
+	ct, ss, err := peer.Encapsulate()
+	if err != nil {
+		t.Fatalf("failed to encapsulate: %v", err)
+	}
+	if _, err := peer.Decapsulate(ct); err == nil {
+		t.Error("public-only kem key should not decapsulate")
+	}
+	if shared, err := kemKey.Decapsulate(ct); err != nil || !bytes.Equal(shared, ss) {
+		t.Errorf("shared secret mismatch: %v", err)
+	}
+	if _, err := got.LoadKEMPublicKey(kemPk[1:]); err == nil {
+		t.Error("kem public key with wrong size should be rejected")
+	}
+
+	if _, _, _, err := DefaultSuites.DecodeDidDoc(nil); err == nil {
+		t.Error("empty doc should be rejected")
+	}
+	if _, _, _, err := DefaultSuites.DecodeDidDoc(doc[:len(suite.ID)]); err == nil {
+		t.Error("truncated suite id should be rejected")
+	}
+	if _, _, _, err := DefaultSuites.DecodeDidDoc(doc[:len(doc)-1]); err == nil {
+		t.Error("truncated keys should be rejected")
+	}
+	unknown := EncodeDidDoc(testSuite("QLINK-TEST-A"), signKey.PublicKey(), kemKey.PublicKey())
+	if _, _, _, err := DefaultSuites.DecodeDidDoc(unknown); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("unregistered suite should be unsupported: %v", err)
+	}
+
+	r := NewSuiteRegistry(suite)
+	r.Disable(suite.ID)
+	if _, _, _, err := r.DecodeDidDoc(doc); !errors.Is(err, ErrSuiteUnsupported) {
+		t.Errorf("disabled suite should be unsupported: %v", err)
+	}
+}